@@ -0,0 +1,5 @@
+package notification
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative notification.proto
+
+//go:generate mockgen -destination mocks/notification.go -package mocks . NotificationClient
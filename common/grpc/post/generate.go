@@ -0,0 +1,5 @@
+package post
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative post.proto
+
+//go:generate mockgen -destination mocks/post.go -package mocks . PostClient
@@ -1,3 +1,5 @@
 package user
 
 //go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative user.proto
+
+//go:generate mockgen -destination mocks/user.go -package mocks . UserClient
@@ -4,23 +4,47 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"net/netip"
 	"smapp/common/jsonresp"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+type requestContextKey struct{}
+
 func WithRequestContextTimeout(timeout time.Duration) func(http.Handler) http.Handler {
 	return func(h http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			ctx, cancel := context.WithTimeout(r.Context(), timeout)
 			defer cancel()
+			// Kept for WithoutRequestContextTimeout.
+			ctx = context.WithValue(ctx, requestContextKey{}, r.Context())
 			h.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
 
+// Lifts the deadline set by WithRequestContextTimeout for long-lived requests such as streams. The context keeps its
+// values and is still canceled when the client disconnects.
+func WithoutRequestContextTimeout(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestCtx, ok := r.Context().Value(requestContextKey{}).(context.Context)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx, cancel := context.WithCancel(context.WithoutCancel(r.Context()))
+		defer cancel()
+		stop := context.AfterFunc(requestCtx, cancel)
+		defer stop()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
 type userIDKey struct{}
 
 func ParseUserID(next http.Handler) http.Handler {
@@ -36,7 +60,20 @@ func ParseUserID(next http.Handler) http.Handler {
 	})
 }
 
-// The error can be non-nil only if the ParseUserID middleware was not used, which is a bug.
+// For routes that anyone can use but that depend on the signed-in user if there is one. Without the header, the user
+// ID is uuid.Nil.
+func ParseOptionalUserID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-User-Id") == "" {
+			ctx := context.WithValue(r.Context(), userIDKey{}, uuid.Nil)
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+		ParseUserID(next).ServeHTTP(w, r)
+	})
+}
+
+// The error can be non-nil only if the ParseUserID or ParseOptionalUserID middleware was not used, which is a bug.
 func GetUserID(ctx context.Context) (uuid.UUID, error) {
 	userID, ok := ctx.Value(userIDKey{}).(uuid.UUID)
 	if !ok {
@@ -44,3 +81,33 @@ func GetUserID(ctx context.Context) (uuid.UUID, error) {
 	}
 	return userID, nil
 }
+
+// Returns the address of the client from the X-Forwarded-For header set by the gateway. Proxies in front of the
+// gateway, like a load balancer, append their own entries, and a client can send any entries before them. So the
+// header is scanned from the right, and the first address that is not private is the client. If all of them are
+// private, e.g. in development, the leftmost one is used.
+func GetClientIP(r *http.Request) string {
+	var addrs []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		for _, addr := range strings.Split(header, ",") {
+			addrs = append(addrs, strings.TrimSpace(addr))
+		}
+	}
+	for i := len(addrs) - 1; i >= 0; i-- {
+		ip, err := netip.ParseAddr(addrs[i])
+		if err != nil {
+			continue
+		}
+		if !ip.IsPrivate() && !ip.IsLoopback() {
+			return ip.String()
+		}
+	}
+	if len(addrs) > 0 {
+		return addrs[0]
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
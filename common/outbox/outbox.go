@@ -0,0 +1,71 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	eventsPB "smapp/common/grpc/events"
+
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Publisher delivers events read from the outbox to their consumers.
+type Publisher interface {
+	Publish(ctx context.Context, event *eventsPB.Event) error
+}
+
+// Handler consumes events published by LocalPublisher.
+type Handler func(ctx context.Context, event *eventsPB.Event) error
+
+// LocalPublisher passes events to handlers in the same process. Together with the MySQL outbox it needs no message
+// broker, which makes it suitable for running locally.
+type LocalPublisher struct {
+	handlers []Handler
+}
+
+func NewLocalPublisher(handlers ...Handler) *LocalPublisher {
+	return &LocalPublisher{handlers: handlers}
+}
+
+// If a handler fails, the event is published again later to all handlers, including the ones that have succeeded.
+func (p *LocalPublisher) Publish(ctx context.Context, event *eventsPB.Event) error {
+	var errs []error
+	for _, handler := range p.handlers {
+		if err := handler(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("handle event %x: %w", event.Id, err)
+	}
+	return nil
+}
+
+// Add writes the event to the outbox table within tx, so that the event is published if and only if tx commits.
+// The ID and the occurrence time of the event are assigned here.
+func Add(ctx context.Context, tx *sql.Tx, event *eventsPB.Event) error {
+	fail := func(err error) error {
+		return fmt.Errorf("add event to outbox: %w", err)
+	}
+
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return fail(err)
+	}
+	event.Id = id[:]
+	event.OccurredAt = timestamppb.Now()
+
+	payload, err := proto.Marshal(event)
+	if err != nil {
+		return fail(err)
+	}
+	_, err = tx.ExecContext(ctx, "INSERT INTO outbox (payload) VALUES (?)", payload)
+	if err != nil {
+		return fail(err)
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	eventsPB "smapp/common/grpc/events"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// Relay moves events from the outbox table to the publisher. Events are delivered at least once: if the relay stops
+// between publishing an event and deleting it, the event is published again, so consumers have to be idempotent.
+//
+// Several replicas can run a relay against the same table. Rows are locked with SKIP LOCKED, so each batch is
+// published by one replica, and events are only roughly ordered across replicas.
+type Relay struct {
+	db             *sql.DB
+	publisher      Publisher
+	batchSize      int
+	interval       time.Duration
+	publishTimeout time.Duration
+}
+
+func NewRelay(db *sql.DB, publisher Publisher, batchSize int, interval, publishTimeout time.Duration) *Relay {
+	return &Relay{db: db, publisher: publisher, batchSize: batchSize, interval: interval, publishTimeout: publishTimeout}
+}
+
+// Run publishes events until ctx is done. The outbox is polled every interval while it has fewer than batchSize events.
+func (r *Relay) Run(ctx context.Context) {
+	for {
+		published, err := r.publishBatch(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Println(err)
+		}
+		if err == nil && published == r.batchSize {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(r.interval):
+		}
+	}
+}
+
+type outboxRow struct {
+	seq     uint64
+	payload []byte
+}
+
+// Returns the number of events that were published and deleted from the outbox.
+func (r *Relay) publishBatch(ctx context.Context) (int, error) {
+	fail := func(err error) (int, error) {
+		return 0, fmt.Errorf("publish outbox batch: %w", err)
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fail(err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(
+		ctx,
+		"SELECT seq, payload FROM outbox ORDER BY seq LIMIT ? FOR UPDATE SKIP LOCKED",
+		r.batchSize,
+	)
+	if err != nil {
+		return fail(err)
+	}
+	var batch []outboxRow
+	for rows.Next() {
+		var row outboxRow
+		if err = rows.Scan(&row.seq, &row.payload); err != nil {
+			rows.Close()
+			return fail(err)
+		}
+		batch = append(batch, row)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return fail(err)
+	}
+
+	// Events after the first one that fails are kept in the outbox, so that they are not published out of order. An
+	// event that a consumer rejects as invalid would fail again, so it is dropped instead of blocking the outbox.
+	done := make([]uint64, 0, len(batch))
+	var publishErr error
+	for _, row := range batch {
+		event := &eventsPB.Event{}
+		if err = proto.Unmarshal(row.payload, event); err != nil {
+			// Such an event can never be published, so it is dropped instead of blocking the outbox.
+			log.Printf("drop outbox event %d: %v", row.seq, err)
+			done = append(done, row.seq)
+			continue
+		}
+		if publishErr = r.publish(ctx, event); publishErr != nil {
+			if !isPermanent(publishErr) {
+				break
+			}
+			log.Printf("drop outbox event %d: %v", row.seq, publishErr)
+			publishErr = nil
+		}
+		done = append(done, row.seq)
+	}
+
+	if len(done) > 0 {
+		placeholders := make([]string, len(done))
+		args := make([]interface{}, len(done))
+		for i, seq := range done {
+			placeholders[i] = "?"
+			args[i] = seq
+		}
+		_, err = tx.ExecContext(
+			ctx,
+			fmt.Sprintf("DELETE FROM outbox WHERE seq IN (%s)", strings.Join(placeholders, ",")),
+			args...,
+		)
+		if err != nil {
+			return fail(err)
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return fail(err)
+	}
+
+	if publishErr != nil {
+		return fail(publishErr)
+	}
+	return len(done), nil
+}
+
+func (r *Relay) publish(ctx context.Context, event *eventsPB.Event) error {
+	ctx, cancel := context.WithTimeout(ctx, r.publishTimeout)
+	defer cancel()
+	return r.publisher.Publish(ctx, event)
+}
+
+// Codes of errors that a consumer returns for the event itself, so publishing the same event again cannot succeed.
+var permanentCodes = map[codes.Code]bool{
+	codes.InvalidArgument:    true,
+	codes.NotFound:           true,
+	codes.AlreadyExists:      true,
+	codes.FailedPrecondition: true,
+	codes.OutOfRange:         true,
+}
+
+// Reports whether err is a gRPC error with one of permanentCodes. Errors joined by LocalPublisher are permanent only if
+// all of them are, since a handler that failed otherwise has to get the event again.
+func isPermanent(err error) bool {
+	// status.FromError is not used, as it would find the first gRPC error among joined errors.
+	if grpcErr, ok := err.(interface{ GRPCStatus() *status.Status }); ok {
+		return permanentCodes[grpcErr.GRPCStatus().Code()]
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, err := range joined.Unwrap() {
+			if !isPermanent(err) {
+				return false
+			}
+		}
+		return len(joined.Unwrap()) > 0
+	}
+	if wrapped := errors.Unwrap(err); wrapped != nil {
+		return isPermanent(wrapped)
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	eventsPB "smapp/common/grpc/events"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/matryer/is"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+func outboxRows(is *is.I, events ...*eventsPB.Event) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"seq", "payload"})
+	for i, event := range events {
+		payload, err := proto.Marshal(event)
+		is.NoErr(err)
+		rows.AddRow(uint64(i+1), payload)
+	}
+	return rows
+}
+
+func TestRelayDropsRejectedEvent(t *testing.T) {
+	is := is.New(t)
+
+	db, mock, err := sqlmock.New()
+	is.NoErr(err)
+	defer db.Close()
+
+	published := 0
+	publisher := NewLocalPublisher(func(ctx context.Context, event *eventsPB.Event) error {
+		published++
+		if string(event.Id) == "invalid" {
+			return fmt.Errorf("record notification: %w", status.Error(codes.InvalidArgument, "invalid recipient id"))
+		}
+		return nil
+	})
+	relay := NewRelay(db, publisher, 10, time.Second, time.Second)
+
+	// The rejected event is deleted along with the event after it.
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT seq, payload FROM outbox").
+		WithArgs(10).
+		WillReturnRows(outboxRows(is, &eventsPB.Event{Id: []byte("invalid")}, &eventsPB.Event{Id: []byte("valid")}))
+	mock.ExpectExec("DELETE FROM outbox").WithArgs(1, 2).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	n, err := relay.publishBatch(context.Background())
+	is.NoErr(err)
+	is.Equal(n, 2)
+	is.Equal(published, 2)
+	is.NoErr(mock.ExpectationsWereMet())
+}
+
+func TestRelayKeepsFailedEvent(t *testing.T) {
+	is := is.New(t)
+
+	db, mock, err := sqlmock.New()
+	is.NoErr(err)
+	defer db.Close()
+
+	// The event was rejected by one handler, but has to be published again for the other one.
+	publisher := NewLocalPublisher(
+		func(ctx context.Context, event *eventsPB.Event) error {
+			return status.Error(codes.InvalidArgument, "invalid recipient id")
+		},
+		func(ctx context.Context, event *eventsPB.Event) error {
+			return status.Error(codes.Unavailable, "connection refused")
+		},
+	)
+	relay := NewRelay(db, publisher, 10, time.Second, time.Second)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT seq, payload FROM outbox").
+		WillReturnRows(outboxRows(is, &eventsPB.Event{Id: []byte("first")}, &eventsPB.Event{Id: []byte("second")}))
+	mock.ExpectCommit()
+
+	_, err = relay.publishBatch(context.Background())
+	is.True(err != nil)
+	is.NoErr(mock.ExpectationsWereMet())
+}
+
+func TestIsPermanent(t *testing.T) {
+	is := is.New(t)
+
+	invalid := status.Error(codes.InvalidArgument, "invalid")
+	unavailable := status.Error(codes.Unavailable, "unavailable")
+
+	is.True(isPermanent(invalid))
+	is.True(isPermanent(fmt.Errorf("handle event: %w", errors.Join(invalid, invalid))))
+	is.True(!isPermanent(unavailable))
+	is.True(!isPermanent(fmt.Errorf("handle event: %w", errors.Join(invalid, unavailable))))
+	is.True(!isPermanent(errors.New("connection refused")))
+	is.True(!isPermanent(context.DeadlineExceeded))
+}
@@ -1,13 +1,26 @@
 # Works for Amazon Linux machines
 
-# Usage: cd smapp && ./deployment/init_swarm.sh
+# Usage: cd smapp && [JWT_PRIVATE_KEYS_FILE=<PATH>] ./deployment/init_swarm.sh
 
 docker swarm init
 
 openssl rand -base64 32  | tr -d '\n' | docker secret create mysql_password -
+openssl rand -base64 32 | docker secret create totp_encryption_key -
 
-(
-    jwt_private_key=$(openssl genpkey -algorithm RSA -pkeyopt rsa_keygen_bits:2048)
-    echo "$jwt_private_key" | docker secret create jwt_private_key -
-    echo "$jwt_private_key" | openssl rsa -pubout | docker config create jwt_public_key -
-)
+# Swarm secrets cannot be read back and rotation needs the current keys, so they are also kept in a file. It must be
+# outside the repository checkout, so that the production key is not copied or committed along with the code.
+JWT_PRIVATE_KEYS_FILE=${JWT_PRIVATE_KEYS_FILE:-$HOME/.smapp/jwt_private_keys.pem}
+REPOSITORY_DIR=$(cd "$(dirname "$0")/../.." && pwd)
+mkdir -p "$(dirname "$JWT_PRIVATE_KEYS_FILE")"
+case "$(cd "$(dirname "$JWT_PRIVATE_KEYS_FILE")" && pwd)/" in
+  "$REPOSITORY_DIR"/*)
+    echo "JWT_PRIVATE_KEYS_FILE must be outside of $REPOSITORY_DIR" >&2
+    exit 1
+    ;;
+esac
+if [ -e "$JWT_PRIVATE_KEYS_FILE" ]; then
+  echo "$JWT_PRIVATE_KEYS_FILE already exists, not overwriting it" >&2
+  exit 1
+fi
+(umask 077; openssl genpkey -algorithm RSA -pkeyopt rsa_keygen_bits:2048 -out "$JWT_PRIVATE_KEYS_FILE")
+docker secret create jwt_private_keys "$JWT_PRIVATE_KEYS_FILE"
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net"
+	commondb "smapp/common/db"
+	commonenv "smapp/common/env"
+	pb "smapp/common/grpc/notification"
+	"smapp/notification/model"
+	"smapp/notification/repository"
+	"smapp/notification/service"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/status"
+)
+
+type mysqlConfig struct {
+	host     string
+	user     string
+	password []byte
+	db       string
+}
+
+func getMysqlConfig() (*mysqlConfig, error) {
+	mysqlConfig := mysqlConfig{}
+	var err error
+	if mysqlConfig.host, err = commonenv.GetEnv("MYSQL_HOST"); err != nil {
+		return nil, err
+	}
+	if mysqlConfig.user, err = commonenv.GetEnv("MYSQL_USER"); err != nil {
+		return nil, err
+	}
+	if mysqlConfig.password, err = commonenv.GetSecret("mysql_password"); err != nil {
+		return nil, err
+	}
+	if mysqlConfig.db, err = commonenv.GetEnv("MYSQL_DB"); err != nil {
+		return nil, err
+	}
+	return &mysqlConfig, nil
+}
+
+var eventTypes = map[pb.EventType]model.NotificationType{
+	pb.EventType_POST_LIKED:           model.PostLiked,
+	pb.EventType_COMMENT_LIKED:        model.CommentLiked,
+	pb.EventType_POST_COMMENTED:       model.PostCommented,
+	pb.EventType_COMMENT_REPLIED:      model.CommentReplied,
+	pb.EventType_USER_FOLLOWED:        model.UserFollowed,
+	pb.EventType_MENTIONED_IN_POST:    model.MentionedInPost,
+	pb.EventType_MENTIONED_IN_COMMENT: model.MentionedInComment,
+}
+
+type notificationServer struct {
+	pb.UnimplementedNotificationServer
+	notificationService *service.Notification
+}
+
+// Invalid events are rejected with InvalidArgument, so that the outbox relays of the other services drop them instead
+// of retrying.
+func (s *notificationServer) RecordEvent(ctx context.Context, req *pb.Event) (*pb.RecordEventResponse, error) {
+	eventType, ok := eventTypes[req.Type]
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "unknown event type: %s", req.Type)
+	}
+	recipientID, err := uuid.FromBytes(req.RecipientId)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid recipient id: %v", err)
+	}
+	actorID, err := uuid.FromBytes(req.ActorId)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid actor id: %v", err)
+	}
+	entityID := uuid.Nil
+	if len(req.EntityId) > 0 {
+		if entityID, err = uuid.FromBytes(req.EntityId); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid entity id: %v", err)
+		}
+	}
+
+	err = s.notificationService.Record(ctx, model.Event{
+		Type:        eventType,
+		RecipientID: recipientID,
+		ActorID:     actorID,
+		EntityID:    entityID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &pb.RecordEventResponse{}, nil
+}
+
+func main() {
+	mysqlConfig, err := getMysqlConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	db, err := sql.Open(
+		"mysql",
+		fmt.Sprintf(
+			"%s:%s@tcp(%s)/%s?parseTime=true",
+			mysqlConfig.user, mysqlConfig.password, mysqlConfig.host, mysqlConfig.db,
+		),
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	err = commondb.WaitForDB(ctx, db)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	// Recording events does not load users, so no user client is needed.
+	notificationService := service.NewNotification(repository.NewNotification(db), nil)
+
+	lis, err := net.Listen("tcp", ":50051")
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Set maximum connection age to periodically trigger DNS lookups in case replicas were added/removed.
+	s := grpc.NewServer(
+		grpc.KeepaliveParams(keepalive.ServerParameters{
+			MaxConnectionAge:      15 * time.Second,
+			MaxConnectionAgeGrace: 5 * time.Second,
+		}),
+	)
+	pb.RegisterNotificationServer(s, &notificationServer{notificationService: notificationService})
+	log.Fatal(s.Serve(lis))
+}
@@ -8,13 +8,11 @@ import (
 	"net/http"
 	commondb "smapp/common/db"
 	commonenv "smapp/common/env"
-	imagePB "smapp/common/grpc/image"
 	userPB "smapp/common/grpc/user"
 	commonmw "smapp/common/middleware"
-	"smapp/common/validation"
-	"smapp/post/handlers"
-	"smapp/post/repository"
-	"smapp/post/service"
+	"smapp/notification/handlers"
+	"smapp/notification/repository"
+	"smapp/notification/service"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
@@ -87,56 +85,17 @@ func main() {
 	defer conn.Close()
 	userClient := userPB.NewUserClient(conn)
 
-	conn, err = grpc.NewClient(
-		"image-grpc:50051",
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
-		grpc.WithDefaultServiceConfig(`{"loadBalancingConfig": [{"round_robin":{}}]}`),
-	)
-	if err != nil {
-		log.Fatal(err)
-	}
-	defer conn.Close()
-	imageClient := imagePB.NewImageClient(conn)
-
-	postRepository := repository.NewDefaultPost(db)
-	commentRepository := repository.NewComment(db)
-	postLikeRepository := repository.NewPostLike(db)
-	commentLikeRepository := repository.NewCommentLike(db)
-
-	postService := service.NewDefaultPost(postRepository, commentRepository, postLikeRepository, userClient, imageClient)
-	commentService := service.NewComment(commentRepository, postRepository)
-	postLikeService := service.NewPostLike(postLikeRepository, postRepository)
-	commentLikeService := service.NewCommentLike(commentLikeRepository, commentRepository)
+	notificationService := service.NewNotification(repository.NewNotification(db), userClient)
 
 	r := mux.NewRouter()
 	r.Handle(
-		"/posts",
-		commonmw.ParseUserID(handlers.CreatePost(validation.DefaultValidator, postService)),
-	).Methods(http.MethodPost)
-	r.Handle(
-		"/posts/{post_id}",
-		handlers.GetPost(postService),
-	).Methods(http.MethodGet)
-	r.Handle(
-		"/posts/{post_id}/comments",
-		commonmw.ParseUserID(handlers.CreateComment(commentService)),
-	).Methods(http.MethodPost)
-	r.Handle(
-		"/posts/{post_id}/comments",
-		handlers.GetComments(commentService),
+		"/notifications",
+		commonmw.ParseUserID(handlers.GetNotifications(notificationService)),
 	).Methods(http.MethodGet)
 	r.Handle(
-		"/posts/{entity_id}/likes",
-		commonmw.ParseUserID(handlers.CreateLike(postLikeService)),
+		"/notifications/read",
+		commonmw.ParseUserID(handlers.MarkRead(notificationService)),
 	).Methods(http.MethodPost)
-	r.Handle(
-		"/comments/{entity_id}/likes",
-		commonmw.ParseUserID(handlers.CreateLike(commentLikeService)),
-	).Methods(http.MethodPost)
-	r.Handle(
-		"/feed",
-		commonmw.ParseUserID(handlers.GetFeed(postService)),
-	).Methods(http.MethodGet)
 
 	r.Use(commonmw.WithRequestContextTimeout(defaultTimeout))
 
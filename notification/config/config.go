@@ -0,0 +1,8 @@
+package config
+
+const NotificationsPaginationLimit = 50
+
+// Number of the latest actors returned with each notification, e.g. "A, B and 5 others liked your post".
+const NotificationActorsLimit = 3
+
+const MarkReadLimit = 100
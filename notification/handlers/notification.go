@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"smapp/common/jsonresp"
+	commonmw "smapp/common/middleware"
+	"smapp/notification/model"
+	"smapp/notification/service"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func GetNotifications(notificationService *service.Notification) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recipientID, err := commonmw.GetUserID(r.Context())
+		if err != nil {
+			log.Println(err)
+			jsonresp.ErrorWithDefaultMessage(w, http.StatusInternalServerError)
+			return
+		}
+
+		lastLoadedTimestamp, err := time.Parse(time.RFC3339, r.URL.Query().Get("last_loaded_timestamp"))
+		if err != nil {
+			jsonresp.Error(w, fmt.Sprintf("last_loaded_timestamp: should be in format %s", time.RFC3339), http.StatusBadRequest)
+			return
+		}
+		lastLoadedID, err := uuid.Parse(r.URL.Query().Get("last_loaded_id"))
+		if err != nil {
+			jsonresp.Error(w, fmt.Sprintf("Invalid last_loaded_id: %s", err.Error()), http.StatusBadRequest)
+			return
+		}
+		limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+		if err != nil {
+			jsonresp.Error(w, "limit: should be an integer", http.StatusBadRequest)
+			return
+		}
+
+		cursor := model.Cursor{
+			LastLoadedTimestamp: lastLoadedTimestamp,
+			LastLoadedID:        lastLoadedID,
+		}
+		notifications, nextCursor, err := notificationService.GetPaginated(r.Context(), recipientID, cursor, limit)
+		if errors.Is(err, service.ErrNotificationsPaginationLimitInvalid) {
+			jsonresp.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			log.Println(err)
+			jsonresp.ErrorWithDefaultMessage(w, http.StatusRequestTimeout)
+			return
+		}
+		if errors.Is(err, context.Canceled) {
+			// client disconnected
+			log.Println(err)
+			return
+		}
+		if err != nil {
+			log.Println(err)
+			jsonresp.ErrorWithDefaultMessage(w, http.StatusInternalServerError)
+			return
+		}
+
+		response := map[string]interface{}{
+			"status": "success",
+			"data": map[string]interface{}{
+				"notifications": notifications,
+				"next_cursor":   nextCursor,
+			},
+		}
+		jsonresp.Response(w, response, http.StatusOK)
+	})
+}
+
+type MarkReadRequestBody struct {
+	IDs []uuid.UUID `json:"ids"`
+}
+
+func MarkRead(notificationService *service.Notification) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body MarkReadRequestBody
+		err := json.NewDecoder(r.Body).Decode(&body)
+		if err != nil {
+			jsonresp.Error(w, "Invalid JSON body", http.StatusBadRequest)
+			return
+		}
+
+		recipientID, err := commonmw.GetUserID(r.Context())
+		if err != nil {
+			log.Println(err)
+			jsonresp.ErrorWithDefaultMessage(w, http.StatusInternalServerError)
+			return
+		}
+
+		err = notificationService.MarkRead(r.Context(), recipientID, body.IDs)
+		if errors.Is(err, service.ErrMarkReadLimitInvalid) {
+			jsonresp.Error(w, fmt.Sprintf("ids: %s", err.Error()), http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			log.Println(err)
+			jsonresp.ErrorWithDefaultMessage(w, http.StatusRequestTimeout)
+			return
+		}
+		if errors.Is(err, context.Canceled) {
+			// client disconnected
+			log.Println(err)
+			return
+		}
+		if err != nil {
+			log.Println(err)
+			jsonresp.ErrorWithDefaultMessage(w, http.StatusInternalServerError)
+			return
+		}
+
+		response := map[string]interface{}{"status": "success"}
+		jsonresp.Response(w, response, http.StatusOK)
+	})
+}
@@ -0,0 +1,29 @@
+-- A notification groups events of the same type about the same entity, e.g. all likes of a post, while it is unread.
+CREATE TABLE notifications (
+    id BINARY(16) PRIMARY KEY,
+    recipient_id BINARY(16) NOT NULL,
+    type ENUM(
+        'post_liked', 'comment_liked', 'post_commented', 'comment_replied',
+        'user_followed', 'mentioned_in_post', 'mentioned_in_comment'
+    ) NOT NULL,
+    -- Nil UUID for notifications that are not about a post or comment, e.g. follows
+    entity_id BINARY(16) NOT NULL,
+    actor_count INT UNSIGNED NOT NULL DEFAULT 0,
+    -- TRUE while unread, NULL once read. NULLs are not considered equal by the unique key, so there is at most one
+    -- unread notification per group, and any number of read ones.
+    unread BOOLEAN DEFAULT TRUE,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY unread_group_unique (recipient_id, type, entity_id, unread),
+    -- Index to speed up ORDER BY when fetching paginated notifications
+    INDEX recipient_updated_at_index (recipient_id, updated_at DESC, id)
+);
+
+CREATE TABLE notification_actors (
+    notification_id BINARY(16) NOT NULL,
+    actor_id BINARY(16) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (notification_id, actor_id),
+    -- Index to speed up loading the latest actors of notifications
+    INDEX notification_created_at_index (notification_id, created_at DESC),
+    FOREIGN KEY (notification_id) REFERENCES notifications(id)
+);
@@ -0,0 +1,52 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type NotificationType string
+
+const (
+	PostLiked          NotificationType = "post_liked"
+	CommentLiked       NotificationType = "comment_liked"
+	PostCommented      NotificationType = "post_commented"
+	CommentReplied     NotificationType = "comment_replied"
+	UserFollowed       NotificationType = "user_followed"
+	MentionedInPost    NotificationType = "mentioned_in_post"
+	MentionedInComment NotificationType = "mentioned_in_comment"
+)
+
+type Event struct {
+	Type        NotificationType
+	RecipientID uuid.UUID
+	ActorID     uuid.UUID
+	// uuid.Nil for events that are not about a post or comment.
+	EntityID uuid.UUID
+}
+
+type Actor struct {
+	ID     uuid.UUID `json:"id"`
+	Name   string    `json:"name"`
+	Handle string    `json:"handle"`
+	// Resolved by the user service. Empty if the actor has no profile image.
+	ImageURL string `json:"image_url,omitempty"`
+}
+
+type Notification struct {
+	ID       uuid.UUID        `json:"id"`
+	Type     NotificationType `json:"type"`
+	EntityID *uuid.UUID       `json:"entity_id,omitempty"`
+	ActorIDs []uuid.UUID      `json:"-"`
+	// The latest actors, the total number of actors is ActorCount.
+	Actors     []Actor   `json:"actors"`
+	ActorCount uint32    `json:"actor_count"`
+	Read       bool      `json:"read"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+type Cursor struct {
+	LastLoadedTimestamp time.Time `json:"last_loaded_timestamp"`
+	LastLoadedID        uuid.UUID `json:"last_loaded_id"`
+}
@@ -0,0 +1,233 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"smapp/notification/model"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+type Notification struct {
+	db *sql.DB
+}
+
+func NewNotification(db *sql.DB) *Notification {
+	return &Notification{db: db}
+}
+
+// Adds the actor to the unread notification of the event's group, creating the notification if there is none.
+// Repeated events of the same actor, e.g. like, unlike and like again, are counted once.
+func (n *Notification) Record(ctx context.Context, event model.Event) error {
+	fail := func(err error) error {
+		return fmt.Errorf("record notification event in db: %w", err)
+	}
+
+	tx, err := n.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fail(err)
+	}
+	defer tx.Rollback()
+
+	newID, err := uuid.NewRandom()
+	if err != nil {
+		return fail(err)
+	}
+	// Relies on unread_group_unique to not create a second unread notification for the group.
+	_, err = tx.ExecContext(
+		ctx,
+		"INSERT IGNORE INTO notifications (id, recipient_id, type, entity_id) VALUES (?, ?, ?, ?)",
+		newID[:], event.RecipientID[:], event.Type, event.EntityID[:],
+	)
+	if err != nil {
+		return fail(changeErrIfCtxDone(ctx, err))
+	}
+	var id uuid.UUID
+	err = tx.QueryRowContext(
+		ctx,
+		"SELECT id FROM notifications WHERE recipient_id = ? AND type = ? AND entity_id = ? AND unread = TRUE FOR UPDATE",
+		event.RecipientID[:], event.Type, event.EntityID[:],
+	).Scan(&id)
+	if err != nil {
+		return fail(changeErrIfCtxDone(ctx, err))
+	}
+
+	result, err := tx.ExecContext(
+		ctx,
+		"INSERT IGNORE INTO notification_actors (notification_id, actor_id) VALUES (?, ?)",
+		id[:], event.ActorID[:],
+	)
+	if err != nil {
+		return fail(changeErrIfCtxDone(ctx, err))
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fail(err)
+	}
+	if rowsAffected > 0 {
+		_, err = tx.ExecContext(
+			ctx,
+			"UPDATE notifications SET actor_count = actor_count + 1, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
+			id[:],
+		)
+		if err != nil {
+			return fail(changeErrIfCtxDone(ctx, err))
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fail(changeErrIfCtxDone(ctx, err))
+	}
+	return nil
+}
+
+// Each notification is loaded with IDs of up to actorsLimit of its latest actors.
+func (n *Notification) GetPaginated(
+	ctx context.Context, recipientID uuid.UUID, cursor model.Cursor, limit, actorsLimit int,
+) ([]model.Notification, *model.Cursor, error) {
+	fail := func(err error) ([]model.Notification, *model.Cursor, error) {
+		return nil, nil, fmt.Errorf("get notifications from db: %w", err)
+	}
+
+	rows, err := n.db.QueryContext(
+		ctx,
+		`
+		SELECT id, type, entity_id, actor_count, unread IS NULL, updated_at 
+		FROM notifications 
+		WHERE recipient_id = ? AND (updated_at < ? OR (updated_at = ? AND id > ?)) 
+		ORDER BY updated_at DESC, id 
+		LIMIT ? 
+		`,
+		recipientID[:], cursor.LastLoadedTimestamp, cursor.LastLoadedTimestamp, cursor.LastLoadedID[:], limit+1,
+	)
+	if err != nil {
+		return fail(err)
+	}
+	defer rows.Close()
+
+	notifications := make([]model.Notification, 0)
+	for i := 0; i < limit && rows.Next(); i++ {
+		var notification model.Notification
+		var entityID uuid.UUID
+		err = rows.Scan(
+			&notification.ID, &notification.Type, &entityID, &notification.ActorCount, &notification.Read,
+			&notification.UpdatedAt,
+		)
+		if err != nil {
+			return fail(err)
+		}
+		if entityID != uuid.Nil {
+			notification.EntityID = &entityID
+		}
+		notifications = append(notifications, notification)
+	}
+	if err = rows.Err(); err != nil {
+		return fail(err)
+	}
+
+	var nextCursor *model.Cursor
+	// Given that we have loaded limit+1 elements and iterated over at most limit elements, rows.Next() == false means its the last page.
+	if rows.Next() {
+		nextCursor = &model.Cursor{
+			LastLoadedTimestamp: notifications[len(notifications)-1].UpdatedAt,
+			LastLoadedID:        notifications[len(notifications)-1].ID,
+		}
+	}
+	// Release the connection before loading actors.
+	rows.Close()
+
+	ids := make([]uuid.UUID, len(notifications))
+	for i, notification := range notifications {
+		ids[i] = notification.ID
+	}
+	actorIDs, err := n.getLatestActorIDs(ctx, ids, actorsLimit)
+	if err != nil {
+		return fail(err)
+	}
+	for i := range notifications {
+		notifications[i].ActorIDs = actorIDs[notifications[i].ID]
+	}
+
+	return notifications, nextCursor, nil
+}
+
+// Loads actors of all given notifications in a single query, latest first.
+func (n *Notification) getLatestActorIDs(
+	ctx context.Context, ids []uuid.UUID, limit int,
+) (map[uuid.UUID][]uuid.UUID, error) {
+	actorIDs := make(map[uuid.UUID][]uuid.UUID, len(ids))
+	if len(ids) == 0 {
+		return actorIDs, nil
+	}
+	placeholders := make([]string, len(ids))
+	args := make([]interface{}, 0, len(ids)+1)
+	for i, id := range ids {
+		actorIDs[id] = make([]uuid.UUID, 0)
+		placeholders[i] = "?"
+		args = append(args, id[:])
+	}
+	args = append(args, limit)
+
+	rows, err := n.db.QueryContext(
+		ctx,
+		fmt.Sprintf(
+			`
+			SELECT notification_id, actor_id 
+			FROM (
+				SELECT notification_id, actor_id, created_at, 
+					ROW_NUMBER() OVER (PARTITION BY notification_id ORDER BY created_at DESC) AS actor_rank 
+				FROM notification_actors 
+				WHERE notification_id IN (%s) 
+			) a 
+			WHERE actor_rank <= ? 
+			ORDER BY notification_id, actor_rank
+			`,
+			strings.Join(placeholders, ","),
+		),
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, actorID uuid.UUID
+		if err = rows.Scan(&id, &actorID); err != nil {
+			return nil, err
+		}
+		actorIDs[id] = append(actorIDs[id], actorID)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return actorIDs, nil
+}
+
+// Notifications of other recipients are ignored.
+func (n *Notification) MarkRead(ctx context.Context, recipientID uuid.UUID, ids []uuid.UUID) error {
+	fail := func(err error) error {
+		return fmt.Errorf("mark notifications as read in db: %w", err)
+	}
+
+	placeholders := make([]string, len(ids))
+	args := make([]interface{}, 0, len(ids)+1)
+	args = append(args, recipientID[:])
+	for i, id := range ids {
+		placeholders[i] = "?"
+		args = append(args, id[:])
+	}
+	_, err := n.db.ExecContext(
+		ctx,
+		fmt.Sprintf(
+			"UPDATE notifications SET unread = NULL WHERE recipient_id = ? AND id IN (%s) AND unread = TRUE",
+			strings.Join(placeholders, ","),
+		),
+		args...,
+	)
+	if err != nil {
+		return fail(err)
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+package repository_test
+
+import (
+	"context"
+	"regexp"
+	"smapp/notification/model"
+	"smapp/notification/repository"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/matryer/is"
+)
+
+func TestNotificationRecord(t *testing.T) {
+	event := model.Event{
+		Type:        model.PostLiked,
+		RecipientID: uuid.New(),
+		ActorID:     uuid.New(),
+		EntityID:    uuid.New(),
+	}
+
+	tests := []struct {
+		name string
+		// Rows inserted by INSERT IGNORE, 0 if the group already has an unread notification or the actor was already
+		// added to it.
+		notificationsInserted int64
+		actorsInserted        int64
+	}{
+		{
+			name:                  "first event of the group",
+			notificationsInserted: 1,
+			actorsInserted:        1,
+		},
+		{
+			name:                  "unread notification of the group exists",
+			notificationsInserted: 0,
+			actorsInserted:        1,
+		},
+		{
+			name:                  "repeated event of the same actor",
+			notificationsInserted: 0,
+			actorsInserted:        0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			is := is.New(t)
+			db, mock, err := sqlmock.New()
+			is.NoErr(err)
+			defer db.Close()
+
+			id := uuid.New()
+			mock.ExpectBegin()
+			mock.ExpectExec("INSERT IGNORE INTO notifications").
+				WithArgs(sqlmock.AnyArg(), event.RecipientID[:], event.Type, event.EntityID[:]).
+				WillReturnResult(sqlmock.NewResult(0, tt.notificationsInserted))
+			// The actor is added to whichever notification is unread, the inserted one or the existing one.
+			mock.ExpectQuery("SELECT id FROM notifications WHERE (.+) AND unread = TRUE FOR UPDATE").
+				WithArgs(event.RecipientID[:], event.Type, event.EntityID[:]).
+				WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(id[:]))
+			mock.ExpectExec("INSERT IGNORE INTO notification_actors").
+				WithArgs(id[:], event.ActorID[:]).
+				WillReturnResult(sqlmock.NewResult(0, tt.actorsInserted))
+			if tt.actorsInserted > 0 {
+				mock.ExpectExec("UPDATE notifications SET actor_count = actor_count \\+ 1").
+					WithArgs(id[:]).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			}
+			mock.ExpectCommit()
+
+			notification := repository.NewNotification(db)
+			is.NoErr(notification.Record(context.TODO(), event))
+			is.NoErr(mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestNotificationGetPaginated(t *testing.T) {
+	recipientID := uuid.New()
+	cursor := model.Cursor{LastLoadedTimestamp: time.Now(), LastLoadedID: uuid.New()}
+	ids := []uuid.UUID{uuid.New(), uuid.New(), uuid.New()}
+	updatedAt := []time.Time{cursor.LastLoadedTimestamp.Add(-time.Minute), cursor.LastLoadedTimestamp.Add(-time.Hour)}
+	entityID := uuid.New()
+	actorIDs := []uuid.UUID{uuid.New(), uuid.New()}
+
+	tests := []struct {
+		name               string
+		loaded             int
+		expectedCount      int
+		expectedNextCursor *model.Cursor
+	}{
+		{
+			name:          "last page",
+			loaded:        2,
+			expectedCount: 2,
+		},
+		{
+			// One more notification than the limit is loaded to find out whether there is a next page.
+			name:          "more pages",
+			loaded:        3,
+			expectedCount: 2,
+			expectedNextCursor: &model.Cursor{
+				LastLoadedTimestamp: updatedAt[1],
+				LastLoadedID:        ids[1],
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			is := is.New(t)
+			db, mock, err := sqlmock.New()
+			is.NoErr(err)
+			defer db.Close()
+
+			rows := sqlmock.NewRows([]string{"id", "type", "entity_id", "actor_count", "read", "updated_at"}).
+				AddRow(ids[0][:], model.PostLiked, entityID[:], 5, false, updatedAt[0]).
+				AddRow(ids[1][:], model.UserFollowed, uuid.Nil[:], 1, true, updatedAt[1])
+			if tt.loaded > 2 {
+				rows.AddRow(ids[2][:], model.PostLiked, entityID[:], 1, true, updatedAt[1])
+			}
+			mock.ExpectQuery("FROM notifications").
+				WithArgs(
+					recipientID[:], cursor.LastLoadedTimestamp, cursor.LastLoadedTimestamp, cursor.LastLoadedID[:], 3,
+				).
+				WillReturnRows(rows)
+			// Actors are only loaded for the notifications on the page.
+			mock.ExpectQuery("FROM notification_actors").
+				WithArgs(ids[0][:], ids[1][:], 10).
+				WillReturnRows(
+					sqlmock.NewRows([]string{"notification_id", "actor_id"}).
+						AddRow(ids[0][:], actorIDs[0][:]).
+						AddRow(ids[0][:], actorIDs[1][:]).
+						AddRow(ids[1][:], actorIDs[0][:]),
+				)
+
+			notification := repository.NewNotification(db)
+			notifications, nextCursor, err := notification.GetPaginated(context.TODO(), recipientID, cursor, 2, 10)
+			is.NoErr(err)
+			is.Equal(len(notifications), tt.expectedCount)
+			is.Equal(nextCursor, tt.expectedNextCursor)
+
+			is.Equal(notifications[0].ID, ids[0])
+			is.Equal(*notifications[0].EntityID, entityID)
+			is.Equal(notifications[0].ActorCount, uint32(5))
+			is.True(!notifications[0].Read)
+			is.Equal(notifications[0].ActorIDs, actorIDs)
+			// uuid.Nil stands for events that are not about an entity.
+			is.Equal(notifications[1].EntityID, nil)
+			is.True(notifications[1].Read)
+			is.Equal(notifications[1].ActorIDs, actorIDs[:1])
+			is.NoErr(mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestNotificationGetPaginatedEmpty(t *testing.T) {
+	is := is.New(t)
+	db, mock, err := sqlmock.New()
+	is.NoErr(err)
+	defer db.Close()
+
+	// No actors are loaded for an empty page.
+	mock.ExpectQuery("FROM notifications").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "type", "entity_id", "actor_count", "read", "updated_at"}))
+
+	notification := repository.NewNotification(db)
+	notifications, nextCursor, err := notification.GetPaginated(context.TODO(), uuid.New(), model.Cursor{}, 2, 10)
+	is.NoErr(err)
+	is.Equal(len(notifications), 0)
+	is.Equal(nextCursor, nil)
+	is.NoErr(mock.ExpectationsWereMet())
+}
+
+func TestNotificationMarkRead(t *testing.T) {
+	is := is.New(t)
+	db, mock, err := sqlmock.New()
+	is.NoErr(err)
+	defer db.Close()
+
+	// Setting unread to NULL takes the notification out of the unique key, so the next event of the group creates a
+	// new unread notification.
+	recipientID := uuid.New()
+	ids := []uuid.UUID{uuid.New(), uuid.New()}
+	mock.ExpectExec(regexp.QuoteMeta(
+		"UPDATE notifications SET unread = NULL WHERE recipient_id = ? AND id IN (?,?) AND unread = TRUE",
+	)).
+		WithArgs(recipientID[:], ids[0][:], ids[1][:]).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	notification := repository.NewNotification(db)
+	is.NoErr(notification.MarkRead(context.TODO(), recipientID, ids))
+	is.NoErr(mock.ExpectationsWereMet())
+}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"smapp/notification/config"
+	"smapp/notification/model"
+	"smapp/notification/repository"
+
+	userPB "smapp/common/grpc/user"
+
+	"github.com/google/uuid"
+)
+
+type Notification struct {
+	notificationRepository *repository.Notification
+	userClient             userPB.UserClient
+}
+
+func NewNotification(notificationRepository *repository.Notification, userClient userPB.UserClient) *Notification {
+	return &Notification{
+		notificationRepository: notificationRepository,
+		userClient:             userClient,
+	}
+}
+
+// Users are not notified about their own actions, e.g. liking their own post.
+func (svc *Notification) Record(ctx context.Context, event model.Event) error {
+	if event.RecipientID == event.ActorID {
+		return nil
+	}
+	if err := svc.notificationRepository.Record(ctx, event); err != nil {
+		return fmt.Errorf("record notification event: %w", err)
+	}
+	return nil
+}
+
+var ErrNotificationsPaginationLimitInvalid = errors.New("notifications pagination limit invalid")
+
+func (svc *Notification) GetPaginated(
+	ctx context.Context, recipientID uuid.UUID, cursor model.Cursor, limit int,
+) ([]model.Notification, *model.Cursor, error) {
+	fail := func(err error) ([]model.Notification, *model.Cursor, error) {
+		return nil, nil, fmt.Errorf("get notifications: %w", err)
+	}
+
+	if limit < 1 || limit > config.NotificationsPaginationLimit {
+		return nil, nil, fmt.Errorf(
+			"%w, should be in range: [1, %d]",
+			ErrNotificationsPaginationLimitInvalid, config.NotificationsPaginationLimit,
+		)
+	}
+
+	notifications, nextCursor, err := svc.notificationRepository.GetPaginated(
+		ctx, recipientID, cursor, limit, config.NotificationActorsLimit,
+	)
+	if err != nil {
+		return fail(err)
+	}
+
+	actorIDs := make([][]byte, 0)
+	seen := make(map[uuid.UUID]bool)
+	for _, notification := range notifications {
+		for _, id := range notification.ActorIDs {
+			if !seen[id] {
+				seen[id] = true
+				actorIDs = append(actorIDs, id[:])
+			}
+		}
+	}
+	actors := make(map[uuid.UUID]model.Actor)
+	if len(actorIDs) > 0 {
+		resp, err := svc.userClient.GetUsers(ctx, &userPB.GetUsersRequest{UserIds: actorIDs})
+		if err != nil {
+			return fail(err)
+		}
+		for _, user := range resp.Users {
+			id, err := uuid.FromBytes(user.Id)
+			if err != nil {
+				return fail(err)
+			}
+			actors[id] = model.Actor{ID: id, Name: user.Name, Handle: user.Handle, ImageURL: user.ImageUrl}
+		}
+	}
+	// Actors that no longer exist are left out, but still counted in ActorCount.
+	for i := range notifications {
+		notifications[i].Actors = make([]model.Actor, 0, len(notifications[i].ActorIDs))
+		for _, id := range notifications[i].ActorIDs {
+			if actor, ok := actors[id]; ok {
+				notifications[i].Actors = append(notifications[i].Actors, actor)
+			}
+		}
+	}
+
+	return notifications, nextCursor, nil
+}
+
+var ErrMarkReadLimitInvalid = errors.New("number of notifications to mark as read invalid")
+
+func (svc *Notification) MarkRead(ctx context.Context, recipientID uuid.UUID, ids []uuid.UUID) error {
+	if len(ids) < 1 || len(ids) > config.MarkReadLimit {
+		return fmt.Errorf("%w, should be in range: [1, %d]", ErrMarkReadLimitInvalid, config.MarkReadLimit)
+	}
+	if err := svc.notificationRepository.MarkRead(ctx, recipientID, ids); err != nil {
+		return fmt.Errorf("mark notifications as read: %w", err)
+	}
+	return nil
+}
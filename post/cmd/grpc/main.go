@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net"
+	commondb "smapp/common/db"
+	commonenv "smapp/common/env"
+	pb "smapp/common/grpc/post"
+	"smapp/post/config"
+	"smapp/post/repository"
+	"smapp/post/service"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/keepalive"
+)
+
+type mysqlConfig struct {
+	host     string
+	user     string
+	password []byte
+	db       string
+}
+
+func getMysqlConfig() (*mysqlConfig, error) {
+	mysqlConfig := mysqlConfig{}
+	var err error
+	if mysqlConfig.host, err = commonenv.GetEnv("MYSQL_HOST"); err != nil {
+		return nil, err
+	}
+	if mysqlConfig.user, err = commonenv.GetEnv("MYSQL_USER"); err != nil {
+		return nil, err
+	}
+	if mysqlConfig.password, err = commonenv.GetSecret("mysql_password"); err != nil {
+		return nil, err
+	}
+	if mysqlConfig.db, err = commonenv.GetEnv("MYSQL_DB"); err != nil {
+		return nil, err
+	}
+	return &mysqlConfig, nil
+}
+
+type postServer struct {
+	pb.UnimplementedPostServer
+	timelineService *service.Timeline
+}
+
+func (s *postServer) AddAuthorToTimeline(ctx context.Context, req *pb.TimelineAuthorRequest) (*pb.TimelineAuthorResponse, error) {
+	userID, err := uuid.FromBytes(req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	authorID, err := uuid.FromBytes(req.AuthorId)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.timelineService.AddAuthor(ctx, userID, authorID); err != nil {
+		return nil, err
+	}
+	return &pb.TimelineAuthorResponse{}, nil
+}
+
+func (s *postServer) RemoveAuthorFromTimeline(ctx context.Context, req *pb.TimelineAuthorRequest) (*pb.TimelineAuthorResponse, error) {
+	userID, err := uuid.FromBytes(req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	authorID, err := uuid.FromBytes(req.AuthorId)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.timelineService.RemoveAuthor(ctx, userID, authorID); err != nil {
+		return nil, err
+	}
+	return &pb.TimelineAuthorResponse{}, nil
+}
+
+func main() {
+	mysqlConfig, err := getMysqlConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	db, err := sql.Open(
+		"mysql",
+		fmt.Sprintf(
+			"%s:%s@tcp(%s)/%s?parseTime=true",
+			mysqlConfig.user, mysqlConfig.password, mysqlConfig.host, mysqlConfig.db,
+		),
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	err = commondb.WaitForDB(ctx, db)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	timelineService := service.NewTimeline(repository.NewDefaultTimeline(db, config.HighFollowerAuthorsCacheTTL))
+
+	lis, err := net.Listen("tcp", ":50051")
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Set maximum connection age to periodically trigger DNS lookups in case replicas were added/removed.
+	s := grpc.NewServer(
+		grpc.KeepaliveParams(keepalive.ServerParameters{
+			MaxConnectionAge:      15 * time.Second,
+			MaxConnectionAgeGrace: 5 * time.Second,
+		}),
+	)
+	pb.RegisterPostServer(s, &postServer{timelineService: timelineService})
+	log.Fatal(s.Serve(lis))
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	commondb "smapp/common/db"
+	commonenv "smapp/common/env"
+	imagePB "smapp/common/grpc/image"
+	notificationPB "smapp/common/grpc/notification"
+	userPB "smapp/common/grpc/user"
+	commonmw "smapp/common/middleware"
+	"smapp/common/outbox"
+	"smapp/common/validation"
+	"smapp/post/config"
+	"smapp/post/handlers"
+	"smapp/post/repository"
+	"smapp/post/service"
+	"strings"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/gorilla/mux"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+type mysqlConfig struct {
+	host     string
+	user     string
+	password []byte
+	db       string
+}
+
+func getMysqlConfig() (*mysqlConfig, error) {
+	mysqlConfig := mysqlConfig{}
+	var err error
+	if mysqlConfig.host, err = commonenv.GetEnv("MYSQL_HOST"); err != nil {
+		return nil, err
+	}
+	if mysqlConfig.user, err = commonenv.GetEnv("MYSQL_USER"); err != nil {
+		return nil, err
+	}
+	if mysqlConfig.password, err = commonenv.GetSecret("mysql_password"); err != nil {
+		return nil, err
+	}
+	if mysqlConfig.db, err = commonenv.GetEnv("MYSQL_DB"); err != nil {
+		return nil, err
+	}
+	return &mysqlConfig, nil
+}
+
+func main() {
+	mysqlConfig, err := getMysqlConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defaultTimeout, err := commonenv.GetEnvDuration("DEFAULT_TIMEOUT")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	db, err := sql.Open(
+		"mysql",
+		fmt.Sprintf(
+			"%s:%s@tcp(%s)/%s?parseTime=true",
+			mysqlConfig.user, mysqlConfig.password, mysqlConfig.host, mysqlConfig.db,
+		),
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	err = commondb.WaitForDB(ctx, db)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	conn, err := grpc.NewClient(
+		"user-grpc:50051",
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultServiceConfig(`{"loadBalancingConfig": [{"round_robin":{}}]}`),
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer conn.Close()
+	userClient := userPB.NewUserClient(conn)
+
+	conn, err = grpc.NewClient(
+		"image-grpc:50051",
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultServiceConfig(`{"loadBalancingConfig": [{"round_robin":{}}]}`),
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer conn.Close()
+	imageClient := imagePB.NewImageClient(conn)
+
+	conn, err = grpc.NewClient(
+		"notification-grpc:50051",
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultServiceConfig(`{"loadBalancingConfig": [{"round_robin":{}}]}`),
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer conn.Close()
+	notificationClient := notificationPB.NewNotificationClient(conn)
+
+	postRepository := repository.NewDefaultPost(db)
+	timelineRepository := repository.NewDefaultTimeline(db, config.HighFollowerAuthorsCacheTTL)
+	commentRepository := repository.NewComment(db)
+	postLikeRepository := repository.NewPostLike(db)
+	commentLikeRepository := repository.NewCommentLike(db)
+	tagRepository := repository.NewTag(db)
+	streamRepository := repository.NewStream(db)
+
+	postService := service.NewDefaultPost(
+		postRepository, commentRepository, postLikeRepository, timelineRepository, userClient, imageClient,
+	)
+	commentService := service.NewComment(commentRepository, postRepository, userClient)
+	postLikeService := service.NewPostLike(postLikeRepository, postRepository, userClient)
+	commentLikeService := service.NewCommentLike(commentLikeRepository, commentRepository, userClient)
+	expiringTags, err := getExpiringTags()
+	if err != nil {
+		log.Fatal(err)
+	}
+	tagService := service.NewTag(postRepository, tagRepository, userClient, expiringTags)
+	streamService := service.NewStream(streamRepository, postRepository, timelineRepository, userClient)
+	notifier := service.NewNotifier(notificationClient)
+
+	relay := outbox.NewRelay(
+		db, outbox.NewLocalPublisher(notifier.HandleEvent), config.OutboxBatchSize, config.OutboxPollInterval, defaultTimeout,
+	)
+	go relay.Run(context.Background())
+	go tagService.RunPostExpiration(context.Background())
+
+	r := mux.NewRouter()
+	r.Handle(
+		"/posts",
+		commonmw.ParseUserID(handlers.CreatePost(validation.DefaultValidator, postService)),
+	).Methods(http.MethodPost)
+	r.Handle(
+		"/posts/{post_id}",
+		commonmw.ParseOptionalUserID(handlers.GetPost(postService)),
+	).Methods(http.MethodGet)
+	r.Handle(
+		"/posts/{post_id}",
+		commonmw.ParseUserID(handlers.UpdatePost(validation.DefaultValidator, postService)),
+	).Methods(http.MethodPatch)
+	r.Handle(
+		"/posts/{post_id}",
+		commonmw.ParseUserID(handlers.DeletePost(postService)),
+	).Methods(http.MethodDelete)
+	r.Handle(
+		"/posts/{post_id}/comments",
+		commonmw.ParseUserID(handlers.CreateComment(commentService)),
+	).Methods(http.MethodPost)
+	r.Handle(
+		"/posts/{post_id}/comments",
+		commonmw.ParseOptionalUserID(handlers.GetComments(commentService)),
+	).Methods(http.MethodGet)
+	r.Handle(
+		"/comments/{comment_id}",
+		commonmw.ParseUserID(handlers.UpdateComment(commentService)),
+	).Methods(http.MethodPatch)
+	r.Handle(
+		"/comments/{comment_id}",
+		commonmw.ParseUserID(handlers.DeleteComment(commentService)),
+	).Methods(http.MethodDelete)
+	r.Handle(
+		"/comments/{comment_id}/replies",
+		commonmw.ParseUserID(handlers.CreateReply(commentService)),
+	).Methods(http.MethodPost)
+	r.Handle(
+		"/comments/{comment_id}/replies",
+		commonmw.ParseOptionalUserID(handlers.GetReplies(commentService)),
+	).Methods(http.MethodGet)
+	r.Handle(
+		"/posts/{entity_id}/likes",
+		commonmw.ParseUserID(handlers.CreateLike(postLikeService)),
+	).Methods(http.MethodPost)
+	r.Handle(
+		"/posts/{entity_id}/likes",
+		commonmw.ParseUserID(handlers.DeleteLike(postLikeService)),
+	).Methods(http.MethodDelete)
+	r.Handle(
+		"/comments/{entity_id}/likes",
+		commonmw.ParseUserID(handlers.CreateLike(commentLikeService)),
+	).Methods(http.MethodPost)
+	r.Handle(
+		"/comments/{entity_id}/likes",
+		commonmw.ParseUserID(handlers.DeleteLike(commentLikeService)),
+	).Methods(http.MethodDelete)
+	r.Handle("/tags/trending", handlers.GetTrendingTags(tagService)).Methods(http.MethodGet)
+	r.Handle(
+		"/tags/{tag}/posts",
+		commonmw.ParseOptionalUserID(handlers.GetTagPosts(tagService)),
+	).Methods(http.MethodGet)
+	r.Handle(
+		"/search/posts",
+		commonmw.ParseOptionalUserID(handlers.SearchPosts(postService)),
+	).Methods(http.MethodGet)
+	r.Handle(
+		"/feed",
+		commonmw.ParseUserID(handlers.GetFeed(postService)),
+	).Methods(http.MethodGet)
+	r.Handle(
+		"/feed/stream",
+		commonmw.ParseUserID(commonmw.WithoutRequestContextTimeout(handlers.StreamFeed(streamService, defaultTimeout))),
+	).Methods(http.MethodGet)
+
+	r.Use(commonmw.WithRequestContextTimeout(defaultTimeout))
+
+	srv := &http.Server{
+		Addr:        ":8080",
+		Handler:     r,
+		ReadTimeout: defaultTimeout,
+	}
+	log.Fatal(srv.ListenAndServe())
+}
+
+// EXPIRING_TAGS is a comma separated list of tags with the durations after which their posts are deleted, e.g.
+// "story=24h,event=168h", which may be empty.
+func getExpiringTags() (map[string]time.Duration, error) {
+	value, err := commonenv.GetEnv("EXPIRING_TAGS")
+	if err != nil {
+		return nil, err
+	}
+	expiringTags := make(map[string]time.Duration)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		tag, ttl, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("parse EXPIRING_TAGS: missing duration of %s", entry)
+		}
+		duration, err := time.ParseDuration(ttl)
+		if err != nil || duration <= 0 {
+			return nil, fmt.Errorf("parse EXPIRING_TAGS: invalid duration of %s", tag)
+		}
+		expiringTags[strings.ToLower(strings.TrimPrefix(tag, "#"))] = duration
+	}
+	return expiringTags, nil
+}
@@ -1,4 +1,47 @@
 package config
 
+import "time"
+
 const PostsPaginationLimit = 30
 const CommentsPaginationLimit = 50
+const SearchPaginationLimit = 30
+
+// Pages with posts or comments the viewer cannot see are topped up from the following items, with at most this many
+// queries per page.
+const PageLoadsLimit = 5
+
+// Authors with more followers than this are not fanned out to on post creation, their posts are merged into feeds on read.
+const FanoutFollowersLimit = 5000
+
+// The list of authors above the limit is read on every feed request, so each instance caches it for this long. A
+// newly marked author's posts can be missing from feeds served by other instances until then.
+const HighFollowerAuthorsCacheTTL = time.Minute
+
+// Number of the author's latest posts added to the follower's timeline on follow.
+const TimelineBackfillLimit = 100
+
+// Tags are ranked by the number of posts using them within this window.
+const TrendingTagsWindow = 24 * time.Hour
+const TrendingTagsLimit = 10
+
+// Tags beyond this limit are ignored.
+const MaxTagsPerPost = 30
+
+// Posts with an expiring tag (EXPIRING_TAGS) are deleted at this interval once they are older than the tag's duration,
+// up to PostExpirationBatchSize posts per query.
+const PostExpirationInterval = time.Minute
+const PostExpirationBatchSize = 100
+
+// The outbox relay publishes up to this many events at once, and polls at this interval while it has fewer.
+const OutboxBatchSize = 100
+const OutboxPollInterval = time.Second
+
+// The feed stream polls for new posts, likes and comments at this interval, up to StreamEventsLimit of each kind at once.
+const StreamPollInterval = 2 * time.Second
+const StreamEventsLimit = 50
+
+// Rows younger than this are left for the next poll, so that rows committed late are not skipped by the stream cursor.
+const StreamSettleDelay = 2 * time.Second
+
+// A comment is sent on an idle stream at this interval, so that proxies do not close the connection.
+const StreamKeepAliveInterval = 15 * time.Second
@@ -5,6 +5,7 @@ go 1.22.6
 replace smapp/common => ../common
 
 require (
+	github.com/DATA-DOG/go-sqlmock v1.5.2
 	github.com/go-ozzo/ozzo-validation/v4 v4.3.0
 	github.com/go-sql-driver/mysql v1.8.1
 	github.com/google/uuid v1.6.0
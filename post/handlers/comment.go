@@ -69,6 +69,14 @@ func CreateComment(commentService *service.Comment) http.Handler {
 			log.Println(err)
 			return
 		}
+		if errors.Is(err, service.ErrBlocked) {
+			jsonresp.Error(w, "Cannot interact with this user", http.StatusForbidden)
+			return
+		}
+		if errors.Is(err, service.ErrPrivateAuthor) {
+			jsonresp.Error(w, "This account is private", http.StatusForbidden)
+			return
+		}
 		if errors.Is(err, context.DeadlineExceeded) {
 			log.Println(err)
 			jsonresp.ErrorWithDefaultMessage(w, http.StatusRequestTimeout)
@@ -93,6 +101,116 @@ func CreateComment(commentService *service.Comment) http.Handler {
 	})
 }
 
+func UpdateComment(commentService *service.Comment) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		commentID, err := uuid.Parse(mux.Vars(r)["comment_id"])
+		if err != nil {
+			jsonresp.Error(w, fmt.Sprintf("Invalid comment ID: %s", err.Error()), http.StatusBadRequest)
+			return
+		}
+
+		var comment CreateCommentRequestBody
+		err = json.NewDecoder(r.Body).Decode(&comment)
+		if err != nil {
+			jsonresp.Error(w, "Invalid JSON body", http.StatusBadRequest)
+			return
+		}
+		err = comment.Validate()
+		if err != nil {
+			if e, ok := err.(validation.InternalError); ok {
+				log.Println(e.InternalError())
+				jsonresp.ErrorWithDefaultMessage(w, http.StatusInternalServerError)
+				return
+			}
+			errors := (err.(validation.Errors).Filter()).(validation.Errors)
+			jsonresp.ValidationError(w, errors, http.StatusBadRequest)
+			return
+		}
+
+		userID, err := commonmw.GetUserID(r.Context())
+		if err != nil {
+			log.Println(err)
+			jsonresp.ErrorWithDefaultMessage(w, http.StatusInternalServerError)
+			return
+		}
+
+		err = commentService.Update(r.Context(), commentID, userID, comment.Body)
+		if errors.Is(err, service.ErrCommentNotFound) {
+			jsonresp.Error(w, "Comment not found", http.StatusNotFound)
+			log.Println(err)
+			return
+		}
+		if errors.Is(err, service.ErrNotCommentAuthor) {
+			jsonresp.Error(w, "Only the author can edit the comment", http.StatusForbidden)
+			return
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			log.Println(err)
+			jsonresp.ErrorWithDefaultMessage(w, http.StatusRequestTimeout)
+			return
+		}
+		if errors.Is(err, context.Canceled) {
+			// client disconnected
+			log.Println(err)
+			return
+		}
+		if err != nil {
+			log.Println(err)
+			jsonresp.ErrorWithDefaultMessage(w, http.StatusInternalServerError)
+			return
+		}
+
+		response := map[string]interface{}{"status": "success"}
+		jsonresp.Response(w, response, http.StatusOK)
+	})
+}
+
+func DeleteComment(commentService *service.Comment) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		commentID, err := uuid.Parse(mux.Vars(r)["comment_id"])
+		if err != nil {
+			jsonresp.Error(w, fmt.Sprintf("Invalid comment ID: %s", err.Error()), http.StatusBadRequest)
+			return
+		}
+
+		userID, err := commonmw.GetUserID(r.Context())
+		if err != nil {
+			log.Println(err)
+			jsonresp.ErrorWithDefaultMessage(w, http.StatusInternalServerError)
+			return
+		}
+
+		err = commentService.Delete(r.Context(), commentID, userID)
+		if errors.Is(err, service.ErrCommentNotFound) {
+			jsonresp.Error(w, "Comment not found", http.StatusNotFound)
+			log.Println(err)
+			return
+		}
+		if errors.Is(err, service.ErrNotCommentAuthor) {
+			jsonresp.Error(w, "Only the author of the comment or of the post can delete the comment", http.StatusForbidden)
+			return
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			log.Println(err)
+			jsonresp.ErrorWithDefaultMessage(w, http.StatusRequestTimeout)
+			return
+		}
+		if errors.Is(err, context.Canceled) {
+			// client disconnected
+			log.Println(err)
+			return
+		}
+		if err != nil {
+			log.Println(err)
+			jsonresp.ErrorWithDefaultMessage(w, http.StatusInternalServerError)
+			return
+		}
+
+		response := map[string]interface{}{"status": "success"}
+		jsonresp.Response(w, response, http.StatusOK)
+	})
+}
+
 func GetComments(commentService *service.Comment) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		postID, err := uuid.Parse(mux.Vars(r)["post_id"])
@@ -121,11 +239,21 @@ func GetComments(commentService *service.Comment) http.Handler {
 			LastLoadedTimestamp: lastLoadedTimestamp,
 			LastLoadedID:        lastLoadedID,
 		}
-		comments, nextCursor, err := commentService.GetPaginatedWithLikeCount(r.Context(), postID, cursor, limit)
+		viewerID, err := commonmw.GetUserID(r.Context())
+		if err != nil {
+			log.Println(err)
+			jsonresp.ErrorWithDefaultMessage(w, http.StatusInternalServerError)
+			return
+		}
+		comments, nextCursor, err := commentService.GetPaginatedWithLikeCount(r.Context(), viewerID, postID, cursor, limit)
 		if errors.Is(err, service.ErrCommentsPaginationLimitInvalid) {
 			jsonresp.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
+		if errors.Is(err, service.ErrPrivateAuthor) {
+			jsonresp.Error(w, "This account is private", http.StatusForbidden)
+			return
+		}
 		if errors.Is(err, service.ErrPostNotFound) {
 			jsonresp.Error(w, "Post not found", http.StatusNotFound)
 			log.Println(err)
@@ -157,3 +285,149 @@ func GetComments(commentService *service.Comment) http.Handler {
 		jsonresp.Response(w, response, http.StatusOK)
 	})
 }
+
+func CreateReply(commentService *service.Comment) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var reply CreateCommentRequestBody
+		err := json.NewDecoder(r.Body).Decode(&reply)
+		if err != nil {
+			jsonresp.Error(w, "Invalid JSON body", http.StatusBadRequest)
+			return
+		}
+		err = reply.Validate()
+		if err != nil {
+			if e, ok := err.(validation.InternalError); ok {
+				log.Println(e.InternalError())
+				jsonresp.ErrorWithDefaultMessage(w, http.StatusInternalServerError)
+				return
+			}
+			errors := (err.(validation.Errors).Filter()).(validation.Errors)
+			jsonresp.ValidationError(w, errors, http.StatusBadRequest)
+			return
+		}
+
+		parentID, err := uuid.Parse(mux.Vars(r)["comment_id"])
+		if err != nil {
+			jsonresp.Error(w, fmt.Sprintf("Invalid comment ID: %s", err.Error()), http.StatusBadRequest)
+			return
+		}
+
+		authorID, err := commonmw.GetUserID(r.Context())
+		if err != nil {
+			log.Println(err)
+			jsonresp.ErrorWithDefaultMessage(w, http.StatusInternalServerError)
+			return
+		}
+
+		id, err := commentService.CreateReply(r.Context(), parentID, authorID, reply.Body)
+		if errors.Is(err, service.ErrCommentNotFound) {
+			jsonresp.Error(w, "Comment ID does not exist", http.StatusBadRequest)
+			log.Println(err)
+			return
+		}
+		if errors.Is(err, service.ErrBlocked) {
+			jsonresp.Error(w, "Cannot interact with this user", http.StatusForbidden)
+			return
+		}
+		if errors.Is(err, service.ErrPrivateAuthor) {
+			jsonresp.Error(w, "This account is private", http.StatusForbidden)
+			return
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			log.Println(err)
+			jsonresp.ErrorWithDefaultMessage(w, http.StatusRequestTimeout)
+			return
+		}
+		if errors.Is(err, context.Canceled) {
+			// client disconnected
+			log.Println(err)
+			return
+		}
+		if err != nil {
+			log.Println(err)
+			jsonresp.ErrorWithDefaultMessage(w, http.StatusInternalServerError)
+			return
+		}
+
+		response := map[string]interface{}{
+			"status": "success",
+			"id":     id,
+		}
+		jsonresp.Response(w, response, http.StatusCreated)
+	})
+}
+
+func GetReplies(commentService *service.Comment) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		commentID, err := uuid.Parse(mux.Vars(r)["comment_id"])
+		if err != nil {
+			jsonresp.Error(w, fmt.Sprintf("Invalid comment ID: %s", err.Error()), http.StatusBadRequest)
+			return
+		}
+
+		lastLoadedTimestamp, err := time.Parse(time.RFC3339, r.URL.Query().Get("last_loaded_timestamp"))
+		if err != nil {
+			jsonresp.Error(w, fmt.Sprintf("last_loaded_timestamp: should be in format %s", time.RFC3339), http.StatusBadRequest)
+			return
+		}
+		lastLoadedID, err := uuid.Parse(r.URL.Query().Get("last_loaded_id"))
+		if err != nil {
+			jsonresp.Error(w, fmt.Sprintf("invalid last_loaded_id: %s", err.Error()), http.StatusBadRequest)
+			return
+		}
+		limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+		if err != nil {
+			jsonresp.Error(w, "limit: should be an integer", http.StatusBadRequest)
+			return
+		}
+
+		cursor := model.Cursor{
+			LastLoadedTimestamp: lastLoadedTimestamp,
+			LastLoadedID:        lastLoadedID,
+		}
+		viewerID, err := commonmw.GetUserID(r.Context())
+		if err != nil {
+			log.Println(err)
+			jsonresp.ErrorWithDefaultMessage(w, http.StatusInternalServerError)
+			return
+		}
+		replies, nextCursor, err := commentService.GetRepliesPaginatedWithLikeCount(r.Context(), viewerID, commentID, cursor, limit)
+		if errors.Is(err, service.ErrCommentsPaginationLimitInvalid) {
+			jsonresp.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, service.ErrPrivateAuthor) {
+			jsonresp.Error(w, "This account is private", http.StatusForbidden)
+			return
+		}
+		if errors.Is(err, service.ErrCommentNotFound) {
+			jsonresp.Error(w, "Comment not found", http.StatusNotFound)
+			log.Println(err)
+			return
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			log.Println(err)
+			jsonresp.ErrorWithDefaultMessage(w, http.StatusRequestTimeout)
+			return
+		}
+		if errors.Is(err, context.Canceled) {
+			// client disconnected
+			log.Println(err)
+			return
+		}
+		if err != nil {
+			log.Println(err)
+			jsonresp.ErrorWithDefaultMessage(w, http.StatusInternalServerError)
+			return
+		}
+
+		response := map[string]interface{}{
+			"status": "success",
+			"data": map[string]interface{}{
+				"replies":     replies,
+				"next_cursor": nextCursor,
+			},
+		}
+		jsonresp.Response(w, response, http.StatusOK)
+	})
+}
@@ -0,0 +1,142 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	commonmw "smapp/common/middleware"
+	"smapp/post/handlers"
+	"smapp/post/repository"
+	"smapp/post/service"
+	"testing"
+
+	repomocks "smapp/post/repository/mocks"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/matryer/is"
+	"go.uber.org/mock/gomock"
+)
+
+func TestDeleteLike(t *testing.T) {
+	userID := uuid.New()
+	entityID := uuid.New()
+
+	tests := []struct {
+		name       string
+		entityType string
+		exists     bool
+		// Rows deleted from likes, 0 if the user has not liked the entity.
+		deleted    int64
+		code       int
+		status     string
+		errMessage string
+	}{
+		{
+			name:       "post like",
+			entityType: "posts",
+			exists:     true,
+			deleted:    1,
+			code:       http.StatusOK,
+			status:     "success",
+		},
+		{
+			name:       "post not liked",
+			entityType: "posts",
+			exists:     true,
+			deleted:    0,
+			code:       http.StatusOK,
+			status:     "unchanged",
+		},
+		{
+			name:       "post not found",
+			entityType: "posts",
+			exists:     false,
+			code:       http.StatusBadRequest,
+			status:     "error",
+			errMessage: "Post ID does not exist",
+		},
+		{
+			name:       "comment like",
+			entityType: "comments",
+			exists:     true,
+			deleted:    1,
+			code:       http.StatusOK,
+			status:     "success",
+		},
+		{
+			name:       "comment not liked",
+			entityType: "comments",
+			exists:     true,
+			deleted:    0,
+			code:       http.StatusOK,
+			status:     "unchanged",
+		},
+		{
+			name:       "comment not found",
+			entityType: "comments",
+			exists:     false,
+			code:       http.StatusBadRequest,
+			status:     "error",
+			errMessage: "Comment ID does not exist",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			is := is.New(t)
+			ctrl := gomock.NewController(t)
+			db, mock, err := sqlmock.New()
+			is.NoErr(err)
+			defer db.Close()
+
+			var likeService *service.Like
+			if test.entityType == "posts" {
+				postRepository := repomocks.NewMockPost(ctrl)
+				checkErr := error(nil)
+				if !test.exists {
+					checkErr = repository.ErrRecordNotFound
+				}
+				postRepository.EXPECT().CheckExists(gomock.Any(), entityID).Return(checkErr)
+				likeService = service.NewPostLike(repository.NewPostLike(db), postRepository, nil)
+			} else {
+				mock.ExpectQuery("SELECT EXISTS\\(SELECT 1 FROM comments WHERE id = \\?\\)").
+					WithArgs(entityID[:]).
+					WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(test.exists))
+				likeService = service.NewCommentLike(repository.NewCommentLike(db), repository.NewComment(db), nil)
+			}
+			if test.exists {
+				mock.ExpectBegin()
+				mock.ExpectExec("DELETE FROM likes").
+					WithArgs(test.entityType, entityID[:], userID[:]).
+					WillReturnResult(sqlmock.NewResult(0, test.deleted))
+				// The count is only decremented if a like was removed.
+				if test.deleted > 0 {
+					mock.ExpectExec("UPDATE likes_count SET count = count - 1").
+						WithArgs(test.entityType, entityID[:]).
+						WillReturnResult(sqlmock.NewResult(0, 1))
+					mock.ExpectCommit()
+				} else {
+					mock.ExpectRollback()
+				}
+			}
+
+			handler := commonmw.ParseUserID(handlers.DeleteLike(likeService))
+			req := httptest.NewRequest(http.MethodDelete, "/"+test.entityType+"/"+entityID.String()+"/likes", nil)
+			req = mux.SetURLVars(req, map[string]string{"entity_id": entityID.String()})
+			req.Header.Set("X-User-Id", userID.String())
+			resp := httptest.NewRecorder()
+			handler.ServeHTTP(resp, req)
+
+			is.Equal(resp.Code, test.code)
+			var respBody map[string]interface{}
+			is.NoErr(json.NewDecoder(resp.Body).Decode(&respBody))
+			is.Equal(respBody["status"], test.status)
+			if test.errMessage != "" {
+				is.Equal(respBody["message"], test.errMessage)
+			}
+			is.NoErr(mock.ExpectationsWereMet())
+		})
+	}
+}
@@ -25,6 +25,8 @@ import (
 type CreatePostRequestBody struct {
 	Body   string                `json:"body"`
 	Images []model.ImageLocation `json:"images"`
+	// Defaults to public.
+	Visibility model.Visibility `json:"visibility"`
 }
 
 func (post *CreatePostRequestBody) Validate() error {
@@ -57,6 +59,12 @@ func (post *CreatePostRequestBody) Validate() error {
 				}),
 			),
 		),
+		ozzo.Field(
+			&post.Visibility,
+			ozzo.In(
+				model.VisibilityPublic, model.VisibilityFollowers, model.VisibilityCloseFriends, model.VisibilityOnlyMe,
+			).Error("must be one of public, followers, close_friends, only_me"),
+		),
 	)
 }
 
@@ -92,7 +100,10 @@ func CreatePost(validator validation.Validator, postService service.Post) http.H
 			return
 		}
 
-		id, err := postService.Create(r.Context(), post.Body, authorID, post.Images)
+		if post.Visibility == "" {
+			post.Visibility = model.VisibilityPublic
+		}
+		id, err := postService.Create(r.Context(), post.Body, authorID, post.Images, post.Visibility)
 		if errors.Is(err, service.ErrInvalidImage) {
 			jsonresp.Error(w, "One or more provided image locations are invalid or inaccessible", http.StatusBadRequest)
 			log.Println(err)
@@ -130,12 +141,22 @@ func GetPost(postService service.Post) http.Handler {
 			return
 		}
 
-		post, err := postService.GetWithCounts(r.Context(), postID)
+		viewerID, err := commonmw.GetUserID(r.Context())
+		if err != nil {
+			log.Println(err)
+			jsonresp.ErrorWithDefaultMessage(w, http.StatusInternalServerError)
+			return
+		}
+		post, err := postService.GetWithCounts(r.Context(), viewerID, postID)
 		if errors.Is(err, service.ErrPostNotFound) {
 			jsonresp.Error(w, "Post not found", http.StatusNotFound)
 			log.Println(err)
 			return
 		}
+		if errors.Is(err, service.ErrPrivateAuthor) {
+			jsonresp.Error(w, "This account is private", http.StatusForbidden)
+			return
+		}
 		if errors.Is(err, context.DeadlineExceeded) {
 			log.Println(err)
 			jsonresp.ErrorWithDefaultMessage(w, http.StatusRequestTimeout)
@@ -220,3 +241,128 @@ func GetFeed(postService service.Post) http.HandlerFunc {
 		jsonresp.Response(w, response, http.StatusOK)
 	}
 }
+
+type UpdatePostRequestBody struct {
+	Body *string `json:"body"`
+}
+
+func (post *UpdatePostRequestBody) Validate() error {
+	return ozzo.ValidateStruct(
+		post,
+		ozzo.Field(&post.Body, ozzo.NotNil, ozzo.Length(0, 5000)),
+	)
+}
+
+func UpdatePost(validator validation.Validator, postService service.Post) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		postID, err := uuid.Parse(mux.Vars(r)["post_id"])
+		if err != nil {
+			jsonresp.Error(w, fmt.Sprintf("Invalid post ID: %s", err.Error()), http.StatusBadRequest)
+			return
+		}
+
+		var post UpdatePostRequestBody
+		err = json.NewDecoder(r.Body).Decode(&post)
+		if err != nil {
+			jsonresp.Error(w, "Invalid JSON body", http.StatusBadRequest)
+			return
+		}
+		err = validator.Validate(&post)
+		if errs, ok := err.(ozzo.Errors); ok {
+			errs := errs.Filter().(ozzo.Errors)
+			jsonresp.ValidationError(w, errs, http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			log.Println(err)
+			jsonresp.ErrorWithDefaultMessage(w, http.StatusInternalServerError)
+			return
+		}
+
+		userID, err := commonmw.GetUserID(r.Context())
+		if err != nil {
+			log.Println(err)
+			jsonresp.ErrorWithDefaultMessage(w, http.StatusInternalServerError)
+			return
+		}
+
+		err = postService.Update(r.Context(), postID, userID, *post.Body)
+		if errors.Is(err, service.ErrPostNotFound) {
+			jsonresp.Error(w, "Post not found", http.StatusNotFound)
+			log.Println(err)
+			return
+		}
+		if errors.Is(err, service.ErrNotPostAuthor) {
+			jsonresp.Error(w, "Only the author can edit the post", http.StatusForbidden)
+			return
+		}
+		if errors.Is(err, service.ErrPostEmpty) {
+			jsonresp.Error(w, "Body cannot be empty for a post without images", http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			log.Println(err)
+			jsonresp.ErrorWithDefaultMessage(w, http.StatusRequestTimeout)
+			return
+		}
+		if errors.Is(err, context.Canceled) {
+			// client disconnected
+			log.Println(err)
+			return
+		}
+		if err != nil {
+			log.Println(err)
+			jsonresp.ErrorWithDefaultMessage(w, http.StatusInternalServerError)
+			return
+		}
+
+		response := map[string]interface{}{"status": "success"}
+		jsonresp.Response(w, response, http.StatusOK)
+	})
+}
+
+func DeletePost(postService service.Post) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		postID, err := uuid.Parse(mux.Vars(r)["post_id"])
+		if err != nil {
+			jsonresp.Error(w, fmt.Sprintf("Invalid post ID: %s", err.Error()), http.StatusBadRequest)
+			return
+		}
+
+		userID, err := commonmw.GetUserID(r.Context())
+		if err != nil {
+			log.Println(err)
+			jsonresp.ErrorWithDefaultMessage(w, http.StatusInternalServerError)
+			return
+		}
+
+		err = postService.Delete(r.Context(), postID, userID)
+		if errors.Is(err, service.ErrPostNotFound) {
+			jsonresp.Error(w, "Post not found", http.StatusNotFound)
+			log.Println(err)
+			return
+		}
+		if errors.Is(err, service.ErrNotPostAuthor) {
+			jsonresp.Error(w, "Only the author can delete the post", http.StatusForbidden)
+			return
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			log.Println(err)
+			jsonresp.ErrorWithDefaultMessage(w, http.StatusRequestTimeout)
+			return
+		}
+		if errors.Is(err, context.Canceled) {
+			// client disconnected
+			log.Println(err)
+			return
+		}
+		if err != nil {
+			log.Println(err)
+			jsonresp.ErrorWithDefaultMessage(w, http.StatusInternalServerError)
+			return
+		}
+
+		response := map[string]interface{}{"status": "success"}
+		jsonresp.Response(w, response, http.StatusOK)
+	})
+}
@@ -66,7 +66,7 @@ func TestCreatePost(t *testing.T) {
 			m := mocks.NewMockPost(ctrl)
 			m.
 				EXPECT().
-				Create(gomock.Any(), reqBody.Body, userID, gomock.Len(len(reqBody.Images))).
+				Create(gomock.Any(), reqBody.Body, userID, gomock.Len(len(reqBody.Images)), model.VisibilityPublic).
 				Return(uuid.Nil, err)
 			return m
 		}
@@ -89,7 +89,7 @@ func TestCreatePost(t *testing.T) {
 				m := mocks.NewMockPost(ctrl)
 				m.
 					EXPECT().
-					Create(gomock.Any(), reqBody.Body, userID, gomock.Len(len(reqBody.Images))).
+					Create(gomock.Any(), reqBody.Body, userID, gomock.Len(len(reqBody.Images)), model.VisibilityPublic).
 					Return(returnedPostID, nil)
 				return m
 			},
@@ -104,7 +104,7 @@ func TestCreatePost(t *testing.T) {
 				m := mocks.NewMockPost(ctrl)
 				m.
 					EXPECT().
-					Create(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+					Create(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
 					Times(0)
 				return m
 			},
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"smapp/common/jsonresp"
+	commonmw "smapp/common/middleware"
+	"smapp/post/model"
+	"smapp/post/service"
+	"strconv"
+
+	"github.com/google/uuid"
+)
+
+func SearchPosts(postService service.Post) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The cursor is omitted when loading the first page.
+		var cursor *model.SearchCursor
+		if r.URL.Query().Has("last_loaded_score") || r.URL.Query().Has("last_loaded_id") {
+			lastLoadedScore, err := strconv.ParseFloat(r.URL.Query().Get("last_loaded_score"), 64)
+			if err != nil {
+				jsonresp.Error(w, "last_loaded_score: should be a number", http.StatusBadRequest)
+				return
+			}
+			lastLoadedID, err := uuid.Parse(r.URL.Query().Get("last_loaded_id"))
+			if err != nil {
+				jsonresp.Error(w, fmt.Sprintf("Invalid last_loaded_id: %s", err.Error()), http.StatusBadRequest)
+				return
+			}
+			cursor = &model.SearchCursor{
+				LastLoadedScore: lastLoadedScore,
+				LastLoadedID:    lastLoadedID,
+			}
+		}
+		limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+		if err != nil {
+			jsonresp.Error(w, "limit: should be an integer", http.StatusBadRequest)
+			return
+		}
+
+		viewerID, err := commonmw.GetUserID(r.Context())
+		if err != nil {
+			log.Println(err)
+			jsonresp.ErrorWithDefaultMessage(w, http.StatusInternalServerError)
+			return
+		}
+		posts, nextCursor, err := postService.Search(r.Context(), viewerID, r.URL.Query().Get("q"), cursor, limit)
+		if errors.Is(err, service.ErrSearchQueryInvalid) {
+			jsonresp.Error(w, fmt.Sprintf("q: %s", err.Error()), http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, service.ErrSearchPaginationLimitInvalid) {
+			jsonresp.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			log.Println(err)
+			jsonresp.ErrorWithDefaultMessage(w, http.StatusRequestTimeout)
+			return
+		}
+		if errors.Is(err, context.Canceled) {
+			// client disconnected
+			log.Println(err)
+			return
+		}
+		if err != nil {
+			log.Println(err)
+			jsonresp.ErrorWithDefaultMessage(w, http.StatusInternalServerError)
+			return
+		}
+
+		response := map[string]interface{}{
+			"status": "success",
+			"data": map[string]interface{}{
+				"posts":       posts,
+				"next_cursor": nextCursor,
+			},
+		}
+		jsonresp.Response(w, response, http.StatusOK)
+	})
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"smapp/common/jsonresp"
+	commonmw "smapp/common/middleware"
+	"smapp/post/config"
+	"smapp/post/model"
+	"smapp/post/service"
+	"time"
+)
+
+// Should be wrapped in WithoutRequestContextTimeout. Each poll of the stream is limited by pollTimeout instead.
+func StreamFeed(streamService *service.Stream, pollTimeout time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, err := commonmw.GetUserID(r.Context())
+		if err != nil {
+			log.Println(err)
+			jsonresp.ErrorWithDefaultMessage(w, http.StatusInternalServerError)
+			return
+		}
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			log.Println("response writer does not support flushing")
+			jsonresp.ErrorWithDefaultMessage(w, http.StatusInternalServerError)
+			return
+		}
+
+		// Sent by EventSource when it reconnects.
+		cursor := streamService.NewCursor()
+		if lastEventID := r.Header.Get("Last-Event-ID"); lastEventID != "" {
+			if cursor, err = decodeStreamCursor(lastEventID); err != nil {
+				jsonresp.Error(w, "Invalid Last-Event-ID", http.StatusBadRequest)
+				return
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), pollTimeout)
+		session, err := streamService.Open(ctx, userID, cursor)
+		cancel()
+		if errors.Is(err, context.DeadlineExceeded) {
+			log.Println(err)
+			jsonresp.ErrorWithDefaultMessage(w, http.StatusRequestTimeout)
+			return
+		}
+		if errors.Is(err, context.Canceled) {
+			// client disconnected
+			log.Println(err)
+			return
+		}
+		if err != nil {
+			log.Println(err)
+			jsonresp.ErrorWithDefaultMessage(w, http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		lastWrite := time.Now()
+		for {
+			ctx, cancel := context.WithTimeout(r.Context(), pollTimeout)
+			events, more, err := session.Poll(ctx)
+			cancel()
+			if r.Context().Err() != nil {
+				// client disconnected
+				return
+			}
+			if err != nil {
+				// The client reconnects with the ID of the last event it has received.
+				log.Println(err)
+				return
+			}
+
+			for _, event := range events {
+				if err = writeStreamEvent(w, event); err != nil {
+					log.Println(err)
+					return
+				}
+			}
+			if len(events) > 0 {
+				lastWrite = time.Now()
+			} else if time.Since(lastWrite) >= config.StreamKeepAliveInterval {
+				if _, err = fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+					return
+				}
+				lastWrite = time.Now()
+			}
+			flusher.Flush()
+
+			if more {
+				continue
+			}
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(config.StreamPollInterval):
+			}
+		}
+	})
+}
+
+func writeStreamEvent(w http.ResponseWriter, event model.StreamEvent) error {
+	fail := func(err error) error {
+		return fmt.Errorf("write stream event: %w", err)
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fail(err)
+	}
+	id, err := encodeStreamCursor(event.Cursor)
+	if err != nil {
+		return fail(err)
+	}
+	if _, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", id, event.Type, data); err != nil {
+		return fail(err)
+	}
+	return nil
+}
+
+// The event ID is opaque to clients, who only send it back in Last-Event-ID.
+func encodeStreamCursor(cursor model.StreamCursor) (string, error) {
+	data, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeStreamCursor(id string) (model.StreamCursor, error) {
+	var cursor model.StreamCursor
+	data, err := base64.RawURLEncoding.DecodeString(id)
+	if err != nil {
+		return cursor, err
+	}
+	err = json.Unmarshal(data, &cursor)
+	return cursor, err
+}
@@ -0,0 +1,35 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	commonmw "smapp/common/middleware"
+	"smapp/post/handlers"
+	"smapp/post/service"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/matryer/is"
+)
+
+func TestStreamFeedInvalidLastEventID(t *testing.T) {
+	is := is.New(t)
+
+	handler := commonmw.WithRequestContextTimeout(time.Second)(
+		commonmw.ParseUserID(commonmw.WithoutRequestContextTimeout(
+			handlers.StreamFeed(service.NewStream(nil, nil, nil, nil), time.Second),
+		)),
+	)
+	req := httptest.NewRequest(http.MethodGet, "/feed/stream", nil)
+	req.Header.Set("X-User-Id", uuid.NewString())
+	req.Header.Set("Last-Event-ID", "not a cursor")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	is.Equal(rr.Code, http.StatusBadRequest)
+	var body map[string]interface{}
+	is.NoErr(json.NewDecoder(rr.Body).Decode(&body))
+	is.Equal(body["message"], "Invalid Last-Event-ID")
+}
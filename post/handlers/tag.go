@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"smapp/common/jsonresp"
+	commonmw "smapp/common/middleware"
+	"smapp/post/model"
+	"smapp/post/service"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+func GetTagPosts(tagService *service.Tag) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lastLoadedTimestamp, err := time.Parse(time.RFC3339, r.URL.Query().Get("last_loaded_timestamp"))
+		if err != nil {
+			jsonresp.Error(w, fmt.Sprintf("last_loaded_timestamp: should be in format %s", time.RFC3339), http.StatusBadRequest)
+			return
+		}
+		lastLoadedID, err := uuid.Parse(r.URL.Query().Get("last_loaded_id"))
+		if err != nil {
+			jsonresp.Error(w, fmt.Sprintf("Invalid last_loaded_id: %s", err.Error()), http.StatusBadRequest)
+			return
+		}
+		limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+		if err != nil {
+			jsonresp.Error(w, "limit: should be an integer", http.StatusBadRequest)
+			return
+		}
+
+		cursor := model.Cursor{
+			LastLoadedTimestamp: lastLoadedTimestamp,
+			LastLoadedID:        lastLoadedID,
+		}
+		viewerID, err := commonmw.GetUserID(r.Context())
+		if err != nil {
+			log.Println(err)
+			jsonresp.ErrorWithDefaultMessage(w, http.StatusInternalServerError)
+			return
+		}
+		posts, nextCursor, err := tagService.GetPosts(r.Context(), viewerID, mux.Vars(r)["tag"], cursor, limit)
+		if errors.Is(err, service.ErrTagInvalid) {
+			jsonresp.Error(w, "Invalid tag", http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, service.ErrPostsPaginationLimitInvalid) {
+			jsonresp.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			log.Println(err)
+			jsonresp.ErrorWithDefaultMessage(w, http.StatusRequestTimeout)
+			return
+		}
+		if errors.Is(err, context.Canceled) {
+			// client disconnected
+			log.Println(err)
+			return
+		}
+		if err != nil {
+			log.Println(err)
+			jsonresp.ErrorWithDefaultMessage(w, http.StatusInternalServerError)
+			return
+		}
+
+		response := map[string]interface{}{
+			"status": "success",
+			"data": map[string]interface{}{
+				"posts":       posts,
+				"next_cursor": nextCursor,
+			},
+		}
+		jsonresp.Response(w, response, http.StatusOK)
+	})
+}
+
+func GetTrendingTags(tagService *service.Tag) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tags, err := tagService.GetTrending(r.Context())
+		if errors.Is(err, context.DeadlineExceeded) {
+			log.Println(err)
+			jsonresp.ErrorWithDefaultMessage(w, http.StatusRequestTimeout)
+			return
+		}
+		if errors.Is(err, context.Canceled) {
+			// client disconnected
+			log.Println(err)
+			return
+		}
+		if err != nil {
+			log.Println(err)
+			jsonresp.ErrorWithDefaultMessage(w, http.StatusInternalServerError)
+			return
+		}
+
+		response := map[string]interface{}{
+			"status": "success",
+			"data":   map[string]interface{}{"tags": tags},
+		}
+		jsonresp.Response(w, response, http.StatusOK)
+	})
+}
@@ -0,0 +1,2 @@
+-- Who can see the post: everyone, followers of the author, users on the author's close friends list or only the author
+ALTER TABLE posts ADD COLUMN visibility ENUM('public', 'followers', 'close_friends', 'only_me') NOT NULL DEFAULT 'public';
@@ -0,0 +1,22 @@
+-- Materialized home timelines, filled on post creation by fanning out to the author's followers
+CREATE TABLE timelines (
+    user_id BINARY(16) NOT NULL,
+    post_id BINARY(16) NOT NULL,
+    author_id BINARY(16) NOT NULL,
+    -- Copy of posts.created_at, so that the feed can be paginated with a range scan on this table only
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, post_id),
+    INDEX user_created_at_index (user_id, created_at DESC, post_id),
+    -- Index to remove the author's posts from the timeline on unfollow
+    INDEX user_author_index (user_id, author_id),
+    INDEX post_id_index (post_id)
+);
+
+-- Authors with too many followers to fan out to. Their posts are merged into the feed on read instead.
+CREATE TABLE high_follower_authors (
+    author_id BINARY(16) PRIMARY KEY,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Index to speed up fetching the latest posts of specific authors
+CREATE INDEX author_created_at_index ON posts (author_id, created_at DESC, id);
@@ -0,0 +1,2 @@
+-- Index to speed up loading images of a page of posts, ordered by position
+CREATE INDEX post_id_position_index ON images (post_id, position);
@@ -0,0 +1,15 @@
+-- Replies are comments with a parent. Only one level of nesting is stored: replies to replies are attached to the top-level comment.
+ALTER TABLE comments
+    ADD COLUMN parent_id BINARY(16) NULL,
+    ADD FOREIGN KEY (parent_id) REFERENCES comments(id),
+    -- Index to speed up ORDER BY when fetching paginated top-level comments of a post and replies of a comment
+    ADD INDEX post_parent_created_at_index (post_id, parent_id, created_at DESC, id),
+    ADD INDEX parent_created_at_index (parent_id, created_at DESC, id);
+
+CREATE TABLE replies_count (
+    id BINARY(16) PRIMARY KEY,
+    comment_id BINARY(16) NOT NULL,
+    count INT UNSIGNED NOT NULL DEFAULT 0,
+    UNIQUE KEY comment_id_unique (comment_id),
+    FOREIGN KEY (comment_id) REFERENCES comments(id)
+);
@@ -0,0 +1,2 @@
+-- Index for full-text search over post bodies
+ALTER TABLE posts ADD FULLTEXT INDEX body_fulltext_index (body);
@@ -0,0 +1,12 @@
+-- created_at is copied from the post, so that posts with a tag can be paginated and tags ranked without joining posts
+CREATE TABLE post_tags (
+    tag VARCHAR(100) NOT NULL,
+    post_id BINARY(16) NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (tag, post_id),
+    -- Index to speed up ORDER BY when fetching paginated posts with a tag
+    INDEX tag_created_at_index (tag, created_at DESC, post_id),
+    -- Index to speed up counting tag usage over a time window
+    INDEX created_at_tag_index (created_at, tag),
+    FOREIGN KEY (post_id) REFERENCES posts(id)
+);
@@ -0,0 +1,9 @@
+-- char_offset and char_length are in Unicode code points of the body and include the '@'
+CREATE TABLE mentions (
+    entity_type ENUM('posts', 'comments') NOT NULL,
+    entity_id BINARY(16) NOT NULL,
+    char_offset INT UNSIGNED NOT NULL,
+    char_length INT UNSIGNED NOT NULL,
+    user_id BINARY(16) NOT NULL,
+    PRIMARY KEY (entity_type, entity_id, char_offset)
+);
@@ -0,0 +1,2 @@
+-- Index for the feed stream, which reads likes created after its cursor
+CREATE INDEX entity_type_created_at_index ON likes (entity_type, created_at, id);
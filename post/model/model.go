@@ -14,23 +14,55 @@ const (
 	CommentType EntityType = "comments"
 )
 
+type Author struct {
+	ID     uuid.UUID `json:"id"`
+	Name   string    `json:"name"`
+	Handle string    `json:"handle"`
+	// Resolved by the user service. Empty if the author has no profile image.
+	ImageURL string `json:"image_url,omitempty"`
+}
+
+// Audience of a post. The author can always see their posts.
+type Visibility string
+
+const (
+	VisibilityPublic       Visibility = "public"
+	VisibilityFollowers    Visibility = "followers"
+	VisibilityCloseFriends Visibility = "close_friends"
+	VisibilityOnlyMe       Visibility = "only_me"
+)
+
 type Post struct {
 	ID           uuid.UUID       `json:"id"`
 	AuthorID     uuid.UUID       `json:"author_id"`
+	Author       *Author         `json:"author,omitempty"`
 	Body         string          `json:"body"`
 	Images       []ImageLocation `json:"images"`
+	Mentions     []Mention       `json:"mentions"`
+	Visibility   Visibility      `json:"visibility"`
 	CreatedAt    time.Time       `json:"created_at"`
 	CommentCount *uint32         `json:"comment_count,omitempty"`
 	LikeCount    *uint32         `json:"like_count,omitempty"`
 }
 
 type Comment struct {
-	ID        uuid.UUID `json:"id"`
-	PostID    uuid.UUID `json:"post_id"`
-	AuthorID  uuid.UUID `json:"author_id"`
-	Body      string    `json:"body"`
-	CreatedAt time.Time `json:"created_at"`
-	LikeCount *uint32   `json:"like_count,omitempty"`
+	ID         uuid.UUID  `json:"id"`
+	PostID     uuid.UUID  `json:"post_id"`
+	ParentID   *uuid.UUID `json:"parent_id,omitempty"`
+	AuthorID   uuid.UUID  `json:"author_id"`
+	Author     *Author    `json:"author,omitempty"`
+	Body       string     `json:"body"`
+	Mentions   []Mention  `json:"mentions"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LikeCount  *uint32    `json:"like_count,omitempty"`
+	ReplyCount *uint32    `json:"reply_count,omitempty"`
+}
+
+// Offset and Length are in Unicode code points of the body and include the '@'.
+type Mention struct {
+	UserID uuid.UUID `json:"user_id"`
+	Offset int       `json:"offset"`
+	Length int       `json:"length"`
 }
 
 type Cursor struct {
@@ -38,6 +70,52 @@ type Cursor struct {
 	LastLoadedID        uuid.UUID `json:"last_loaded_id"`
 }
 
+type StreamEventType string
+
+const (
+	StreamPostCreated   StreamEventType = "post"
+	StreamPostLiked     StreamEventType = "like"
+	StreamPostCommented StreamEventType = "comment"
+)
+
+// Position of the feed stream in each of its sources. Sent to clients as the ID of each event, so that they can resume.
+type StreamCursor struct {
+	Posts    Cursor `json:"posts"`
+	Likes    Cursor `json:"likes"`
+	Comments Cursor `json:"comments"`
+}
+
+// Something that happened in a source of the feed stream. ID is the ID of the post, like or comment.
+type StreamItem struct {
+	ID        uuid.UUID
+	PostID    uuid.UUID
+	ActorID   uuid.UUID
+	CreatedAt time.Time
+}
+
+type StreamEvent struct {
+	Type      StreamEventType `json:"type"`
+	PostID    uuid.UUID       `json:"post_id"`
+	Post      *Post           `json:"post,omitempty"`
+	CommentID *uuid.UUID      `json:"comment_id,omitempty"`
+	ActorID   uuid.UUID       `json:"actor_id"`
+	Actor     *Author         `json:"actor,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+	// Cursor of the stream right after this event.
+	Cursor StreamCursor `json:"-"`
+}
+
+type TagUsage struct {
+	Tag       string `json:"tag"`
+	PostCount uint32 `json:"post_count"`
+}
+
+// Search results are ordered by score, so the score of the last loaded result is used instead of its creation time.
+type SearchCursor struct {
+	LastLoadedScore float64   `json:"last_loaded_score"`
+	LastLoadedID    uuid.UUID `json:"last_loaded_id"`
+}
+
 type ImageLocation struct {
 	Bucket string `json:"bucket"`
 	Key    string `json:"key"`
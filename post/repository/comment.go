@@ -7,6 +7,9 @@ import (
 	"fmt"
 	"smapp/post/model"
 
+	eventsPB "smapp/common/grpc/events"
+	"smapp/common/outbox"
+
 	"github.com/go-sql-driver/mysql"
 	"github.com/google/uuid"
 )
@@ -19,7 +22,9 @@ func NewComment(db *sql.DB) *Comment {
 	return &Comment{db: db}
 }
 
-func (c *Comment) Create(ctx context.Context, postID, authorID uuid.UUID, body string) (uuid.UUID, error) {
+func (c *Comment) Create(
+	ctx context.Context, postID, authorID uuid.UUID, body string, mentions []model.Mention,
+) (uuid.UUID, error) {
 	fail := func(err error) (uuid.UUID, error) {
 		return uuid.Nil, fmt.Errorf("add comment to db: %w", err)
 	}
@@ -30,6 +35,15 @@ func (c *Comment) Create(ctx context.Context, postID, authorID uuid.UUID, body s
 	}
 	defer tx.Rollback()
 
+	var postAuthorID uuid.UUID
+	err = tx.QueryRowContext(ctx, "SELECT author_id FROM posts WHERE id = ?", postID[:]).Scan(&postAuthorID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return uuid.Nil, ErrPostIDNotFound
+	}
+	if err != nil {
+		return fail(changeErrIfCtxDone(ctx, err))
+	}
+
 	id, err := uuid.NewRandom()
 	if err != nil {
 		return fail(err)
@@ -41,6 +55,7 @@ func (c *Comment) Create(ctx context.Context, postID, authorID uuid.UUID, body s
 		id[:], postID[:], authorID[:], body,
 	)
 	var mysqlError *mysql.MySQLError
+	// The post could have been deleted after it was read.
 	if errors.As(err, &mysqlError) && mysqlError.Number == 1452 {
 		return uuid.Nil, ErrPostIDNotFound
 	}
@@ -48,6 +63,10 @@ func (c *Comment) Create(ctx context.Context, postID, authorID uuid.UUID, body s
 		return fail(changeErrIfCtxDone(ctx, err))
 	}
 
+	if err = insertMentions(ctx, tx, model.CommentType, id, mentions); err != nil {
+		return fail(changeErrIfCtxDone(ctx, err))
+	}
+
 	countID, err := uuid.NewRandom()
 	if err != nil {
 		return fail(err)
@@ -61,12 +80,256 @@ func (c *Comment) Create(ctx context.Context, postID, authorID uuid.UUID, body s
 		return fail(changeErrIfCtxDone(ctx, err))
 	}
 
+	err = outbox.Add(ctx, tx, &eventsPB.Event{
+		Payload: &eventsPB.Event_CommentCreated{CommentCreated: &eventsPB.CommentCreated{
+			CommentId:        id[:],
+			PostId:           postID[:],
+			PostAuthorId:     postAuthorID[:],
+			AuthorId:         authorID[:],
+			MentionedUserIds: mentionedUserIDs(mentions),
+		}},
+	})
+	if err != nil {
+		return fail(changeErrIfCtxDone(ctx, err))
+	}
+
 	if err = tx.Commit(); err != nil {
 		return fail(changeErrIfCtxDone(ctx, err))
 	}
 	return id, nil
 }
 
+// Replies to replies are attached to the top-level comment, so threads are only one level deep.
+func (c *Comment) CreateReply(
+	ctx context.Context, parentID, authorID uuid.UUID, body string, mentions []model.Mention,
+) (uuid.UUID, error) {
+	fail := func(err error) (uuid.UUID, error) {
+		return uuid.Nil, fmt.Errorf("add reply to db: %w", err)
+	}
+
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fail(err)
+	}
+	defer tx.Rollback()
+
+	var postID, postAuthorID, parentAuthorID uuid.UUID
+	var grandparentID uuid.NullUUID
+	err = tx.QueryRowContext(
+		ctx,
+		"SELECT c.post_id, c.parent_id, c.author_id, p.author_id FROM comments c JOIN posts p ON p.id = c.post_id WHERE c.id = ?",
+		parentID[:],
+	).Scan(&postID, &grandparentID, &parentAuthorID, &postAuthorID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return uuid.Nil, ErrCommentIDNotFound
+	}
+	if err != nil {
+		return fail(changeErrIfCtxDone(ctx, err))
+	}
+	// The event refers to the comment that was replied to, even if the reply is attached to its parent.
+	repliedCommentID := parentID
+	if grandparentID.Valid {
+		parentID = grandparentID.UUID
+	}
+
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return fail(err)
+	}
+	_, err = tx.ExecContext(
+		ctx,
+		"INSERT INTO comments (id, post_id, parent_id, author_id, body) VALUES (?, ?, ?, ?, ?)",
+		id[:], postID[:], parentID[:], authorID[:], body,
+	)
+	var mysqlError *mysql.MySQLError
+	// The parent could have been deleted after it was read.
+	if errors.As(err, &mysqlError) && mysqlError.Number == 1452 {
+		return uuid.Nil, ErrCommentIDNotFound
+	}
+	if err != nil {
+		return fail(changeErrIfCtxDone(ctx, err))
+	}
+
+	if err = insertMentions(ctx, tx, model.CommentType, id, mentions); err != nil {
+		return fail(changeErrIfCtxDone(ctx, err))
+	}
+
+	countID, err := uuid.NewRandom()
+	if err != nil {
+		return fail(err)
+	}
+	_, err = tx.ExecContext(
+		ctx,
+		"INSERT INTO replies_count (id, comment_id, count) VALUES (?, ?, 1) ON DUPLICATE KEY UPDATE count = count + 1",
+		countID[:], parentID[:],
+	)
+	if err != nil {
+		return fail(changeErrIfCtxDone(ctx, err))
+	}
+
+	// Replies are included in the comment count of the post.
+	countID, err = uuid.NewRandom()
+	if err != nil {
+		return fail(err)
+	}
+	_, err = tx.ExecContext(
+		ctx,
+		"INSERT INTO comments_count (id, post_id, count) VALUES (?, ?, 1) ON DUPLICATE KEY UPDATE count = count + 1",
+		countID[:], postID[:],
+	)
+	if err != nil {
+		return fail(changeErrIfCtxDone(ctx, err))
+	}
+
+	err = outbox.Add(ctx, tx, &eventsPB.Event{
+		Payload: &eventsPB.Event_CommentCreated{CommentCreated: &eventsPB.CommentCreated{
+			CommentId:              id[:],
+			PostId:                 postID[:],
+			PostAuthorId:           postAuthorID[:],
+			AuthorId:               authorID[:],
+			RepliedCommentId:       repliedCommentID[:],
+			RepliedCommentAuthorId: parentAuthorID[:],
+			MentionedUserIds:       mentionedUserIDs(mentions),
+		}},
+	})
+	if err != nil {
+		return fail(changeErrIfCtxDone(ctx, err))
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fail(changeErrIfCtxDone(ctx, err))
+	}
+	return id, nil
+}
+
+// Returns the author of the comment and the author of the post it belongs to.
+// Also returns the visibility of the post, since whoever can see the comment has to be able to see the post.
+func (c *Comment) GetAuthorIDs(
+	ctx context.Context, id uuid.UUID,
+) (commentAuthorID, postAuthorID uuid.UUID, postVisibility model.Visibility, err error) {
+	fail := func(err error) (uuid.UUID, uuid.UUID, model.Visibility, error) {
+		return uuid.Nil, uuid.Nil, "", fmt.Errorf("get comment author ids from db: %w", err)
+	}
+
+	err = c.db.QueryRowContext(
+		ctx,
+		"SELECT c.author_id, p.author_id, p.visibility FROM comments c JOIN posts p ON p.id = c.post_id WHERE c.id = ?",
+		id[:],
+	).Scan(&commentAuthorID, &postAuthorID, &postVisibility)
+	if errors.Is(err, sql.ErrNoRows) {
+		return uuid.Nil, uuid.Nil, "", ErrRecordNotFound
+	}
+	if err != nil {
+		return fail(err)
+	}
+	return commentAuthorID, postAuthorID, postVisibility, nil
+}
+
+// Mentions of the comment are replaced with the given ones in the same transaction.
+func (c *Comment) Update(ctx context.Context, id uuid.UUID, body string, mentions []model.Mention) error {
+	fail := func(err error) error {
+		return fmt.Errorf("update comment in db: %w", err)
+	}
+
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fail(err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, "UPDATE comments SET body = ? WHERE id = ?", body, id[:])
+	if err != nil {
+		return fail(changeErrIfCtxDone(ctx, err))
+	}
+	if err = replaceMentions(ctx, tx, model.CommentType, id, mentions); err != nil {
+		return fail(changeErrIfCtxDone(ctx, err))
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fail(changeErrIfCtxDone(ctx, err))
+	}
+	return nil
+}
+
+// Deleting a top-level comment also deletes its replies.
+func (c *Comment) Delete(ctx context.Context, id uuid.UUID) error {
+	fail := func(err error) error {
+		return fmt.Errorf("delete comment from db: %w", err)
+	}
+
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fail(err)
+	}
+	defer tx.Rollback()
+
+	var postID uuid.UUID
+	var parentID uuid.NullUUID
+	err = tx.QueryRowContext(
+		ctx,
+		"SELECT post_id, parent_id FROM comments WHERE id = ? FOR UPDATE",
+		id[:],
+	).Scan(&postID, &parentID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrRecordNotFound
+	}
+	if err != nil {
+		return fail(changeErrIfCtxDone(ctx, err))
+	}
+
+	// Likes and like counts of the replies have to go before the replies themselves, since they are found through them.
+	queries := []string{
+		"DELETE FROM likes WHERE entity_type = 'comments' AND entity_id IN (SELECT id FROM comments WHERE parent_id = ?)",
+		"DELETE FROM likes_count WHERE entity_type = 'comments' AND entity_id IN (SELECT id FROM comments WHERE parent_id = ?)",
+		"DELETE FROM mentions WHERE entity_type = 'comments' AND entity_id IN (SELECT id FROM comments WHERE parent_id = ?)",
+		"DELETE FROM likes WHERE entity_type = 'comments' AND entity_id = ?",
+		"DELETE FROM likes_count WHERE entity_type = 'comments' AND entity_id = ?",
+		"DELETE FROM mentions WHERE entity_type = 'comments' AND entity_id = ?",
+		"DELETE FROM replies_count WHERE comment_id = ?",
+	}
+	for _, query := range queries {
+		if _, err = tx.ExecContext(ctx, query, id[:]); err != nil {
+			return fail(changeErrIfCtxDone(ctx, err))
+		}
+	}
+
+	result, err := tx.ExecContext(ctx, "DELETE FROM comments WHERE parent_id = ?", id[:])
+	if err != nil {
+		return fail(changeErrIfCtxDone(ctx, err))
+	}
+	deletedReplies, err := result.RowsAffected()
+	if err != nil {
+		return fail(err)
+	}
+	if _, err = tx.ExecContext(ctx, "DELETE FROM comments WHERE id = ?", id[:]); err != nil {
+		return fail(changeErrIfCtxDone(ctx, err))
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		"UPDATE comments_count SET count = GREATEST(CAST(count AS SIGNED) - ?, 0) WHERE post_id = ?",
+		deletedReplies+1, postID[:],
+	)
+	if err != nil {
+		return fail(changeErrIfCtxDone(ctx, err))
+	}
+	if parentID.Valid {
+		_, err = tx.ExecContext(
+			ctx,
+			"UPDATE replies_count SET count = count - 1 WHERE comment_id = ? AND count > 0",
+			parentID.UUID[:],
+		)
+		if err != nil {
+			return fail(changeErrIfCtxDone(ctx, err))
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fail(changeErrIfCtxDone(ctx, err))
+	}
+	return nil
+}
+
 func (c *Comment) CheckExists(ctx context.Context, id uuid.UUID) error {
 	fail := func(err error) error {
 		return fmt.Errorf("check if comment exists in db: %w", err)
@@ -87,42 +350,85 @@ func (c *Comment) CheckExists(ctx context.Context, id uuid.UUID) error {
 	return nil
 }
 
-func (c *Comment) GetPaginatedWithLikeCount(ctx context.Context, postID uuid.UUID, cursor model.Cursor, limit int) ([]model.Comment, *model.Cursor, error) {
-	fail := func(err error) ([]model.Comment, *model.Cursor, error) {
+// Returns top-level comments of the post, each with its reply count. Comments of excludedAuthorIDs are left out.
+func (c *Comment) GetPaginatedWithLikeCount(
+	ctx context.Context, postID uuid.UUID, excludedAuthorIDs []uuid.UUID, cursor model.Cursor, limit int,
+) ([]model.Comment, *model.Cursor, error) {
+	query := `
+		SELECT c.id, c.post_id, c.parent_id, c.author_id, c.body, c.created_at, IFNULL(lc.count, 0), IFNULL(rc.count, 0) 
+		FROM comments c 
+		LEFT JOIN likes_count lc ON lc.entity_type = 'comments' AND lc.entity_id = c.id 
+		LEFT JOIN replies_count rc ON rc.comment_id = c.id 
+		WHERE c.post_id = ? AND c.parent_id IS NULL AND (c.created_at < ? OR (c.created_at = ? AND c.id > ?))%s 
+		ORDER BY c.created_at DESC, c.id 
+		LIMIT ? 
+	`
+	comments, nextCursor, err := c.getPaginated(ctx, query, postID, excludedAuthorIDs, cursor, limit, true)
+	if err != nil {
 		return nil, nil, fmt.Errorf("get comments from db: %w", err)
 	}
+	return comments, nextCursor, nil
+}
 
+func (c *Comment) GetRepliesPaginatedWithLikeCount(
+	ctx context.Context, commentID uuid.UUID, excludedAuthorIDs []uuid.UUID, cursor model.Cursor, limit int,
+) ([]model.Comment, *model.Cursor, error) {
 	query := `
-		SELECT c.id, c.author_id, c.body, c.created_at, IFNULL(lc.count, 0) 
+		SELECT c.id, c.post_id, c.parent_id, c.author_id, c.body, c.created_at, IFNULL(lc.count, 0), 0 
 		FROM comments c 
 		LEFT JOIN likes_count lc ON lc.entity_type = 'comments' AND lc.entity_id = c.id 
-		WHERE c.post_id = ? AND (c.created_at < ? OR (c.created_at = ? AND c.id > ?)) 
+		WHERE c.parent_id = ? AND (c.created_at < ? OR (c.created_at = ? AND c.id > ?))%s 
 		ORDER BY c.created_at DESC, c.id 
 		LIMIT ? 
 	`
-	rows, err := c.db.QueryContext(
-		ctx,
-		query,
-		postID[:], cursor.LastLoadedTimestamp, cursor.LastLoadedTimestamp,
-		cursor.LastLoadedID[:], limit+1,
-	)
+	replies, nextCursor, err := c.getPaginated(ctx, query, commentID, excludedAuthorIDs, cursor, limit, false)
 	if err != nil {
-		return fail(err)
+		return nil, nil, fmt.Errorf("get replies from db: %w", err)
+	}
+	return replies, nextCursor, nil
+}
+
+// The query has a placeholder for the condition that excludes authors, right before the limit.
+func (c *Comment) getPaginated(
+	ctx context.Context,
+	query string,
+	parentID uuid.UUID,
+	excludedAuthorIDs []uuid.UUID,
+	cursor model.Cursor,
+	limit int,
+	withReplyCount bool,
+) ([]model.Comment, *model.Cursor, error) {
+	exclusion, exclusionArgs := notInCondition("c.author_id", excludedAuthorIDs)
+	args := []interface{}{parentID[:], cursor.LastLoadedTimestamp, cursor.LastLoadedTimestamp, cursor.LastLoadedID[:]}
+	args = append(append(args, exclusionArgs...), limit+1)
+	rows, err := c.db.QueryContext(ctx, fmt.Sprintf(query, exclusion), args...)
+	if err != nil {
+		return nil, nil, err
 	}
 	defer rows.Close()
 	comments := make([]model.Comment, 0)
 	for i := 0; i < limit && rows.Next(); i++ {
 		var comment model.Comment
-		var likeCount uint32
-		if err = rows.Scan(&comment.ID, &comment.AuthorID, &comment.Body, &comment.CreatedAt, &likeCount); err != nil {
-			return fail(err)
+		var commentParentID uuid.NullUUID
+		var likeCount, replyCount uint32
+		err = rows.Scan(
+			&comment.ID, &comment.PostID, &commentParentID, &comment.AuthorID, &comment.Body, &comment.CreatedAt,
+			&likeCount, &replyCount,
+		)
+		if err != nil {
+			return nil, nil, err
+		}
+		if commentParentID.Valid {
+			comment.ParentID = &commentParentID.UUID
 		}
-		comment.PostID = postID
 		comment.LikeCount = &likeCount
+		if withReplyCount {
+			comment.ReplyCount = &replyCount
+		}
 		comments = append(comments, comment)
 	}
 	if err = rows.Err(); err != nil {
-		return fail(err)
+		return nil, nil, err
 	}
 	var nextCursor *model.Cursor
 	// Given that we have loaded limit+1 elements and iterated over at most limit elements, rows.Next() == false means its the last page.
@@ -132,6 +438,21 @@ func (c *Comment) GetPaginatedWithLikeCount(ctx context.Context, postID uuid.UUI
 			LastLoadedID:        comments[len(comments)-1].ID,
 		}
 	}
+	// Release the connection before loading mentions.
+	rows.Close()
+
+	commentIDs := make([]uuid.UUID, len(comments))
+	for i, comment := range comments {
+		commentIDs[i] = comment.ID
+	}
+	mentions, err := getMentions(ctx, c.db, model.CommentType, commentIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range comments {
+		comments[i].Mentions = mentions[comments[i].ID]
+	}
+
 	return comments, nextCursor, nil
 }
 
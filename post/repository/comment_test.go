@@ -0,0 +1,96 @@
+package repository_test
+
+import (
+	"context"
+	"regexp"
+	"smapp/post/repository"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/matryer/is"
+)
+
+func TestCommentDeleteUpdatesCounts(t *testing.T) {
+	postID := uuid.New()
+	parentID := uuid.New()
+
+	tests := []struct {
+		name     string
+		parentID *uuid.UUID
+		replies  int64
+	}{
+		{
+			name:     "top-level comment with replies",
+			parentID: nil,
+			replies:  3,
+		},
+		{
+			name:     "reply",
+			parentID: &parentID,
+			replies:  0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			is := is.New(t)
+			db, mock, err := sqlmock.New()
+			is.NoErr(err)
+			defer db.Close()
+
+			id := uuid.New()
+			var parent interface{}
+			if tt.parentID != nil {
+				parent = tt.parentID[:]
+			}
+
+			mock.ExpectBegin()
+			mock.ExpectQuery("SELECT post_id, parent_id FROM comments").
+				WithArgs(id[:]).
+				WillReturnRows(sqlmock.NewRows([]string{"post_id", "parent_id"}).AddRow(postID[:], parent))
+			for i := 0; i < 7; i++ {
+				mock.ExpectExec("DELETE FROM").WithArgs(id[:]).WillReturnResult(sqlmock.NewResult(0, 0))
+			}
+			mock.ExpectExec(regexp.QuoteMeta("DELETE FROM comments WHERE parent_id = ?")).
+				WithArgs(id[:]).
+				WillReturnResult(sqlmock.NewResult(0, tt.replies))
+			mock.ExpectExec(regexp.QuoteMeta("DELETE FROM comments WHERE id = ?")).
+				WithArgs(id[:]).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			// The comment itself and its replies are subtracted from the comment count of the post.
+			mock.ExpectExec("UPDATE comments_count").
+				WithArgs(tt.replies+1, postID[:]).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			if tt.parentID != nil {
+				mock.ExpectExec("UPDATE replies_count").
+					WithArgs(tt.parentID[:]).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			}
+			mock.ExpectCommit()
+
+			comment := repository.NewComment(db)
+			is.NoErr(comment.Delete(context.TODO(), id))
+			is.NoErr(mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestCommentDeleteNotFound(t *testing.T) {
+	is := is.New(t)
+	db, mock, err := sqlmock.New()
+	is.NoErr(err)
+	defer db.Close()
+
+	id := uuid.New()
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT post_id, parent_id FROM comments").
+		WithArgs(id[:]).
+		WillReturnRows(sqlmock.NewRows([]string{"post_id", "parent_id"}))
+	mock.ExpectRollback()
+
+	comment := repository.NewComment(db)
+	err = comment.Delete(context.TODO(), id)
+	is.Equal(err, repository.ErrRecordNotFound)
+	is.NoErr(mock.ExpectationsWereMet())
+}
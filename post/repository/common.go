@@ -4,12 +4,17 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
 )
 
 var (
-	ErrRecordNotFound = errors.New("record not found")
-	ErrRecordExists   = errors.New("record already exists")
-	ErrPostIDNotFound = errors.New("id not found in posts table")
+	ErrRecordNotFound    = errors.New("record not found")
+	ErrRecordExists      = errors.New("record already exists")
+	ErrPostIDNotFound    = errors.New("id not found in posts table")
+	ErrCommentIDNotFound = errors.New("id not found in comments table")
 )
 
 // tx operations may return sql.ErrTxDone if the context is done and the transaction rollback has already completed. Return a context error instead for clarity in the service layer
@@ -19,3 +24,18 @@ func changeErrIfCtxDone(ctx context.Context, err error) error {
 	}
 	return err
 }
+
+// Returns a condition to be appended to a WHERE clause, which excludes rows whose column is one of the IDs, and its
+// arguments. Both are empty if there are no IDs.
+func notInCondition(column string, ids []uuid.UUID) (string, []interface{}) {
+	if len(ids) == 0 {
+		return "", nil
+	}
+	placeholders := make([]string, len(ids))
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		placeholders[i] = "?"
+		args[i] = id[:]
+	}
+	return fmt.Sprintf(" AND %s NOT IN (%s)", column, strings.Join(placeholders, ",")), args
+}
@@ -7,22 +7,47 @@ import (
 	"fmt"
 	"smapp/post/model"
 
+	eventsPB "smapp/common/grpc/events"
+	"smapp/common/outbox"
+
 	"github.com/google/uuid"
 )
 
 type Like struct {
 	db         *sql.DB
 	entityType model.EntityType
+	newEvent   func(entityID, entityAuthorID, userID uuid.UUID) *eventsPB.Event
 }
 
 func NewPostLike(db *sql.DB) *Like {
-	return &Like{db: db, entityType: model.PostType}
+	return &Like{
+		db:         db,
+		entityType: model.PostType,
+		newEvent: func(postID, postAuthorID, userID uuid.UUID) *eventsPB.Event {
+			return &eventsPB.Event{Payload: &eventsPB.Event_PostLiked{PostLiked: &eventsPB.PostLiked{
+				PostId:       postID[:],
+				PostAuthorId: postAuthorID[:],
+				UserId:       userID[:],
+			}}}
+		},
+	}
 }
 
 func NewCommentLike(db *sql.DB) *Like {
-	return &Like{db: db, entityType: model.CommentType}
+	return &Like{
+		db:         db,
+		entityType: model.CommentType,
+		newEvent: func(commentID, commentAuthorID, userID uuid.UUID) *eventsPB.Event {
+			return &eventsPB.Event{Payload: &eventsPB.Event_CommentLiked{CommentLiked: &eventsPB.CommentLiked{
+				CommentId:       commentID[:],
+				CommentAuthorId: commentAuthorID[:],
+				UserId:          userID[:],
+			}}}
+		},
+	}
 }
 
+// Returns ErrRecordNotFound if the liked entity does not exist.
 func (l *Like) Create(ctx context.Context, entityID, authorID uuid.UUID) error {
 	fail := func(err error) error {
 		return fmt.Errorf("add like to db: %w", err)
@@ -34,6 +59,20 @@ func (l *Like) Create(ctx context.Context, entityID, authorID uuid.UUID) error {
 	}
 	defer tx.Rollback()
 
+	// The entity type is also the name of its table.
+	var entityAuthorID uuid.UUID
+	err = tx.QueryRowContext(
+		ctx,
+		fmt.Sprintf("SELECT author_id FROM %s WHERE id = ?", l.entityType),
+		entityID[:],
+	).Scan(&entityAuthorID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrRecordNotFound
+	}
+	if err != nil {
+		return fail(changeErrIfCtxDone(ctx, err))
+	}
+
 	id, err := uuid.NewRandom()
 	if err != nil {
 		return fail(err)
@@ -68,6 +107,52 @@ func (l *Like) Create(ctx context.Context, entityID, authorID uuid.UUID) error {
 		return fail(changeErrIfCtxDone(ctx, err))
 	}
 
+	if err = outbox.Add(ctx, tx, l.newEvent(entityID, entityAuthorID, authorID)); err != nil {
+		return fail(changeErrIfCtxDone(ctx, err))
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fail(changeErrIfCtxDone(ctx, err))
+	}
+	return nil
+}
+
+func (l *Like) Delete(ctx context.Context, entityID, authorID uuid.UUID) error {
+	fail := func(err error) error {
+		return fmt.Errorf("delete like from db: %w", err)
+	}
+
+	tx, err := l.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fail(err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(
+		ctx,
+		"DELETE FROM likes WHERE entity_type = ? AND entity_id = ? AND author_id = ?",
+		l.entityType, entityID[:], authorID[:],
+	)
+	if err != nil {
+		return fail(changeErrIfCtxDone(ctx, err))
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fail(err)
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		"UPDATE likes_count SET count = count - 1 WHERE entity_type = ? AND entity_id = ? AND count > 0",
+		l.entityType, entityID[:],
+	)
+	if err != nil {
+		return fail(changeErrIfCtxDone(ctx, err))
+	}
+
 	if err = tx.Commit(); err != nil {
 		return fail(changeErrIfCtxDone(ctx, err))
 	}
@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"smapp/post/model"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+func insertMentions(
+	ctx context.Context, tx *sql.Tx, entityType model.EntityType, entityID uuid.UUID, mentions []model.Mention,
+) error {
+	if len(mentions) == 0 {
+		return nil
+	}
+	placeholders := make([]string, len(mentions))
+	args := make([]interface{}, 0, 5*len(mentions))
+	for i, mention := range mentions {
+		placeholders[i] = "(?, ?, ?, ?, ?)"
+		args = append(args, entityType, entityID[:], mention.Offset, mention.Length, mention.UserID[:])
+	}
+	_, err := tx.ExecContext(
+		ctx,
+		fmt.Sprintf(
+			"INSERT INTO mentions (entity_type, entity_id, char_offset, char_length, user_id) VALUES %s",
+			strings.Join(placeholders, ","),
+		),
+		args...,
+	)
+	return err
+}
+
+func replaceMentions(
+	ctx context.Context, tx *sql.Tx, entityType model.EntityType, entityID uuid.UUID, mentions []model.Mention,
+) error {
+	_, err := tx.ExecContext(
+		ctx,
+		"DELETE FROM mentions WHERE entity_type = ? AND entity_id = ?",
+		entityType, entityID[:],
+	)
+	if err != nil {
+		return err
+	}
+	return insertMentions(ctx, tx, entityType, entityID, mentions)
+}
+
+// Each mentioned user is listed once, however many times they are mentioned.
+func mentionedUserIDs(mentions []model.Mention) [][]byte {
+	seen := make(map[uuid.UUID]bool)
+	userIDs := make([][]byte, 0, len(mentions))
+	for _, mention := range mentions {
+		if !seen[mention.UserID] {
+			seen[mention.UserID] = true
+			userIDs = append(userIDs, mention.UserID[:])
+		}
+	}
+	return userIDs
+}
+
+// Loads mentions of all given entities in a single query. Entities without mentions are mapped to an empty slice.
+func getMentions(
+	ctx context.Context, db *sql.DB, entityType model.EntityType, entityIDs []uuid.UUID,
+) (map[uuid.UUID][]model.Mention, error) {
+	fail := func(err error) (map[uuid.UUID][]model.Mention, error) {
+		return nil, fmt.Errorf("get mentions from db: %w", err)
+	}
+
+	mentions := make(map[uuid.UUID][]model.Mention, len(entityIDs))
+	if len(entityIDs) == 0 {
+		return mentions, nil
+	}
+	placeholders := make([]string, len(entityIDs))
+	args := make([]interface{}, 0, len(entityIDs)+1)
+	args = append(args, entityType)
+	for i, entityID := range entityIDs {
+		mentions[entityID] = make([]model.Mention, 0)
+		placeholders[i] = "?"
+		args = append(args, entityID[:])
+	}
+
+	rows, err := db.QueryContext(
+		ctx,
+		fmt.Sprintf(
+			`
+			SELECT entity_id, char_offset, char_length, user_id 
+			FROM mentions 
+			WHERE entity_type = ? AND entity_id IN (%s) 
+			ORDER BY entity_id, char_offset
+			`,
+			strings.Join(placeholders, ","),
+		),
+		args...,
+	)
+	if err != nil {
+		return fail(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var entityID uuid.UUID
+		var mention model.Mention
+		if err = rows.Scan(&entityID, &mention.Offset, &mention.Length, &mention.UserID); err != nil {
+			return fail(err)
+		}
+		mentions[entityID] = append(mentions[entityID], mention)
+	}
+	if err = rows.Err(); err != nil {
+		return fail(err)
+	}
+	return mentions, nil
+}
@@ -7,6 +7,10 @@ import (
 	"fmt"
 	"smapp/post/model"
 	"strings"
+	"time"
+
+	eventsPB "smapp/common/grpc/events"
+	"smapp/common/outbox"
 
 	"github.com/google/uuid"
 )
@@ -14,10 +18,25 @@ import (
 //go:generate mockgen -destination mocks/post.go -package mocks . Post
 
 type Post interface {
-	Create(ctx context.Context, body string, authorID uuid.UUID, images []model.ImageLocation) (uuid.UUID, error)
+	Create(
+		ctx context.Context, body string, authorID uuid.UUID, images []model.ImageLocation,
+		visibility model.Visibility, tags []string, mentions []model.Mention, timelineUserIDs []uuid.UUID,
+	) (uuid.UUID, error)
 	CheckExists(ctx context.Context, id uuid.UUID) error
 	Get(ctx context.Context, id uuid.UUID) (model.Post, error)
-	GetWithCountsByUserIDs(ctx context.Context, userIDs []uuid.UUID, cursor model.Cursor, limit int) ([]model.Post, *model.Cursor, error)
+	GetByIDs(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]model.Post, error)
+	GetTimelineWithCounts(
+		ctx context.Context, userID uuid.UUID, extraAuthorIDs, excludedAuthorIDs []uuid.UUID, cursor model.Cursor,
+		limit int,
+	) ([]model.Post, *model.Cursor, error)
+	GetByTagWithCounts(ctx context.Context, tag string, cursor model.Cursor, limit int) ([]model.Post, *model.Cursor, error)
+	SearchWithCounts(
+		ctx context.Context, query string, cursor *model.SearchCursor, limit int,
+	) ([]model.Post, *model.SearchCursor, error)
+	GetAuthorID(ctx context.Context, id uuid.UUID) (uuid.UUID, error)
+	GetAuthorIDAndVisibility(ctx context.Context, id uuid.UUID) (uuid.UUID, model.Visibility, error)
+	Update(ctx context.Context, id uuid.UUID, body string, tags []string, mentions []model.Mention) error
+	Delete(ctx context.Context, id uuid.UUID) error
 }
 
 type DefaultPost struct {
@@ -28,7 +47,11 @@ func NewDefaultPost(db *sql.DB) *DefaultPost {
 	return &DefaultPost{db: db}
 }
 
-func (p *DefaultPost) Create(ctx context.Context, body string, authorID uuid.UUID, images []model.ImageLocation) (uuid.UUID, error) {
+// Tags, mentions, timeline entries of timelineUserIDs and the PostCreated event are added in the same transaction.
+func (p *DefaultPost) Create(
+	ctx context.Context, body string, authorID uuid.UUID, images []model.ImageLocation,
+	visibility model.Visibility, tags []string, mentions []model.Mention, timelineUserIDs []uuid.UUID,
+) (uuid.UUID, error) {
 	fail := func(err error) (uuid.UUID, error) {
 		return uuid.Nil, fmt.Errorf("add post to db: %w", err)
 	}
@@ -45,8 +68,8 @@ func (p *DefaultPost) Create(ctx context.Context, body string, authorID uuid.UUI
 	}
 	_, err = tx.ExecContext(
 		ctx,
-		"INSERT INTO posts (id, body, author_id) VALUES (?, ?, ?)",
-		id[:], body, authorID[:],
+		"INSERT INTO posts (id, body, author_id, visibility) VALUES (?, ?, ?, ?)",
+		id[:], body, authorID[:], visibility,
 	)
 	if err != nil {
 		return fail(changeErrIfCtxDone(ctx, err))
@@ -66,12 +89,65 @@ func (p *DefaultPost) Create(ctx context.Context, body string, authorID uuid.UUI
 			return fail(changeErrIfCtxDone(ctx, err))
 		}
 	}
+
+	// Tags and timeline entries copy the creation time of the post, so that they can be paginated without joining posts.
+	var createdAt time.Time
+	if len(tags) > 0 || len(timelineUserIDs) > 0 {
+		err = tx.QueryRowContext(ctx, "SELECT created_at FROM posts WHERE id = ?", id[:]).Scan(&createdAt)
+		if err != nil {
+			return fail(changeErrIfCtxDone(ctx, err))
+		}
+	}
+
+	if err = insertTags(ctx, tx, id, tags, createdAt); err != nil {
+		return fail(changeErrIfCtxDone(ctx, err))
+	}
+	if err = insertMentions(ctx, tx, model.PostType, id, mentions); err != nil {
+		return fail(changeErrIfCtxDone(ctx, err))
+	}
+
+	if len(timelineUserIDs) > 0 {
+		for start := 0; start < len(timelineUserIDs); start += timelineInsertBatchSize {
+			batch := timelineUserIDs[start:min(start+timelineInsertBatchSize, len(timelineUserIDs))]
+			placeholders := make([]string, len(batch))
+			args := make([]interface{}, 0, 4*len(batch))
+			for i, userID := range batch {
+				placeholders[i] = "(?, ?, ?, ?)"
+				args = append(args, userID[:], id[:], authorID[:], createdAt)
+			}
+			_, err = tx.ExecContext(
+				ctx,
+				fmt.Sprintf(
+					"INSERT INTO timelines (user_id, post_id, author_id, created_at) VALUES %s",
+					strings.Join(placeholders, ","),
+				),
+				args...,
+			)
+			if err != nil {
+				return fail(changeErrIfCtxDone(ctx, err))
+			}
+		}
+	}
+
+	err = outbox.Add(ctx, tx, &eventsPB.Event{
+		Payload: &eventsPB.Event_PostCreated{PostCreated: &eventsPB.PostCreated{
+			PostId:           id[:],
+			AuthorId:         authorID[:],
+			MentionedUserIds: mentionedUserIDs(mentions),
+		}},
+	})
+	if err != nil {
+		return fail(changeErrIfCtxDone(ctx, err))
+	}
+
 	if err = tx.Commit(); err != nil {
 		return fail(changeErrIfCtxDone(ctx, err))
 	}
 	return id, nil
 }
 
+const timelineInsertBatchSize = 1000
+
 func (p *DefaultPost) CheckExists(ctx context.Context, id uuid.UUID) error {
 	fail := func(err error) error {
 		return fmt.Errorf("check if post exists in db: %w", err)
@@ -92,26 +168,43 @@ func (p *DefaultPost) CheckExists(ctx context.Context, id uuid.UUID) error {
 	return nil
 }
 
-func (p *DefaultPost) getImagesByPostID(ctx context.Context, postID uuid.UUID) ([]model.ImageLocation, error) {
-	fail := func(err error) ([]model.ImageLocation, error) {
-		return nil, fmt.Errorf("get images by post id from db: %w", err)
+// Loads images of all given posts in a single query. Posts without images are mapped to an empty slice.
+func (p *DefaultPost) getImagesByPostIDs(ctx context.Context, postIDs []uuid.UUID) (map[uuid.UUID][]model.ImageLocation, error) {
+	fail := func(err error) (map[uuid.UUID][]model.ImageLocation, error) {
+		return nil, fmt.Errorf("get images by post ids from db: %w", err)
+	}
+
+	images := make(map[uuid.UUID][]model.ImageLocation, len(postIDs))
+	if len(postIDs) == 0 {
+		return images, nil
 	}
+	placeholders := make([]string, len(postIDs))
+	args := make([]interface{}, len(postIDs))
+	for i, postID := range postIDs {
+		images[postID] = make([]model.ImageLocation, 0)
+		placeholders[i] = "?"
+		args[i] = postID[:]
+	}
+
 	rows, err := p.db.QueryContext(
 		ctx,
-		"SELECT s3_bucket, s3_key FROM images WHERE post_id = ? ORDER BY position",
-		postID[:],
+		fmt.Sprintf(
+			"SELECT post_id, s3_bucket, s3_key FROM images WHERE post_id IN (%s) ORDER BY post_id, position",
+			strings.Join(placeholders, ","),
+		),
+		args...,
 	)
 	if err != nil {
 		return fail(err)
 	}
 	defer rows.Close()
-	images := make([]model.ImageLocation, 0)
 	for rows.Next() {
+		var postID uuid.UUID
 		var image model.ImageLocation
-		if err = rows.Scan(&image.Bucket, &image.Key); err != nil {
+		if err = rows.Scan(&postID, &image.Bucket, &image.Key); err != nil {
 			return fail(err)
 		}
-		images = append(images, image)
+		images[postID] = append(images[postID], image)
 	}
 	if err = rows.Err(); err != nil {
 		return fail(err)
@@ -128,9 +221,9 @@ func (p *DefaultPost) Get(ctx context.Context, id uuid.UUID) (model.Post, error)
 	post.ID = id
 	err := p.db.QueryRowContext(
 		ctx,
-		"SELECT author_id, body, created_at FROM posts WHERE id = ?",
+		"SELECT author_id, body, visibility, created_at FROM posts WHERE id = ?",
 		id[:],
-	).Scan(&post.AuthorID, &post.Body, &post.CreatedAt)
+	).Scan(&post.AuthorID, &post.Body, &post.Visibility, &post.CreatedAt)
 	if errors.Is(err, sql.ErrNoRows) {
 		return model.Post{}, ErrRecordNotFound
 	}
@@ -138,41 +231,190 @@ func (p *DefaultPost) Get(ctx context.Context, id uuid.UUID) (model.Post, error)
 		return fail(err)
 	}
 
-	post.Images, err = p.getImagesByPostID(ctx, id)
+	images, err := p.getImagesByPostIDs(ctx, []uuid.UUID{id})
 	if err != nil {
 		return fail(err)
 	}
+	post.Images = images[id]
+
+	mentions, err := getMentions(ctx, p.db, model.PostType, []uuid.UUID{id})
+	if err != nil {
+		return fail(err)
+	}
+	post.Mentions = mentions[id]
 
 	return post, nil
 }
 
-func (p *DefaultPost) GetWithCountsByUserIDs(
-	ctx context.Context, userIDs []uuid.UUID, cursor model.Cursor, limit int,
+// Loads the given posts with their images and mentions in three queries. Posts that do not exist are left out.
+func (p *DefaultPost) GetByIDs(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]model.Post, error) {
+	fail := func(err error) (map[uuid.UUID]model.Post, error) {
+		return nil, fmt.Errorf("get posts by ids from db: %w", err)
+	}
+
+	posts := make(map[uuid.UUID]model.Post, len(ids))
+	if len(ids) == 0 {
+		return posts, nil
+	}
+	placeholders := make([]string, len(ids))
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		placeholders[i] = "?"
+		args[i] = id[:]
+	}
+
+	rows, err := p.db.QueryContext(
+		ctx,
+		fmt.Sprintf(
+			"SELECT id, author_id, body, visibility, created_at FROM posts WHERE id IN (%s)",
+			strings.Join(placeholders, ","),
+		),
+		args...,
+	)
+	if err != nil {
+		return fail(err)
+	}
+	defer rows.Close()
+	foundIDs := make([]uuid.UUID, 0, len(ids))
+	for rows.Next() {
+		var post model.Post
+		if err = rows.Scan(&post.ID, &post.AuthorID, &post.Body, &post.Visibility, &post.CreatedAt); err != nil {
+			return fail(err)
+		}
+		posts[post.ID] = post
+		foundIDs = append(foundIDs, post.ID)
+	}
+	if err = rows.Err(); err != nil {
+		return fail(err)
+	}
+	// Release the connection before loading images and mentions.
+	rows.Close()
+
+	images, err := p.getImagesByPostIDs(ctx, foundIDs)
+	if err != nil {
+		return fail(err)
+	}
+	mentions, err := getMentions(ctx, p.db, model.PostType, foundIDs)
+	if err != nil {
+		return fail(err)
+	}
+	for _, id := range foundIDs {
+		post := posts[id]
+		post.Images = images[id]
+		post.Mentions = mentions[id]
+		posts[id] = post
+	}
+	return posts, nil
+}
+
+// Returns posts from the user's timeline merged with posts of extraAuthorIDs, which are not fanned out to timelines.
+// Posts of excludedAuthorIDs are left out, which should not overlap with extraAuthorIDs.
+func (p *DefaultPost) GetTimelineWithCounts(
+	ctx context.Context, userID uuid.UUID, extraAuthorIDs, excludedAuthorIDs []uuid.UUID, cursor model.Cursor,
+	limit int,
 ) ([]model.Post, *model.Cursor, error) {
 	fail := func(err error) ([]model.Post, *model.Cursor, error) {
-		return nil, nil, fmt.Errorf("get posts by user ids from db: %w", err)
+		return nil, nil, fmt.Errorf("get timeline from db: %w", err)
 	}
 
-	hexUserIDs := make([]string, len(userIDs))
-	for i, userID := range userIDs {
-		hexUserIDs[i] = fmt.Sprintf("X'%x'", userID[:])
+	// Each subquery is limited separately, so that both are range scans on an index. Excluded authors are filtered
+	// before the limit, so that they do not cut pages short.
+	feedQuery := `
+		SELECT post_id, created_at 
+		FROM timelines 
+		WHERE user_id = ? AND (created_at < ? OR (created_at = ? AND post_id > ?)) 
+		ORDER BY created_at DESC, post_id 
+		LIMIT ? 
+	`
+	args := []interface{}{
+		userID[:], cursor.LastLoadedTimestamp, cursor.LastLoadedTimestamp, cursor.LastLoadedID[:], limit + 1,
+	}
+	if len(excludedAuthorIDs) > 0 {
+		exclusion, exclusionArgs := notInCondition("p.author_id", excludedAuthorIDs)
+		feedQuery = fmt.Sprintf(`
+			SELECT t.post_id, t.created_at 
+			FROM timelines t 
+			JOIN posts p ON p.id = t.post_id 
+			WHERE t.user_id = ? AND (t.created_at < ? OR (t.created_at = ? AND t.post_id > ?))%s 
+			ORDER BY t.created_at DESC, t.post_id 
+			LIMIT ? 
+		`, exclusion)
+		args = []interface{}{userID[:], cursor.LastLoadedTimestamp, cursor.LastLoadedTimestamp, cursor.LastLoadedID[:]}
+		args = append(append(args, exclusionArgs...), limit+1)
+	}
+	if len(extraAuthorIDs) > 0 {
+		placeholders := make([]string, len(extraAuthorIDs))
+		for i, authorID := range extraAuthorIDs {
+			placeholders[i] = "?"
+			args = append(args, authorID[:])
+		}
+		// UNION rather than UNION ALL: posts created before the author was marked as high follower can be in both sets.
+		feedQuery = fmt.Sprintf(`
+			(%s) 
+			UNION 
+			(
+				SELECT id, created_at 
+				FROM posts 
+				WHERE author_id IN (%s) AND (created_at < ? OR (created_at = ? AND id > ?)) 
+				ORDER BY created_at DESC, id 
+				LIMIT ? 
+			)
+		`, feedQuery, strings.Join(placeholders, ","))
+		args = append(
+			args,
+			cursor.LastLoadedTimestamp, cursor.LastLoadedTimestamp, cursor.LastLoadedID[:], limit+1,
+		)
+	}
+	posts, nextCursor, err := p.getPageWithCounts(ctx, feedQuery, args, limit)
+	if err != nil {
+		return fail(err)
 	}
+	return posts, nextCursor, nil
+}
+
+func (p *DefaultPost) GetByTagWithCounts(
+	ctx context.Context, tag string, cursor model.Cursor, limit int,
+) ([]model.Post, *model.Cursor, error) {
+	fail := func(err error) ([]model.Post, *model.Cursor, error) {
+		return nil, nil, fmt.Errorf("get posts by tag from db: %w", err)
+	}
+
+	tagQuery := `
+		SELECT post_id, created_at 
+		FROM post_tags 
+		WHERE tag = ? AND (created_at < ? OR (created_at = ? AND post_id > ?)) 
+		ORDER BY created_at DESC, post_id 
+		LIMIT ? 
+	`
+	args := []interface{}{
+		tag, cursor.LastLoadedTimestamp, cursor.LastLoadedTimestamp, cursor.LastLoadedID[:], limit + 1,
+	}
+	posts, nextCursor, err := p.getPageWithCounts(ctx, tagQuery, args, limit)
+	if err != nil {
+		return fail(err)
+	}
+	return posts, nextCursor, nil
+}
+
+// Loads a page of posts with counts and images. pageQuery selects (post_id, created_at) of at most limit+1 posts
+// that belong to the page.
+func (p *DefaultPost) getPageWithCounts(
+	ctx context.Context, pageQuery string, args []interface{}, limit int,
+) ([]model.Post, *model.Cursor, error) {
+	args = append(args, limit+1)
 
 	query := fmt.Sprintf(`
-		SELECT p.id, p.author_id, p.body, p.created_at, IFNULL(cc.count, 0), IFNULL(lc.count, 0) 
-		FROM posts p 
+		SELECT p.id, p.author_id, p.body, p.visibility, p.created_at, IFNULL(cc.count, 0), IFNULL(lc.count, 0) 
+		FROM (%s) f 
+		JOIN posts p ON p.id = f.post_id 
 		LEFT JOIN comments_count cc ON cc.post_id = p.id 
 		LEFT JOIN likes_count lc ON lc.entity_type = 'posts' AND lc.entity_id = p.id 
-		WHERE p.author_id IN (%s) AND (p.created_at < ? OR (p.created_at = ? AND p.id > ?)) 
-		ORDER BY p.created_at DESC, p.id 
+		ORDER BY f.created_at DESC, f.post_id 
 		LIMIT ? 
-	`, strings.Join(hexUserIDs, ","))
-	rows, err := p.db.QueryContext(
-		ctx, query,
-		cursor.LastLoadedTimestamp, cursor.LastLoadedTimestamp, cursor.LastLoadedID[:], limit+1,
-	)
+	`, pageQuery)
+	rows, err := p.db.QueryContext(ctx, query, args...)
 	if err != nil {
-		return fail(err)
+		return nil, nil, err
 	}
 	defer rows.Close()
 
@@ -180,19 +422,18 @@ func (p *DefaultPost) GetWithCountsByUserIDs(
 	for i := 0; i < limit && rows.Next(); i++ {
 		var post model.Post
 		var commentCount, likeCount uint32
-		if err = rows.Scan(&post.ID, &post.AuthorID, &post.Body, &post.CreatedAt, &commentCount, &likeCount); err != nil {
-			return fail(err)
+		err = rows.Scan(
+			&post.ID, &post.AuthorID, &post.Body, &post.Visibility, &post.CreatedAt, &commentCount, &likeCount,
+		)
+		if err != nil {
+			return nil, nil, err
 		}
 		post.CommentCount = &commentCount
 		post.LikeCount = &likeCount
-		post.Images, err = p.getImagesByPostID(ctx, post.ID)
-		if err != nil {
-			return fail(err)
-		}
 		posts = append(posts, post)
 	}
 	if err = rows.Err(); err != nil {
-		return fail(err)
+		return nil, nil, err
 	}
 
 	var nextCursor *model.Cursor
@@ -203,6 +444,245 @@ func (p *DefaultPost) GetWithCountsByUserIDs(
 			LastLoadedID:        posts[len(posts)-1].ID,
 		}
 	}
+	// Release the connection before loading images.
+	rows.Close()
+
+	postIDs := make([]uuid.UUID, len(posts))
+	for i, post := range posts {
+		postIDs[i] = post.ID
+	}
+	images, err := p.getImagesByPostIDs(ctx, postIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	mentions, err := getMentions(ctx, p.db, model.PostType, postIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range posts {
+		posts[i].Images = images[posts[i].ID]
+		posts[i].Mentions = mentions[posts[i].ID]
+	}
+
+	return posts, nextCursor, nil
+}
+
+// Posts are ordered by relevance to the query, most relevant first. A nil cursor means the first page.
+func (p *DefaultPost) SearchWithCounts(
+	ctx context.Context, query string, cursor *model.SearchCursor, limit int,
+) ([]model.Post, *model.SearchCursor, error) {
+	fail := func(err error) ([]model.Post, *model.SearchCursor, error) {
+		return nil, nil, fmt.Errorf("search posts in db: %w", err)
+	}
+
+	const match = "MATCH(p.body) AGAINST (? IN NATURAL LANGUAGE MODE)"
+	where := match + " > 0"
+	args := []interface{}{query, query}
+	if cursor != nil {
+		where += fmt.Sprintf(" AND (%s < ? OR (%s = ? AND p.id > ?))", match, match)
+		args = append(
+			args,
+			query, cursor.LastLoadedScore, query, cursor.LastLoadedScore, cursor.LastLoadedID[:],
+		)
+	}
+	args = append(args, limit+1)
+
+	rows, err := p.db.QueryContext(ctx, fmt.Sprintf(`
+		SELECT p.id, p.author_id, p.body, p.visibility, p.created_at, IFNULL(cc.count, 0), IFNULL(lc.count, 0), %s AS score 
+		FROM posts p 
+		LEFT JOIN comments_count cc ON cc.post_id = p.id 
+		LEFT JOIN likes_count lc ON lc.entity_type = 'posts' AND lc.entity_id = p.id 
+		WHERE %s 
+		ORDER BY score DESC, p.id 
+		LIMIT ? 
+	`, match, where), args...)
+	if err != nil {
+		return fail(err)
+	}
+	defer rows.Close()
+
+	posts := make([]model.Post, 0)
+	var lastScore float64
+	for i := 0; i < limit && rows.Next(); i++ {
+		var post model.Post
+		var commentCount, likeCount uint32
+		err = rows.Scan(
+			&post.ID, &post.AuthorID, &post.Body, &post.Visibility, &post.CreatedAt, &commentCount, &likeCount,
+			&lastScore,
+		)
+		if err != nil {
+			return fail(err)
+		}
+		post.CommentCount = &commentCount
+		post.LikeCount = &likeCount
+		posts = append(posts, post)
+	}
+	if err = rows.Err(); err != nil {
+		return fail(err)
+	}
+
+	var nextCursor *model.SearchCursor
+	// Given that we have loaded limit+1 elements and iterated over at most limit elements, rows.Next() == false means its the last page.
+	if rows.Next() {
+		nextCursor = &model.SearchCursor{
+			LastLoadedScore: lastScore,
+			LastLoadedID:    posts[len(posts)-1].ID,
+		}
+	}
+	// Release the connection before loading images.
+	rows.Close()
+
+	postIDs := make([]uuid.UUID, len(posts))
+	for i, post := range posts {
+		postIDs[i] = post.ID
+	}
+	images, err := p.getImagesByPostIDs(ctx, postIDs)
+	if err != nil {
+		return fail(err)
+	}
+	mentions, err := getMentions(ctx, p.db, model.PostType, postIDs)
+	if err != nil {
+		return fail(err)
+	}
+	for i := range posts {
+		posts[i].Images = images[posts[i].ID]
+		posts[i].Mentions = mentions[posts[i].ID]
+	}
 
 	return posts, nextCursor, nil
 }
+
+func (p *DefaultPost) GetAuthorID(ctx context.Context, id uuid.UUID) (uuid.UUID, error) {
+	fail := func(err error) (uuid.UUID, error) {
+		return uuid.Nil, fmt.Errorf("get post author id from db: %w", err)
+	}
+
+	var authorID uuid.UUID
+	err := p.db.QueryRowContext(
+		ctx,
+		"SELECT author_id FROM posts WHERE id = ?",
+		id[:],
+	).Scan(&authorID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return uuid.Nil, ErrRecordNotFound
+	}
+	if err != nil {
+		return fail(err)
+	}
+	return authorID, nil
+}
+
+func (p *DefaultPost) GetAuthorIDAndVisibility(ctx context.Context, id uuid.UUID) (uuid.UUID, model.Visibility, error) {
+	fail := func(err error) (uuid.UUID, model.Visibility, error) {
+		return uuid.Nil, "", fmt.Errorf("get post author id and visibility from db: %w", err)
+	}
+
+	var authorID uuid.UUID
+	var visibility model.Visibility
+	err := p.db.QueryRowContext(
+		ctx,
+		"SELECT author_id, visibility FROM posts WHERE id = ?",
+		id[:],
+	).Scan(&authorID, &visibility)
+	if errors.Is(err, sql.ErrNoRows) {
+		return uuid.Nil, "", ErrRecordNotFound
+	}
+	if err != nil {
+		return fail(err)
+	}
+	return authorID, visibility, nil
+}
+
+// Tags and mentions of the post are replaced with the given ones in the same transaction.
+func (p *DefaultPost) Update(
+	ctx context.Context, id uuid.UUID, body string, tags []string, mentions []model.Mention,
+) error {
+	fail := func(err error) error {
+		return fmt.Errorf("update post in db: %w", err)
+	}
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fail(err)
+	}
+	defer tx.Rollback()
+
+	var createdAt time.Time
+	err = tx.QueryRowContext(ctx, "SELECT created_at FROM posts WHERE id = ? FOR UPDATE", id[:]).Scan(&createdAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrRecordNotFound
+	}
+	if err != nil {
+		return fail(changeErrIfCtxDone(ctx, err))
+	}
+
+	if _, err = tx.ExecContext(ctx, "UPDATE posts SET body = ? WHERE id = ?", body, id[:]); err != nil {
+		return fail(changeErrIfCtxDone(ctx, err))
+	}
+	if _, err = tx.ExecContext(ctx, "DELETE FROM post_tags WHERE post_id = ?", id[:]); err != nil {
+		return fail(changeErrIfCtxDone(ctx, err))
+	}
+	if err = insertTags(ctx, tx, id, tags, createdAt); err != nil {
+		return fail(changeErrIfCtxDone(ctx, err))
+	}
+	if err = replaceMentions(ctx, tx, model.PostType, id, mentions); err != nil {
+		return fail(changeErrIfCtxDone(ctx, err))
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fail(changeErrIfCtxDone(ctx, err))
+	}
+	return nil
+}
+
+func (p *DefaultPost) Delete(ctx context.Context, id uuid.UUID) error {
+	fail := func(err error) error {
+		return fmt.Errorf("delete post from db: %w", err)
+	}
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fail(err)
+	}
+	defer tx.Rollback()
+
+	// Likes and like counts of the post's comments have to go before the comments themselves, since they are found through them.
+	queries := []string{
+		"DELETE FROM likes WHERE entity_type = 'comments' AND entity_id IN (SELECT id FROM comments WHERE post_id = ?)",
+		"DELETE FROM likes_count WHERE entity_type = 'comments' AND entity_id IN (SELECT id FROM comments WHERE post_id = ?)",
+		"DELETE FROM replies_count WHERE comment_id IN (SELECT id FROM comments WHERE post_id = ?)",
+		"DELETE FROM mentions WHERE entity_type = 'comments' AND entity_id IN (SELECT id FROM comments WHERE post_id = ?)",
+		"DELETE FROM mentions WHERE entity_type = 'posts' AND entity_id = ?",
+		"DELETE FROM likes WHERE entity_type = 'posts' AND entity_id = ?",
+		"DELETE FROM likes_count WHERE entity_type = 'posts' AND entity_id = ?",
+		// Replies reference their parents, so they are deleted first.
+		"DELETE FROM comments WHERE post_id = ? AND parent_id IS NOT NULL",
+		"DELETE FROM comments WHERE post_id = ?",
+		"DELETE FROM comments_count WHERE post_id = ?",
+		"DELETE FROM images WHERE post_id = ?",
+		"DELETE FROM timelines WHERE post_id = ?",
+		"DELETE FROM post_tags WHERE post_id = ?",
+	}
+	for _, query := range queries {
+		if _, err = tx.ExecContext(ctx, query, id[:]); err != nil {
+			return fail(changeErrIfCtxDone(ctx, err))
+		}
+	}
+
+	result, err := tx.ExecContext(ctx, "DELETE FROM posts WHERE id = ?", id[:])
+	if err != nil {
+		return fail(changeErrIfCtxDone(ctx, err))
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fail(err)
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fail(changeErrIfCtxDone(ctx, err))
+	}
+	return nil
+}
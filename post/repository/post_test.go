@@ -0,0 +1,102 @@
+package repository_test
+
+import (
+	"context"
+	"smapp/post/model"
+	"smapp/post/repository"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/matryer/is"
+)
+
+func TestDefaultPostGetTimelineWithCountsQueryCount(t *testing.T) {
+	is := is.New(t)
+	db, mock, err := sqlmock.New()
+	is.NoErr(err)
+	defer db.Close()
+
+	const limit = 30
+	userID := uuid.New()
+	createdAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// limit+1 rows are loaded to find out whether there is a next page.
+	postIDs := make([]uuid.UUID, limit+1)
+	postRows := sqlmock.NewRows(
+		[]string{"id", "author_id", "body", "visibility", "created_at", "comment_count", "like_count"},
+	)
+	for i := range postIDs {
+		postIDs[i] = uuid.New()
+		authorID := uuid.New()
+		postRows.AddRow(
+			postIDs[i][:], authorID[:], "body", "public", createdAt.Add(-time.Duration(i)*time.Second), 0, 0,
+		)
+	}
+	mock.ExpectQuery("FROM timelines").WillReturnRows(postRows)
+
+	imageRows := sqlmock.NewRows([]string{"post_id", "s3_bucket", "s3_key"}).
+		AddRow(postIDs[0][:], "bucket", "images/post/1").
+		AddRow(postIDs[0][:], "bucket", "images/post/2").
+		AddRow(postIDs[5][:], "bucket", "images/post/3")
+	mock.ExpectQuery("FROM images WHERE post_id IN").WillReturnRows(imageRows)
+	mock.ExpectQuery("FROM mentions").
+		WillReturnRows(sqlmock.NewRows([]string{"entity_id", "char_offset", "char_length", "user_id"}))
+
+	post := repository.NewDefaultPost(db)
+	posts, nextCursor, err := post.GetTimelineWithCounts(context.TODO(), userID, nil, nil, model.Cursor{}, limit)
+	is.NoErr(err)
+	// Any query other than the three expected ones would have failed the call.
+	is.NoErr(mock.ExpectationsWereMet())
+
+	is.Equal(len(posts), limit)
+	is.True(nextCursor != nil)
+	is.Equal(nextCursor.LastLoadedID, postIDs[limit-1])
+	is.Equal(posts[0].Images, []model.ImageLocation{
+		{Bucket: "bucket", Key: "images/post/1"},
+		{Bucket: "bucket", Key: "images/post/2"},
+	})
+	is.Equal(posts[1].Images, []model.ImageLocation{})
+	is.Equal(posts[5].Images, []model.ImageLocation{{Bucket: "bucket", Key: "images/post/3"}})
+}
+
+func TestDefaultPostGetByIDs(t *testing.T) {
+	is := is.New(t)
+	db, mock, err := sqlmock.New()
+	is.NoErr(err)
+	defer db.Close()
+
+	ids := []uuid.UUID{uuid.New(), uuid.New(), uuid.New()}
+	authorID := uuid.New()
+	createdAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// The second post does not exist, so images and mentions are only loaded for the other two.
+	mock.ExpectQuery("FROM posts WHERE id IN \\(\\?,\\?,\\?\\)").
+		WithArgs(ids[0][:], ids[1][:], ids[2][:]).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "author_id", "body", "visibility", "created_at"}).
+				AddRow(ids[0][:], authorID[:], "first", "public", createdAt).
+				AddRow(ids[2][:], authorID[:], "third", "followers", createdAt),
+		)
+	mock.ExpectQuery("FROM images WHERE post_id IN").
+		WithArgs(ids[0][:], ids[2][:]).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"post_id", "s3_bucket", "s3_key"}).AddRow(ids[2][:], "bucket", "images/post/1"),
+		)
+	mock.ExpectQuery("FROM mentions").
+		WithArgs(model.PostType, ids[0][:], ids[2][:]).
+		WillReturnRows(sqlmock.NewRows([]string{"entity_id", "char_offset", "char_length", "user_id"}))
+
+	post := repository.NewDefaultPost(db)
+	posts, err := post.GetByIDs(context.TODO(), ids)
+	is.NoErr(err)
+	is.NoErr(mock.ExpectationsWereMet())
+
+	is.Equal(len(posts), 2)
+	is.Equal(posts[ids[0]].Body, "first")
+	is.Equal(posts[ids[0]].Images, []model.ImageLocation{})
+	is.Equal(posts[ids[2]].Visibility, model.VisibilityFollowers)
+	is.Equal(posts[ids[2]].Images, []model.ImageLocation{{Bucket: "bucket", Key: "images/post/1"}})
+	is.Equal(posts[ids[2]].Mentions, []model.Mention{})
+}
@@ -0,0 +1,140 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"smapp/post/model"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Stream reads what happened after a cursor, oldest first. Only rows created before the until time are returned: the
+// creation time has a precision of one second and rows become visible when their transaction commits, so the most
+// recent rows could otherwise be skipped by a cursor that has already moved past them.
+type Stream struct {
+	db *sql.DB
+}
+
+func NewStream(db *sql.DB) *Stream {
+	return &Stream{db: db}
+}
+
+// New posts in the user's timeline and new posts of extraAuthorIDs, which are not fanned out to timelines.
+func (s *Stream) GetNewPosts(
+	ctx context.Context, userID uuid.UUID, extraAuthorIDs []uuid.UUID, cursor model.Cursor, until time.Time, limit int,
+) ([]model.StreamItem, error) {
+	fail := func(err error) ([]model.StreamItem, error) {
+		return nil, fmt.Errorf("get new posts from db: %w", err)
+	}
+
+	query := `
+		SELECT post_id AS id, post_id, author_id AS actor_id, created_at
+		FROM timelines
+		WHERE user_id = ? AND (created_at > ? OR (created_at = ? AND post_id > ?)) AND created_at < ?
+	`
+	args := []interface{}{
+		userID[:], cursor.LastLoadedTimestamp, cursor.LastLoadedTimestamp, cursor.LastLoadedID[:], until,
+	}
+	if len(extraAuthorIDs) > 0 {
+		placeholders := make([]string, len(extraAuthorIDs))
+		for i, authorID := range extraAuthorIDs {
+			placeholders[i] = "?"
+			args = append(args, authorID[:])
+		}
+		// UNION rather than UNION ALL: posts created before the author was marked as high follower can be in both sets.
+		query = fmt.Sprintf(`
+			%s
+			UNION
+			SELECT id, id, author_id, created_at
+			FROM posts
+			WHERE author_id IN (%s) AND (created_at > ? OR (created_at = ? AND id > ?)) AND created_at < ?
+		`, query, strings.Join(placeholders, ","))
+		args = append(args, cursor.LastLoadedTimestamp, cursor.LastLoadedTimestamp, cursor.LastLoadedID[:], until)
+	}
+
+	items, err := s.getItems(ctx, query, args, limit)
+	if err != nil {
+		return fail(err)
+	}
+	return items, nil
+}
+
+// New likes of posts written by the user. The user's own likes are left out.
+func (s *Stream) GetNewLikes(
+	ctx context.Context, userID uuid.UUID, cursor model.Cursor, until time.Time, limit int,
+) ([]model.StreamItem, error) {
+	fail := func(err error) ([]model.StreamItem, error) {
+		return nil, fmt.Errorf("get new likes from db: %w", err)
+	}
+
+	query := `
+		SELECT l.id, l.entity_id AS post_id, l.author_id AS actor_id, l.created_at
+		FROM likes l
+		JOIN posts p ON p.id = l.entity_id
+		WHERE l.entity_type = 'posts' AND p.author_id = ? AND l.author_id != ?
+			AND (l.created_at > ? OR (l.created_at = ? AND l.id > ?)) AND l.created_at < ?
+	`
+	args := []interface{}{
+		userID[:], userID[:], cursor.LastLoadedTimestamp, cursor.LastLoadedTimestamp, cursor.LastLoadedID[:], until,
+	}
+	items, err := s.getItems(ctx, query, args, limit)
+	if err != nil {
+		return fail(err)
+	}
+	return items, nil
+}
+
+// New comments and replies under posts written by the user. The user's own comments are left out.
+func (s *Stream) GetNewComments(
+	ctx context.Context, userID uuid.UUID, cursor model.Cursor, until time.Time, limit int,
+) ([]model.StreamItem, error) {
+	fail := func(err error) ([]model.StreamItem, error) {
+		return nil, fmt.Errorf("get new comments from db: %w", err)
+	}
+
+	query := `
+		SELECT c.id, c.post_id, c.author_id AS actor_id, c.created_at
+		FROM comments c
+		JOIN posts p ON p.id = c.post_id
+		WHERE p.author_id = ? AND c.author_id != ?
+			AND (c.created_at > ? OR (c.created_at = ? AND c.id > ?)) AND c.created_at < ?
+	`
+	args := []interface{}{
+		userID[:], userID[:], cursor.LastLoadedTimestamp, cursor.LastLoadedTimestamp, cursor.LastLoadedID[:], until,
+	}
+	items, err := s.getItems(ctx, query, args, limit)
+	if err != nil {
+		return fail(err)
+	}
+	return items, nil
+}
+
+// itemsQuery selects (id, post_id, actor_id, created_at) of the items.
+func (s *Stream) getItems(ctx context.Context, itemsQuery string, args []interface{}, limit int) ([]model.StreamItem, error) {
+	args = append(args, limit)
+	rows, err := s.db.QueryContext(
+		ctx,
+		fmt.Sprintf("SELECT id, post_id, actor_id, created_at FROM (%s) i ORDER BY created_at, id LIMIT ?", itemsQuery),
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]model.StreamItem, 0)
+	for rows.Next() {
+		var item model.StreamItem
+		if err = rows.Scan(&item.ID, &item.PostID, &item.ActorID, &item.CreatedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
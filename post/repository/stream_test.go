@@ -0,0 +1,126 @@
+package repository_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"smapp/post/model"
+	"smapp/post/repository"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/matryer/is"
+)
+
+var streamItemColumns = []string{"id", "post_id", "actor_id", "created_at"}
+
+func TestStreamGetNewPosts(t *testing.T) {
+	userID := uuid.New()
+	authorID := uuid.New()
+	cursor := model.Cursor{LastLoadedTimestamp: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), LastLoadedID: uuid.New()}
+	until := cursor.LastLoadedTimestamp.Add(time.Minute)
+
+	tests := []struct {
+		name           string
+		extraAuthorIDs []uuid.UUID
+		query          string
+		args           []driver.Value
+	}{
+		{
+			name:  "timeline only",
+			query: "FROM timelines WHERE (.+) LIMIT",
+			args: []driver.Value{
+				userID[:], cursor.LastLoadedTimestamp, cursor.LastLoadedTimestamp, cursor.LastLoadedID[:], until, 50,
+			},
+		},
+		{
+			// Posts of high follower authors are not in the timeline.
+			name:           "extra authors",
+			extraAuthorIDs: []uuid.UUID{authorID},
+			query:          "FROM timelines WHERE (.+) UNION SELECT (.+) FROM posts WHERE author_id IN \\(\\?\\)",
+			args: []driver.Value{
+				userID[:], cursor.LastLoadedTimestamp, cursor.LastLoadedTimestamp, cursor.LastLoadedID[:], until,
+				authorID[:], cursor.LastLoadedTimestamp, cursor.LastLoadedTimestamp, cursor.LastLoadedID[:], until, 50,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			is := is.New(t)
+			db, mock, err := sqlmock.New()
+			is.NoErr(err)
+			defer db.Close()
+
+			postID := uuid.New()
+			createdAt := cursor.LastLoadedTimestamp.Add(time.Second)
+			mock.ExpectQuery(tt.query).
+				WithArgs(tt.args...).
+				WillReturnRows(sqlmock.NewRows(streamItemColumns).AddRow(postID[:], postID[:], authorID[:], createdAt))
+
+			stream := repository.NewStream(db)
+			items, err := stream.GetNewPosts(context.TODO(), userID, tt.extraAuthorIDs, cursor, until, 50)
+			is.NoErr(err)
+			is.Equal(items, []model.StreamItem{{ID: postID, PostID: postID, ActorID: authorID, CreatedAt: createdAt}})
+			is.NoErr(mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestStreamGetNewLikes(t *testing.T) {
+	is := is.New(t)
+	db, mock, err := sqlmock.New()
+	is.NoErr(err)
+	defer db.Close()
+
+	userID := uuid.New()
+	cursor := model.Cursor{LastLoadedTimestamp: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), LastLoadedID: uuid.New()}
+	until := cursor.LastLoadedTimestamp.Add(time.Minute)
+
+	// The user is passed twice: likes of the user's posts, except the user's own likes.
+	mock.ExpectQuery("FROM likes l JOIN posts p (.+) WHERE l.entity_type = 'posts' AND p.author_id = \\? AND l.author_id != \\?").
+		WithArgs(
+			userID[:], userID[:], cursor.LastLoadedTimestamp, cursor.LastLoadedTimestamp, cursor.LastLoadedID[:], until, 10,
+		).
+		WillReturnRows(sqlmock.NewRows(streamItemColumns))
+
+	stream := repository.NewStream(db)
+	items, err := stream.GetNewLikes(context.TODO(), userID, cursor, until, 10)
+	is.NoErr(err)
+	is.Equal(items, []model.StreamItem{})
+	is.NoErr(mock.ExpectationsWereMet())
+}
+
+func TestStreamGetNewComments(t *testing.T) {
+	is := is.New(t)
+	db, mock, err := sqlmock.New()
+	is.NoErr(err)
+	defer db.Close()
+
+	userID := uuid.New()
+	cursor := model.Cursor{LastLoadedTimestamp: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), LastLoadedID: uuid.New()}
+	until := cursor.LastLoadedTimestamp.Add(time.Minute)
+
+	ids := []uuid.UUID{uuid.New(), uuid.New()}
+	postID, actorID := uuid.New(), uuid.New()
+	createdAt := cursor.LastLoadedTimestamp.Add(time.Second)
+	mock.ExpectQuery("FROM comments c JOIN posts p (.+) WHERE p.author_id = \\? AND c.author_id != \\?").
+		WithArgs(
+			userID[:], userID[:], cursor.LastLoadedTimestamp, cursor.LastLoadedTimestamp, cursor.LastLoadedID[:], until, 10,
+		).
+		WillReturnRows(
+			sqlmock.NewRows(streamItemColumns).
+				AddRow(ids[0][:], postID[:], actorID[:], createdAt).
+				AddRow(ids[1][:], postID[:], actorID[:], createdAt),
+		)
+
+	stream := repository.NewStream(db)
+	items, err := stream.GetNewComments(context.TODO(), userID, cursor, until, 10)
+	is.NoErr(err)
+	is.Equal(items, []model.StreamItem{
+		{ID: ids[0], PostID: postID, ActorID: actorID, CreatedAt: createdAt},
+		{ID: ids[1], PostID: postID, ActorID: actorID, CreatedAt: createdAt},
+	})
+	is.NoErr(mock.ExpectationsWereMet())
+}
@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"smapp/post/model"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Tag struct {
+	db *sql.DB
+}
+
+func NewTag(db *sql.DB) *Tag {
+	return &Tag{db: db}
+}
+
+// Returns the authors of public posts with tags created since the given time.
+func (t *Tag) GetTrendingAuthors(ctx context.Context, since time.Time) ([]uuid.UUID, error) {
+	fail := func(err error) ([]uuid.UUID, error) {
+		return nil, fmt.Errorf("get trending tag authors from db: %w", err)
+	}
+
+	rows, err := t.db.QueryContext(
+		ctx,
+		`
+		SELECT DISTINCT p.author_id 
+		FROM post_tags pt 
+		JOIN posts p ON p.id = pt.post_id 
+		WHERE pt.created_at >= ? AND p.visibility = 'public' 
+		`,
+		since,
+	)
+	if err != nil {
+		return fail(err)
+	}
+	defer rows.Close()
+
+	authorIDs := make([]uuid.UUID, 0)
+	for rows.Next() {
+		var authorID uuid.UUID
+		if err = rows.Scan(&authorID); err != nil {
+			return fail(err)
+		}
+		authorIDs = append(authorIDs, authorID)
+	}
+	if err = rows.Err(); err != nil {
+		return fail(err)
+	}
+	return authorIDs, nil
+}
+
+// Ranks tags by the number of public posts created since the given time. Posts of excludedAuthorIDs are not counted.
+func (t *Tag) GetTrending(
+	ctx context.Context, since time.Time, excludedAuthorIDs []uuid.UUID, limit int,
+) ([]model.TagUsage, error) {
+	fail := func(err error) ([]model.TagUsage, error) {
+		return nil, fmt.Errorf("get trending tags from db: %w", err)
+	}
+
+	exclusion, exclusionArgs := notInCondition("p.author_id", excludedAuthorIDs)
+	args := append([]interface{}{since}, exclusionArgs...)
+	args = append(args, limit)
+	rows, err := t.db.QueryContext(
+		ctx,
+		fmt.Sprintf(
+			`
+			SELECT pt.tag, COUNT(*) AS post_count 
+			FROM post_tags pt 
+			JOIN posts p ON p.id = pt.post_id 
+			WHERE pt.created_at >= ? AND p.visibility = 'public'%s 
+			GROUP BY pt.tag 
+			ORDER BY post_count DESC, pt.tag 
+			LIMIT ? 
+			`,
+			exclusion,
+		),
+		args...,
+	)
+	if err != nil {
+		return fail(err)
+	}
+	defer rows.Close()
+
+	tags := make([]model.TagUsage, 0)
+	for rows.Next() {
+		var tag model.TagUsage
+		if err = rows.Scan(&tag.Tag, &tag.PostCount); err != nil {
+			return fail(err)
+		}
+		tags = append(tags, tag)
+	}
+	if err = rows.Err(); err != nil {
+		return fail(err)
+	}
+	return tags, nil
+}
+
+func insertTags(ctx context.Context, tx *sql.Tx, postID uuid.UUID, tags []string, createdAt time.Time) error {
+	if len(tags) == 0 {
+		return nil
+	}
+	placeholders := make([]string, len(tags))
+	args := make([]interface{}, 0, 3*len(tags))
+	for i, tag := range tags {
+		placeholders[i] = "(?, ?, ?)"
+		args = append(args, tag, postID[:], createdAt)
+	}
+	_, err := tx.ExecContext(
+		ctx,
+		fmt.Sprintf("INSERT INTO post_tags (tag, post_id, created_at) VALUES %s", strings.Join(placeholders, ",")),
+		args...,
+	)
+	return err
+}
+
+// Returns up to limit posts with the tag that were created before the given time, oldest first.
+func (t *Tag) GetPostIDsCreatedBefore(ctx context.Context, tag string, before time.Time, limit int) ([]uuid.UUID, error) {
+	fail := func(err error) ([]uuid.UUID, error) {
+		return nil, fmt.Errorf("get posts with tag created before from db: %w", err)
+	}
+
+	rows, err := t.db.QueryContext(
+		ctx,
+		"SELECT post_id FROM post_tags WHERE tag = ? AND created_at < ? ORDER BY created_at LIMIT ?",
+		tag, before, limit,
+	)
+	if err != nil {
+		return fail(err)
+	}
+	defer rows.Close()
+
+	postIDs := make([]uuid.UUID, 0)
+	for rows.Next() {
+		var postID uuid.UUID
+		if err = rows.Scan(&postID); err != nil {
+			return fail(err)
+		}
+		postIDs = append(postIDs, postID)
+	}
+	if err = rows.Err(); err != nil {
+		return fail(err)
+	}
+	return postIDs, nil
+}
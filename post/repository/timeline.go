@@ -0,0 +1,154 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+//go:generate mockgen -destination mocks/timeline.go -package mocks . Timeline
+
+type Timeline interface {
+	AddAuthor(ctx context.Context, userID, authorID uuid.UUID, limit int) error
+	RemoveAuthor(ctx context.Context, userID, authorID uuid.UUID) error
+	MarkHighFollowerAuthor(ctx context.Context, authorID uuid.UUID) error
+	IsHighFollowerAuthor(ctx context.Context, authorID uuid.UUID) (bool, error)
+	GetHighFollowerAuthors(ctx context.Context) ([]uuid.UUID, error)
+}
+
+type DefaultTimeline struct {
+	db *sql.DB
+	// High follower authors are read on every feed request, so they are kept in memory for highFollowerAuthorsTTL.
+	highFollowerAuthorsTTL time.Duration
+	mu                     sync.Mutex
+	highFollowerAuthors    []uuid.UUID
+	highFollowerAuthorsAt  time.Time
+}
+
+func NewDefaultTimeline(db *sql.DB, highFollowerAuthorsTTL time.Duration) *DefaultTimeline {
+	return &DefaultTimeline{db: db, highFollowerAuthorsTTL: highFollowerAuthorsTTL}
+}
+
+// Adds up to limit latest posts of the author to the user's timeline. Posts only the author can see are left out.
+func (t *DefaultTimeline) AddAuthor(ctx context.Context, userID, authorID uuid.UUID, limit int) error {
+	fail := func(err error) error {
+		return fmt.Errorf("add author posts to timeline in db: %w", err)
+	}
+
+	_, err := t.db.ExecContext(
+		ctx,
+		`
+		INSERT IGNORE INTO timelines (user_id, post_id, author_id, created_at) 
+		SELECT ?, id, author_id, created_at 
+		FROM posts 
+		WHERE author_id = ? AND visibility != 'only_me' 
+		ORDER BY created_at DESC, id 
+		LIMIT ? 
+		`,
+		userID[:], authorID[:], limit,
+	)
+	if err != nil {
+		return fail(err)
+	}
+	return nil
+}
+
+func (t *DefaultTimeline) RemoveAuthor(ctx context.Context, userID, authorID uuid.UUID) error {
+	fail := func(err error) error {
+		return fmt.Errorf("remove author posts from timeline in db: %w", err)
+	}
+
+	_, err := t.db.ExecContext(
+		ctx,
+		"DELETE FROM timelines WHERE user_id = ? AND author_id = ?",
+		userID[:], authorID[:],
+	)
+	if err != nil {
+		return fail(err)
+	}
+	return nil
+}
+
+func (t *DefaultTimeline) MarkHighFollowerAuthor(ctx context.Context, authorID uuid.UUID) error {
+	fail := func(err error) error {
+		return fmt.Errorf("mark high follower author in db: %w", err)
+	}
+
+	_, err := t.db.ExecContext(
+		ctx,
+		"INSERT IGNORE INTO high_follower_authors (author_id) VALUES (?)",
+		authorID[:],
+	)
+	if err != nil {
+		return fail(err)
+	}
+	// Other instances pick the author up once their cache expires.
+	t.mu.Lock()
+	t.highFollowerAuthors = nil
+	t.mu.Unlock()
+	return nil
+}
+
+func (t *DefaultTimeline) IsHighFollowerAuthor(ctx context.Context, authorID uuid.UUID) (bool, error) {
+	var exists bool
+	err := t.db.QueryRowContext(
+		ctx,
+		"SELECT EXISTS(SELECT 1 FROM high_follower_authors WHERE author_id = ?)",
+		authorID[:],
+	).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("check if author has high follower count in db: %w", err)
+	}
+	return exists, nil
+}
+
+// The result can be up to highFollowerAuthorsTTL old.
+func (t *DefaultTimeline) GetHighFollowerAuthors(ctx context.Context) ([]uuid.UUID, error) {
+	t.mu.Lock()
+	if t.highFollowerAuthors != nil && time.Since(t.highFollowerAuthorsAt) < t.highFollowerAuthorsTTL {
+		authorIDs := slices.Clone(t.highFollowerAuthors)
+		t.mu.Unlock()
+		return authorIDs, nil
+	}
+	t.mu.Unlock()
+
+	authorIDs, err := t.loadHighFollowerAuthors(ctx)
+	if err != nil {
+		return nil, err
+	}
+	t.mu.Lock()
+	t.highFollowerAuthors = slices.Clone(authorIDs)
+	t.highFollowerAuthorsAt = time.Now()
+	t.mu.Unlock()
+	return authorIDs, nil
+}
+
+func (t *DefaultTimeline) loadHighFollowerAuthors(ctx context.Context) ([]uuid.UUID, error) {
+	fail := func(err error) ([]uuid.UUID, error) {
+		return nil, fmt.Errorf("get high follower authors from db: %w", err)
+	}
+
+	rows, err := t.db.QueryContext(ctx, "SELECT author_id FROM high_follower_authors")
+	if err != nil {
+		return fail(err)
+	}
+	defer rows.Close()
+
+	authorIDs := make([]uuid.UUID, 0)
+	for rows.Next() {
+		var authorID uuid.UUID
+		if err = rows.Scan(&authorID); err != nil {
+			return fail(err)
+		}
+		authorIDs = append(authorIDs, authorID)
+	}
+	if err = rows.Err(); err != nil {
+		return fail(err)
+	}
+	return authorIDs, nil
+}
@@ -0,0 +1,39 @@
+package repository_test
+
+import (
+	"context"
+	"smapp/post/repository"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/matryer/is"
+)
+
+func TestDefaultTimelineGetHighFollowerAuthorsCached(t *testing.T) {
+	is := is.New(t)
+	db, mock, err := sqlmock.New()
+	is.NoErr(err)
+	defer db.Close()
+
+	authorID := uuid.New()
+	timeline := repository.NewDefaultTimeline(db, time.Hour)
+
+	// Only the first call reads the table, and marking an author makes the next call read it again.
+	mock.ExpectQuery("FROM high_follower_authors").
+		WillReturnRows(sqlmock.NewRows([]string{"author_id"}).AddRow(authorID[:]))
+	mock.ExpectExec("INSERT IGNORE INTO high_follower_authors").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("FROM high_follower_authors").
+		WillReturnRows(sqlmock.NewRows([]string{"author_id"}).AddRow(authorID[:]))
+
+	for range 2 {
+		authorIDs, err := timeline.GetHighFollowerAuthors(context.TODO())
+		is.NoErr(err)
+		is.Equal(authorIDs, []uuid.UUID{authorID})
+	}
+	is.NoErr(timeline.MarkHighFollowerAuthor(context.TODO(), uuid.New()))
+	_, err = timeline.GetHighFollowerAuthors(context.TODO())
+	is.NoErr(err)
+	is.NoErr(mock.ExpectationsWereMet())
+}
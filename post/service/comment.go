@@ -8,18 +8,24 @@ import (
 	"smapp/post/model"
 	"smapp/post/repository"
 
+	userPB "smapp/common/grpc/user"
+
 	"github.com/google/uuid"
 )
 
 type Comment struct {
 	commentRepository *repository.Comment
 	postRepository    repository.Post
+	userClient        userPB.UserClient
 }
 
-func NewComment(commentRepository *repository.Comment, postRepository repository.Post) *Comment {
+func NewComment(
+	commentRepository *repository.Comment, postRepository repository.Post, userClient userPB.UserClient,
+) *Comment {
 	return &Comment{
 		commentRepository: commentRepository,
 		postRepository:    postRepository,
+		userClient:        userClient,
 	}
 }
 
@@ -28,7 +34,48 @@ func (svc *Comment) Create(ctx context.Context, postID, authorID uuid.UUID, body
 		return uuid.Nil, fmt.Errorf("create comment: %w", err)
 	}
 
-	id, err := svc.commentRepository.Create(ctx, postID, authorID, body)
+	postAuthorID, postVisibility, err := svc.postRepository.GetAuthorIDAndVisibility(ctx, postID)
+	if errors.Is(err, repository.ErrRecordNotFound) {
+		return uuid.Nil, fmt.Errorf("%w: %s", ErrPostNotFound, postID)
+	}
+	if err != nil {
+		return fail(err)
+	}
+	visible, err := isInAudience(ctx, svc.userClient, authorID, postAudience{postAuthorID, postVisibility})
+	if err != nil {
+		return fail(err)
+	}
+	if !visible {
+		return uuid.Nil, fmt.Errorf("%w: %s", ErrPostNotFound, postID)
+	}
+	err = checkNotBlocked(ctx, svc.userClient, authorID, postAuthorID)
+	if errors.Is(err, ErrBlocked) {
+		return uuid.Nil, err
+	}
+	if err != nil {
+		return fail(err)
+	}
+	err = checkNotPrivate(ctx, svc.userClient, authorID, postAuthorID)
+	if errors.Is(err, ErrPrivateAuthor) {
+		return uuid.Nil, err
+	}
+	if err != nil {
+		return fail(err)
+	}
+
+	mentions, err := resolveMentions(ctx, svc.userClient, body)
+	if err != nil {
+		return fail(err)
+	}
+	// The audience of the post also decides who can see its comments.
+	mentions, err = removeMentionsOutsideAudience(
+		ctx, svc.userClient, postAudience{postAuthorID, postVisibility}, mentions,
+	)
+	if err != nil {
+		return fail(err)
+	}
+
+	id, err := svc.commentRepository.Create(ctx, postID, authorID, body, mentions)
 	if errors.Is(err, repository.ErrPostIDNotFound) {
 		return uuid.Nil, fmt.Errorf("%w: %s", ErrPostNotFound, postID)
 	}
@@ -39,10 +86,132 @@ func (svc *Comment) Create(ctx context.Context, postID, authorID uuid.UUID, body
 	return id, nil
 }
 
+func (svc *Comment) CreateReply(ctx context.Context, parentID, authorID uuid.UUID, body string) (uuid.UUID, error) {
+	fail := func(err error) (uuid.UUID, error) {
+		return uuid.Nil, fmt.Errorf("create reply: %w", err)
+	}
+
+	// Replying is blocked by the author of the parent comment and by the author of the post.
+	parentAuthorID, postAuthorID, postVisibility, err := svc.commentRepository.GetAuthorIDs(ctx, parentID)
+	if errors.Is(err, repository.ErrRecordNotFound) {
+		return uuid.Nil, fmt.Errorf("%w: %s", ErrCommentNotFound, parentID)
+	}
+	if err != nil {
+		return fail(err)
+	}
+	visible, err := isInAudience(ctx, svc.userClient, authorID, postAudience{postAuthorID, postVisibility})
+	if err != nil {
+		return fail(err)
+	}
+	if !visible {
+		return uuid.Nil, fmt.Errorf("%w: %s", ErrCommentNotFound, parentID)
+	}
+	err = checkNotBlocked(ctx, svc.userClient, authorID, parentAuthorID, postAuthorID)
+	if errors.Is(err, ErrBlocked) {
+		return uuid.Nil, err
+	}
+	if err != nil {
+		return fail(err)
+	}
+	err = checkNotPrivate(ctx, svc.userClient, authorID, parentAuthorID, postAuthorID)
+	if errors.Is(err, ErrPrivateAuthor) {
+		return uuid.Nil, err
+	}
+	if err != nil {
+		return fail(err)
+	}
+
+	mentions, err := resolveMentions(ctx, svc.userClient, body)
+	if err != nil {
+		return fail(err)
+	}
+	// The audience of the post also decides who can see its comments.
+	mentions, err = removeMentionsOutsideAudience(
+		ctx, svc.userClient, postAudience{postAuthorID, postVisibility}, mentions,
+	)
+	if err != nil {
+		return fail(err)
+	}
+
+	id, err := svc.commentRepository.CreateReply(ctx, parentID, authorID, body, mentions)
+	if errors.Is(err, repository.ErrCommentIDNotFound) {
+		return uuid.Nil, fmt.Errorf("%w: %s", ErrCommentNotFound, parentID)
+	}
+	if err != nil {
+		return fail(err)
+	}
+
+	return id, nil
+}
+
+func (svc *Comment) Update(ctx context.Context, id, userID uuid.UUID, body string) error {
+	fail := func(err error) error {
+		return fmt.Errorf("update comment: %w", err)
+	}
+
+	authorID, postAuthorID, postVisibility, err := svc.commentRepository.GetAuthorIDs(ctx, id)
+	if errors.Is(err, repository.ErrRecordNotFound) {
+		return fmt.Errorf("%w: %s", ErrCommentNotFound, id)
+	}
+	if err != nil {
+		return fail(err)
+	}
+	if authorID != userID {
+		return fmt.Errorf("%w: %s", ErrNotCommentAuthor, id)
+	}
+
+	mentions, err := resolveMentions(ctx, svc.userClient, body)
+	if err != nil {
+		return fail(err)
+	}
+	mentions, err = removeMentionsOutsideAudience(
+		ctx, svc.userClient, postAudience{postAuthorID, postVisibility}, mentions,
+	)
+	if err != nil {
+		return fail(err)
+	}
+
+	if err = svc.commentRepository.Update(ctx, id, body, mentions); err != nil {
+		return fail(err)
+	}
+	return nil
+}
+
+// Both the author of the comment and the author of the post can delete the comment.
+func (svc *Comment) Delete(ctx context.Context, id, userID uuid.UUID) error {
+	fail := func(err error) error {
+		return fmt.Errorf("delete comment: %w", err)
+	}
+
+	authorID, postAuthorID, _, err := svc.commentRepository.GetAuthorIDs(ctx, id)
+	if errors.Is(err, repository.ErrRecordNotFound) {
+		return fmt.Errorf("%w: %s", ErrCommentNotFound, id)
+	}
+	if err != nil {
+		return fail(err)
+	}
+	if authorID != userID && postAuthorID != userID {
+		return fmt.Errorf("%w: %s", ErrNotCommentAuthor, id)
+	}
+
+	err = svc.commentRepository.Delete(ctx, id)
+	// The comment could have been deleted by a concurrent request after the author check.
+	if errors.Is(err, repository.ErrRecordNotFound) {
+		return fmt.Errorf("%w: %s", ErrCommentNotFound, id)
+	}
+	if err != nil {
+		return fail(err)
+	}
+	return nil
+}
+
 var ErrCommentsPaginationLimitInvalid = errors.New("comments pagination limit invalid")
 
+// The viewer does not see comments of users they have blocked, muted or been blocked by, nor comments of private
+// users they do not follow. Comments on posts of such private users are not returned at all, and neither are comments
+// on posts the viewer is not in the audience of. uuid.Nil stands for an anonymous viewer.
 func (svc *Comment) GetPaginatedWithLikeCount(
-	ctx context.Context, postID uuid.UUID, cursor model.Cursor, limit int,
+	ctx context.Context, viewerID, postID uuid.UUID, cursor model.Cursor, limit int,
 ) ([]model.Comment, *model.Cursor, error) {
 	fail := func(err error) ([]model.Comment, *model.Cursor, error) {
 		return nil, nil, fmt.Errorf("get comments: %w", err)
@@ -55,17 +224,129 @@ func (svc *Comment) GetPaginatedWithLikeCount(
 		)
 	}
 
-	if err := svc.postRepository.CheckExists(ctx, postID); err != nil {
-		if errors.Is(err, repository.ErrRecordNotFound) {
-			return nil, nil, fmt.Errorf("%w: %s", ErrPostNotFound, postID)
-		}
+	postAuthorID, postVisibility, err := svc.postRepository.GetAuthorIDAndVisibility(ctx, postID)
+	if errors.Is(err, repository.ErrRecordNotFound) {
+		return nil, nil, fmt.Errorf("%w: %s", ErrPostNotFound, postID)
+	}
+	if err != nil {
+		return fail(err)
+	}
+	visible, err := isInAudience(ctx, svc.userClient, viewerID, postAudience{postAuthorID, postVisibility})
+	if err != nil {
+		return fail(err)
+	}
+	if !visible {
+		return nil, nil, fmt.Errorf("%w: %s", ErrPostNotFound, postID)
+	}
+	err = checkNotPrivate(ctx, svc.userClient, viewerID, postAuthorID)
+	if errors.Is(err, ErrPrivateAuthor) {
+		return nil, nil, err
+	}
+	if err != nil {
 		return fail(err)
 	}
 
-	comments, nextCursor, err := svc.commentRepository.GetPaginatedWithLikeCount(ctx, postID, cursor, limit)
+	hiddenAuthorIDs, err := svc.getHiddenAuthors(ctx, viewerID)
 	if err != nil {
 		return fail(err)
 	}
+	comments, nextCursor, err := loadFilteredPage(
+		&cursor, limit,
+		func(cursor *model.Cursor, limit int) ([]model.Comment, *model.Cursor, error) {
+			return svc.commentRepository.GetPaginatedWithLikeCount(ctx, postID, hiddenAuthorIDs, *cursor, limit)
+		},
+		func(comments []model.Comment) ([]model.Comment, error) {
+			return removePrivate(ctx, svc.userClient, viewerID, comments, commentAuthorID)
+		},
+	)
+	if err != nil {
+		return fail(err)
+	}
+	if err = svc.embedAuthors(ctx, comments); err != nil {
+		return fail(err)
+	}
 
 	return comments, nextCursor, nil
 }
+
+// Same visibility rules as GetPaginatedWithLikeCount, and the parent comment must be visible too.
+func (svc *Comment) GetRepliesPaginatedWithLikeCount(
+	ctx context.Context, viewerID, commentID uuid.UUID, cursor model.Cursor, limit int,
+) ([]model.Comment, *model.Cursor, error) {
+	fail := func(err error) ([]model.Comment, *model.Cursor, error) {
+		return nil, nil, fmt.Errorf("get replies: %w", err)
+	}
+
+	if limit < 1 || limit > config.CommentsPaginationLimit {
+		return nil, nil, fmt.Errorf(
+			"%w, should be in range: [1, %d]",
+			ErrCommentsPaginationLimitInvalid, config.CommentsPaginationLimit,
+		)
+	}
+
+	parentAuthorID, postAuthorID, postVisibility, err := svc.commentRepository.GetAuthorIDs(ctx, commentID)
+	if errors.Is(err, repository.ErrRecordNotFound) {
+		return nil, nil, fmt.Errorf("%w: %s", ErrCommentNotFound, commentID)
+	}
+	if err != nil {
+		return fail(err)
+	}
+	visible, err := isInAudience(ctx, svc.userClient, viewerID, postAudience{postAuthorID, postVisibility})
+	if err != nil {
+		return fail(err)
+	}
+	if !visible {
+		return nil, nil, fmt.Errorf("%w: %s", ErrCommentNotFound, commentID)
+	}
+	err = checkNotPrivate(ctx, svc.userClient, viewerID, parentAuthorID, postAuthorID)
+	if errors.Is(err, ErrPrivateAuthor) {
+		return nil, nil, err
+	}
+	if err != nil {
+		return fail(err)
+	}
+
+	hiddenAuthorIDs, err := svc.getHiddenAuthors(ctx, viewerID)
+	if err != nil {
+		return fail(err)
+	}
+	replies, nextCursor, err := loadFilteredPage(
+		&cursor, limit,
+		func(cursor *model.Cursor, limit int) ([]model.Comment, *model.Cursor, error) {
+			return svc.commentRepository.GetRepliesPaginatedWithLikeCount(ctx, commentID, hiddenAuthorIDs, *cursor, limit)
+		},
+		func(replies []model.Comment) ([]model.Comment, error) {
+			return removePrivate(ctx, svc.userClient, viewerID, replies, commentAuthorID)
+		},
+	)
+	if err != nil {
+		return fail(err)
+	}
+	if err = svc.embedAuthors(ctx, replies); err != nil {
+		return fail(err)
+	}
+
+	return replies, nextCursor, nil
+}
+
+func (svc *Comment) getHiddenAuthors(ctx context.Context, viewerID uuid.UUID) ([]uuid.UUID, error) {
+	if viewerID == uuid.Nil {
+		return nil, nil
+	}
+	return getHiddenUsers(ctx, svc.userClient, viewerID)
+}
+
+func (svc *Comment) embedAuthors(ctx context.Context, comments []model.Comment) error {
+	authorIDs := make([]uuid.UUID, len(comments))
+	for i, comment := range comments {
+		authorIDs[i] = comment.AuthorID
+	}
+	authors, err := getAuthors(ctx, svc.userClient, authorIDs)
+	if err != nil {
+		return err
+	}
+	for i := range comments {
+		comments[i].Author = authors[comments[i].AuthorID]
+	}
+	return nil
+}
@@ -1,8 +1,360 @@
 package service
 
-import "errors"
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"smapp/post/config"
+	"smapp/post/model"
+	"smapp/post/repository"
+
+	userPB "smapp/common/grpc/user"
+
+	"github.com/google/uuid"
+)
 
 var (
-	ErrPostNotFound    = errors.New("post not found")
-	ErrCommentNotFound = errors.New("comment not found")
+	ErrPostNotFound     = errors.New("post not found")
+	ErrCommentNotFound  = errors.New("comment not found")
+	ErrNotPostAuthor    = errors.New("user is not the author of the post")
+	ErrNotCommentAuthor = errors.New("user is not the author of the comment")
+	ErrBlocked          = errors.New("user is blocked")
+	ErrPrivateAuthor    = errors.New("author is private")
 )
+
+// Authors that no longer exist are missing from the result.
+func getAuthors(ctx context.Context, userClient userPB.UserClient, authorIDs []uuid.UUID) (map[uuid.UUID]*model.Author, error) {
+	fail := func(err error) (map[uuid.UUID]*model.Author, error) {
+		return nil, fmt.Errorf("get authors: %w", err)
+	}
+
+	authors := make(map[uuid.UUID]*model.Author)
+	if len(authorIDs) == 0 {
+		return authors, nil
+	}
+
+	seen := make(map[uuid.UUID]bool)
+	userIDs := make([][]byte, 0, len(authorIDs))
+	for _, id := range authorIDs {
+		if !seen[id] {
+			seen[id] = true
+			userIDs = append(userIDs, id[:])
+		}
+	}
+
+	resp, err := userClient.GetUsers(ctx, &userPB.GetUsersRequest{UserIds: userIDs})
+	if err != nil {
+		return fail(err)
+	}
+	for _, user := range resp.Users {
+		id, err := uuid.FromBytes(user.Id)
+		if err != nil {
+			return fail(err)
+		}
+		authors[id] = &model.Author{ID: id, Name: user.Name, Handle: user.Handle, ImageURL: user.ImageUrl}
+	}
+	return authors, nil
+}
+
+func embedPostAuthors(ctx context.Context, userClient userPB.UserClient, posts []model.Post) error {
+	authorIDs := make([]uuid.UUID, len(posts))
+	for i, post := range posts {
+		authorIDs[i] = post.AuthorID
+	}
+	authors, err := getAuthors(ctx, userClient, authorIDs)
+	if err != nil {
+		return err
+	}
+	for i := range posts {
+		posts[i].Author = authors[posts[i].AuthorID]
+	}
+	return nil
+}
+
+func getFollowedHighFollowerAuthors(
+	ctx context.Context, timelineRepository repository.Timeline, userClient userPB.UserClient, userID uuid.UUID,
+) ([]uuid.UUID, error) {
+	fail := func(err error) ([]uuid.UUID, error) {
+		return nil, fmt.Errorf("get followed high follower authors: %w", err)
+	}
+
+	highFollowerAuthorIDs, err := timelineRepository.GetHighFollowerAuthors(ctx)
+	if err != nil {
+		return fail(err)
+	}
+	if len(highFollowerAuthorIDs) == 0 {
+		return nil, nil
+	}
+	candidateIDs := make([][]byte, len(highFollowerAuthorIDs))
+	for i, authorID := range highFollowerAuthorIDs {
+		candidateIDs[i] = authorID[:]
+	}
+	followed, err := userClient.FilterFollowed(ctx, &userPB.FilterFollowedRequest{
+		UserId:       userID[:],
+		CandidateIds: candidateIDs,
+	})
+	if err != nil {
+		return fail(err)
+	}
+	followedIDs, err := uuidsFromBytes(followed.UserIds)
+	if err != nil {
+		return fail(err)
+	}
+	return followedIDs, nil
+}
+
+func uuidsFromBytes(ids [][]byte) ([]uuid.UUID, error) {
+	result := make([]uuid.UUID, len(ids))
+	for i, id := range ids {
+		var err error
+		if result[i], err = uuid.FromBytes(id); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// Returns the users the user should not see posts and comments of: the blocked and the muted ones.
+func getHiddenUsers(ctx context.Context, userClient userPB.UserClient, userID uuid.UUID) ([]uuid.UUID, error) {
+	fail := func(err error) ([]uuid.UUID, error) {
+		return nil, fmt.Errorf("get hidden users: %w", err)
+	}
+
+	resp, err := userClient.GetHiddenUsers(ctx, &userPB.GetHiddenUsersRequest{UserId: userID[:]})
+	if err != nil {
+		return fail(err)
+	}
+	blockedIDs, err := uuidsFromBytes(resp.BlockedIds)
+	if err != nil {
+		return fail(err)
+	}
+	mutedIDs, err := uuidsFromBytes(resp.MutedIds)
+	if err != nil {
+		return fail(err)
+	}
+	return append(blockedIDs, mutedIDs...), nil
+}
+
+// Returns ErrBlocked if the user has blocked any of the authors or has been blocked by them.
+func checkNotBlocked(ctx context.Context, userClient userPB.UserClient, userID uuid.UUID, authorIDs ...uuid.UUID) error {
+	resp, err := userClient.GetHiddenUsers(ctx, &userPB.GetHiddenUsersRequest{UserId: userID[:]})
+	if err != nil {
+		return fmt.Errorf("check blocks: %w", err)
+	}
+	for _, blockedID := range resp.BlockedIds {
+		for _, authorID := range authorIDs {
+			if bytes.Equal(blockedID, authorID[:]) {
+				return ErrBlocked
+			}
+		}
+	}
+	return nil
+}
+
+// Returns the authors whose posts and comments the viewer cannot see, because they are private and not followed by
+// the viewer. uuid.Nil stands for an anonymous viewer.
+func getPrivateAuthors(
+	ctx context.Context, userClient userPB.UserClient, viewerID uuid.UUID, authorIDs []uuid.UUID,
+) ([]uuid.UUID, error) {
+	fail := func(err error) ([]uuid.UUID, error) {
+		return nil, fmt.Errorf("get private authors: %w", err)
+	}
+
+	if len(authorIDs) == 0 {
+		return nil, nil
+	}
+	req := &userPB.FilterPrivateAuthorsRequest{AuthorIds: make([][]byte, len(authorIDs))}
+	if viewerID != uuid.Nil {
+		req.ViewerId = viewerID[:]
+	}
+	for i, id := range authorIDs {
+		req.AuthorIds[i] = id[:]
+	}
+	resp, err := userClient.FilterPrivateAuthors(ctx, req)
+	if err != nil {
+		return fail(err)
+	}
+	privateIDs, err := uuidsFromBytes(resp.AuthorIds)
+	if err != nil {
+		return fail(err)
+	}
+	return privateIDs, nil
+}
+
+// Returns ErrPrivateAuthor if any of the authors is private and not followed by the viewer.
+func checkNotPrivate(ctx context.Context, userClient userPB.UserClient, viewerID uuid.UUID, authorIDs ...uuid.UUID) error {
+	privateIDs, err := getPrivateAuthors(ctx, userClient, viewerID, authorIDs)
+	if err != nil {
+		return err
+	}
+	if len(privateIDs) > 0 {
+		return ErrPrivateAuthor
+	}
+	return nil
+}
+
+// Removes the items of authors who are private and not followed by the viewer.
+func removePrivate[T any](
+	ctx context.Context, userClient userPB.UserClient, viewerID uuid.UUID, items []T, getAuthorID func(T) uuid.UUID,
+) ([]T, error) {
+	authorIDs := make([]uuid.UUID, len(items))
+	for i, item := range items {
+		authorIDs[i] = getAuthorID(item)
+	}
+	privateIDs, err := getPrivateAuthors(ctx, userClient, viewerID, authorIDs)
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(items, func(item T) bool {
+		return slices.Contains(privateIDs, getAuthorID(item))
+	}), nil
+}
+
+// Removes the posts the viewer is not in the audience of and the posts of private authors the viewer does not follow.
+func removeHiddenPosts(
+	ctx context.Context, userClient userPB.UserClient, viewerID uuid.UUID, posts []model.Post,
+) ([]model.Post, error) {
+	posts, err := removeNotInAudience(ctx, userClient, viewerID, posts)
+	if err != nil {
+		return nil, err
+	}
+	return removePrivate(ctx, userClient, viewerID, posts, postAuthorID)
+}
+
+func postAuthorID(post model.Post) uuid.UUID {
+	return post.AuthorID
+}
+
+func commentAuthorID(comment model.Comment) uuid.UUID {
+	return comment.AuthorID
+}
+
+// Who can see a post, checked against a viewer with inAudience.
+type postAudience struct {
+	authorID   uuid.UUID
+	visibility model.Visibility
+}
+
+func postAudienceOf(post model.Post) postAudience {
+	return postAudience{authorID: post.AuthorID, visibility: post.Visibility}
+}
+
+// Reports for each audience whether the viewer belongs to it. The author always does, and an anonymous viewer, given
+// as uuid.Nil, only belongs to public audiences.
+func inAudience(
+	ctx context.Context, userClient userPB.UserClient, viewerID uuid.UUID, audiences []postAudience,
+) ([]bool, error) {
+	fail := func(err error) ([]bool, error) {
+		return nil, fmt.Errorf("check post audiences: %w", err)
+	}
+
+	result := make([]bool, len(audiences))
+	var followersOf, closeFriendsOf [][]byte
+	for i, audience := range audiences {
+		result[i] = audience.visibility == model.VisibilityPublic || audience.authorID == viewerID
+		if result[i] || viewerID == uuid.Nil {
+			continue
+		}
+		switch audience.visibility {
+		case model.VisibilityFollowers:
+			followersOf = append(followersOf, audience.authorID[:])
+		case model.VisibilityCloseFriends:
+			closeFriendsOf = append(closeFriendsOf, audience.authorID[:])
+		}
+	}
+
+	var followedIDs, listedByIDs [][]byte
+	if len(followersOf) > 0 {
+		resp, err := userClient.FilterFollowed(ctx, &userPB.FilterFollowedRequest{
+			UserId:       viewerID[:],
+			CandidateIds: followersOf,
+		})
+		if err != nil {
+			return fail(err)
+		}
+		followedIDs = resp.UserIds
+	}
+	if len(closeFriendsOf) > 0 {
+		resp, err := userClient.FilterCloseFriendOf(ctx, &userPB.FilterCloseFriendOfRequest{
+			UserId:       viewerID[:],
+			CandidateIds: closeFriendsOf,
+		})
+		if err != nil {
+			return fail(err)
+		}
+		listedByIDs = resp.UserIds
+	}
+
+	containsID := func(ids [][]byte, id uuid.UUID) bool {
+		return slices.ContainsFunc(ids, func(other []byte) bool { return bytes.Equal(other, id[:]) })
+	}
+	for i, audience := range audiences {
+		if result[i] {
+			continue
+		}
+		switch audience.visibility {
+		case model.VisibilityFollowers:
+			result[i] = containsID(followedIDs, audience.authorID)
+		case model.VisibilityCloseFriends:
+			result[i] = containsID(listedByIDs, audience.authorID)
+		}
+	}
+	return result, nil
+}
+
+func isInAudience(
+	ctx context.Context, userClient userPB.UserClient, viewerID uuid.UUID, audience postAudience,
+) (bool, error) {
+	result, err := inAudience(ctx, userClient, viewerID, []postAudience{audience})
+	if err != nil {
+		return false, err
+	}
+	return result[0], nil
+}
+
+// Removes the posts the viewer is not in the audience of.
+func removeNotInAudience(
+	ctx context.Context, userClient userPB.UserClient, viewerID uuid.UUID, posts []model.Post,
+) ([]model.Post, error) {
+	audiences := make([]postAudience, len(posts))
+	for i, post := range posts {
+		audiences[i] = postAudienceOf(post)
+	}
+	visible, err := inAudience(ctx, userClient, viewerID, audiences)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]model.Post, 0, len(posts))
+	for i, post := range posts {
+		if visible[i] {
+			result = append(result, post)
+		}
+	}
+	return result, nil
+}
+
+// Loads pages starting at the cursor and filters them until limit items are left or there are no more items. The
+// returned cursor points at the last loaded item, so filtered items are not loaded again. After config.PageLoadsLimit
+// loads the page is returned even if it is short, so that a long run of filtered items does not make a request slow.
+func loadFilteredPage[T any, C any](
+	cursor *C, limit int, load func(cursor *C, limit int) ([]T, *C, error), filter func(items []T) ([]T, error),
+) ([]T, *C, error) {
+	result := make([]T, 0, limit)
+	for i := 0; i < config.PageLoadsLimit; i++ {
+		items, nextCursor, err := load(cursor, limit-len(result))
+		if err != nil {
+			return nil, nil, err
+		}
+		if items, err = filter(items); err != nil {
+			return nil, nil, err
+		}
+		result = append(result, items...)
+		cursor = nextCursor
+		if cursor == nil || len(result) == limit {
+			break
+		}
+	}
+	return result, cursor, nil
+}
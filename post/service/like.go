@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"smapp/post/repository"
 
+	userPB "smapp/common/grpc/user"
+
 	"github.com/google/uuid"
 )
 
@@ -14,23 +16,42 @@ type entityRepository interface {
 }
 
 type Like struct {
-	likeRepository    *repository.Like
-	entityRepository  entityRepository
+	likeRepository   *repository.Like
+	entityRepository entityRepository
+	// Users who have blocked or are blocked by any of the returned authors cannot like the entity, and neither can
+	// users outside the audience of the post.
+	getAuthorIDs      func(ctx context.Context, entityID uuid.UUID) ([]uuid.UUID, postAudience, error)
+	userClient        userPB.UserClient
 	errEntityNotFound error
 }
 
-func NewPostLike(likeRepository *repository.Like, postRepository repository.Post) *Like {
+func NewPostLike(
+	likeRepository *repository.Like, postRepository repository.Post, userClient userPB.UserClient,
+) *Like {
 	return &Like{
-		likeRepository:    likeRepository,
-		entityRepository:  postRepository,
+		likeRepository:   likeRepository,
+		entityRepository: postRepository,
+		getAuthorIDs: func(ctx context.Context, postID uuid.UUID) ([]uuid.UUID, postAudience, error) {
+			authorID, visibility, err := postRepository.GetAuthorIDAndVisibility(ctx, postID)
+			return []uuid.UUID{authorID}, postAudience{authorID, visibility}, err
+		},
+		userClient:        userClient,
 		errEntityNotFound: ErrPostNotFound,
 	}
 }
 
-func NewCommentLike(likeRepository *repository.Like, commentRepository *repository.Comment) *Like {
+// Both the author of the comment and the author of the post can block likes on the comment.
+func NewCommentLike(
+	likeRepository *repository.Like, commentRepository *repository.Comment, userClient userPB.UserClient,
+) *Like {
 	return &Like{
-		likeRepository:    likeRepository,
-		entityRepository:  commentRepository,
+		likeRepository:   likeRepository,
+		entityRepository: commentRepository,
+		getAuthorIDs: func(ctx context.Context, commentID uuid.UUID) ([]uuid.UUID, postAudience, error) {
+			authorID, postAuthorID, postVisibility, err := commentRepository.GetAuthorIDs(ctx, commentID)
+			return []uuid.UUID{authorID, postAuthorID}, postAudience{postAuthorID, postVisibility}, err
+		},
+		userClient:        userClient,
 		errEntityNotFound: ErrCommentNotFound,
 	}
 }
@@ -42,6 +63,58 @@ func (svc Like) Create(ctx context.Context, entityID, authorID uuid.UUID) error
 		return fmt.Errorf("create like: %w", err)
 	}
 
+	entityAuthorIDs, audience, err := svc.getAuthorIDs(ctx, entityID)
+	if errors.Is(err, repository.ErrRecordNotFound) {
+		return fmt.Errorf("%w: %s", svc.errEntityNotFound, entityID)
+	}
+	if err != nil {
+		return fail(err)
+	}
+	visible, err := isInAudience(ctx, svc.userClient, authorID, audience)
+	if err != nil {
+		return fail(err)
+	}
+	if !visible {
+		return fmt.Errorf("%w: %s", svc.errEntityNotFound, entityID)
+	}
+	err = checkNotBlocked(ctx, svc.userClient, authorID, entityAuthorIDs...)
+	if errors.Is(err, ErrBlocked) {
+		return err
+	}
+	if err != nil {
+		return fail(err)
+	}
+	// Users who cannot see the entity cannot like it either.
+	err = checkNotPrivate(ctx, svc.userClient, authorID, entityAuthorIDs...)
+	if errors.Is(err, ErrPrivateAuthor) {
+		return err
+	}
+	if err != nil {
+		return fail(err)
+	}
+
+	err = svc.likeRepository.Create(ctx, entityID, authorID)
+	if err != nil {
+		if errors.Is(err, repository.ErrRecordExists) {
+			return ErrLikeExists
+		}
+		// The entity could have been deleted after the check.
+		if errors.Is(err, repository.ErrRecordNotFound) {
+			return fmt.Errorf("%w: %s", svc.errEntityNotFound, entityID)
+		}
+		return fail(err)
+	}
+
+	return nil
+}
+
+var ErrLikeNotFound = errors.New("like not found")
+
+func (svc Like) Delete(ctx context.Context, entityID, authorID uuid.UUID) error {
+	fail := func(err error) error {
+		return fmt.Errorf("delete like: %w", err)
+	}
+
 	if err := svc.entityRepository.CheckExists(ctx, entityID); err != nil {
 		if errors.Is(err, repository.ErrRecordNotFound) {
 			return fmt.Errorf("%w: %s", svc.errEntityNotFound, entityID)
@@ -49,10 +122,10 @@ func (svc Like) Create(ctx context.Context, entityID, authorID uuid.UUID) error
 		return fail(err)
 	}
 
-	err := svc.likeRepository.Create(ctx, entityID, authorID)
+	err := svc.likeRepository.Delete(ctx, entityID, authorID)
 	if err != nil {
-		if errors.Is(err, repository.ErrRecordExists) {
-			return ErrLikeExists
+		if errors.Is(err, repository.ErrRecordNotFound) {
+			return ErrLikeNotFound
 		}
 		return fail(err)
 	}
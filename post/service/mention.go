@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"smapp/post/model"
+	"strings"
+	"unicode/utf8"
+
+	userPB "smapp/common/grpc/user"
+
+	"github.com/google/uuid"
+)
+
+const maxHandleLength = 20
+
+// A mention starts with '@' that is not preceded by a word character, so that e.g. emails are not mentions.
+var mentionRegexp = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_@])(@([\p{L}\p{N}_]+))`)
+
+// Finds @handle mentions in the body and resolves them to user IDs. Handles that do not belong to any user are left
+// as plain text.
+func resolveMentions(ctx context.Context, userClient userPB.UserClient, body string) ([]model.Mention, error) {
+	type candidate struct {
+		handle string
+		offset int
+		length int
+	}
+
+	candidates := make([]candidate, 0)
+	handles := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, match := range mentionRegexp.FindAllStringSubmatchIndex(body, -1) {
+		handle := body[match[4]:match[5]]
+		if utf8.RuneCountInString(handle) > maxHandleLength {
+			continue
+		}
+		candidates = append(candidates, candidate{
+			handle: strings.ToLower(handle),
+			offset: utf8.RuneCountInString(body[:match[2]]),
+			length: utf8.RuneCountInString(body[match[2]:match[3]]),
+		})
+		if !seen[strings.ToLower(handle)] {
+			seen[strings.ToLower(handle)] = true
+			handles = append(handles, handle)
+		}
+	}
+	if len(handles) == 0 {
+		return []model.Mention{}, nil
+	}
+
+	resp, err := userClient.ResolveHandles(ctx, &userPB.ResolveHandlesRequest{Handles: handles})
+	if err != nil {
+		return nil, fmt.Errorf("resolve mentions: %w", err)
+	}
+	// Handles are case-insensitive, the user service returns them as stored.
+	userIDs := make(map[string]uuid.UUID, len(resp.Users))
+	for _, user := range resp.Users {
+		id, err := uuid.FromBytes(user.UserId)
+		if err != nil {
+			return nil, fmt.Errorf("resolve mentions: %w", err)
+		}
+		userIDs[strings.ToLower(user.Handle)] = id
+	}
+
+	mentions := make([]model.Mention, 0, len(candidates))
+	for _, c := range candidates {
+		if userID, ok := userIDs[c.handle]; ok {
+			mentions = append(mentions, model.Mention{UserID: userID, Offset: c.offset, Length: c.length})
+		}
+	}
+	return mentions, nil
+}
+
+// Removes mentions of users who cannot see the post, because they are outside of its audience or its author is private
+// and not followed by them. They are left as plain text, so that they are not told about the post.
+func removeMentionsOutsideAudience(
+	ctx context.Context, userClient userPB.UserClient, audience postAudience, mentions []model.Mention,
+) ([]model.Mention, error) {
+	fail := func(err error) ([]model.Mention, error) {
+		return nil, fmt.Errorf("remove mentions outside audience: %w", err)
+	}
+
+	visible := make(map[uuid.UUID]bool)
+	result := make([]model.Mention, 0, len(mentions))
+	for _, mention := range mentions {
+		if _, ok := visible[mention.UserID]; !ok {
+			inAudience, err := isInAudience(ctx, userClient, mention.UserID, audience)
+			if err != nil {
+				return fail(err)
+			}
+			if inAudience {
+				privateIDs, err := getPrivateAuthors(ctx, userClient, mention.UserID, []uuid.UUID{audience.authorID})
+				if err != nil {
+					return fail(err)
+				}
+				inAudience = len(privateIDs) == 0
+			}
+			visible[mention.UserID] = inAudience
+		}
+		if visible[mention.UserID] {
+			result = append(result, mention)
+		}
+	}
+	return result, nil
+}
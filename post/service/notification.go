@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	eventsPB "smapp/common/grpc/events"
+	notificationPB "smapp/common/grpc/notification"
+)
+
+// Notifier turns domain events of the post service into notifications. It is run by the outbox relay, so an event can
+// be handled more than once. The notification service ignores actors that are already recorded.
+type Notifier struct {
+	notificationClient notificationPB.NotificationClient
+}
+
+func NewNotifier(notificationClient notificationPB.NotificationClient) *Notifier {
+	return &Notifier{notificationClient: notificationClient}
+}
+
+func (svc *Notifier) HandleEvent(ctx context.Context, event *eventsPB.Event) error {
+	var notifications []*notificationPB.Event
+	switch payload := event.Payload.(type) {
+	case *eventsPB.Event_PostCreated:
+		e := payload.PostCreated
+		notifications = mentionNotifications(notificationPB.EventType_MENTIONED_IN_POST, e.MentionedUserIds, e.AuthorId, e.PostId)
+	case *eventsPB.Event_PostLiked:
+		e := payload.PostLiked
+		notifications = append(notifications, &notificationPB.Event{
+			Type:        notificationPB.EventType_POST_LIKED,
+			RecipientId: e.PostAuthorId,
+			ActorId:     e.UserId,
+			EntityId:    e.PostId,
+		})
+	case *eventsPB.Event_CommentCreated:
+		e := payload.CommentCreated
+		if len(e.RepliedCommentId) > 0 {
+			notifications = append(notifications, &notificationPB.Event{
+				Type:        notificationPB.EventType_COMMENT_REPLIED,
+				RecipientId: e.RepliedCommentAuthorId,
+				ActorId:     e.AuthorId,
+				EntityId:    e.RepliedCommentId,
+			})
+		} else {
+			notifications = append(notifications, &notificationPB.Event{
+				Type:        notificationPB.EventType_POST_COMMENTED,
+				RecipientId: e.PostAuthorId,
+				ActorId:     e.AuthorId,
+				EntityId:    e.PostId,
+			})
+		}
+		notifications = append(
+			notifications,
+			mentionNotifications(notificationPB.EventType_MENTIONED_IN_COMMENT, e.MentionedUserIds, e.AuthorId, e.CommentId)...,
+		)
+	case *eventsPB.Event_CommentLiked:
+		e := payload.CommentLiked
+		notifications = append(notifications, &notificationPB.Event{
+			Type:        notificationPB.EventType_COMMENT_LIKED,
+			RecipientId: e.CommentAuthorId,
+			ActorId:     e.UserId,
+			EntityId:    e.CommentId,
+		})
+	}
+
+	var errs []error
+	for _, notification := range notifications {
+		if _, err := svc.notificationClient.RecordEvent(ctx, notification); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("record notifications: %w", err)
+	}
+	return nil
+}
+
+func mentionNotifications(
+	eventType notificationPB.EventType, mentionedUserIDs [][]byte, actorID, entityID []byte,
+) []*notificationPB.Event {
+	notifications := make([]*notificationPB.Event, len(mentionedUserIDs))
+	for i, userID := range mentionedUserIDs {
+		notifications[i] = &notificationPB.Event{
+			Type:        eventType,
+			RecipientId: userID,
+			ActorId:     actorID,
+			EntityId:    entityID,
+		}
+	}
+	return notifications
+}
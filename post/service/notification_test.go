@@ -0,0 +1,91 @@
+package service_test
+
+import (
+	"context"
+	"smapp/post/service"
+	"testing"
+
+	eventsPB "smapp/common/grpc/events"
+	notificationPB "smapp/common/grpc/notification"
+	notificationmocks "smapp/common/grpc/notification/mocks"
+
+	"github.com/google/uuid"
+	"github.com/matryer/is"
+	"go.uber.org/mock/gomock"
+)
+
+func TestNotifierHandleCommentCreated(t *testing.T) {
+	postID, postAuthorID := uuid.New(), uuid.New()
+	commentID, authorID := uuid.New(), uuid.New()
+	repliedCommentID, repliedCommentAuthorID := uuid.New(), uuid.New()
+	mentionedID := uuid.New()
+
+	tests := []struct {
+		name          string
+		event         *eventsPB.CommentCreated
+		expectedTypes []notificationPB.EventType
+	}{
+		{
+			name: "top-level comment notifies the post author and mentioned users",
+			event: &eventsPB.CommentCreated{
+				CommentId:        commentID[:],
+				PostId:           postID[:],
+				PostAuthorId:     postAuthorID[:],
+				AuthorId:         authorID[:],
+				MentionedUserIds: [][]byte{mentionedID[:]},
+			},
+			expectedTypes: []notificationPB.EventType{
+				notificationPB.EventType_POST_COMMENTED,
+				notificationPB.EventType_MENTIONED_IN_COMMENT,
+			},
+		},
+		{
+			name: "reply notifies the author of the replied comment instead of the post author",
+			event: &eventsPB.CommentCreated{
+				CommentId:              commentID[:],
+				PostId:                 postID[:],
+				PostAuthorId:           postAuthorID[:],
+				AuthorId:               authorID[:],
+				RepliedCommentId:       repliedCommentID[:],
+				RepliedCommentAuthorId: repliedCommentAuthorID[:],
+			},
+			expectedTypes: []notificationPB.EventType{
+				notificationPB.EventType_COMMENT_REPLIED,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			is := is.New(t)
+			ctrl := gomock.NewController(t)
+
+			var recorded []*notificationPB.Event
+			notificationClient := notificationmocks.NewMockNotificationClient(ctrl)
+			notificationClient.EXPECT().
+				RecordEvent(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ context.Context, event *notificationPB.Event, _ ...any) (*notificationPB.RecordEventResponse, error) {
+					recorded = append(recorded, event)
+					return &notificationPB.RecordEventResponse{}, nil
+				}).
+				Times(len(test.expectedTypes))
+
+			notifier := service.NewNotifier(notificationClient)
+			err := notifier.HandleEvent(context.TODO(), &eventsPB.Event{
+				Payload: &eventsPB.Event_CommentCreated{CommentCreated: test.event},
+			})
+			is.NoErr(err)
+			for i, eventType := range test.expectedTypes {
+				is.Equal(recorded[i].Type, eventType)
+				is.Equal(recorded[i].ActorId, authorID[:])
+			}
+			if len(test.event.RepliedCommentId) > 0 {
+				is.Equal(recorded[0].RecipientId, repliedCommentAuthorID[:])
+				is.Equal(recorded[0].EntityId, repliedCommentID[:])
+			} else {
+				is.Equal(recorded[0].RecipientId, postAuthorID[:])
+				is.Equal(recorded[0].EntityId, postID[:])
+			}
+		})
+	}
+}
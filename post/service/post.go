@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
 	"smapp/post/config"
 	"smapp/post/model"
 	"smapp/post/repository"
@@ -20,38 +21,48 @@ import (
 type Post interface {
 	Create(
 		ctx context.Context, body string, authorID uuid.UUID, images []model.ImageLocation,
+		visibility model.Visibility,
 	) (uuid.UUID, error)
-	GetWithCounts(ctx context.Context, id uuid.UUID) (model.Post, error)
+	GetWithCounts(ctx context.Context, viewerID, id uuid.UUID) (model.Post, error)
 	GetFeed(
-		ctx context.Context, authorID uuid.UUID, cursor model.Cursor, limit int,
+		ctx context.Context, userID uuid.UUID, cursor model.Cursor, limit int,
 	) ([]model.Post, *model.Cursor, error)
+	Search(
+		ctx context.Context, viewerID uuid.UUID, query string, cursor *model.SearchCursor, limit int,
+	) ([]model.Post, *model.SearchCursor, error)
+	Update(ctx context.Context, id, userID uuid.UUID, body string) error
+	Delete(ctx context.Context, id, userID uuid.UUID) error
 }
 
 type DefaultPost struct {
-	postRepository    repository.Post
-	commentRepository *repository.Comment
-	likeRepository    *repository.Like
-	userClient        userPB.UserClient
-	imageClient       imagePB.ImageClient
+	postRepository     repository.Post
+	commentRepository  *repository.Comment
+	likeRepository     *repository.Like
+	timelineRepository repository.Timeline
+	userClient         userPB.UserClient
+	imageClient        imagePB.ImageClient
 }
 
 func NewDefaultPost(
 	postRepository repository.Post, commentRepository *repository.Comment, likeRepository *repository.Like,
-	userClient userPB.UserClient, imageClient imagePB.ImageClient,
+	timelineRepository repository.Timeline, userClient userPB.UserClient, imageClient imagePB.ImageClient,
 ) *DefaultPost {
 	return &DefaultPost{
-		postRepository:    postRepository,
-		commentRepository: commentRepository,
-		likeRepository:    likeRepository,
-		imageClient:       imageClient,
-		userClient:        userClient,
+		postRepository:     postRepository,
+		commentRepository:  commentRepository,
+		likeRepository:     likeRepository,
+		timelineRepository: timelineRepository,
+		imageClient:        imageClient,
+		userClient:         userClient,
 	}
 }
 
 var ErrInvalidImage = fmt.Errorf("image invalid or inaccessible")
 
+// Posts only the author can see are not fanned out to timelines of followers.
 func (svc *DefaultPost) Create(
 	ctx context.Context, body string, authorID uuid.UUID, images []model.ImageLocation,
+	visibility model.Visibility,
 ) (uuid.UUID, error) {
 	fail := func(err error) (uuid.UUID, error) {
 		return uuid.Nil, fmt.Errorf("create post: %w", err)
@@ -73,18 +84,52 @@ func (svc *DefaultPost) Create(
 		}
 	}
 
-	id, err := svc.postRepository.Create(ctx, body, authorID, images)
+	var timelineUserIDs []uuid.UUID
+	if visibility != model.VisibilityOnlyMe {
+		followers, err := svc.userClient.GetFollowerIDs(ctx, &userPB.GetFollowerIDsRequest{
+			UserId: authorID[:],
+			Limit:  config.FanoutFollowersLimit,
+		})
+		if err != nil {
+			return fail(err)
+		}
+		if followers.LimitExceeded {
+			// Once marked, the author stays marked even if the follower count drops, since earlier posts were not
+			// fanned out.
+			if err = svc.timelineRepository.MarkHighFollowerAuthor(ctx, authorID); err != nil {
+				return fail(err)
+			}
+		} else {
+			timelineUserIDs, err = uuidsFromBytes(followers.UserIds)
+			if err != nil {
+				return fail(err)
+			}
+		}
+	}
+
+	mentions, err := resolveMentions(ctx, svc.userClient, body)
+	if err != nil {
+		return fail(err)
+	}
+	mentions, err = removeMentionsOutsideAudience(ctx, svc.userClient, postAudience{authorID, visibility}, mentions)
 	if err != nil {
 		return fail(err)
 	}
 
-	// TODO: Implement expiration for certain tags
+	id, err := svc.postRepository.Create(
+		ctx, body, authorID, images, visibility, extractTags(body), mentions, timelineUserIDs,
+	)
+	if err != nil {
+		return fail(err)
+	}
 
 	return id, nil
 }
 
 // TODO: implement WithLikeCount/WithCommentCount options
-func (svc *DefaultPost) GetWithCounts(ctx context.Context, id uuid.UUID) (model.Post, error) {
+// Posts of private authors are only returned to their followers. Posts the viewer is not in the audience of are
+// reported as not found. uuid.Nil stands for an anonymous viewer.
+func (svc *DefaultPost) GetWithCounts(ctx context.Context, viewerID, id uuid.UUID) (model.Post, error) {
 	fail := func(err error) (model.Post, error) {
 		return model.Post{}, fmt.Errorf("get post: %w", err)
 	}
@@ -96,6 +141,20 @@ func (svc *DefaultPost) GetWithCounts(ctx context.Context, id uuid.UUID) (model.
 	if err != nil {
 		return fail(err)
 	}
+	visible, err := isInAudience(ctx, svc.userClient, viewerID, postAudienceOf(post))
+	if err != nil {
+		return fail(err)
+	}
+	if !visible {
+		return model.Post{}, fmt.Errorf("%w: %s", ErrPostNotFound, id)
+	}
+	err = checkNotPrivate(ctx, svc.userClient, viewerID, post.AuthorID)
+	if errors.Is(err, ErrPrivateAuthor) {
+		return model.Post{}, err
+	}
+	if err != nil {
+		return fail(err)
+	}
 
 	commentCount, err := svc.commentRepository.GetCount(ctx, id)
 	if err != nil {
@@ -109,44 +168,182 @@ func (svc *DefaultPost) GetWithCounts(ctx context.Context, id uuid.UUID) (model.
 	}
 	post.LikeCount = &likeCount
 
+	authors, err := getAuthors(ctx, svc.userClient, []uuid.UUID{post.AuthorID})
+	if err != nil {
+		return fail(err)
+	}
+	post.Author = authors[post.AuthorID]
+
 	return post, nil
 }
 
 var ErrPostsPaginationLimitInvalid = errors.New("posts pagination limit invalid")
 
 func (svc *DefaultPost) GetFeed(
-	ctx context.Context, authorID uuid.UUID, cursor model.Cursor, limit int,
+	ctx context.Context, userID uuid.UUID, cursor model.Cursor, limit int,
 ) ([]model.Post, *model.Cursor, error) {
 	fail := func(err error) ([]model.Post, *model.Cursor, error) {
 		return nil, nil, fmt.Errorf("get feed: %w", err)
 	}
 
-	if limit < 1 || limit > config.CommentsPaginationLimit {
+	if limit < 1 || limit > config.PostsPaginationLimit {
 		return nil, nil, fmt.Errorf(
 			"%w, should be in range: [1, %d]",
-			ErrPostsPaginationLimitInvalid, config.CommentsPaginationLimit,
+			ErrPostsPaginationLimitInvalid, config.PostsPaginationLimit,
 		)
 	}
 
-	followed, err := svc.userClient.GetFollowed(ctx, &userPB.GetFollowedRequest{UserId: authorID[:]})
+	// Posts of high follower authors are not fanned out to timelines, so they have to be merged in on read.
+	followedHighFollowerAuthorIDs, err := getFollowedHighFollowerAuthors(
+		ctx, svc.timelineRepository, svc.userClient, userID,
+	)
 	if err != nil {
 		return fail(err)
 	}
-	if len(followed.UserIds) == 0 {
-		return []model.Post{}, nil, nil
+	// Blocks remove follows, but timelines can still contain posts of blocked authors, e.g. if the removal failed.
+	hiddenAuthorIDs, err := getHiddenUsers(ctx, svc.userClient, userID)
+	if err != nil {
+		return fail(err)
 	}
-	userIDs := make([]uuid.UUID, len(followed.UserIds))
-	for i, userID := range followed.UserIds {
-		userIDs[i], err = uuid.FromBytes(userID)
-		if err != nil {
-			return fail(err)
-		}
+	followedHighFollowerAuthorIDs = slices.DeleteFunc(followedHighFollowerAuthorIDs, func(id uuid.UUID) bool {
+		return slices.Contains(hiddenAuthorIDs, id)
+	})
+
+	posts, nextCursor, err := loadFilteredPage(
+		&cursor, limit,
+		func(cursor *model.Cursor, limit int) ([]model.Post, *model.Cursor, error) {
+			return svc.postRepository.GetTimelineWithCounts(
+				ctx, userID, followedHighFollowerAuthorIDs, hiddenAuthorIDs, *cursor, limit,
+			)
+		},
+		// Timelines also hold posts for followers who are not in their audience, e.g. posts for close friends.
+		func(posts []model.Post) ([]model.Post, error) {
+			return removeNotInAudience(ctx, svc.userClient, userID, posts)
+		},
+	)
+	if err != nil {
+		return fail(err)
+	}
+	if err = embedPostAuthors(ctx, svc.userClient, posts); err != nil {
+		return fail(err)
+	}
+
+	return posts, nextCursor, nil
+}
+
+var (
+	ErrSearchPaginationLimitInvalid = errors.New("search pagination limit invalid")
+	ErrSearchQueryInvalid           = errors.New("search query invalid")
+)
+
+const maxSearchQueryLength = 200
+
+// Posts of private authors are only found by their followers, and other posts only by their audience. uuid.Nil stands
+// for an anonymous viewer.
+func (svc *DefaultPost) Search(
+	ctx context.Context, viewerID uuid.UUID, query string, cursor *model.SearchCursor, limit int,
+) ([]model.Post, *model.SearchCursor, error) {
+	fail := func(err error) ([]model.Post, *model.SearchCursor, error) {
+		return nil, nil, fmt.Errorf("search posts: %w", err)
+	}
+
+	query = strings.TrimSpace(query)
+	if query == "" || len(query) > maxSearchQueryLength {
+		return nil, nil, fmt.Errorf(
+			"%w, should be non-empty and at most %d characters long",
+			ErrSearchQueryInvalid, maxSearchQueryLength,
+		)
+	}
+	if limit < 1 || limit > config.SearchPaginationLimit {
+		return nil, nil, fmt.Errorf(
+			"%w, should be in range: [1, %d]",
+			ErrSearchPaginationLimitInvalid, config.SearchPaginationLimit,
+		)
 	}
 
-	posts, nextCursor, err := svc.postRepository.GetWithCountsByUserIDs(ctx, userIDs, cursor, limit)
+	posts, nextCursor, err := loadFilteredPage(
+		cursor, limit,
+		func(cursor *model.SearchCursor, limit int) ([]model.Post, *model.SearchCursor, error) {
+			return svc.postRepository.SearchWithCounts(ctx, query, cursor, limit)
+		},
+		func(posts []model.Post) ([]model.Post, error) {
+			return removeHiddenPosts(ctx, svc.userClient, viewerID, posts)
+		},
+	)
 	if err != nil {
 		return fail(err)
 	}
+	if err = embedPostAuthors(ctx, svc.userClient, posts); err != nil {
+		return fail(err)
+	}
 
 	return posts, nextCursor, nil
 }
+
+var ErrPostEmpty = errors.New("post must have body or images")
+
+func (svc *DefaultPost) Update(ctx context.Context, id, userID uuid.UUID, body string) error {
+	fail := func(err error) error {
+		return fmt.Errorf("update post: %w", err)
+	}
+
+	post, err := svc.postRepository.Get(ctx, id)
+	if errors.Is(err, repository.ErrRecordNotFound) {
+		return fmt.Errorf("%w: %s", ErrPostNotFound, id)
+	}
+	if err != nil {
+		return fail(err)
+	}
+	if post.AuthorID != userID {
+		return fmt.Errorf("%w: %s", ErrNotPostAuthor, id)
+	}
+	if body == "" && len(post.Images) == 0 {
+		return ErrPostEmpty
+	}
+
+	mentions, err := resolveMentions(ctx, svc.userClient, body)
+	if err != nil {
+		return fail(err)
+	}
+	mentions, err = removeMentionsOutsideAudience(ctx, svc.userClient, postAudienceOf(post), mentions)
+	if err != nil {
+		return fail(err)
+	}
+
+	err = svc.postRepository.Update(ctx, id, body, extractTags(body), mentions)
+	// The post could have been deleted by a concurrent request after the author check.
+	if errors.Is(err, repository.ErrRecordNotFound) {
+		return fmt.Errorf("%w: %s", ErrPostNotFound, id)
+	}
+	if err != nil {
+		return fail(err)
+	}
+	return nil
+}
+
+func (svc *DefaultPost) Delete(ctx context.Context, id, userID uuid.UUID) error {
+	fail := func(err error) error {
+		return fmt.Errorf("delete post: %w", err)
+	}
+
+	authorID, err := svc.postRepository.GetAuthorID(ctx, id)
+	if errors.Is(err, repository.ErrRecordNotFound) {
+		return fmt.Errorf("%w: %s", ErrPostNotFound, id)
+	}
+	if err != nil {
+		return fail(err)
+	}
+	if authorID != userID {
+		return fmt.Errorf("%w: %s", ErrNotPostAuthor, id)
+	}
+
+	err = svc.postRepository.Delete(ctx, id)
+	// The post could have been deleted by a concurrent request after the author check.
+	if errors.Is(err, repository.ErrRecordNotFound) {
+		return fmt.Errorf("%w: %s", ErrPostNotFound, id)
+	}
+	if err != nil {
+		return fail(err)
+	}
+	return nil
+}
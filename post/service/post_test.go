@@ -1,13 +1,18 @@
 package service_test
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"smapp/post/model"
+	"smapp/post/repository"
 	"smapp/post/service"
+	"strings"
 	"testing"
 
 	imagemocks "smapp/common/grpc/image/mocks"
+	userPB "smapp/common/grpc/user"
+	usermocks "smapp/common/grpc/user/mocks"
 	repomocks "smapp/post/repository/mocks"
 
 	"github.com/google/uuid"
@@ -32,11 +37,17 @@ func TestDefaultPostCreate(t *testing.T) {
 		returnedPostID[i] = byte(i)
 	}
 
+	followerIDs := make([][]byte, 2)
+	for i := range followerIDs {
+		id := uuid.New()
+		followerIDs[i] = id[:]
+	}
+
 	getPostMockReturnsError := func(err error) func(ctrl *gomock.Controller) *repomocks.MockPost {
 		return func(ctrl *gomock.Controller) *repomocks.MockPost {
 			m := repomocks.NewMockPost(ctrl)
 			m.EXPECT().
-				Create(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+				Create(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
 				Return(uuid.Nil, err)
 			return m
 		}
@@ -64,7 +75,10 @@ func TestDefaultPostCreate(t *testing.T) {
 			getPostMock: func(ctrl *gomock.Controller) *repomocks.MockPost {
 				m := repomocks.NewMockPost(ctrl)
 				m.EXPECT().
-					Create(gomock.Any(), body, authorID, gomock.Len(2)).
+					Create(
+						gomock.Any(), body, authorID, gomock.Len(2), model.VisibilityPublic, gomock.Any(), gomock.Any(),
+						gomock.Len(len(followerIDs)),
+					).
 					Return(returnedPostID, nil)
 				return m
 			},
@@ -87,7 +101,7 @@ func TestDefaultPostCreate(t *testing.T) {
 			getPostMock: func(ctrl *gomock.Controller) *repomocks.MockPost {
 				m := repomocks.NewMockPost(ctrl)
 				m.EXPECT().
-					Create(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+					Create(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
 					Times(0)
 				return m
 			},
@@ -107,7 +121,7 @@ func TestDefaultPostCreate(t *testing.T) {
 			getPostMock: func(ctrl *gomock.Controller) *repomocks.MockPost {
 				m := repomocks.NewMockPost(ctrl)
 				m.EXPECT().
-					Create(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+					Create(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
 					Times(0)
 				return m
 			},
@@ -159,9 +173,527 @@ func TestDefaultPostCreate(t *testing.T) {
 		t.Run(test.name, func(t *testing.T) {
 			is := is.New(t)
 			ctrl := gomock.NewController(t)
-			post := service.NewDefaultPost(test.getPostMock(ctrl), nil, nil, nil, test.getImageMock(ctrl))
-			id, err := post.Create(context.TODO(), body, authorID, test.images)
+			userClient := usermocks.NewMockUserClient(ctrl)
+			userClient.EXPECT().
+				GetFollowerIDs(gomock.Any(), gomock.Any()).
+				Return(&userPB.GetFollowerIDsResponse{UserIds: followerIDs}, nil).
+				AnyTimes()
+			post := service.NewDefaultPost(test.getPostMock(ctrl), nil, nil, nil, userClient, test.getImageMock(ctrl))
+			id, err := post.Create(context.TODO(), body, authorID, test.images, model.VisibilityPublic)
 			test.checkResult(is, id, err)
 		})
 	}
 }
+
+func TestDefaultPostDelete(t *testing.T) {
+	var authorID, otherUserID, postID uuid.UUID
+	for i := 0; i < 16; i++ {
+		authorID[i] = byte(i)
+		otherUserID[i] = byte(i + 1)
+		postID[i] = byte(15 - i)
+	}
+
+	unknownError := errors.New("unknown error")
+
+	tests := []struct {
+		name        string
+		userID      uuid.UUID
+		getPostMock func(*gomock.Controller) *repomocks.MockPost
+		targetErr   error
+	}{
+		{
+			name:   "deletes the post when called by the author",
+			userID: authorID,
+			getPostMock: func(ctrl *gomock.Controller) *repomocks.MockPost {
+				m := repomocks.NewMockPost(ctrl)
+				m.EXPECT().GetAuthorID(gomock.Any(), postID).Return(authorID, nil)
+				m.EXPECT().Delete(gomock.Any(), postID).Return(nil)
+				return m
+			},
+			targetErr: nil,
+		},
+		{
+			name:   "returns ErrNotPostAuthor when called by another user",
+			userID: otherUserID,
+			getPostMock: func(ctrl *gomock.Controller) *repomocks.MockPost {
+				m := repomocks.NewMockPost(ctrl)
+				m.EXPECT().GetAuthorID(gomock.Any(), postID).Return(authorID, nil)
+				m.EXPECT().Delete(gomock.Any(), gomock.Any()).Times(0)
+				return m
+			},
+			targetErr: service.ErrNotPostAuthor,
+		},
+		{
+			name:   "returns ErrPostNotFound when the post does not exist",
+			userID: authorID,
+			getPostMock: func(ctrl *gomock.Controller) *repomocks.MockPost {
+				m := repomocks.NewMockPost(ctrl)
+				m.EXPECT().GetAuthorID(gomock.Any(), postID).Return(uuid.Nil, repository.ErrRecordNotFound)
+				m.EXPECT().Delete(gomock.Any(), gomock.Any()).Times(0)
+				return m
+			},
+			targetErr: service.ErrPostNotFound,
+		},
+		{
+			name:   "returns the error that the repository returns if it is unknown",
+			userID: authorID,
+			getPostMock: func(ctrl *gomock.Controller) *repomocks.MockPost {
+				m := repomocks.NewMockPost(ctrl)
+				m.EXPECT().GetAuthorID(gomock.Any(), postID).Return(authorID, nil)
+				m.EXPECT().Delete(gomock.Any(), postID).Return(unknownError)
+				return m
+			},
+			targetErr: unknownError,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			is := is.New(t)
+			ctrl := gomock.NewController(t)
+			post := service.NewDefaultPost(test.getPostMock(ctrl), nil, nil, nil, nil, nil)
+			err := post.Delete(context.TODO(), postID, test.userID)
+			if test.targetErr == nil {
+				is.NoErr(err)
+			} else {
+				is.True(errors.Is(err, test.targetErr))
+			}
+		})
+	}
+}
+
+func TestDefaultPostGetFeedEmbedsAuthors(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+
+	var userID, authorID1, authorID2 uuid.UUID
+	for i := 0; i < 16; i++ {
+		userID[i] = byte(i)
+		authorID1[i] = byte(i + 1)
+		authorID2[i] = byte(i + 2)
+	}
+	posts := []model.Post{
+		{ID: uuid.New(), AuthorID: authorID1, Visibility: model.VisibilityPublic},
+		{ID: uuid.New(), AuthorID: authorID2, Visibility: model.VisibilityPublic},
+		{ID: uuid.New(), AuthorID: authorID1, Visibility: model.VisibilityPublic},
+	}
+
+	timelineRepository := repomocks.NewMockTimeline(ctrl)
+	timelineRepository.EXPECT().GetHighFollowerAuthors(gomock.Any()).Return([]uuid.UUID{}, nil)
+
+	userClient := usermocks.NewMockUserClient(ctrl)
+	userClient.EXPECT().FilterFollowed(gomock.Any(), gomock.Any()).Times(0)
+	userClient.EXPECT().GetHiddenUsers(gomock.Any(), gomock.Any()).Return(&userPB.GetHiddenUsersResponse{}, nil)
+	userClient.EXPECT().
+		GetUsers(gomock.Any(), gomock.Cond(func(req any) bool {
+			// Author IDs should be deduplicated
+			return len(req.(*userPB.GetUsersRequest).UserIds) == 2
+		})).
+		Return(&userPB.GetUsersResponse{Users: []*userPB.UserInfo{
+			{Id: authorID1[:], Name: "Author 1", Handle: "author1"},
+			{
+				Id: authorID2[:], Name: "Author 2", Handle: "author2",
+				ImageUrl: "https://images.example.com/bucket/images/profile/key",
+			},
+		}}, nil)
+
+	postRepository := repomocks.NewMockPost(ctrl)
+	postRepository.EXPECT().
+		GetTimelineWithCounts(gomock.Any(), userID, gomock.Len(0), gomock.Len(0), gomock.Any(), 10).
+		Return(posts, nil, nil)
+
+	post := service.NewDefaultPost(postRepository, nil, nil, timelineRepository, userClient, nil)
+	result, nextCursor, err := post.GetFeed(context.TODO(), userID, model.Cursor{}, 10)
+	is.NoErr(err)
+	is.Equal(nextCursor, nil)
+	is.Equal(len(result), 3)
+	is.Equal(result[0].Author.Handle, "author1")
+	is.Equal(result[0].Author.ImageURL, "")
+	is.Equal(result[1].Author.Handle, "author2")
+	is.Equal(result[1].Author.ImageURL, "https://images.example.com/bucket/images/profile/key")
+	is.Equal(result[2].Author.Handle, "author1")
+}
+
+func TestDefaultPostGetFeedExcludesHiddenAuthors(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+
+	userID, followedID, blockedID, mutedID := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+
+	timelineRepository := repomocks.NewMockTimeline(ctrl)
+	timelineRepository.EXPECT().
+		GetHighFollowerAuthors(gomock.Any()).
+		Return([]uuid.UUID{followedID, mutedID}, nil)
+
+	userClient := usermocks.NewMockUserClient(ctrl)
+	userClient.EXPECT().
+		FilterFollowed(gomock.Any(), gomock.Any()).
+		Return(&userPB.FilterFollowedResponse{UserIds: [][]byte{followedID[:], mutedID[:]}}, nil)
+	userClient.EXPECT().
+		GetHiddenUsers(gomock.Any(), gomock.Any()).
+		Return(&userPB.GetHiddenUsersResponse{BlockedIds: [][]byte{blockedID[:]}, MutedIds: [][]byte{mutedID[:]}}, nil)
+
+	// A muted author is still followed, but their posts are not merged in.
+	postRepository := repomocks.NewMockPost(ctrl)
+	postRepository.EXPECT().
+		GetTimelineWithCounts(gomock.Any(), userID, []uuid.UUID{followedID}, []uuid.UUID{blockedID, mutedID}, gomock.Any(), 10).
+		Return([]model.Post{}, nil, nil)
+
+	post := service.NewDefaultPost(postRepository, nil, nil, timelineRepository, userClient, nil)
+	result, _, err := post.GetFeed(context.TODO(), userID, model.Cursor{}, 10)
+	is.NoErr(err)
+	is.Equal(len(result), 0)
+}
+
+func TestDefaultPostGetWithCountsPrivateAuthor(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+
+	postID, authorID := uuid.New(), uuid.New()
+
+	postRepository := repomocks.NewMockPost(ctrl)
+	postRepository.EXPECT().
+		Get(gomock.Any(), postID).
+		Return(model.Post{ID: postID, AuthorID: authorID, Visibility: model.VisibilityPublic}, nil)
+
+	// The viewer is anonymous, so the viewer ID is left empty.
+	userClient := usermocks.NewMockUserClient(ctrl)
+	userClient.EXPECT().
+		FilterPrivateAuthors(gomock.Any(), &userPB.FilterPrivateAuthorsRequest{AuthorIds: [][]byte{authorID[:]}}).
+		Return(&userPB.FilterPrivateAuthorsResponse{AuthorIds: [][]byte{authorID[:]}}, nil)
+
+	// Counts are not loaded for a post the viewer cannot see.
+	post := service.NewDefaultPost(postRepository, nil, nil, nil, userClient, nil)
+	_, err := post.GetWithCounts(context.TODO(), uuid.Nil, postID)
+	is.True(errors.Is(err, service.ErrPrivateAuthor))
+}
+
+func TestDefaultPostGetWithCountsAnonymousViewer(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+
+	postID, authorID := uuid.New(), uuid.New()
+
+	postRepository := repomocks.NewMockPost(ctrl)
+	postRepository.EXPECT().
+		Get(gomock.Any(), postID).
+		Return(model.Post{ID: postID, AuthorID: authorID, Visibility: model.VisibilityFollowers}, nil)
+
+	// An anonymous viewer follows no one, so the user service is not asked.
+	post := service.NewDefaultPost(postRepository, nil, nil, nil, usermocks.NewMockUserClient(ctrl), nil)
+	_, err := post.GetWithCounts(context.TODO(), uuid.Nil, postID)
+	is.True(errors.Is(err, service.ErrPostNotFound))
+}
+
+func TestDefaultPostGetFeedRemovesPostsOutsideAudience(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+
+	userID := uuid.New()
+	followedID, notFollowedID := uuid.New(), uuid.New()
+	listedByID, notListedByID := uuid.New(), uuid.New()
+	posts := []model.Post{
+		{ID: uuid.New(), AuthorID: notFollowedID, Visibility: model.VisibilityPublic},
+		{ID: uuid.New(), AuthorID: followedID, Visibility: model.VisibilityFollowers},
+		{ID: uuid.New(), AuthorID: notFollowedID, Visibility: model.VisibilityFollowers},
+		{ID: uuid.New(), AuthorID: listedByID, Visibility: model.VisibilityCloseFriends},
+		{ID: uuid.New(), AuthorID: notListedByID, Visibility: model.VisibilityCloseFriends},
+		{ID: uuid.New(), AuthorID: followedID, Visibility: model.VisibilityOnlyMe},
+		{ID: uuid.New(), AuthorID: userID, Visibility: model.VisibilityOnlyMe},
+	}
+
+	timelineRepository := repomocks.NewMockTimeline(ctrl)
+	timelineRepository.EXPECT().GetHighFollowerAuthors(gomock.Any()).Return([]uuid.UUID{}, nil)
+
+	userClient := usermocks.NewMockUserClient(ctrl)
+	userClient.EXPECT().GetHiddenUsers(gomock.Any(), gomock.Any()).Return(&userPB.GetHiddenUsersResponse{}, nil)
+	userClient.EXPECT().
+		FilterFollowed(gomock.Any(), &userPB.FilterFollowedRequest{
+			UserId:       userID[:],
+			CandidateIds: [][]byte{followedID[:], notFollowedID[:]},
+		}).
+		Return(&userPB.FilterFollowedResponse{UserIds: [][]byte{followedID[:]}}, nil)
+	userClient.EXPECT().
+		FilterCloseFriendOf(gomock.Any(), &userPB.FilterCloseFriendOfRequest{
+			UserId:       userID[:],
+			CandidateIds: [][]byte{listedByID[:], notListedByID[:]},
+		}).
+		Return(&userPB.FilterCloseFriendOfResponse{UserIds: [][]byte{listedByID[:]}}, nil)
+	userClient.EXPECT().GetUsers(gomock.Any(), gomock.Any()).Return(&userPB.GetUsersResponse{}, nil)
+
+	postRepository := repomocks.NewMockPost(ctrl)
+	postRepository.EXPECT().
+		GetTimelineWithCounts(gomock.Any(), userID, gomock.Any(), gomock.Any(), gomock.Any(), 10).
+		Return(posts, nil, nil)
+
+	post := service.NewDefaultPost(postRepository, nil, nil, timelineRepository, userClient, nil)
+	result, _, err := post.GetFeed(context.TODO(), userID, model.Cursor{}, 10)
+	is.NoErr(err)
+	is.Equal(result, []model.Post{posts[0], posts[1], posts[3], posts[6]})
+}
+
+func TestDefaultPostGetFeedFillsPage(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+
+	userID, notFollowedID := uuid.New(), uuid.New()
+	firstPage := []model.Post{
+		{ID: uuid.New(), AuthorID: notFollowedID, Visibility: model.VisibilityPublic},
+		{ID: uuid.New(), AuthorID: notFollowedID, Visibility: model.VisibilityFollowers},
+	}
+	secondPage := []model.Post{
+		{ID: uuid.New(), AuthorID: notFollowedID, Visibility: model.VisibilityPublic},
+	}
+	firstCursor := &model.Cursor{LastLoadedID: firstPage[1].ID}
+	secondCursor := &model.Cursor{LastLoadedID: secondPage[0].ID}
+
+	timelineRepository := repomocks.NewMockTimeline(ctrl)
+	timelineRepository.EXPECT().GetHighFollowerAuthors(gomock.Any()).Return([]uuid.UUID{}, nil)
+
+	userClient := usermocks.NewMockUserClient(ctrl)
+	userClient.EXPECT().GetHiddenUsers(gomock.Any(), gomock.Any()).Return(&userPB.GetHiddenUsersResponse{}, nil)
+	userClient.EXPECT().FilterFollowed(gomock.Any(), gomock.Any()).Return(&userPB.FilterFollowedResponse{}, nil)
+	userClient.EXPECT().GetUsers(gomock.Any(), gomock.Any()).Return(&userPB.GetUsersResponse{}, nil)
+
+	// The second post of the first page is removed, so only the missing post is loaded from where the page ended.
+	postRepository := repomocks.NewMockPost(ctrl)
+	gomock.InOrder(
+		postRepository.EXPECT().
+			GetTimelineWithCounts(gomock.Any(), userID, gomock.Any(), gomock.Any(), model.Cursor{}, 2).
+			Return(firstPage, firstCursor, nil),
+		postRepository.EXPECT().
+			GetTimelineWithCounts(gomock.Any(), userID, gomock.Any(), gomock.Any(), *firstCursor, 1).
+			Return(secondPage, secondCursor, nil),
+	)
+
+	post := service.NewDefaultPost(postRepository, nil, nil, timelineRepository, userClient, nil)
+	result, nextCursor, err := post.GetFeed(context.TODO(), userID, model.Cursor{}, 2)
+	is.NoErr(err)
+	is.Equal(result, []model.Post{firstPage[0], secondPage[0]})
+	is.Equal(nextCursor, secondCursor)
+}
+
+func TestDefaultPostCreateHighFollowerAuthor(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+
+	var authorID uuid.UUID
+	for i := 0; i < 16; i++ {
+		authorID[i] = byte(i)
+	}
+
+	userClient := usermocks.NewMockUserClient(ctrl)
+	userClient.EXPECT().
+		GetFollowerIDs(gomock.Any(), gomock.Any()).
+		Return(&userPB.GetFollowerIDsResponse{LimitExceeded: true}, nil)
+
+	timelineRepository := repomocks.NewMockTimeline(ctrl)
+	timelineRepository.EXPECT().MarkHighFollowerAuthor(gomock.Any(), authorID).Return(nil)
+
+	returnedPostID := uuid.New()
+	postRepository := repomocks.NewMockPost(ctrl)
+	postRepository.EXPECT().
+		Create(
+			gomock.Any(), "Post body!", authorID, gomock.Len(0), model.VisibilityPublic, gomock.Len(0), gomock.Len(0),
+			gomock.Len(0),
+		).
+		Return(returnedPostID, nil)
+
+	post := service.NewDefaultPost(postRepository, nil, nil, timelineRepository, userClient, nil)
+	id, err := post.Create(context.TODO(), "Post body!", authorID, nil, model.VisibilityPublic)
+	is.NoErr(err)
+	is.Equal(id, returnedPostID)
+}
+
+func TestDefaultPostSearch(t *testing.T) {
+	tests := []struct {
+		name            string
+		query           string
+		limit           int
+		expectedQuery   string
+		repositoryCalls int
+		expectedErr     error
+	}{
+		{
+			name:            "query is trimmed",
+			query:           "  golang  ",
+			limit:           10,
+			expectedQuery:   "golang",
+			repositoryCalls: 1,
+		},
+		{
+			name:        "blank query",
+			query:       "   ",
+			limit:       10,
+			expectedErr: service.ErrSearchQueryInvalid,
+		},
+		{
+			name:        "query too long",
+			query:       strings.Repeat("a", 201),
+			limit:       10,
+			expectedErr: service.ErrSearchQueryInvalid,
+		},
+		{
+			name:        "limit too big",
+			query:       "golang",
+			limit:       31,
+			expectedErr: service.ErrSearchPaginationLimitInvalid,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			is := is.New(t)
+			ctrl := gomock.NewController(t)
+
+			postRepository := repomocks.NewMockPost(ctrl)
+			postRepository.EXPECT().
+				SearchWithCounts(gomock.Any(), tt.expectedQuery, nil, tt.limit).
+				Return([]model.Post{}, nil, nil).
+				Times(tt.repositoryCalls)
+
+			post := service.NewDefaultPost(postRepository, nil, nil, nil, nil, nil)
+			_, _, err := post.Search(context.TODO(), uuid.Nil, tt.query, nil, tt.limit)
+			is.True(errors.Is(err, tt.expectedErr))
+		})
+	}
+}
+
+func TestDefaultPostCreateExtractsTags(t *testing.T) {
+	tests := []struct {
+		name         string
+		body         string
+		expectedTags []string
+	}{
+		{
+			name:         "tags are lowercased and deduplicated",
+			body:         "#Go is great, #go #GoLang",
+			expectedTags: []string{"go", "golang"},
+		},
+		{
+			name:         "unicode tags",
+			body:         "Привет #мир and #café_au_lait!",
+			expectedTags: []string{"мир", "café_au_lait"},
+		},
+		{
+			name:         "hash inside a word or URL is not a tag",
+			body:         "I like C#, see https://example.com/page#section and a#b",
+			expectedTags: []string{},
+		},
+		{
+			name:         "numeric tags are ignored",
+			body:         "We are #1 in #2024goals",
+			expectedTags: []string{"2024goals"},
+		},
+		{
+			name:         "no tags",
+			body:         "Just a post",
+			expectedTags: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			is := is.New(t)
+			ctrl := gomock.NewController(t)
+
+			userClient := usermocks.NewMockUserClient(ctrl)
+			userClient.EXPECT().
+				GetFollowerIDs(gomock.Any(), gomock.Any()).
+				Return(&userPB.GetFollowerIDsResponse{}, nil)
+
+			postRepository := repomocks.NewMockPost(ctrl)
+			postRepository.EXPECT().
+				Create(gomock.Any(), tt.body, gomock.Any(), gomock.Any(), gomock.Any(), tt.expectedTags, gomock.Any(), gomock.Any()).
+				Return(uuid.New(), nil)
+
+			post := service.NewDefaultPost(postRepository, nil, nil, nil, userClient, nil)
+			_, err := post.Create(context.TODO(), tt.body, uuid.New(), nil, model.VisibilityPublic)
+			is.NoErr(err)
+		})
+	}
+}
+
+func TestDefaultPostCreateResolvesMentions(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+
+	aliceID, bobID := uuid.New(), uuid.New()
+	body := "Привет @Alice, cc @bob and @alice. @ghost a@b.com"
+
+	userClient := usermocks.NewMockUserClient(ctrl)
+	userClient.EXPECT().
+		GetFollowerIDs(gomock.Any(), gomock.Any()).
+		Return(&userPB.GetFollowerIDsResponse{}, nil)
+	userClient.EXPECT().
+		ResolveHandles(gomock.Any(), gomock.Cond(func(req any) bool {
+			// Handles should be deduplicated case-insensitively, and the email should not be a mention
+			return len(req.(*userPB.ResolveHandlesRequest).Handles) == 3
+		})).
+		Return(&userPB.ResolveHandlesResponse{Users: []*userPB.ResolvedHandle{
+			{Handle: "alice", UserId: aliceID[:]},
+			{Handle: "Bob", UserId: bobID[:]},
+		}}, nil)
+	// Checked once per mentioned user
+	userClient.EXPECT().
+		FilterPrivateAuthors(gomock.Any(), gomock.Any()).
+		Return(&userPB.FilterPrivateAuthorsResponse{}, nil).
+		Times(2)
+
+	postRepository := repomocks.NewMockPost(ctrl)
+	postRepository.EXPECT().
+		Create(gomock.Any(), body, gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), []model.Mention{
+			// Offsets are in code points, and the unknown handle stays plain text
+			{UserID: aliceID, Offset: 7, Length: 6},
+			{UserID: bobID, Offset: 18, Length: 4},
+			{UserID: aliceID, Offset: 27, Length: 6},
+		}, gomock.Any()).
+		Return(uuid.New(), nil)
+
+	post := service.NewDefaultPost(postRepository, nil, nil, nil, userClient, nil)
+	_, err := post.Create(context.TODO(), body, uuid.New(), nil, model.VisibilityPublic)
+	is.NoErr(err)
+}
+
+func TestDefaultPostCreateRemovesMentionsOutsideAudience(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+
+	authorID, followerID, strangerID := uuid.New(), uuid.New(), uuid.New()
+	body := "@follower @stranger"
+
+	userClient := usermocks.NewMockUserClient(ctrl)
+	userClient.EXPECT().
+		GetFollowerIDs(gomock.Any(), gomock.Any()).
+		Return(&userPB.GetFollowerIDsResponse{UserIds: [][]byte{followerID[:]}}, nil)
+	userClient.EXPECT().
+		ResolveHandles(gomock.Any(), gomock.Any()).
+		Return(&userPB.ResolveHandlesResponse{Users: []*userPB.ResolvedHandle{
+			{Handle: "follower", UserId: followerID[:]},
+			{Handle: "stranger", UserId: strangerID[:]},
+		}}, nil)
+	userClient.EXPECT().
+		FilterFollowed(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, req *userPB.FilterFollowedRequest, _ ...any) (*userPB.FilterFollowedResponse, error) {
+			if bytes.Equal(req.UserId, followerID[:]) {
+				return &userPB.FilterFollowedResponse{UserIds: [][]byte{authorID[:]}}, nil
+			}
+			return &userPB.FilterFollowedResponse{}, nil
+		}).
+		Times(2)
+	userClient.EXPECT().
+		FilterPrivateAuthors(gomock.Any(), gomock.Any()).
+		Return(&userPB.FilterPrivateAuthorsResponse{}, nil)
+
+	// The stranger is not a follower, so they are neither linked nor notified.
+	postRepository := repomocks.NewMockPost(ctrl)
+	postRepository.EXPECT().
+		Create(gomock.Any(), body, gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), []model.Mention{
+			{UserID: followerID, Offset: 0, Length: 9},
+		}, gomock.Any()).
+		Return(uuid.New(), nil)
+
+	post := service.NewDefaultPost(postRepository, nil, nil, nil, userClient, nil)
+	_, err := post.Create(context.TODO(), body, authorID, nil, model.VisibilityFollowers)
+	is.NoErr(err)
+}
@@ -0,0 +1,206 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"smapp/post/config"
+	"smapp/post/model"
+	"smapp/post/repository"
+	"time"
+
+	userPB "smapp/common/grpc/user"
+
+	"github.com/google/uuid"
+)
+
+type Stream struct {
+	streamRepository   *repository.Stream
+	postRepository     repository.Post
+	timelineRepository repository.Timeline
+	userClient         userPB.UserClient
+}
+
+func NewStream(
+	streamRepository *repository.Stream, postRepository repository.Post, timelineRepository repository.Timeline,
+	userClient userPB.UserClient,
+) *Stream {
+	return &Stream{
+		streamRepository:   streamRepository,
+		postRepository:     postRepository,
+		timelineRepository: timelineRepository,
+		userClient:         userClient,
+	}
+}
+
+// Cursor of a stream that starts now.
+func (svc *Stream) NewCursor() model.StreamCursor {
+	// Everything created up to and including the current second is treated as already seen.
+	cursor := model.Cursor{LastLoadedTimestamp: time.Now().UTC().Truncate(time.Second), LastLoadedID: uuid.Max}
+	return model.StreamCursor{Posts: cursor, Likes: cursor, Comments: cursor}
+}
+
+// StreamSession is the state of one open feed stream.
+type StreamSession struct {
+	svc            *Stream
+	userID         uuid.UUID
+	extraAuthorIDs []uuid.UUID
+	// Events of users the user has blocked, muted or been blocked by are skipped.
+	hiddenUserIDs []uuid.UUID
+	cursor        model.StreamCursor
+}
+
+func (svc *Stream) Open(ctx context.Context, userID uuid.UUID, cursor model.StreamCursor) (*StreamSession, error) {
+	fail := func(err error) (*StreamSession, error) {
+		return nil, fmt.Errorf("open stream: %w", err)
+	}
+
+	// Following or unfollowing a high follower author, blocks and mutes take effect when the client reconnects.
+	extraAuthorIDs, err := getFollowedHighFollowerAuthors(ctx, svc.timelineRepository, svc.userClient, userID)
+	if err != nil {
+		return fail(err)
+	}
+	hiddenUserIDs, err := getHiddenUsers(ctx, svc.userClient, userID)
+	if err != nil {
+		return fail(err)
+	}
+	extraAuthorIDs = slices.DeleteFunc(extraAuthorIDs, func(id uuid.UUID) bool {
+		return slices.Contains(hiddenUserIDs, id)
+	})
+	return &StreamSession{
+		svc:            svc,
+		userID:         userID,
+		extraAuthorIDs: extraAuthorIDs,
+		hiddenUserIDs:  hiddenUserIDs,
+		cursor:         cursor,
+	}, nil
+}
+
+// Poll returns the events that happened since the previous poll, and whether there are more events to poll right away.
+// New posts come first, then likes, then comments. Each kind is ordered by creation time.
+func (session *StreamSession) Poll(ctx context.Context) ([]model.StreamEvent, bool, error) {
+	fail := func(err error) ([]model.StreamEvent, bool, error) {
+		return nil, false, fmt.Errorf("poll stream: %w", err)
+	}
+	svc := session.svc
+	until := time.Now().Add(-config.StreamSettleDelay)
+	limit := config.StreamEventsLimit
+
+	posts, err := svc.streamRepository.GetNewPosts(
+		ctx, session.userID, session.extraAuthorIDs, session.cursor.Posts, until, limit,
+	)
+	if err != nil {
+		return fail(err)
+	}
+	likes, err := svc.streamRepository.GetNewLikes(ctx, session.userID, session.cursor.Likes, until, limit)
+	if err != nil {
+		return fail(err)
+	}
+	comments, err := svc.streamRepository.GetNewComments(ctx, session.userID, session.cursor.Comments, until, limit)
+	if err != nil {
+		return fail(err)
+	}
+	more := len(posts) == limit || len(likes) == limit || len(comments) == limit
+
+	actorIDs := make([]uuid.UUID, 0, len(posts)+len(likes)+len(comments))
+	for _, items := range [][]model.StreamItem{posts, likes, comments} {
+		for _, item := range items {
+			if !session.isHidden(item) {
+				actorIDs = append(actorIDs, item.ActorID)
+			}
+		}
+	}
+	actors, err := getAuthors(ctx, svc.userClient, actorIDs)
+	if err != nil {
+		return fail(err)
+	}
+
+	// The session only moves on once the whole poll has succeeded. Hidden items are skipped, but the cursor moves past
+	// them.
+	cursor := session.cursor
+	events := make([]model.StreamEvent, 0, len(actorIDs))
+	postIDs := make([]uuid.UUID, 0, len(posts))
+	for _, item := range posts {
+		if !session.isHidden(item) {
+			postIDs = append(postIDs, item.ID)
+		}
+	}
+	// Posts deleted since they were read from the timeline are missing from postsByID.
+	postsByID, err := svc.postRepository.GetByIDs(ctx, postIDs)
+	if err != nil {
+		return fail(err)
+	}
+	// Posts that are hidden, deleted or not shared with the user stay nil.
+	loadedPosts := make([]*model.Post, len(posts))
+	loadedIndexes := make([]int, 0, len(posts))
+	audiences := make([]postAudience, 0, len(posts))
+	for i, item := range posts {
+		post, ok := postsByID[item.ID]
+		if !ok {
+			continue
+		}
+		loadedPosts[i] = &post
+		loadedIndexes = append(loadedIndexes, i)
+		audiences = append(audiences, postAudienceOf(post))
+	}
+	visible, err := inAudience(ctx, svc.userClient, session.userID, audiences)
+	if err != nil {
+		return fail(err)
+	}
+	for j, i := range loadedIndexes {
+		if !visible[j] {
+			loadedPosts[i] = nil
+		}
+	}
+	for i, item := range posts {
+		cursor.Posts = itemCursor(item)
+		post := loadedPosts[i]
+		if post == nil {
+			continue
+		}
+		post.Author = actors[item.ActorID]
+		events = append(events, newStreamEvent(model.StreamPostCreated, item, post, nil, actors, cursor))
+	}
+	for _, item := range likes {
+		cursor.Likes = itemCursor(item)
+		if session.isHidden(item) {
+			continue
+		}
+		events = append(events, newStreamEvent(model.StreamPostLiked, item, nil, nil, actors, cursor))
+	}
+	for _, item := range comments {
+		cursor.Comments = itemCursor(item)
+		if session.isHidden(item) {
+			continue
+		}
+		commentID := item.ID
+		events = append(events, newStreamEvent(model.StreamPostCommented, item, nil, &commentID, actors, cursor))
+	}
+
+	session.cursor = cursor
+	return events, more, nil
+}
+
+func (session *StreamSession) isHidden(item model.StreamItem) bool {
+	return slices.Contains(session.hiddenUserIDs, item.ActorID)
+}
+
+func newStreamEvent(
+	eventType model.StreamEventType, item model.StreamItem, post *model.Post, commentID *uuid.UUID,
+	actors map[uuid.UUID]*model.Author, cursor model.StreamCursor,
+) model.StreamEvent {
+	return model.StreamEvent{
+		Type:      eventType,
+		PostID:    item.PostID,
+		Post:      post,
+		CommentID: commentID,
+		ActorID:   item.ActorID,
+		Actor:     actors[item.ActorID],
+		CreatedAt: item.CreatedAt,
+		Cursor:    cursor,
+	}
+}
+
+func itemCursor(item model.StreamItem) model.Cursor {
+	return model.Cursor{LastLoadedTimestamp: item.CreatedAt, LastLoadedID: item.ID}
+}
@@ -0,0 +1,159 @@
+package service_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"smapp/post/config"
+	"smapp/post/model"
+	"smapp/post/repository"
+	"smapp/post/service"
+	"testing"
+	"time"
+
+	userPB "smapp/common/grpc/user"
+	usermocks "smapp/common/grpc/user/mocks"
+	repomocks "smapp/post/repository/mocks"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/matryer/is"
+	"go.uber.org/mock/gomock"
+)
+
+var streamItemColumns = []string{"id", "post_id", "actor_id", "created_at"}
+
+// Arguments of a stream query of the user after cursor, the until time is not checked.
+func streamArgs(userID uuid.UUID, cursor model.Cursor, userArgs int) []driver.Value {
+	args := make([]driver.Value, 0, userArgs+5)
+	for i := 0; i < userArgs; i++ {
+		args = append(args, userID[:])
+	}
+	return append(
+		args,
+		cursor.LastLoadedTimestamp, cursor.LastLoadedTimestamp, cursor.LastLoadedID[:], sqlmock.AnyArg(),
+		config.StreamEventsLimit,
+	)
+}
+
+func TestStreamSessionPoll(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+
+	db, mock, err := sqlmock.New()
+	is.NoErr(err)
+	defer db.Close()
+
+	userID, authorID, mutedID := uuid.New(), uuid.New(), uuid.New()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cursor := model.StreamCursor{
+		Posts:    model.Cursor{LastLoadedTimestamp: start, LastLoadedID: uuid.Max},
+		Likes:    model.Cursor{LastLoadedTimestamp: start, LastLoadedID: uuid.Max},
+		Comments: model.Cursor{LastLoadedTimestamp: start, LastLoadedID: uuid.Max},
+	}
+	visibleID, mutedPostID, deletedID, likeID, retriedID := uuid.New(), uuid.New(), uuid.New(), uuid.New(), uuid.New()
+
+	timelineRepository := repomocks.NewMockTimeline(ctrl)
+	timelineRepository.EXPECT().GetHighFollowerAuthors(gomock.Any()).Return([]uuid.UUID{}, nil).Times(2)
+	userClient := usermocks.NewMockUserClient(ctrl)
+	userClient.EXPECT().
+		GetHiddenUsers(gomock.Any(), gomock.Any()).
+		Return(&userPB.GetHiddenUsersResponse{MutedIds: [][]byte{mutedID[:]}}, nil).
+		Times(2)
+	userClient.EXPECT().
+		GetUsers(gomock.Any(), gomock.Any()).
+		Return(&userPB.GetUsersResponse{Users: []*userPB.UserInfo{{Id: authorID[:], Handle: "author"}}}, nil).
+		AnyTimes()
+	postRepository := repomocks.NewMockPost(ctrl)
+	svc := service.NewStream(repository.NewStream(db), postRepository, timelineRepository, userClient)
+
+	session, err := svc.Open(context.TODO(), userID, cursor)
+	is.NoErr(err)
+
+	// The posts of the muted user are not loaded, and the deleted post is not found.
+	mock.ExpectQuery("FROM timelines").
+		WithArgs(streamArgs(userID, cursor.Posts, 1)...).
+		WillReturnRows(
+			sqlmock.NewRows(streamItemColumns).
+				AddRow(visibleID[:], visibleID[:], authorID[:], start.Add(time.Second)).
+				AddRow(mutedPostID[:], mutedPostID[:], mutedID[:], start.Add(2*time.Second)).
+				AddRow(deletedID[:], deletedID[:], authorID[:], start.Add(3*time.Second)),
+		)
+	mock.ExpectQuery("FROM likes").
+		WithArgs(streamArgs(userID, cursor.Likes, 2)...).
+		WillReturnRows(sqlmock.NewRows(streamItemColumns).AddRow(likeID[:], visibleID[:], authorID[:], start))
+	mock.ExpectQuery("FROM comments").
+		WithArgs(streamArgs(userID, cursor.Comments, 2)...).
+		WillReturnRows(sqlmock.NewRows(streamItemColumns))
+	postRepository.EXPECT().
+		GetByIDs(gomock.Any(), []uuid.UUID{visibleID, deletedID}).
+		Return(map[uuid.UUID]model.Post{
+			visibleID: {ID: visibleID, AuthorID: authorID, Visibility: model.VisibilityPublic},
+		}, nil)
+
+	events, more, err := session.Poll(context.TODO())
+	is.NoErr(err)
+	is.True(!more)
+	is.Equal(len(events), 2)
+	is.Equal(events[0].Type, model.StreamPostCreated)
+	is.Equal(events[0].Post.ID, visibleID)
+	is.Equal(events[0].Post.Author.Handle, "author")
+	is.Equal(events[0].Cursor.Posts.LastLoadedID, visibleID)
+	is.Equal(events[1].Type, model.StreamPostLiked)
+	is.Equal(events[1].Actor.Handle, "author")
+	// The cursor moves past the skipped posts.
+	is.Equal(events[1].Cursor.Posts, model.Cursor{LastLoadedTimestamp: start.Add(3 * time.Second), LastLoadedID: deletedID})
+	is.Equal(events[1].Cursor.Likes, model.Cursor{LastLoadedTimestamp: start, LastLoadedID: likeID})
+	is.Equal(events[1].Cursor.Comments, cursor.Comments)
+	polledCursor := events[1].Cursor
+
+	// A poll that fails does not move the cursor, so the next one returns the same events.
+	for _, fails := range []bool{true, false} {
+		mock.ExpectQuery("FROM timelines").
+			WithArgs(streamArgs(userID, polledCursor.Posts, 1)...).
+			WillReturnRows(
+				sqlmock.NewRows(streamItemColumns).AddRow(retriedID[:], retriedID[:], authorID[:], start.Add(4*time.Second)),
+			)
+		mock.ExpectQuery("FROM likes").
+			WithArgs(streamArgs(userID, polledCursor.Likes, 2)...).
+			WillReturnRows(sqlmock.NewRows(streamItemColumns))
+		mock.ExpectQuery("FROM comments").
+			WithArgs(streamArgs(userID, polledCursor.Comments, 2)...).
+			WillReturnRows(sqlmock.NewRows(streamItemColumns))
+		if fails {
+			postRepository.EXPECT().GetByIDs(gomock.Any(), []uuid.UUID{retriedID}).Return(nil, errors.New("connection lost"))
+		} else {
+			postRepository.EXPECT().
+				GetByIDs(gomock.Any(), []uuid.UUID{retriedID}).
+				Return(map[uuid.UUID]model.Post{
+					retriedID: {ID: retriedID, AuthorID: authorID, Visibility: model.VisibilityPublic},
+				}, nil)
+		}
+	}
+	_, _, err = session.Poll(context.TODO())
+	is.True(err != nil)
+	events, _, err = session.Poll(context.TODO())
+	is.NoErr(err)
+	is.Equal(len(events), 1)
+	is.Equal(events[0].PostID, retriedID)
+
+	// A client that reconnects resumes from the cursor of the last event it received.
+	session, err = svc.Open(context.TODO(), userID, events[0].Cursor)
+	is.NoErr(err)
+	resumedCursor := model.Cursor{LastLoadedTimestamp: start.Add(4 * time.Second), LastLoadedID: retriedID}
+	mock.ExpectQuery("FROM timelines").
+		WithArgs(streamArgs(userID, resumedCursor, 1)...).
+		WillReturnRows(sqlmock.NewRows(streamItemColumns))
+	mock.ExpectQuery("FROM likes").
+		WithArgs(streamArgs(userID, polledCursor.Likes, 2)...).
+		WillReturnRows(sqlmock.NewRows(streamItemColumns))
+	mock.ExpectQuery("FROM comments").
+		WithArgs(streamArgs(userID, polledCursor.Comments, 2)...).
+		WillReturnRows(sqlmock.NewRows(streamItemColumns))
+	postRepository.EXPECT().GetByIDs(gomock.Any(), gomock.Len(0)).Return(map[uuid.UUID]model.Post{}, nil)
+
+	events, _, err = session.Poll(context.TODO())
+	is.NoErr(err)
+	is.Equal(len(events), 0)
+	is.NoErr(mock.ExpectationsWereMet())
+}
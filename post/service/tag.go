@@ -0,0 +1,181 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"smapp/post/config"
+	"smapp/post/model"
+	"smapp/post/repository"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	userPB "smapp/common/grpc/user"
+
+	"github.com/google/uuid"
+)
+
+type Tag struct {
+	postRepository repository.Post
+	tagRepository  *repository.Tag
+	userClient     userPB.UserClient
+	// Posts with these tags are deleted once they are older than the duration of the tag.
+	expiringTags map[string]time.Duration
+}
+
+func NewTag(
+	postRepository repository.Post, tagRepository *repository.Tag, userClient userPB.UserClient,
+	expiringTags map[string]time.Duration,
+) *Tag {
+	return &Tag{
+		postRepository: postRepository,
+		tagRepository:  tagRepository,
+		userClient:     userClient,
+		expiringTags:   expiringTags,
+	}
+}
+
+var ErrTagInvalid = errors.New("tag invalid")
+
+// Posts of private authors are only returned to their followers, and other posts only to their audience. uuid.Nil
+// stands for an anonymous viewer.
+func (svc *Tag) GetPosts(
+	ctx context.Context, viewerID uuid.UUID, tag string, cursor model.Cursor, limit int,
+) ([]model.Post, *model.Cursor, error) {
+	fail := func(err error) ([]model.Post, *model.Cursor, error) {
+		return nil, nil, fmt.Errorf("get posts by tag: %w", err)
+	}
+
+	tag = strings.ToLower(strings.TrimPrefix(tag, "#"))
+	if !isValidTag(tag) {
+		return nil, nil, fmt.Errorf("%w: %s", ErrTagInvalid, tag)
+	}
+	if limit < 1 || limit > config.PostsPaginationLimit {
+		return nil, nil, fmt.Errorf(
+			"%w, should be in range: [1, %d]",
+			ErrPostsPaginationLimitInvalid, config.PostsPaginationLimit,
+		)
+	}
+
+	posts, nextCursor, err := loadFilteredPage(
+		&cursor, limit,
+		func(cursor *model.Cursor, limit int) ([]model.Post, *model.Cursor, error) {
+			return svc.postRepository.GetByTagWithCounts(ctx, tag, *cursor, limit)
+		},
+		func(posts []model.Post) ([]model.Post, error) {
+			return removeHiddenPosts(ctx, svc.userClient, viewerID, posts)
+		},
+	)
+	if err != nil {
+		return fail(err)
+	}
+	if err = embedPostAuthors(ctx, svc.userClient, posts); err != nil {
+		return fail(err)
+	}
+
+	return posts, nextCursor, nil
+}
+
+// Trending tags are public, so only posts that an anonymous viewer can see are counted.
+func (svc *Tag) GetTrending(ctx context.Context) ([]model.TagUsage, error) {
+	fail := func(err error) ([]model.TagUsage, error) {
+		return nil, fmt.Errorf("get trending tags: %w", err)
+	}
+
+	since := time.Now().Add(-config.TrendingTagsWindow)
+	authorIDs, err := svc.tagRepository.GetTrendingAuthors(ctx, since)
+	if err != nil {
+		return fail(err)
+	}
+	privateIDs, err := getPrivateAuthors(ctx, svc.userClient, uuid.Nil, authorIDs)
+	if err != nil {
+		return fail(err)
+	}
+	tags, err := svc.tagRepository.GetTrending(ctx, since, privateIDs, config.TrendingTagsLimit)
+	if err != nil {
+		return fail(err)
+	}
+	return tags, nil
+}
+
+const maxTagLength = 100
+
+// A hashtag starts with '#' that is not preceded by a word character, so that e.g. URL fragments and "C#" are not tags.
+var tagRegexp = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_&/#])#([\p{L}\p{N}_]+)`)
+
+var validTagRegexp = regexp.MustCompile(`^[\p{L}\p{N}_]+$`)
+
+// Tags are case-insensitive, so they are stored in lower case. Returns unique tags in order of appearance.
+func extractTags(body string) []string {
+	seen := make(map[string]bool)
+	tags := make([]string, 0)
+	for _, match := range tagRegexp.FindAllStringSubmatch(body, -1) {
+		tag := strings.ToLower(match[1])
+		if !isValidTag(tag) || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+		if len(tags) == config.MaxTagsPerPost {
+			break
+		}
+	}
+	return tags
+}
+
+// Tags consisting only of digits are not allowed, so that e.g. "#1" is not a tag.
+func isValidTag(tag string) bool {
+	if utf8.RuneCountInString(tag) > maxTagLength || !validTagRegexp.MatchString(tag) {
+		return false
+	}
+	return strings.TrimFunc(tag, func(r rune) bool { return r >= '0' && r <= '9' }) != ""
+}
+
+// Deletes expired posts at config.PostExpirationInterval until the context is done.
+func (svc *Tag) RunPostExpiration(ctx context.Context) {
+	if len(svc.expiringTags) == 0 {
+		return
+	}
+	for {
+		if err := svc.DeleteExpiredPosts(ctx); err != nil {
+			log.Println(err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(config.PostExpirationInterval):
+		}
+	}
+}
+
+// Deletes the posts with an expiring tag that are older than the tag's duration, along with their comments and likes.
+func (svc *Tag) DeleteExpiredPosts(ctx context.Context) error {
+	fail := func(err error) error {
+		return fmt.Errorf("delete expired posts: %w", err)
+	}
+
+	for tag, ttl := range svc.expiringTags {
+		for {
+			postIDs, err := svc.tagRepository.GetPostIDsCreatedBefore(
+				ctx, tag, time.Now().Add(-ttl), config.PostExpirationBatchSize,
+			)
+			if err != nil {
+				return fail(err)
+			}
+			for _, postID := range postIDs {
+				// Other instances delete expired posts too.
+				err = svc.postRepository.Delete(ctx, postID)
+				if err != nil && !errors.Is(err, repository.ErrRecordNotFound) {
+					return fail(err)
+				}
+			}
+			if len(postIDs) < config.PostExpirationBatchSize {
+				break
+			}
+		}
+	}
+	return nil
+}
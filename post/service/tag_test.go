@@ -0,0 +1,84 @@
+package service_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"smapp/post/config"
+	"smapp/post/model"
+	"smapp/post/repository"
+	"smapp/post/service"
+	"testing"
+	"time"
+
+	userPB "smapp/common/grpc/user"
+	usermocks "smapp/common/grpc/user/mocks"
+	repomocks "smapp/post/repository/mocks"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/matryer/is"
+	"go.uber.org/mock/gomock"
+)
+
+func TestTagGetTrendingExcludesPrivateAuthors(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+
+	db, mock, err := sqlmock.New()
+	is.NoErr(err)
+	defer db.Close()
+
+	publicID, privateID := uuid.New(), uuid.New()
+	mock.ExpectQuery("SELECT DISTINCT p.author_id").
+		WillReturnRows(sqlmock.NewRows([]string{"author_id"}).AddRow(publicID[:]).AddRow(privateID[:]))
+	mock.ExpectQuery("p.visibility = 'public' AND p.author_id NOT IN").
+		WithArgs(sqlmock.AnyArg(), privateID[:], sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"tag", "post_count"}).AddRow("go", 3))
+
+	// Checked for an anonymous viewer, since the page is public.
+	userClient := usermocks.NewMockUserClient(ctrl)
+	userClient.EXPECT().
+		FilterPrivateAuthors(gomock.Any(), &userPB.FilterPrivateAuthorsRequest{
+			AuthorIds: [][]byte{publicID[:], privateID[:]},
+		}).
+		Return(&userPB.FilterPrivateAuthorsResponse{AuthorIds: [][]byte{privateID[:]}}, nil)
+
+	tagService := service.NewTag(nil, repository.NewTag(db), userClient, nil)
+	tags, err := tagService.GetTrending(context.TODO())
+	is.NoErr(err)
+	is.Equal(tags, []model.TagUsage{{Tag: "go", PostCount: 3}})
+	is.NoErr(mock.ExpectationsWereMet())
+}
+
+func TestTagDeleteExpiredPosts(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+
+	db, mock, err := sqlmock.New()
+	is.NoErr(err)
+	defer db.Close()
+
+	// The first post has been deleted by another instance in the meantime.
+	deletedID, expiredID := uuid.New(), uuid.New()
+	mock.ExpectQuery("SELECT post_id FROM post_tags").
+		WithArgs("story", timeAround(time.Now().Add(-24*time.Hour)), config.PostExpirationBatchSize).
+		WillReturnRows(sqlmock.NewRows([]string{"post_id"}).AddRow(deletedID[:]).AddRow(expiredID[:]))
+
+	postRepository := repomocks.NewMockPost(ctrl)
+	postRepository.EXPECT().Delete(gomock.Any(), deletedID).Return(repository.ErrRecordNotFound)
+	postRepository.EXPECT().Delete(gomock.Any(), expiredID).Return(nil)
+
+	tagService := service.NewTag(
+		postRepository, repository.NewTag(db), nil, map[string]time.Duration{"story": 24 * time.Hour},
+	)
+	is.NoErr(tagService.DeleteExpiredPosts(context.Background()))
+	is.NoErr(mock.ExpectationsWereMet())
+}
+
+// Matches times within a minute of the given one.
+type timeAround time.Time
+
+func (t timeAround) Match(v driver.Value) bool {
+	value, ok := v.(time.Time)
+	return ok && value.Sub(time.Time(t)).Abs() < time.Minute
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"smapp/post/config"
+	"smapp/post/repository"
+
+	"github.com/google/uuid"
+)
+
+type Timeline struct {
+	timelineRepository repository.Timeline
+}
+
+func NewTimeline(timelineRepository repository.Timeline) *Timeline {
+	return &Timeline{timelineRepository: timelineRepository}
+}
+
+func (svc *Timeline) AddAuthor(ctx context.Context, userID, authorID uuid.UUID) error {
+	fail := func(err error) error {
+		return fmt.Errorf("add author to timeline: %w", err)
+	}
+
+	// Posts of high follower authors are merged into the feed on read.
+	isHighFollower, err := svc.timelineRepository.IsHighFollowerAuthor(ctx, authorID)
+	if err != nil {
+		return fail(err)
+	}
+	if isHighFollower {
+		return nil
+	}
+
+	if err = svc.timelineRepository.AddAuthor(ctx, userID, authorID, config.TimelineBackfillLimit); err != nil {
+		return fail(err)
+	}
+	return nil
+}
+
+func (svc *Timeline) RemoveAuthor(ctx context.Context, userID, authorID uuid.UUID) error {
+	if err := svc.timelineRepository.RemoveAuthor(ctx, userID, authorID); err != nil {
+		return fmt.Errorf("remove author from timeline: %w", err)
+	}
+	return nil
+}
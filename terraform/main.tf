@@ -14,5 +14,7 @@ provider "aws" {
 }
 
 locals {
-  services = toset(["traefik", "user", "user-grpc", "post", "image", "image-grpc"])
+  services = toset([
+    "traefik", "user", "user-grpc", "post", "post-grpc", "notification", "notification-grpc", "image", "image-grpc",
+  ])
 }
@@ -0,0 +1,84 @@
+// Adds the posts of followed authors to the timelines of their followers. Timelines are otherwise only filled on new
+// posts and new follows, so this has to be run once after the timelines migration for follows made before it.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	commondb "smapp/common/db"
+	commonenv "smapp/common/env"
+	postPB "smapp/common/grpc/post"
+	"smapp/user/repository"
+	"smapp/user/service"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+type mysqlConfig struct {
+	host     string
+	user     string
+	password []byte
+	db       string
+}
+
+func getMysqlConfig() (*mysqlConfig, error) {
+	mysqlConfig := mysqlConfig{}
+	var err error
+	if mysqlConfig.host, err = commonenv.GetEnv("MYSQL_HOST"); err != nil {
+		return nil, err
+	}
+	if mysqlConfig.user, err = commonenv.GetEnv("MYSQL_USER"); err != nil {
+		return nil, err
+	}
+	if mysqlConfig.password, err = commonenv.GetSecret("mysql_password"); err != nil {
+		return nil, err
+	}
+	if mysqlConfig.db, err = commonenv.GetEnv("MYSQL_DB"); err != nil {
+		return nil, err
+	}
+	return &mysqlConfig, nil
+}
+
+func main() {
+	mysqlConfig, err := getMysqlConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	db, err := sql.Open(
+		"mysql",
+		fmt.Sprintf("%s:%s@tcp(%s)/%s?parseTime=true", mysqlConfig.user, mysqlConfig.password, mysqlConfig.host, mysqlConfig.db),
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	err = commondb.WaitForDB(ctx, db)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	conn, err := grpc.NewClient(
+		"post-grpc:50051",
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultServiceConfig(`{"loadBalancingConfig": [{"round_robin":{}}]}`),
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer conn.Close()
+
+	timelineService := service.NewTimeline(repository.NewFollow(db), postPB.NewPostClient(conn))
+	processed, err := timelineService.BackfillAll(context.Background())
+	if err != nil {
+		log.Fatalf("%v (%d follows processed before the error)", err, processed)
+	}
+	log.Printf("backfilled timelines for %d follows", processed)
+}
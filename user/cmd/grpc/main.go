@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log"
 	"net"
+	"net/url"
 	commondb "smapp/common/db"
 	commonenv "smapp/common/env"
 	pb "smapp/common/grpc/user"
@@ -45,7 +46,13 @@ func getMysqlConfig() (*mysqlConfig, error) {
 
 type userServer struct {
 	pb.UnimplementedUserServer
-	followService *service.Follow
+	followService      *service.Follow
+	profileService     *service.Profile
+	tokenService       *service.Token
+	blockService       *service.Block
+	muteService        *service.Mute
+	closeFriendService *service.CloseFriend
+	imageBaseURL       *url.URL
 }
 
 func (s *userServer) GetFollowed(ctx context.Context, req *pb.GetFollowedRequest) (*pb.GetFollowedResponse, error) {
@@ -57,11 +64,173 @@ func (s *userServer) GetFollowed(ctx context.Context, req *pb.GetFollowedRequest
 	if err != nil {
 		return nil, err
 	}
-	followedBytes := make([][]byte, len(followed))
-	for i, id := range followed {
-		followedBytes[i] = id[:]
+	return &pb.GetFollowedResponse{UserIds: uuidsToBytes(followed)}, nil
+}
+
+func (s *userServer) GetFollowerIDs(ctx context.Context, req *pb.GetFollowerIDsRequest) (*pb.GetFollowerIDsResponse, error) {
+	userID, err := uuid.FromBytes(req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	followers, limitExceeded, err := s.followService.GetFollowerIDs(ctx, userID, int(req.Limit))
+	if err != nil {
+		return nil, err
+	}
+	return &pb.GetFollowerIDsResponse{UserIds: uuidsToBytes(followers), LimitExceeded: limitExceeded}, nil
+}
+
+func (s *userServer) FilterFollowed(ctx context.Context, req *pb.FilterFollowedRequest) (*pb.FilterFollowedResponse, error) {
+	userID, err := uuid.FromBytes(req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	candidateIDs, err := uuidsFromBytes(req.CandidateIds)
+	if err != nil {
+		return nil, err
+	}
+	followed, err := s.followService.FilterFollowed(ctx, userID, candidateIDs)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.FilterFollowedResponse{UserIds: uuidsToBytes(followed)}, nil
+}
+
+func (s *userServer) GetUsers(ctx context.Context, req *pb.GetUsersRequest) (*pb.GetUsersResponse, error) {
+	userIDs, err := uuidsFromBytes(req.UserIds)
+	if err != nil {
+		return nil, err
+	}
+	users, err := s.profileService.GetSummaries(ctx, userIDs)
+	if err != nil {
+		return nil, err
+	}
+	userInfos := make([]*pb.UserInfo, len(users))
+	for i, user := range users {
+		userInfos[i] = &pb.UserInfo{
+			Id:     user.ID[:],
+			Name:   user.Name,
+			Handle: user.Handle,
+		}
+		if user.Image != nil {
+			userInfos[i].ImageUrl = user.Image.URL(s.imageBaseURL)
+		}
+	}
+	return &pb.GetUsersResponse{Users: userInfos}, nil
+}
+
+func (s *userServer) ResolveHandles(ctx context.Context, req *pb.ResolveHandlesRequest) (*pb.ResolveHandlesResponse, error) {
+	ids, err := s.profileService.ResolveHandles(ctx, req.Handles)
+	if err != nil {
+		return nil, err
+	}
+	users := make([]*pb.ResolvedHandle, 0, len(ids))
+	for handle, id := range ids {
+		users = append(users, &pb.ResolvedHandle{Handle: handle, UserId: id[:]})
+	}
+	return &pb.ResolveHandlesResponse{Users: users}, nil
+}
+
+func (s *userServer) IsTokenRevoked(ctx context.Context, req *pb.IsTokenRevokedRequest) (*pb.IsTokenRevokedResponse, error) {
+	jti, err := uuid.FromBytes(req.Jti)
+	if err != nil {
+		return nil, err
+	}
+	userID, err := uuid.FromBytes(req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	revoked, err := s.tokenService.IsRevoked(ctx, jti, userID, time.Unix(req.IssuedAt, 0))
+	if err != nil {
+		return nil, err
+	}
+	return &pb.IsTokenRevokedResponse{Revoked: revoked}, nil
+}
+
+func (s *userServer) GetHiddenUsers(ctx context.Context, req *pb.GetHiddenUsersRequest) (*pb.GetHiddenUsersResponse, error) {
+	userID, err := uuid.FromBytes(req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	blocked, err := s.blockService.GetBlockedIDs(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	muted, err := s.muteService.GetMutedIDs(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.GetHiddenUsersResponse{BlockedIds: uuidsToBytes(blocked), MutedIds: uuidsToBytes(muted)}, nil
+}
+
+func (s *userServer) FilterPrivateAuthors(
+	ctx context.Context, req *pb.FilterPrivateAuthorsRequest,
+) (*pb.FilterPrivateAuthorsResponse, error) {
+	viewerID := uuid.Nil
+	if len(req.ViewerId) > 0 {
+		var err error
+		if viewerID, err = uuid.FromBytes(req.ViewerId); err != nil {
+			return nil, err
+		}
+	}
+	authorIDs, err := uuidsFromBytes(req.AuthorIds)
+	if err != nil {
+		return nil, err
+	}
+	private, err := s.followService.FilterPrivateNotFollowed(ctx, viewerID, authorIDs)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.FilterPrivateAuthorsResponse{AuthorIds: uuidsToBytes(private)}, nil
+}
+
+func (s *userServer) FilterCloseFriendOf(
+	ctx context.Context, req *pb.FilterCloseFriendOfRequest,
+) (*pb.FilterCloseFriendOfResponse, error) {
+	userID, err := uuid.FromBytes(req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	candidateIDs, err := uuidsFromBytes(req.CandidateIds)
+	if err != nil {
+		return nil, err
+	}
+	listedBy, err := s.closeFriendService.FilterListedBy(ctx, userID, candidateIDs)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.FilterCloseFriendOfResponse{UserIds: uuidsToBytes(listedBy)}, nil
+}
+
+func uuidsFromBytes(ids [][]byte) ([]uuid.UUID, error) {
+	result := make([]uuid.UUID, len(ids))
+	for i, id := range ids {
+		var err error
+		if result[i], err = uuid.FromBytes(id); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+func uuidsToBytes(ids []uuid.UUID) [][]byte {
+	result := make([][]byte, len(ids))
+	for i, id := range ids {
+		result[i] = id[:]
+	}
+	return result
+}
+
+// IMAGE_BASE_URL is the URL that images are served from, see model.ImageLocation.URL.
+func getImageBaseURL() (*url.URL, error) {
+	imageBaseURL, err := commonenv.GetEnv("IMAGE_BASE_URL")
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := url.Parse(imageBaseURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("IMAGE_BASE_URL must be an absolute http(s) URL, got %q", imageBaseURL)
 	}
-	return &pb.GetFollowedResponse{UserIds: followedBytes}, nil
+	return parsed, nil
 }
 
 func main() {
@@ -69,10 +238,14 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
+	imageBaseURL, err := getImageBaseURL()
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	db, err := sql.Open(
 		"mysql",
-		fmt.Sprintf("%s:%s@tcp(%s)/%s", mysqlConfig.user, mysqlConfig.password, mysqlConfig.host, mysqlConfig.db),
+		fmt.Sprintf("%s:%s@tcp(%s)/%s?parseTime=true", mysqlConfig.user, mysqlConfig.password, mysqlConfig.host, mysqlConfig.db),
 	)
 	if err != nil {
 		log.Fatal(err)
@@ -85,7 +258,18 @@ func main() {
 	}
 	defer db.Close()
 
-	followService := service.NewFollow(repository.NewFollow(db))
+	userRepository := repository.NewUser(db)
+	blockRepository := repository.NewBlock(db)
+	followService := service.NewFollow(
+		repository.NewFollow(db), repository.NewFollowRequest(db), userRepository, blockRepository,
+	)
+	blockService := service.NewBlock(blockRepository)
+	muteService := service.NewMute(repository.NewMute(db))
+	closeFriendService := service.NewCloseFriend(repository.NewCloseFriend(db))
+	// Profile updates are served over HTTP, so the image client is not needed here.
+	profileService := service.NewProfile(userRepository, nil)
+	// Tokens are only checked here, so neither signing nor refresh token expiration is needed.
+	tokenService := service.NewToken(repository.NewToken(db), nil, 0)
 
 	lis, err := net.Listen("tcp", ":50051")
 	if err != nil {
@@ -98,6 +282,14 @@ func main() {
 			MaxConnectionAgeGrace: 5 * time.Second,
 		}),
 	)
-	pb.RegisterUserServer(s, &userServer{followService: followService})
+	pb.RegisterUserServer(s, &userServer{
+		followService:      followService,
+		profileService:     profileService,
+		tokenService:       tokenService,
+		blockService:       blockService,
+		muteService:        muteService,
+		closeFriendService: closeFriendService,
+		imageBaseURL:       imageBaseURL,
+	})
 	log.Fatal(s.Serve(lis))
 }
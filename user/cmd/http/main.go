@@ -1,25 +1,41 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"crypto/aes"
+	"crypto/cipher"
 	"crypto/rsa"
 	"crypto/x509"
 	"database/sql"
+	"encoding/base64"
 	"encoding/pem"
 	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
+	"os"
+	"strings"
 	"time"
 
 	commondb "smapp/common/db"
 	commonenv "smapp/common/env"
+	imagePB "smapp/common/grpc/image"
+	notificationPB "smapp/common/grpc/notification"
+	postPB "smapp/common/grpc/post"
 	commonmw "smapp/common/middleware"
+	"smapp/common/outbox"
+	"smapp/user/config"
 	"smapp/user/handlers"
+	"smapp/user/mailer"
+	"smapp/user/oidc"
 	"smapp/user/repository"
 	"smapp/user/service"
 
 	"github.com/gorilla/mux"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
 
 	_ "github.com/go-sql-driver/mysql"
 )
@@ -32,8 +48,9 @@ type mysqlConfig struct {
 }
 
 type jwtConfig struct {
-	privateKey *rsa.PrivateKey
-	ttl        time.Duration
+	privateKeys []*rsa.PrivateKey
+	ttl         time.Duration
+	refreshTTL  time.Duration
 }
 
 func getMysqlConfig() (*mysqlConfig, error) {
@@ -54,29 +71,150 @@ func getMysqlConfig() (*mysqlConfig, error) {
 	return &mysqlConfig, nil
 }
 
-func getJWTConfig() (*jwtConfig, error) {
-	jwtConfig := jwtConfig{}
-	var err error
-	privateKeyData, err := commonenv.GetSecret("jwt_private_key")
+// MAILER is either "smtp" or "log". The log mailer writes emails to stdout instead of sending them, for development
+// only, since anyone who can read the log could use the password reset links in them.
+func getMailer() (mailer.Mailer, error) {
+	mailerType, err := commonenv.GetEnv("MAILER")
+	if err != nil {
+		return nil, err
+	}
+	from, err := commonenv.GetEnv("MAIL_FROM")
+	if err != nil {
+		return nil, err
+	}
+	switch mailerType {
+	case "log":
+		return mailer.NewLog(os.Stdout, from), nil
+	case "smtp":
+		addr, err := commonenv.GetEnv("SMTP_ADDR")
+		if err != nil {
+			return nil, err
+		}
+		if addr == "" {
+			return nil, errors.New("SMTP_ADDR is empty")
+		}
+		username, err := commonenv.GetEnv("SMTP_USERNAME")
+		if err != nil {
+			return nil, err
+		}
+		password, err := commonenv.GetSecret("smtp_password")
+		if err != nil {
+			return nil, err
+		}
+		return mailer.NewSMTP(addr, username, password, from), nil
+	default:
+		return nil, fmt.Errorf("unknown mailer %s", mailerType)
+	}
+}
+
+// APP_URL is the base URL of the frontend, e.g. "https://smapp.example.com", which links in emails point to.
+func getAppURL() (string, error) {
+	appURL, err := commonenv.GetEnv("APP_URL")
+	if err != nil {
+		return "", err
+	}
+	parsed, err := url.Parse(appURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return "", fmt.Errorf("APP_URL must be an absolute http(s) URL, got %q", appURL)
+	}
+	return strings.TrimSuffix(appURL, "/"), nil
+}
+
+// The key encrypts TOTP secrets in the database. The secret holds 32 base64 encoded bytes (AES-256).
+func getTOTPSecretCipher() (cipher.AEAD, error) {
+	keyData, err := commonenv.GetSecret("totp_encryption_key")
 	if err != nil {
 		return nil, err
 	}
-	block, _ := pem.Decode(privateKeyData)
-	if block == nil || block.Type != "PRIVATE KEY" {
-		return nil, errors.New("failed to decode PEM block containing private key")
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(keyData)))
+	if err != nil {
+		return nil, fmt.Errorf("decode totp encryption key: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, errors.New("totp encryption key must be 32 bytes long")
 	}
-	privateKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	block, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, err
 	}
-	var ok bool
-	if jwtConfig.privateKey, ok = privateKey.(*rsa.PrivateKey); !ok {
-		return nil, errors.New("private key is not RSA")
+	return cipher.NewGCM(block)
+}
+
+// OIDC_PROVIDERS is a comma separated list of provider names, e.g. "google,gitlab", which may be empty. Each provider
+// is configured with OIDC_<NAME>_ISSUER, OIDC_<NAME>_CLIENT_ID and the oidc_<name>_client_secret secret. The provider
+// redirects back to the app, at <APP_URL>/login/oidc/<name>/callback.
+func getSocialLoginProviders(appURL string, httpClient *http.Client) (map[string]*oidc.Provider, error) {
+	names, err := commonenv.GetEnv("OIDC_PROVIDERS")
+	if err != nil {
+		return nil, err
+	}
+	providers := make(map[string]*oidc.Provider)
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		envPrefix := "OIDC_" + strings.ToUpper(name) + "_"
+		issuer, err := commonenv.GetEnv(envPrefix + "ISSUER")
+		if err != nil {
+			return nil, err
+		}
+		clientID, err := commonenv.GetEnv(envPrefix + "CLIENT_ID")
+		if err != nil {
+			return nil, err
+		}
+		clientSecret, err := commonenv.GetSecret("oidc_" + strings.ToLower(name) + "_client_secret")
+		if err != nil {
+			return nil, err
+		}
+		providers[name] = oidc.NewProvider(oidc.Config{
+			Issuer:       issuer,
+			ClientID:     clientID,
+			ClientSecret: bytes.TrimSpace(clientSecret),
+			RedirectURL:  fmt.Sprintf("%s/login/oidc/%s/callback", appURL, name),
+		}, httpClient)
+	}
+	return providers, nil
+}
+
+func getJWTConfig() (*jwtConfig, error) {
+	jwtConfig := jwtConfig{}
+	var err error
+	// The secret contains one or more PEM encoded keys. The first one signs tokens, see the key rotation section in
+	// README.
+	privateKeysData, err := commonenv.GetSecret("jwt_private_keys")
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var block *pem.Block
+		block, privateKeysData = pem.Decode(privateKeysData)
+		if block == nil {
+			break
+		}
+		if block.Type != "PRIVATE KEY" {
+			return nil, errors.New("failed to decode PEM block containing private key")
+		}
+		privateKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		rsaPrivateKey, ok := privateKey.(*rsa.PrivateKey)
+		if !ok {
+			return nil, errors.New("private key is not RSA")
+		}
+		jwtConfig.privateKeys = append(jwtConfig.privateKeys, rsaPrivateKey)
+	}
+	if len(jwtConfig.privateKeys) == 0 {
+		return nil, errors.New("no private keys found")
 	}
 
 	if jwtConfig.ttl, err = commonenv.GetEnvDuration("JWT_TTL"); err != nil {
 		return nil, err
 	}
+	if jwtConfig.refreshTTL, err = commonenv.GetEnvDuration("REFRESH_TOKEN_TTL"); err != nil {
+		return nil, err
+	}
 	return &jwtConfig, nil
 }
 
@@ -89,14 +227,30 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
+	totpSecretCipher, err := getTOTPSecretCipher()
+	if err != nil {
+		log.Fatal(err)
+	}
 	defaultTimeout, err := commonenv.GetEnvDuration("DEFAULT_TIMEOUT")
 	if err != nil {
 		log.Fatal(err)
 	}
+	mailSender, err := getMailer()
+	if err != nil {
+		log.Fatal(err)
+	}
+	appURL, err := getAppURL()
+	if err != nil {
+		log.Fatal(err)
+	}
+	socialLoginProviders, err := getSocialLoginProviders(appURL, &http.Client{Timeout: defaultTimeout})
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	db, err := sql.Open(
 		"mysql",
-		fmt.Sprintf("%s:%s@tcp(%s)/%s", mysqlConfig.user, mysqlConfig.password, mysqlConfig.host, mysqlConfig.db),
+		fmt.Sprintf("%s:%s@tcp(%s)/%s?parseTime=true", mysqlConfig.user, mysqlConfig.password, mysqlConfig.host, mysqlConfig.db),
 	)
 	if err != nil {
 		log.Fatal(err)
@@ -109,20 +263,179 @@ func main() {
 	}
 	defer db.Close()
 
+	conn, err := grpc.NewClient(
+		"image-grpc:50051",
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultServiceConfig(`{"loadBalancingConfig": [{"round_robin":{}}]}`),
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer conn.Close()
+	imageClient := imagePB.NewImageClient(conn)
+
+	conn, err = grpc.NewClient(
+		"post-grpc:50051",
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultServiceConfig(`{"loadBalancingConfig": [{"round_robin":{}}]}`),
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer conn.Close()
+	postClient := postPB.NewPostClient(conn)
+
+	conn, err = grpc.NewClient(
+		"notification-grpc:50051",
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultServiceConfig(`{"loadBalancingConfig": [{"round_robin":{}}]}`),
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer conn.Close()
+	notificationClient := notificationPB.NewNotificationClient(conn)
+
 	userRepository := repository.NewUser(db)
 	followRepository := repository.NewFollow(db)
+	tokenRepository := repository.NewToken(db)
+	accountTokenRepository := repository.NewAccountToken(db)
+	loginAttemptRepository := repository.NewLoginAttempt(db)
+	twoFactorRepository := repository.NewTwoFactor(db)
+	socialLoginRepository := repository.NewSocialLogin(db)
+	blockRepository := repository.NewBlock(db)
 
-	jwtService := service.NewJWT(jwtConfig.privateKey, jwtConfig.ttl)
-	userService := service.NewUser(userRepository, jwtService)
-	followService := service.NewFollow(followRepository)
+	jwtService := service.NewJWT(jwtConfig.privateKeys, jwtConfig.ttl)
+	tokenService := service.NewToken(tokenRepository, jwtService, jwtConfig.refreshTTL)
+	accountService := service.NewAccount(
+		userRepository, accountTokenRepository, tokenService, mailSender, appURL,
+	)
+	twoFactorService := service.NewTwoFactor(
+		twoFactorRepository, userRepository, loginAttemptRepository, tokenService, totpSecretCipher,
+	)
+	userService := service.NewUser(
+		userRepository, loginAttemptRepository, tokenService, accountService, twoFactorService,
+	)
+	socialLoginService := service.NewSocialLogin(
+		socialLoginRepository, tokenService, twoFactorService, socialLoginProviders,
+	)
+	profileService := service.NewProfile(userRepository, imageClient)
+	followService := service.NewFollow(followRepository, repository.NewFollowRequest(db), userRepository, blockRepository)
+	blockService := service.NewBlock(blockRepository)
+	muteService := service.NewMute(repository.NewMute(db))
+	closeFriendService := service.NewCloseFriend(repository.NewCloseFriend(db))
+	notifier := service.NewNotifier(notificationClient)
+	// Timelines are updated from the outbox, so that follows and unfollows do not wait for the post service and updates
+	// that fail are retried.
+	timelineService := service.NewTimeline(followRepository, postClient)
+
+	relay := outbox.NewRelay(
+		db, outbox.NewLocalPublisher(notifier.HandleEvent, timelineService.HandleEvent),
+		config.OutboxBatchSize, config.OutboxPollInterval, defaultTimeout,
+	)
+	go relay.Run(context.Background())
 
 	r := mux.NewRouter()
 	r.Handle("/signup", handlers.Signup(userService)).Methods(http.MethodPost)
 	r.Handle("/login", handlers.Login(userService)).Methods(http.MethodPost)
+	r.Handle("/login/2fa", handlers.LoginTwoFactor(twoFactorService)).Methods(http.MethodPost)
+	r.Handle("/login/oidc/{provider}", handlers.StartSocialLogin(socialLoginService)).Methods(http.MethodPost)
+	r.Handle(
+		"/login/oidc/{provider}/callback",
+		handlers.CompleteSocialLogin(socialLoginService),
+	).Methods(http.MethodPost)
+	r.Handle("/token/refresh", handlers.RefreshToken(tokenService)).Methods(http.MethodPost)
+	r.Handle("/token/check", handlers.CheckToken(tokenService)).Methods(http.MethodGet)
+	r.Handle("/.well-known/jwks.json", handlers.JWKS(jwtService)).Methods(http.MethodGet)
+	r.Handle("/logout", handlers.Logout(tokenService)).Methods(http.MethodPost)
+	r.Handle("/email/verify", handlers.VerifyEmail(accountService)).Methods(http.MethodPost)
+	r.Handle("/password/forgot", handlers.ForgotPassword(accountService)).Methods(http.MethodPost)
+	r.Handle("/password/reset", handlers.ResetPassword(accountService)).Methods(http.MethodPost)
+	r.Handle(
+		"/users/me",
+		commonmw.ParseUserID(handlers.UpdateProfile(profileService)),
+	).Methods(http.MethodPatch)
+	r.Handle(
+		"/users/me/password",
+		commonmw.ParseUserID(handlers.ChangePassword(accountService)),
+	).Methods(http.MethodPost)
+	r.Handle(
+		"/users/me/email",
+		commonmw.ParseUserID(handlers.ChangeEmail(accountService)),
+	).Methods(http.MethodPost)
+	r.Handle(
+		"/users/me/handle",
+		commonmw.ParseUserID(handlers.ChangeHandle(accountService)),
+	).Methods(http.MethodPost)
+	r.Handle(
+		"/users/me/2fa/totp",
+		commonmw.ParseUserID(handlers.StartTOTPEnrollment(twoFactorService)),
+	).Methods(http.MethodPost)
+	r.Handle(
+		"/users/me/2fa/totp/confirm",
+		commonmw.ParseUserID(handlers.ConfirmTOTPEnrollment(twoFactorService)),
+	).Methods(http.MethodPost)
+	r.Handle(
+		"/users/me/2fa/totp",
+		commonmw.ParseUserID(handlers.DisableTOTP(twoFactorService)),
+	).Methods(http.MethodDelete)
+	r.Handle(
+		"/users/me/email/verification",
+		commonmw.ParseUserID(handlers.SendVerificationEmail(accountService)),
+	).Methods(http.MethodPost)
+	r.Handle(
+		"/users/me/follow-requests",
+		commonmw.ParseUserID(handlers.GetFollowRequests(followService)),
+	).Methods(http.MethodGet)
+	r.Handle(
+		"/users/me/follow-requests/{user_id}/approve",
+		commonmw.ParseUserID(handlers.ApproveFollowRequest(followService)),
+	).Methods(http.MethodPost)
+	r.Handle(
+		"/users/me/follow-requests/{user_id}",
+		commonmw.ParseUserID(handlers.RejectFollowRequest(followService)),
+	).Methods(http.MethodDelete)
+	r.Handle(
+		"/users/me/close-friends",
+		commonmw.ParseUserID(handlers.GetCloseFriends(closeFriendService)),
+	).Methods(http.MethodGet)
+	r.Handle(
+		"/users/me/close-friends/{user_id}",
+		commonmw.ParseUserID(handlers.AddCloseFriend(closeFriendService)),
+	).Methods(http.MethodPost)
+	r.Handle(
+		"/users/me/close-friends/{user_id}",
+		commonmw.ParseUserID(handlers.RemoveCloseFriend(closeFriendService)),
+	).Methods(http.MethodDelete)
+	r.Handle("/search/users", handlers.SearchUsers(profileService)).Methods(http.MethodGet)
+	r.Handle("/users/by-handle/{handle}", handlers.GetProfileByHandle(profileService)).Methods(http.MethodGet)
+	r.Handle("/users/{user_id}", handlers.GetProfile(profileService)).Methods(http.MethodGet)
 	r.Handle(
 		"/users/{user_id}/follow",
 		commonmw.ParseUserID(handlers.Follow(followService)),
 	).Methods(http.MethodPost)
+	r.Handle(
+		"/users/{user_id}/follow",
+		commonmw.ParseUserID(handlers.Unfollow(followService)),
+	).Methods(http.MethodDelete)
+	r.Handle(
+		"/users/{user_id}/block",
+		commonmw.ParseUserID(handlers.Block(blockService)),
+	).Methods(http.MethodPost)
+	r.Handle(
+		"/users/{user_id}/block",
+		commonmw.ParseUserID(handlers.Unblock(blockService)),
+	).Methods(http.MethodDelete)
+	r.Handle(
+		"/users/{user_id}/mute",
+		commonmw.ParseUserID(handlers.Mute(muteService)),
+	).Methods(http.MethodPost)
+	r.Handle(
+		"/users/{user_id}/mute",
+		commonmw.ParseUserID(handlers.Unmute(muteService)),
+	).Methods(http.MethodDelete)
+	r.Handle("/users/{user_id}/followers", handlers.GetFollowers(followService)).Methods(http.MethodGet)
+	r.Handle("/users/{user_id}/following", handlers.GetFollowing(followService)).Methods(http.MethodGet)
 	r.Use(commonmw.WithRequestContextTimeout(defaultTimeout))
 
 	srv := &http.Server{
@@ -0,0 +1,40 @@
+package config
+
+import "time"
+
+const FollowsPaginationLimit = 50
+const SearchPaginationLimit = 30
+
+// Follows are loaded this many at a time by the timeline backfill job.
+const TimelineBackfillBatchSize = 500
+
+// The outbox relay publishes up to this many events at once, and polls at this interval while it has fewer.
+const OutboxBatchSize = 100
+const OutboxPollInterval = time.Second
+
+// Lifetime of the single-use tokens sent by email.
+const EmailVerificationTokenTTL = 48 * time.Hour
+const PasswordResetTokenTTL = time.Hour
+
+// Emails are sent in the background, after the response, and given up on after this long.
+const EmailSendTimeout = 30 * time.Second
+
+// Failed logins allowed per identifier and per client IP before lockouts start. Clients behind the same NAT share the
+// IP counter, so its limit is higher.
+const LoginIdentifierFreeAttempts = 5
+const LoginIPFreeAttempts = 20
+
+// The lockout starts at the base and doubles with every further failure, up to the max. Counters are reset after the
+// window passes without failures.
+const LoginLockoutBase = time.Second
+const LoginLockoutMax = 15 * time.Minute
+const LoginFailureWindow = time.Hour
+
+// The second login step has to be completed within the TTL and with fewer wrong codes than the limit.
+const LoginChallengeTTL = 5 * time.Minute
+const LoginChallengeMaxFailures = 5
+
+const RecoveryCodeCount = 10
+
+// The user has to come back from the provider within the TTL.
+const SocialLoginStateTTL = 10 * time.Minute
@@ -5,11 +5,14 @@ go 1.22.6
 replace smapp/common => ../common
 
 require (
+	github.com/DATA-DOG/go-sqlmock v1.5.2
 	github.com/go-ozzo/ozzo-validation/v4 v4.3.0
 	github.com/go-sql-driver/mysql v1.8.1
 	github.com/golang-jwt/jwt/v5 v5.2.1
 	github.com/google/uuid v1.6.0
 	github.com/gorilla/mux v1.8.1
+	github.com/matryer/is v1.4.1
+	go.uber.org/mock v0.5.0
 	golang.org/x/crypto v0.28.0
 	google.golang.org/grpc v1.67.1
 	smapp/common v0.0.0-00010101000000-000000000000
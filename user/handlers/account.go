@@ -0,0 +1,272 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"regexp"
+	"smapp/common/jsonresp"
+	commonmw "smapp/common/middleware"
+	"smapp/user/service"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/go-ozzo/ozzo-validation/v4/is"
+)
+
+// Decodes and validates the request body, and writes the error response if it fails.
+func decodeAndValidate(w http.ResponseWriter, r *http.Request, body validation.Validatable) bool {
+	if err := json.NewDecoder(r.Body).Decode(body); err != nil {
+		jsonresp.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return false
+	}
+	err := body.Validate()
+	if err != nil {
+		if e, ok := err.(validation.InternalError); ok {
+			log.Println(e.InternalError())
+			jsonresp.ErrorWithDefaultMessage(w, http.StatusInternalServerError)
+			return false
+		}
+		errors := (err.(validation.Errors).Filter()).(validation.Errors)
+		jsonresp.ValidationError(w, errors, http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// The data is omitted from the response if nil.
+func writeAccountResponse(w http.ResponseWriter, data interface{}, err error, code int) {
+	if errors.Is(err, service.ErrUserNotFound) {
+		jsonresp.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, service.ErrWrongPassword) {
+		jsonresp.Error(w, "Incorrect password", http.StatusForbidden)
+		return
+	}
+	if errors.Is(err, service.ErrEmailExists) {
+		jsonresp.Error(w, "Email already exists", http.StatusConflict)
+		return
+	}
+	if errors.Is(err, service.ErrHandleExists) {
+		jsonresp.Error(w, "Handle already exists", http.StatusConflict)
+		return
+	}
+	if errors.Is(err, service.ErrInvalidAccountToken) {
+		jsonresp.Error(w, "Invalid or expired token", http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, service.ErrEmailAlreadyVerified) {
+		jsonresp.Error(w, "Email already verified", http.StatusConflict)
+		return
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		log.Println(err)
+		jsonresp.ErrorWithDefaultMessage(w, http.StatusGatewayTimeout)
+		return
+	}
+	if errors.Is(err, context.Canceled) {
+		// client disconnected
+		log.Println(err)
+		return
+	}
+	if err != nil {
+		log.Println(err)
+		jsonresp.ErrorWithDefaultMessage(w, http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{"status": "success"}
+	if data != nil {
+		response["data"] = data
+	}
+	jsonresp.Response(w, response, code)
+}
+
+func SendVerificationEmail(accountService *service.Account) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, err := commonmw.GetUserID(r.Context())
+		if err != nil {
+			log.Println(err)
+			jsonresp.ErrorWithDefaultMessage(w, http.StatusInternalServerError)
+			return
+		}
+
+		err = accountService.SendVerificationEmail(r.Context(), userID)
+		writeAccountResponse(w, nil, err, http.StatusAccepted)
+	})
+}
+
+type AccountTokenRequestBody struct {
+	Token string `json:"token"`
+}
+
+func (body *AccountTokenRequestBody) Validate() error {
+	return validation.ValidateStruct(
+		body,
+		validation.Field(&body.Token, validation.Required, validation.Length(1, 100)),
+	)
+}
+
+func VerifyEmail(accountService *service.Account) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body AccountTokenRequestBody
+		if !decodeAndValidate(w, r, &body) {
+			return
+		}
+
+		err := accountService.VerifyEmail(r.Context(), body.Token)
+		writeAccountResponse(w, nil, err, http.StatusOK)
+	})
+}
+
+type ForgotPasswordRequestBody struct {
+	Email string `json:"email"`
+}
+
+func (body *ForgotPasswordRequestBody) Validate() error {
+	return validation.ValidateStruct(
+		body,
+		validation.Field(&body.Email, validation.Required, is.Email),
+	)
+}
+
+// Responds the same way whether the email is registered or not.
+func ForgotPassword(accountService *service.Account) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body ForgotPasswordRequestBody
+		if !decodeAndValidate(w, r, &body) {
+			return
+		}
+
+		accountService.RequestPasswordReset(r.Context(), body.Email)
+		writeAccountResponse(w, nil, nil, http.StatusAccepted)
+	})
+}
+
+type ResetPasswordRequestBody struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// TODO: use constants for field length limits
+func (body *ResetPasswordRequestBody) Validate() error {
+	return validation.ValidateStruct(
+		body,
+		validation.Field(&body.Token, validation.Required, validation.Length(1, 100)),
+		validation.Field(&body.Password, validation.Required, validation.Length(6, 128)),
+	)
+}
+
+func ResetPassword(accountService *service.Account) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body ResetPasswordRequestBody
+		if !decodeAndValidate(w, r, &body) {
+			return
+		}
+
+		err := accountService.ResetPassword(r.Context(), body.Token, body.Password)
+		writeAccountResponse(w, nil, err, http.StatusOK)
+	})
+}
+
+type ChangePasswordRequestBody struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+// TODO: use constants for field length limits
+func (body *ChangePasswordRequestBody) Validate() error {
+	return validation.ValidateStruct(
+		body,
+		validation.Field(&body.CurrentPassword, validation.Required, validation.Length(1, 128)),
+		validation.Field(&body.NewPassword, validation.Required, validation.Length(6, 128)),
+	)
+}
+
+// Responds with new tokens, since all refresh tokens of the user are revoked.
+func ChangePassword(accountService *service.Account) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body ChangePasswordRequestBody
+		if !decodeAndValidate(w, r, &body) {
+			return
+		}
+		userID, err := commonmw.GetUserID(r.Context())
+		if err != nil {
+			log.Println(err)
+			jsonresp.ErrorWithDefaultMessage(w, http.StatusInternalServerError)
+			return
+		}
+
+		tokens, err := accountService.ChangePassword(r.Context(), userID, body.CurrentPassword, body.NewPassword)
+		writeAccountResponse(w, tokens, err, http.StatusOK)
+	})
+}
+
+type ChangeEmailRequestBody struct {
+	Password string `json:"password"`
+	Email    string `json:"email"`
+}
+
+// TODO: use constants for field length limits
+func (body *ChangeEmailRequestBody) Validate() error {
+	return validation.ValidateStruct(
+		body,
+		validation.Field(&body.Password, validation.Required, validation.Length(1, 128)),
+		validation.Field(&body.Email, validation.Required, is.Email),
+	)
+}
+
+func ChangeEmail(accountService *service.Account) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body ChangeEmailRequestBody
+		if !decodeAndValidate(w, r, &body) {
+			return
+		}
+		userID, err := commonmw.GetUserID(r.Context())
+		if err != nil {
+			log.Println(err)
+			jsonresp.ErrorWithDefaultMessage(w, http.StatusInternalServerError)
+			return
+		}
+
+		err = accountService.ChangeEmail(r.Context(), userID, body.Password, body.Email)
+		writeAccountResponse(w, nil, err, http.StatusOK)
+	})
+}
+
+type ChangeHandleRequestBody struct {
+	Handle string `json:"handle"`
+}
+
+// TODO: use constants for field length limits
+func (body *ChangeHandleRequestBody) Validate() error {
+	return validation.ValidateStruct(
+		body,
+		validation.Field(
+			&body.Handle,
+			validation.Required,
+			validation.Length(1, 20),
+			validation.Match(regexp.MustCompile("^[^@]*$")).Error("handle cannot contain '@'"),
+		),
+	)
+}
+
+func ChangeHandle(accountService *service.Account) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body ChangeHandleRequestBody
+		if !decodeAndValidate(w, r, &body) {
+			return
+		}
+		userID, err := commonmw.GetUserID(r.Context())
+		if err != nil {
+			log.Println(err)
+			jsonresp.ErrorWithDefaultMessage(w, http.StatusInternalServerError)
+			return
+		}
+
+		err = accountService.ChangeHandle(r.Context(), userID, body.Handle)
+		writeAccountResponse(w, nil, err, http.StatusOK)
+	})
+}
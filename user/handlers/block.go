@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"smapp/common/jsonresp"
+	commonmw "smapp/common/middleware"
+	"smapp/user/service"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+type changeRelationFunc func(ctx context.Context, userID, otherID uuid.UUID) error
+
+// Blocking, muting and changing close friends are idempotent: repeating a request responds with "unchanged".
+func changeRelation(change changeRelationFunc, successCode int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		otherID, err := uuid.Parse(mux.Vars(r)["user_id"])
+		if err != nil {
+			jsonresp.Error(w, fmt.Sprintf("Invalid user ID: %s", err.Error()), http.StatusBadRequest)
+			return
+		}
+
+		userID, err := commonmw.GetUserID(r.Context())
+		if err != nil {
+			log.Println(err)
+			jsonresp.ErrorWithDefaultMessage(w, http.StatusInternalServerError)
+			return
+		}
+
+		err = change(r.Context(), userID, otherID)
+		if errors.Is(err, service.ErrSelfBlock) {
+			jsonresp.Error(w, "Cannot block self", http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, service.ErrSelfMute) {
+			jsonresp.Error(w, "Cannot mute self", http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, service.ErrSelfCloseFriend) {
+			jsonresp.Error(w, "Cannot add self to close friends", http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, service.ErrUserNotFound) {
+			jsonresp.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, service.ErrBlockExists) || errors.Is(err, service.ErrBlockNotFound) ||
+			errors.Is(err, service.ErrMuteExists) || errors.Is(err, service.ErrMuteNotFound) ||
+			errors.Is(err, service.ErrCloseFriendExists) || errors.Is(err, service.ErrCloseFriendNotFound) {
+			response := map[string]interface{}{"status": "unchanged"}
+			jsonresp.Response(w, response, http.StatusOK)
+			return
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			log.Println(err)
+			jsonresp.ErrorWithDefaultMessage(w, http.StatusRequestTimeout)
+			return
+		}
+		if errors.Is(err, context.Canceled) {
+			// client disconnected
+			log.Println(err)
+			return
+		}
+		if err != nil {
+			log.Println(err)
+			jsonresp.ErrorWithDefaultMessage(w, http.StatusInternalServerError)
+			return
+		}
+
+		response := map[string]interface{}{"status": "success"}
+		jsonresp.Response(w, response, successCode)
+	})
+}
+
+func Block(blockService *service.Block) http.Handler {
+	return changeRelation(blockService.Create, http.StatusCreated)
+}
+
+func Unblock(blockService *service.Block) http.Handler {
+	return changeRelation(blockService.Delete, http.StatusOK)
+}
+
+func Mute(muteService *service.Mute) http.Handler {
+	return changeRelation(muteService.Create, http.StatusCreated)
+}
+
+func Unmute(muteService *service.Mute) http.Handler {
+	return changeRelation(muteService.Delete, http.StatusOK)
+}
+
+func AddCloseFriend(closeFriendService *service.CloseFriend) http.Handler {
+	return changeRelation(closeFriendService.Create, http.StatusCreated)
+}
+
+func RemoveCloseFriend(closeFriendService *service.CloseFriend) http.Handler {
+	return changeRelation(closeFriendService.Delete, http.StatusOK)
+}
+
+func GetCloseFriends(closeFriendService *service.CloseFriend) http.Handler {
+	return getOwnList(closeFriendService.Get, "close_friends")
+}
@@ -8,7 +8,10 @@ import (
 	"net/http"
 	"smapp/common/jsonresp"
 	commonmw "smapp/common/middleware"
+	"smapp/user/model"
 	"smapp/user/service"
+	"strconv"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
@@ -29,7 +32,7 @@ func Follow(followService *service.Follow) http.Handler {
 			return
 		}
 
-		err = followService.Create(r.Context(), followerID, followedID)
+		requested, err := followService.Create(r.Context(), followerID, followedID)
 		if errors.Is(err, service.ErrSelfFollow) {
 			jsonresp.Error(w, "Cannot follow self", http.StatusBadRequest)
 			return
@@ -38,7 +41,11 @@ func Follow(followService *service.Follow) http.Handler {
 			jsonresp.Error(w, "User not found", http.StatusNotFound)
 			return
 		}
-		if errors.Is(err, service.ErrFollowExists) {
+		if errors.Is(err, service.ErrFollowBlocked) {
+			jsonresp.Error(w, "Cannot follow this user", http.StatusForbidden)
+			return
+		}
+		if errors.Is(err, service.ErrFollowExists) || errors.Is(err, service.ErrFollowRequestExists) {
 			response := map[string]interface{}{"status": "unchanged"}
 			jsonresp.Response(w, response, http.StatusOK)
 			return
@@ -59,7 +66,250 @@ func Follow(followService *service.Follow) http.Handler {
 			return
 		}
 
+		if requested {
+			// The user is private and has to approve the request first
+			response := map[string]interface{}{"status": "requested"}
+			jsonresp.Response(w, response, http.StatusAccepted)
+			return
+		}
 		response := map[string]interface{}{"status": "success"}
 		jsonresp.Response(w, response, http.StatusCreated)
 	})
 }
+
+func Unfollow(followService *service.Follow) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		followedID, err := uuid.Parse(mux.Vars(r)["user_id"])
+		if err != nil {
+			jsonresp.Error(w, fmt.Sprintf("Invalid user ID: %s", err.Error()), http.StatusBadRequest)
+			return
+		}
+
+		followerID, err := commonmw.GetUserID(r.Context())
+		if err != nil {
+			log.Println(err)
+			jsonresp.ErrorWithDefaultMessage(w, http.StatusInternalServerError)
+			return
+		}
+
+		err = followService.Delete(r.Context(), followerID, followedID)
+		if errors.Is(err, service.ErrFollowNotFound) {
+			response := map[string]interface{}{"status": "unchanged"}
+			jsonresp.Response(w, response, http.StatusOK)
+			return
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			log.Println(err)
+			jsonresp.ErrorWithDefaultMessage(w, http.StatusRequestTimeout)
+			return
+		}
+		if errors.Is(err, context.Canceled) {
+			// client disconnected
+			log.Println(err)
+			return
+		}
+		if err != nil {
+			log.Println(err)
+			jsonresp.ErrorWithDefaultMessage(w, http.StatusInternalServerError)
+			return
+		}
+
+		response := map[string]interface{}{"status": "success"}
+		jsonresp.Response(w, response, http.StatusOK)
+	})
+}
+
+type getFollowEntriesFunc func(
+	ctx context.Context, userID uuid.UUID, cursor model.Cursor, limit int,
+) ([]model.FollowEntry, *model.Cursor, error)
+
+func GetFollowers(followService *service.Follow) http.Handler {
+	return getFollowEntries(followService.GetFollowers, "followers")
+}
+
+func GetFollowing(followService *service.Follow) http.Handler {
+	return getFollowEntries(followService.GetFollowing, "following")
+}
+
+func getFollowEntries(getEntries getFollowEntriesFunc, responseKey string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, err := uuid.Parse(mux.Vars(r)["user_id"])
+		if err != nil {
+			jsonresp.Error(w, fmt.Sprintf("Invalid user ID: %s", err.Error()), http.StatusBadRequest)
+			return
+		}
+
+		lastLoadedTimestamp, err := time.Parse(time.RFC3339, r.URL.Query().Get("last_loaded_timestamp"))
+		if err != nil {
+			jsonresp.Error(w, fmt.Sprintf("last_loaded_timestamp: should be in format %s", time.RFC3339), http.StatusBadRequest)
+			return
+		}
+		lastLoadedID, err := uuid.Parse(r.URL.Query().Get("last_loaded_id"))
+		if err != nil {
+			jsonresp.Error(w, fmt.Sprintf("Invalid last_loaded_id: %s", err.Error()), http.StatusBadRequest)
+			return
+		}
+		limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+		if err != nil {
+			jsonresp.Error(w, "limit: should be an integer", http.StatusBadRequest)
+			return
+		}
+
+		cursor := model.Cursor{
+			LastLoadedTimestamp: lastLoadedTimestamp,
+			LastLoadedID:        lastLoadedID,
+		}
+		entries, nextCursor, err := getEntries(r.Context(), userID, cursor, limit)
+		if errors.Is(err, service.ErrFollowsPaginationLimitInvalid) {
+			jsonresp.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, service.ErrUserNotFound) {
+			jsonresp.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			log.Println(err)
+			jsonresp.ErrorWithDefaultMessage(w, http.StatusRequestTimeout)
+			return
+		}
+		if errors.Is(err, context.Canceled) {
+			// client disconnected
+			log.Println(err)
+			return
+		}
+		if err != nil {
+			log.Println(err)
+			jsonresp.ErrorWithDefaultMessage(w, http.StatusInternalServerError)
+			return
+		}
+
+		response := map[string]interface{}{
+			"status": "success",
+			"data": map[string]interface{}{
+				responseKey:   entries,
+				"next_cursor": nextCursor,
+			},
+		}
+		jsonresp.Response(w, response, http.StatusOK)
+	})
+}
+
+func GetFollowRequests(followService *service.Follow) http.Handler {
+	return getOwnList(followService.GetRequests, "follow_requests")
+}
+
+type getOwnListFunc[T any] func(
+	ctx context.Context, userID uuid.UUID, cursor model.Cursor, limit int,
+) ([]T, *model.Cursor, error)
+
+// For paginated lists that only the user can see.
+func getOwnList[T any](getEntries getOwnListFunc[T], responseKey string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lastLoadedTimestamp, err := time.Parse(time.RFC3339, r.URL.Query().Get("last_loaded_timestamp"))
+		if err != nil {
+			jsonresp.Error(w, fmt.Sprintf("last_loaded_timestamp: should be in format %s", time.RFC3339), http.StatusBadRequest)
+			return
+		}
+		lastLoadedID, err := uuid.Parse(r.URL.Query().Get("last_loaded_id"))
+		if err != nil {
+			jsonresp.Error(w, fmt.Sprintf("Invalid last_loaded_id: %s", err.Error()), http.StatusBadRequest)
+			return
+		}
+		limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+		if err != nil {
+			jsonresp.Error(w, "limit: should be an integer", http.StatusBadRequest)
+			return
+		}
+
+		userID, err := commonmw.GetUserID(r.Context())
+		if err != nil {
+			log.Println(err)
+			jsonresp.ErrorWithDefaultMessage(w, http.StatusInternalServerError)
+			return
+		}
+
+		cursor := model.Cursor{
+			LastLoadedTimestamp: lastLoadedTimestamp,
+			LastLoadedID:        lastLoadedID,
+		}
+		entries, nextCursor, err := getEntries(r.Context(), userID, cursor, limit)
+		if errors.Is(err, service.ErrFollowsPaginationLimitInvalid) {
+			jsonresp.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			log.Println(err)
+			jsonresp.ErrorWithDefaultMessage(w, http.StatusRequestTimeout)
+			return
+		}
+		if errors.Is(err, context.Canceled) {
+			// client disconnected
+			log.Println(err)
+			return
+		}
+		if err != nil {
+			log.Println(err)
+			jsonresp.ErrorWithDefaultMessage(w, http.StatusInternalServerError)
+			return
+		}
+
+		response := map[string]interface{}{
+			"status": "success",
+			"data": map[string]interface{}{
+				responseKey:   entries,
+				"next_cursor": nextCursor,
+			},
+		}
+		jsonresp.Response(w, response, http.StatusOK)
+	})
+}
+
+func ApproveFollowRequest(followService *service.Follow) http.Handler {
+	return resolveFollowRequest(followService.ApproveRequest)
+}
+
+func RejectFollowRequest(followService *service.Follow) http.Handler {
+	return resolveFollowRequest(followService.RejectRequest)
+}
+
+func resolveFollowRequest(resolve changeRelationFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requesterID, err := uuid.Parse(mux.Vars(r)["user_id"])
+		if err != nil {
+			jsonresp.Error(w, fmt.Sprintf("Invalid user ID: %s", err.Error()), http.StatusBadRequest)
+			return
+		}
+
+		userID, err := commonmw.GetUserID(r.Context())
+		if err != nil {
+			log.Println(err)
+			jsonresp.ErrorWithDefaultMessage(w, http.StatusInternalServerError)
+			return
+		}
+
+		err = resolve(r.Context(), userID, requesterID)
+		if errors.Is(err, service.ErrFollowRequestNotFound) {
+			jsonresp.Error(w, "Follow request not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			log.Println(err)
+			jsonresp.ErrorWithDefaultMessage(w, http.StatusRequestTimeout)
+			return
+		}
+		if errors.Is(err, context.Canceled) {
+			// client disconnected
+			log.Println(err)
+			return
+		}
+		if err != nil {
+			log.Println(err)
+			jsonresp.ErrorWithDefaultMessage(w, http.StatusInternalServerError)
+			return
+		}
+
+		response := map[string]interface{}{"status": "success"}
+		jsonresp.Response(w, response, http.StatusOK)
+	})
+}
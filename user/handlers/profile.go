@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"smapp/common/jsonresp"
+	commonmw "smapp/common/middleware"
+	"smapp/user/model"
+	"smapp/user/service"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+func GetProfile(profileService *service.Profile) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, err := uuid.Parse(mux.Vars(r)["user_id"])
+		if err != nil {
+			jsonresp.Error(w, fmt.Sprintf("Invalid user ID: %s", err.Error()), http.StatusBadRequest)
+			return
+		}
+
+		user, err := profileService.GetProfile(r.Context(), userID)
+		writeProfileResponse(w, user, err)
+	})
+}
+
+func GetProfileByHandle(profileService *service.Profile) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, err := profileService.GetProfileByHandle(r.Context(), mux.Vars(r)["handle"])
+		writeProfileResponse(w, user, err)
+	})
+}
+
+func writeProfileResponse(w http.ResponseWriter, user model.User, err error) {
+	if errors.Is(err, service.ErrUserNotFound) {
+		jsonresp.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		log.Println(err)
+		jsonresp.ErrorWithDefaultMessage(w, http.StatusGatewayTimeout)
+		return
+	}
+	if errors.Is(err, context.Canceled) {
+		// client disconnected
+		log.Println(err)
+		return
+	}
+	if err != nil {
+		log.Println(err)
+		jsonresp.ErrorWithDefaultMessage(w, http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"status": "success",
+		"data":   map[string]interface{}{"user": user},
+	}
+	jsonresp.Response(w, response, http.StatusOK)
+}
+
+type UpdateProfileRequestBody struct {
+	Name      *string              `json:"name"`
+	Image     *model.ImageLocation `json:"image"`
+	IsPrivate *bool                `json:"is_private"`
+}
+
+// TODO: use constants for field length limits
+func (profile *UpdateProfileRequestBody) Validate() error {
+	return validation.ValidateStruct(
+		profile,
+		validation.Field(
+			&profile.Name,
+			validation.When(
+				profile.Image == nil && profile.IsPrivate == nil,
+				validation.NotNil.Error("name, image or is_private is required"),
+			),
+			validation.NilOrNotEmpty,
+			validation.Length(1, 50),
+		),
+		validation.Field(&profile.Image),
+	)
+}
+
+func UpdateProfile(profileService *service.Profile) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var profile UpdateProfileRequestBody
+		err := json.NewDecoder(r.Body).Decode(&profile)
+		if err != nil {
+			jsonresp.Error(w, "Invalid JSON body", http.StatusBadRequest)
+			return
+		}
+		err = profile.Validate()
+		if err != nil {
+			if e, ok := err.(validation.InternalError); ok {
+				log.Println(e.InternalError())
+				jsonresp.ErrorWithDefaultMessage(w, http.StatusInternalServerError)
+				return
+			}
+			errors := (err.(validation.Errors).Filter()).(validation.Errors)
+			jsonresp.ValidationError(w, errors, http.StatusBadRequest)
+			return
+		}
+
+		userID, err := commonmw.GetUserID(r.Context())
+		if err != nil {
+			log.Println(err)
+			jsonresp.ErrorWithDefaultMessage(w, http.StatusInternalServerError)
+			return
+		}
+
+		err = profileService.UpdateProfile(r.Context(), userID, profile.Name, profile.Image, profile.IsPrivate)
+		if errors.Is(err, service.ErrInvalidImage) {
+			jsonresp.Error(w, "Provided image location is invalid or inaccessible", http.StatusBadRequest)
+			log.Println(err)
+			return
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			log.Println(err)
+			jsonresp.ErrorWithDefaultMessage(w, http.StatusGatewayTimeout)
+			return
+		}
+		if errors.Is(err, context.Canceled) {
+			// client disconnected
+			log.Println(err)
+			return
+		}
+		if err != nil {
+			log.Println(err)
+			jsonresp.ErrorWithDefaultMessage(w, http.StatusInternalServerError)
+			return
+		}
+
+		response := map[string]interface{}{"status": "success"}
+		jsonresp.Response(w, response, http.StatusOK)
+	})
+}
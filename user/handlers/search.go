@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"smapp/common/jsonresp"
+	"smapp/user/model"
+	"smapp/user/service"
+	"strconv"
+
+	"github.com/google/uuid"
+)
+
+func SearchUsers(profileService *service.Profile) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The cursor is omitted when loading the first page.
+		var cursor *model.SearchCursor
+		if r.URL.Query().Has("last_loaded_score") || r.URL.Query().Has("last_loaded_id") {
+			lastLoadedScore, err := strconv.Atoi(r.URL.Query().Get("last_loaded_score"))
+			if err != nil {
+				jsonresp.Error(w, "last_loaded_score: should be an integer", http.StatusBadRequest)
+				return
+			}
+			lastLoadedID, err := uuid.Parse(r.URL.Query().Get("last_loaded_id"))
+			if err != nil {
+				jsonresp.Error(w, fmt.Sprintf("Invalid last_loaded_id: %s", err.Error()), http.StatusBadRequest)
+				return
+			}
+			cursor = &model.SearchCursor{
+				LastLoadedScore: lastLoadedScore,
+				LastLoadedID:    lastLoadedID,
+			}
+		}
+		limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+		if err != nil {
+			jsonresp.Error(w, "limit: should be an integer", http.StatusBadRequest)
+			return
+		}
+
+		users, nextCursor, err := profileService.Search(r.Context(), r.URL.Query().Get("q"), cursor, limit)
+		if errors.Is(err, service.ErrSearchQueryInvalid) {
+			jsonresp.Error(w, fmt.Sprintf("q: %s", err.Error()), http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, service.ErrSearchPaginationLimitInvalid) {
+			jsonresp.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			log.Println(err)
+			jsonresp.ErrorWithDefaultMessage(w, http.StatusRequestTimeout)
+			return
+		}
+		if errors.Is(err, context.Canceled) {
+			// client disconnected
+			log.Println(err)
+			return
+		}
+		if err != nil {
+			log.Println(err)
+			jsonresp.ErrorWithDefaultMessage(w, http.StatusInternalServerError)
+			return
+		}
+
+		response := map[string]interface{}{
+			"status": "success",
+			"data": map[string]interface{}{
+				"users":       users,
+				"next_cursor": nextCursor,
+			},
+		}
+		jsonresp.Response(w, response, http.StatusOK)
+	})
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"smapp/common/jsonresp"
+	"smapp/user/service"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/gorilla/mux"
+)
+
+func writeSocialLoginResponse(w http.ResponseWriter, data interface{}, err error) {
+	if errors.Is(err, service.ErrUnknownProvider) {
+		jsonresp.Error(w, "Unknown login provider", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, service.ErrInvalidSocialLoginState) {
+		jsonresp.Error(w, "Invalid or expired login state", http.StatusBadRequest)
+		return
+	}
+	// Accounts are not linked by email. A registered email gets the same response as a failed login, so that it cannot
+	// be told apart.
+	if errors.Is(err, service.ErrSocialLoginFailed) || errors.Is(err, service.ErrEmailExists) {
+		log.Println(err)
+		jsonresp.Error(w, "Login with the provider failed", http.StatusUnauthorized)
+		return
+	}
+	if errors.Is(err, service.ErrSocialLoginEmailMissing) {
+		jsonresp.Error(w, "The provider did not share an email address", http.StatusUnprocessableEntity)
+		return
+	}
+	if errors.Is(err, service.ErrHandleExists) {
+		jsonresp.Error(w, "Handle already exists", http.StatusConflict)
+		return
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		log.Println(err)
+		jsonresp.ErrorWithDefaultMessage(w, http.StatusGatewayTimeout)
+		return
+	}
+	if errors.Is(err, context.Canceled) {
+		// client disconnected
+		log.Println(err)
+		return
+	}
+	if err != nil {
+		log.Println(err)
+		jsonresp.ErrorWithDefaultMessage(w, http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"status": "success",
+		"data":   data,
+	}
+	jsonresp.Response(w, response, http.StatusOK)
+}
+
+// Returns the URL to redirect the user to. The provider redirects back to the app, which passes the parameters on to
+// CompleteSocialLogin.
+func StartSocialLogin(socialLoginService *service.SocialLogin) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start, err := socialLoginService.StartLogin(r.Context(), mux.Vars(r)["provider"])
+		writeSocialLoginResponse(w, start, err)
+	})
+}
+
+type CompleteSocialLoginRequestBody struct {
+	State string `json:"state"`
+	Code  string `json:"code"`
+}
+
+func (body *CompleteSocialLoginRequestBody) Validate() error {
+	return validation.ValidateStruct(
+		body,
+		validation.Field(&body.State, validation.Required, validation.Length(1, 100)),
+		validation.Field(&body.Code, validation.Required, validation.Length(1, 2048)),
+	)
+}
+
+func CompleteSocialLogin(socialLoginService *service.SocialLogin) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body CompleteSocialLoginRequestBody
+		if !decodeAndValidate(w, r, &body) {
+			return
+		}
+
+		result, err := socialLoginService.CompleteLogin(r.Context(), mux.Vars(r)["provider"], body.State, body.Code)
+		writeSocialLoginResponse(w, result, err)
+	})
+}
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"smapp/common/jsonresp"
+	"smapp/user/service"
+	"strings"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+type RefreshTokenRequestBody struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+func (body *RefreshTokenRequestBody) Validate() error {
+	return validation.ValidateStruct(
+		body,
+		validation.Field(&body.RefreshToken, validation.Required, validation.Length(1, 100)),
+	)
+}
+
+func RefreshToken(tokenService *service.Token) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body RefreshTokenRequestBody
+		err := json.NewDecoder(r.Body).Decode(&body)
+		if err != nil {
+			jsonresp.Error(w, "Invalid JSON body", http.StatusBadRequest)
+			return
+		}
+		err = body.Validate()
+		if err != nil {
+			if e, ok := err.(validation.InternalError); ok {
+				log.Println(e.InternalError())
+				jsonresp.ErrorWithDefaultMessage(w, http.StatusInternalServerError)
+				return
+			}
+			errors := (err.(validation.Errors).Filter()).(validation.Errors)
+			jsonresp.ValidationError(w, errors, http.StatusBadRequest)
+			return
+		}
+
+		tokens, err := tokenService.Refresh(r.Context(), body.RefreshToken)
+		if errors.Is(err, service.ErrInvalidRefreshToken) {
+			jsonresp.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+			return
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			log.Println(err)
+			jsonresp.ErrorWithDefaultMessage(w, http.StatusGatewayTimeout)
+			return
+		}
+		if errors.Is(err, context.Canceled) {
+			// client disconnected
+			log.Println(err)
+			return
+		}
+		if err != nil {
+			log.Println(err)
+			jsonresp.ErrorWithDefaultMessage(w, http.StatusInternalServerError)
+			return
+		}
+
+		response := map[string]interface{}{
+			"status": "success",
+			"data":   tokens,
+		}
+		jsonresp.Response(w, response, http.StatusOK)
+	})
+}
+
+type LogoutRequestBody struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// The refresh token is optional. Without it, only the access token used for the request is revoked.
+func Logout(tokenService *service.Token) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accessToken, ok := getBearerToken(r)
+		if !ok {
+			jsonresp.Error(w, "Missing bearer token", http.StatusUnauthorized)
+			return
+		}
+		var body LogoutRequestBody
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				jsonresp.Error(w, "Invalid JSON body", http.StatusBadRequest)
+				return
+			}
+		}
+
+		err := tokenService.Logout(r.Context(), accessToken, body.RefreshToken)
+		if errors.Is(err, service.ErrInvalidAccessToken) {
+			jsonresp.Error(w, "Invalid access token", http.StatusUnauthorized)
+			return
+		}
+		if errors.Is(err, service.ErrInvalidRefreshToken) {
+			jsonresp.Error(w, "Invalid refresh token", http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			log.Println(err)
+			jsonresp.ErrorWithDefaultMessage(w, http.StatusGatewayTimeout)
+			return
+		}
+		if errors.Is(err, context.Canceled) {
+			// client disconnected
+			log.Println(err)
+			return
+		}
+		if err != nil {
+			log.Println(err)
+			jsonresp.ErrorWithDefaultMessage(w, http.StatusInternalServerError)
+			return
+		}
+
+		jsonresp.Response(w, map[string]interface{}{"status": "success"}, http.StatusOK)
+	})
+}
+
+// Used by the gateway as a forward auth endpoint, after the token signature has been verified there.
+func CheckToken(tokenService *service.Token) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accessToken, ok := getBearerToken(r)
+		if !ok {
+			jsonresp.Error(w, "Missing bearer token", http.StatusUnauthorized)
+			return
+		}
+
+		_, err := tokenService.Check(r.Context(), accessToken)
+		if errors.Is(err, service.ErrInvalidAccessToken) {
+			jsonresp.Error(w, "Invalid access token", http.StatusUnauthorized)
+			return
+		}
+		if errors.Is(err, service.ErrAccessTokenRevoked) {
+			jsonresp.Error(w, "Access token revoked", http.StatusUnauthorized)
+			return
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			log.Println(err)
+			jsonresp.ErrorWithDefaultMessage(w, http.StatusGatewayTimeout)
+			return
+		}
+		if errors.Is(err, context.Canceled) {
+			// client disconnected
+			log.Println(err)
+			return
+		}
+		if err != nil {
+			log.Println(err)
+			jsonresp.ErrorWithDefaultMessage(w, http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// Serves the JSON Web Key Set that the gateway and other services verify access tokens against.
+func JWKS(jwtService *service.JWT) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The keys only change on deployments, and rotation leaves enough time for caches to expire.
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		jsonresp.Response(w, map[string]interface{}{"keys": jwtService.GetPublicKeys()}, http.StatusOK)
+	})
+}
+
+func getBearerToken(r *http.Request) (string, bool) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return token, ok && token != ""
+}
@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"smapp/common/jsonresp"
+	commonmw "smapp/common/middleware"
+	"smapp/user/service"
+	"strconv"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+// The data is omitted from the response if nil.
+func writeTwoFactorResponse(w http.ResponseWriter, data interface{}, err error) {
+	if errors.Is(err, service.ErrUserNotFound) {
+		jsonresp.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, service.ErrTwoFactorEnabled) {
+		jsonresp.Error(w, "Two-factor authentication already enabled", http.StatusConflict)
+		return
+	}
+	if errors.Is(err, service.ErrTwoFactorNotEnabled) {
+		jsonresp.Error(w, "Two-factor authentication not enabled", http.StatusConflict)
+		return
+	}
+	if errors.Is(err, service.ErrTwoFactorNotPending) {
+		jsonresp.Error(w, "Two-factor enrollment not started", http.StatusConflict)
+		return
+	}
+	if errors.Is(err, service.ErrInvalidTwoFactorCode) {
+		jsonresp.Error(w, "Invalid code", http.StatusUnauthorized)
+		return
+	}
+	if errors.Is(err, service.ErrInvalidLoginChallenge) {
+		jsonresp.Error(w, "Invalid or expired login challenge", http.StatusUnauthorized)
+		return
+	}
+	var lockedErr *service.LoginLockedError
+	if errors.As(err, &lockedErr) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(lockedErr.RetryAfter.Seconds())))
+		jsonresp.Error(w, "Too many failed login attempts, try again later", http.StatusTooManyRequests)
+		return
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		log.Println(err)
+		jsonresp.ErrorWithDefaultMessage(w, http.StatusGatewayTimeout)
+		return
+	}
+	if errors.Is(err, context.Canceled) {
+		// client disconnected
+		log.Println(err)
+		return
+	}
+	if err != nil {
+		log.Println(err)
+		jsonresp.ErrorWithDefaultMessage(w, http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{"status": "success"}
+	if data != nil {
+		response["data"] = data
+	}
+	jsonresp.Response(w, response, http.StatusOK)
+}
+
+type TwoFactorCodeRequestBody struct {
+	// A TOTP code, or a recovery code where accepted
+	Code string `json:"code"`
+}
+
+func (body *TwoFactorCodeRequestBody) Validate() error {
+	return validation.ValidateStruct(
+		body,
+		validation.Field(&body.Code, validation.Required, validation.Length(1, 20)),
+	)
+}
+
+func StartTOTPEnrollment(twoFactorService *service.TwoFactor) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, err := commonmw.GetUserID(r.Context())
+		if err != nil {
+			log.Println(err)
+			jsonresp.ErrorWithDefaultMessage(w, http.StatusInternalServerError)
+			return
+		}
+
+		enrollment, err := twoFactorService.StartTOTPEnrollment(r.Context(), userID)
+		writeTwoFactorResponse(w, enrollment, err)
+	})
+}
+
+func ConfirmTOTPEnrollment(twoFactorService *service.TwoFactor) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body TwoFactorCodeRequestBody
+		if !decodeAndValidate(w, r, &body) {
+			return
+		}
+		userID, err := commonmw.GetUserID(r.Context())
+		if err != nil {
+			log.Println(err)
+			jsonresp.ErrorWithDefaultMessage(w, http.StatusInternalServerError)
+			return
+		}
+
+		recoveryCodes, err := twoFactorService.ConfirmTOTPEnrollment(r.Context(), userID, body.Code)
+		writeTwoFactorResponse(w, map[string]interface{}{"recovery_codes": recoveryCodes}, err)
+	})
+}
+
+func DisableTOTP(twoFactorService *service.TwoFactor) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body TwoFactorCodeRequestBody
+		if !decodeAndValidate(w, r, &body) {
+			return
+		}
+		userID, err := commonmw.GetUserID(r.Context())
+		if err != nil {
+			log.Println(err)
+			jsonresp.ErrorWithDefaultMessage(w, http.StatusInternalServerError)
+			return
+		}
+
+		err = twoFactorService.DisableTOTP(r.Context(), userID, body.Code)
+		writeTwoFactorResponse(w, nil, err)
+	})
+}
+
+type LoginTwoFactorRequestBody struct {
+	ChallengeToken string `json:"challenge_token"`
+	// A TOTP code or a recovery code
+	Code string `json:"code"`
+}
+
+func (body *LoginTwoFactorRequestBody) Validate() error {
+	return validation.ValidateStruct(
+		body,
+		validation.Field(&body.ChallengeToken, validation.Required, validation.Length(1, 100)),
+		validation.Field(&body.Code, validation.Required, validation.Length(1, 20)),
+	)
+}
+
+// The second step of the login for users with two-factor authentication enabled.
+func LoginTwoFactor(twoFactorService *service.TwoFactor) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body LoginTwoFactorRequestBody
+		if !decodeAndValidate(w, r, &body) {
+			return
+		}
+
+		tokens, err := twoFactorService.CompleteLogin(
+			r.Context(), body.ChallengeToken, body.Code, commonmw.GetClientIP(r),
+		)
+		writeTwoFactorResponse(w, tokens, err)
+	})
+}
@@ -8,7 +8,9 @@ import (
 	"net/http"
 	"regexp"
 	"smapp/common/jsonresp"
+	commonmw "smapp/common/middleware"
 	"smapp/user/service"
+	"strconv"
 	"strings"
 
 	"golang.org/x/crypto/bcrypt"
@@ -21,7 +23,6 @@ type SignupRequestBody struct {
 	Name     string `json:"name"`
 	Email    string `json:"email"`
 	Handle   string `json:"handle"`
-	ImageURL string `json:"imageURL"`
 	Password string `json:"password"`
 }
 
@@ -61,7 +62,7 @@ func Signup(userService *service.User) http.Handler {
 			return
 		}
 
-		token, err := userService.Signup(r.Context(), user.Name, user.Email, user.Handle, user.Password)
+		tokens, err := userService.Signup(r.Context(), user.Name, user.Email, user.Handle, user.Password)
 		if errors.Is(err, service.ErrEmailExists) {
 			jsonresp.Error(w, "Email already exists", http.StatusConflict)
 			return
@@ -88,7 +89,7 @@ func Signup(userService *service.User) http.Handler {
 
 		response := map[string]interface{}{
 			"status": "success",
-			"data":   map[string]string{"token": token},
+			"data":   tokens,
 		}
 		jsonresp.Response(w, response, http.StatusCreated)
 	})
@@ -150,11 +151,20 @@ func Login(userService *service.User) http.Handler {
 			return
 		}
 
-		token, err := userService.Login(r.Context(), user.Identifier, []byte(user.Password))
+		result, err := userService.Login(
+			r.Context(), user.Identifier, []byte(user.Password), commonmw.GetClientIP(r),
+		)
 		if errors.Is(err, service.ErrUserNotFound) || errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
 			jsonresp.Error(w, wrongCredentialsMessage, http.StatusUnauthorized)
 			return
 		}
+		// The same for existing and non-existing users.
+		var lockedErr *service.LoginLockedError
+		if errors.As(err, &lockedErr) {
+			w.Header().Set("Retry-After", strconv.Itoa(int(lockedErr.RetryAfter.Seconds())))
+			jsonresp.Error(w, "Too many failed login attempts, try again later", http.StatusTooManyRequests)
+			return
+		}
 		if errors.Is(err, context.DeadlineExceeded) {
 			log.Println(err)
 			jsonresp.ErrorWithDefaultMessage(w, http.StatusGatewayTimeout)
@@ -173,7 +183,7 @@ func Login(userService *service.User) http.Handler {
 
 		response := map[string]interface{}{
 			"status": "success",
-			"data":   map[string]string{"token": token},
+			"data":   result,
 		}
 		jsonresp.Response(w, response, http.StatusOK)
 	})
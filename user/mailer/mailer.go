@@ -0,0 +1,94 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	// Plain text
+	Body string
+}
+
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+type SMTP struct {
+	addr string
+	auth smtp.Auth
+	from string
+}
+
+// Authenticates with PLAIN auth, which net/smtp only allows over TLS or to localhost.
+func NewSMTP(addr, username string, password []byte, from string) *SMTP {
+	host, _, _ := net.SplitHostPort(addr)
+	return &SMTP{
+		addr: addr,
+		auth: smtp.PlainAuth("", username, string(password), host),
+		from: from,
+	}
+}
+
+// net/smtp does not support contexts, so the context is only checked before sending.
+func (m *SMTP) Send(ctx context.Context, msg Message) error {
+	fail := func(err error) error {
+		return fmt.Errorf("send email over smtp: %w", err)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return fail(err)
+	}
+	// The From header can contain a display name, but the envelope sender must be the bare address.
+	sender, err := mail.ParseAddress(m.from)
+	if err != nil {
+		return fail(err)
+	}
+	if err = smtp.SendMail(m.addr, m.auth, sender.Address, []string{msg.To}, format(m.from, msg)); err != nil {
+		return fail(err)
+	}
+	return nil
+}
+
+func format(from string, msg Message) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+// Writes emails to a file or to the log instead of sending them. Intended for development and tests.
+type Log struct {
+	mu   sync.Mutex
+	w    io.Writer
+	from string
+}
+
+func NewLog(w io.Writer, from string) *Log {
+	return &Log{w: w, from: from}
+}
+
+func (m *Log) Send(ctx context.Context, msg Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, err := fmt.Fprintf(m.w, "%s\r\n\r\n", format(m.from, msg)); err != nil {
+		return fmt.Errorf("write email to log: %w", err)
+	}
+	return nil
+}
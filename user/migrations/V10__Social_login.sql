@@ -0,0 +1,26 @@
+-- Accounts created through social login have no password until the user sets one with a password reset
+ALTER TABLE users MODIFY password_hash VARCHAR(60) NULL;
+
+-- Links accounts of external OpenID Connect providers to users
+CREATE TABLE user_identities (
+    -- The provider name from the configuration, e.g. "google"
+    provider VARCHAR(50) NOT NULL,
+    -- The sub claim of the ID token, unique per provider
+    subject VARCHAR(255) NOT NULL,
+    user_id BINARY(16) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (provider, subject),
+    INDEX user_id_index (user_id),
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+-- Issued when a social login starts, and consumed when the provider redirects back
+CREATE TABLE social_login_states (
+    -- SHA-256 of the state parameter, the state itself is not stored
+    state_hash BINARY(32) PRIMARY KEY,
+    provider VARCHAR(50) NOT NULL,
+    code_verifier VARCHAR(64) NOT NULL,
+    nonce VARCHAR(64) NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    INDEX expires_at_index (expires_at)
+);
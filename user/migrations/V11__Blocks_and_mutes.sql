@@ -0,0 +1,20 @@
+-- Blocked users cannot follow or interact with the blocker, and the other way around
+CREATE TABLE blocks (
+    blocker_id BINARY(16) NOT NULL,
+    blocked_id BINARY(16) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (blocker_id, blocked_id),
+    INDEX blocked_id_index (blocked_id),
+    FOREIGN KEY (blocker_id) REFERENCES users(id),
+    FOREIGN KEY (blocked_id) REFERENCES users(id)
+);
+
+-- Posts and comments of muted users are hidden from the muter, nothing else changes
+CREATE TABLE mutes (
+    muter_id BINARY(16) NOT NULL,
+    muted_id BINARY(16) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (muter_id, muted_id),
+    FOREIGN KEY (muter_id) REFERENCES users(id),
+    FOREIGN KEY (muted_id) REFERENCES users(id)
+);
@@ -0,0 +1,14 @@
+-- Only followers can see posts and comments of private users, and following them needs their approval
+ALTER TABLE users ADD COLUMN is_private BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Pending follows of private users, deleted once approved or rejected
+CREATE TABLE follow_requests (
+    requester_id BINARY(16) NOT NULL,
+    target_id BINARY(16) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (requester_id, target_id),
+    -- For fetching paginated requests of a user
+    INDEX target_created_at_index (target_id, created_at DESC, requester_id),
+    FOREIGN KEY (requester_id) REFERENCES users(id),
+    FOREIGN KEY (target_id) REFERENCES users(id)
+);
@@ -0,0 +1,13 @@
+-- Posts shared with close friends are only visible to the users on their author's list
+CREATE TABLE close_friends (
+    user_id BINARY(16) NOT NULL,
+    friend_id BINARY(16) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, friend_id),
+    -- For fetching the paginated list of a user
+    INDEX user_created_at_index (user_id, created_at DESC, friend_id),
+    -- For finding the lists a user is on
+    INDEX friend_id_index (friend_id),
+    FOREIGN KEY (user_id) REFERENCES users(id),
+    FOREIGN KEY (friend_id) REFERENCES users(id)
+);
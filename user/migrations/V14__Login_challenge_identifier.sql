@@ -0,0 +1,3 @@
+-- The identifier the password login was made with, so that wrong codes count against its lockout. NULL for social
+-- login.
+ALTER TABLE login_challenges ADD COLUMN identifier VARCHAR(254) NULL AFTER user_id;
@@ -0,0 +1,2 @@
+-- Access tokens issued before the password was last changed or reset are rejected
+ALTER TABLE users ADD COLUMN password_changed_at TIMESTAMP NULL;
@@ -0,0 +1,3 @@
+-- Indexes to speed up ORDER BY when fetching paginated followers/following of a user
+CREATE INDEX followed_created_at_index ON follows (followed_id, created_at DESC, follower_id);
+CREATE INDEX follower_created_at_index ON follows (follower_id, created_at DESC, followed_id);
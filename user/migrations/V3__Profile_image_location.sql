@@ -0,0 +1,5 @@
+-- Profile images are uploaded through the image service, which issues S3 keys rather than URLs. image_url was never written.
+ALTER TABLE users
+    DROP COLUMN image_url,
+    ADD COLUMN image_s3_bucket VARCHAR(63),
+    ADD COLUMN image_s3_key VARCHAR(1024);
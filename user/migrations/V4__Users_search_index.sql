@@ -0,0 +1,2 @@
+-- Index for prefix search over user names, handles are already covered by handle_unique
+CREATE INDEX name_index ON users (name);
@@ -0,0 +1,6 @@
+-- Domain events written in the same transaction as the change they describe. The relay publishes and deletes them in seq order.
+CREATE TABLE outbox (
+    seq BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    payload BLOB NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,24 @@
+CREATE TABLE refresh_tokens (
+    id BINARY(16) PRIMARY KEY,
+    user_id BINARY(16) NOT NULL,
+    -- Tokens obtained by rotating the token of the same login share a family, which is revoked as a whole
+    family_id BINARY(16) NOT NULL,
+    -- SHA-256 of the token, the token itself is not stored
+    token_hash BINARY(32) NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    -- Set when the token is exchanged for a new one. A rotated token that is presented again has been stolen.
+    rotated_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY token_hash_unique (token_hash),
+    INDEX family_id_index (family_id),
+    INDEX user_expires_at_index (user_id, expires_at),
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+-- IDs (jti) of access tokens revoked before their expiration
+CREATE TABLE revoked_tokens (
+    jti BINARY(16) PRIMARY KEY,
+    -- Expiration of the access token, after which the row is no longer needed
+    expires_at TIMESTAMP NOT NULL,
+    INDEX expires_at_index (expires_at)
+);
@@ -0,0 +1,15 @@
+ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMP NULL;
+
+-- Single-use tokens sent by email, e.g. to verify the email address or to reset the password
+CREATE TABLE account_tokens (
+    -- SHA-256 of the token, the token itself is not stored
+    token_hash BINARY(32) PRIMARY KEY,
+    user_id BINARY(16) NOT NULL,
+    purpose ENUM('verify_email', 'reset_password') NOT NULL,
+    -- The address the token was sent to. Verification fails if the user has changed the email since.
+    email VARCHAR(254) NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX user_purpose_index (user_id, purpose),
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
@@ -0,0 +1,9 @@
+-- Failed logins, counted separately per login identifier and per client IP
+CREATE TABLE login_attempts (
+    -- "identifier:" or "ip:" followed by the lowercased identifier or the address
+    attempt_key VARCHAR(300) PRIMARY KEY,
+    failures INT NOT NULL,
+    last_failure_at TIMESTAMP NOT NULL,
+    locked_until TIMESTAMP NULL,
+    INDEX last_failure_at_index (last_failure_at)
+);
@@ -0,0 +1,31 @@
+CREATE TABLE totp_secrets (
+    user_id BINARY(16) PRIMARY KEY,
+    -- AES-GCM encrypted secret, prefixed with the nonce
+    secret_encrypted VARBINARY(128) NOT NULL,
+    -- Not set until the user confirms the enrollment with a valid code
+    enabled_at TIMESTAMP NULL,
+    -- Time step of the last accepted code, so that a code cannot be used twice
+    last_used_step BIGINT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+-- Single-use codes for logging in without the authenticator app
+CREATE TABLE recovery_codes (
+    user_id BINARY(16) NOT NULL,
+    -- SHA-256 of the code, the code itself is not stored
+    code_hash BINARY(32) NOT NULL,
+    PRIMARY KEY (user_id, code_hash),
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+-- Issued by the first login step when the password is correct and two-factor authentication is enabled
+CREATE TABLE login_challenges (
+    -- SHA-256 of the token, the token itself is not stored
+    token_hash BINARY(32) PRIMARY KEY,
+    user_id BINARY(16) NOT NULL,
+    failures INT NOT NULL DEFAULT 0,
+    expires_at TIMESTAMP NOT NULL,
+    INDEX expires_at_index (expires_at),
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
@@ -0,0 +1,119 @@
+package model
+
+import (
+	"net/url"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/google/uuid"
+)
+
+type User struct {
+	ID        uuid.UUID      `json:"id"`
+	Name      string         `json:"name"`
+	Handle    string         `json:"handle"`
+	Image     *ImageLocation `json:"image"`
+	IsPrivate bool           `json:"is_private"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+type UserSummary struct {
+	ID     uuid.UUID      `json:"id"`
+	Name   string         `json:"name"`
+	Handle string         `json:"handle"`
+	Image  *ImageLocation `json:"image"`
+}
+
+type FollowEntry struct {
+	UserSummary
+	FollowedAt time.Time `json:"followed_at"`
+}
+
+type Follow struct {
+	FollowerID uuid.UUID
+	FollowedID uuid.UUID
+}
+
+type FollowRequest struct {
+	UserSummary
+	RequestedAt time.Time `json:"requested_at"`
+}
+
+type CloseFriend struct {
+	UserSummary
+	AddedAt time.Time `json:"added_at"`
+}
+
+type TokenPair struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// If the user has two-factor authentication enabled, only the challenge token is set, which is exchanged for the
+// tokens along with a code in the second step.
+type LoginResult struct {
+	*TokenPair
+	TwoFactorRequired bool   `json:"two_factor_required"`
+	ChallengeToken    string `json:"challenge_token,omitempty"`
+}
+
+type SocialLoginStart struct {
+	// The user is redirected to this URL at the provider
+	AuthorizationURL string `json:"authorization_url"`
+	// Also included in the URL. The client should keep it and check that the provider returns the same value.
+	State string `json:"state"`
+}
+
+type TOTPEnrollment struct {
+	// Base32 encoded, for entering manually into an authenticator app
+	Secret string `json:"secret"`
+	// otpauth:// URI to be shown as a QR code
+	URI string `json:"uri"`
+}
+
+type AccountTokenPurpose string
+
+const (
+	AccountTokenVerifyEmail   AccountTokenPurpose = "verify_email"
+	AccountTokenResetPassword AccountTokenPurpose = "reset_password"
+)
+
+// JSON Web Key as defined in RFC 7517.
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+type Cursor struct {
+	LastLoadedTimestamp time.Time `json:"last_loaded_timestamp"`
+	LastLoadedID        uuid.UUID `json:"last_loaded_id"`
+}
+
+// Search results are ordered by score, so the score of the last loaded result is used instead of its creation time.
+type SearchCursor struct {
+	LastLoadedScore int       `json:"last_loaded_score"`
+	LastLoadedID    uuid.UUID `json:"last_loaded_id"`
+}
+
+type ImageLocation struct {
+	Bucket string `json:"bucket"`
+	Key    string `json:"key"`
+}
+
+func (image *ImageLocation) Validate() error {
+	return validation.ValidateStruct(
+		image,
+		validation.Field(&image.Bucket, validation.Required, validation.Length(1, 63)),
+		validation.Field(&image.Key, validation.Required, validation.Length(1, 1024)),
+	)
+}
+
+// Images are served from baseURL, e.g. "https://s3.eu-north-1.amazonaws.com", with the bucket as the first segment of
+// the path.
+func (image *ImageLocation) URL(baseURL *url.URL) string {
+	return baseURL.JoinPath(image.Bucket, image.Key).String()
+}
@@ -0,0 +1,82 @@
+package oidc_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"smapp/user/oidc"
+	"smapp/user/oidc/oidctest"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func newProvider(t *testing.T) (*oidctest.Server, *oidc.Provider) {
+	is := is.New(t)
+
+	server, err := oidctest.NewServer("client", "secret")
+	is.NoErr(err)
+	t.Cleanup(server.Close)
+	provider := oidc.NewProvider(oidc.Config{
+		Issuer:       server.URL,
+		ClientID:     "client",
+		ClientSecret: []byte("secret"),
+		RedirectURL:  "https://example.com/login/oidc/test/callback",
+	}, http.DefaultClient)
+	return server, provider
+}
+
+func TestLogin(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	server, provider := newProvider(t)
+
+	authURL, err := provider.AuthCodeURL(ctx, "state", "nonce", "verifier")
+	is.NoErr(err)
+	code, state, err := server.Authorize(authURL, oidctest.User{
+		Subject: "123", Email: "user@example.com", EmailVerified: true, Name: "User",
+	})
+	is.NoErr(err)
+	is.Equal(state, "state")
+
+	idToken, err := provider.Exchange(ctx, code, "verifier")
+	is.NoErr(err)
+	claims, err := provider.VerifyIDToken(ctx, idToken, "nonce")
+	is.NoErr(err)
+	is.Equal(claims, oidc.Claims{Subject: "123", Email: "user@example.com", EmailVerified: true, Name: "User"})
+
+	// The code has been used.
+	_, err = provider.Exchange(ctx, code, "verifier")
+	is.True(errors.Is(err, oidc.ErrExchangeFailed))
+}
+
+func TestExchangeWrongCodeVerifier(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	server, provider := newProvider(t)
+
+	authURL, err := provider.AuthCodeURL(ctx, "state", "nonce", "verifier")
+	is.NoErr(err)
+	code, _, err := server.Authorize(authURL, oidctest.User{Subject: "123"})
+	is.NoErr(err)
+
+	// An intercepted code is useless without the verifier.
+	_, err = provider.Exchange(ctx, code, "other verifier")
+	is.True(errors.Is(err, oidc.ErrExchangeFailed))
+}
+
+func TestVerifyIDTokenWrongNonce(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	server, provider := newProvider(t)
+
+	authURL, err := provider.AuthCodeURL(ctx, "state", "nonce", "verifier")
+	is.NoErr(err)
+	code, _, err := server.Authorize(authURL, oidctest.User{Subject: "123"})
+	is.NoErr(err)
+	idToken, err := provider.Exchange(ctx, code, "verifier")
+	is.NoErr(err)
+
+	_, err = provider.VerifyIDToken(ctx, idToken, "other nonce")
+	is.True(errors.Is(err, oidc.ErrInvalidIDToken))
+}
@@ -0,0 +1,173 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"smapp/user/model"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type AccountToken struct {
+	db *sql.DB
+}
+
+func NewAccountToken(db *sql.DB) *AccountToken {
+	return &AccountToken{db: db}
+}
+
+// Earlier tokens of the user with the same purpose are removed, so that only the latest email works.
+func (a *AccountToken) Create(
+	ctx context.Context,
+	userID uuid.UUID,
+	purpose model.AccountTokenPurpose,
+	email string,
+	tokenHash []byte,
+	expiresAt time.Time,
+) error {
+	fail := func(err error) error {
+		return fmt.Errorf("add account token to db: %w", err)
+	}
+
+	tx, err := a.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fail(err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(
+		ctx,
+		"DELETE FROM account_tokens WHERE user_id = ? AND purpose = ?",
+		userID[:], purpose,
+	)
+	if err != nil {
+		return fail(err)
+	}
+	_, err = tx.ExecContext(
+		ctx,
+		"INSERT INTO account_tokens (token_hash, user_id, purpose, email, expires_at) VALUES (?, ?, ?, ?, ?)",
+		tokenHash, userID[:], purpose, email, expiresAt,
+	)
+	if err != nil {
+		return fail(err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fail(err)
+	}
+	return nil
+}
+
+// Deletes the token within the transaction, and returns the user and the email the token was sent to.
+func consumeAccountToken(
+	ctx context.Context, tx *sql.Tx, purpose model.AccountTokenPurpose, tokenHash []byte,
+) (uuid.UUID, string, error) {
+	var userID uuid.UUID
+	var email string
+	var expired bool
+	err := tx.QueryRowContext(
+		ctx,
+		`SELECT user_id, email, expires_at < NOW()
+		FROM account_tokens
+		WHERE token_hash = ? AND purpose = ?
+		FOR UPDATE`,
+		tokenHash, purpose,
+	).Scan(&userID, &email, &expired)
+	if errors.Is(err, sql.ErrNoRows) {
+		return uuid.Nil, "", ErrRecordNotFound
+	}
+	if err != nil {
+		return uuid.Nil, "", err
+	}
+	if expired {
+		return uuid.Nil, "", ErrTokenExpired
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM account_tokens WHERE token_hash = ?", tokenHash)
+	if err != nil {
+		return uuid.Nil, "", err
+	}
+	return userID, email, nil
+}
+
+// Returns ErrRecordNotFound if the token does not exist or the user's email has changed since the token was sent.
+func (a *AccountToken) VerifyEmail(ctx context.Context, tokenHash []byte) error {
+	fail := func(err error) error {
+		return fmt.Errorf("verify email in db: %w", err)
+	}
+
+	tx, err := a.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fail(err)
+	}
+	defer tx.Rollback()
+
+	userID, email, err := consumeAccountToken(ctx, tx, model.AccountTokenVerifyEmail, tokenHash)
+	if errors.Is(err, ErrRecordNotFound) || errors.Is(err, ErrTokenExpired) {
+		return err
+	}
+	if err != nil {
+		return fail(err)
+	}
+	result, err := tx.ExecContext(
+		ctx,
+		"UPDATE users SET email_verified_at = NOW() WHERE id = ? AND email = ?",
+		userID[:], email,
+	)
+	if err != nil {
+		return fail(err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fail(err)
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fail(err)
+	}
+	return nil
+}
+
+// Sets the new password and revokes all refresh tokens of the user, so that other sessions have to log in again.
+func (a *AccountToken) ResetPassword(ctx context.Context, tokenHash []byte, passwordHash string) error {
+	fail := func(err error) error {
+		return fmt.Errorf("reset password in db: %w", err)
+	}
+
+	tx, err := a.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fail(err)
+	}
+	defer tx.Rollback()
+
+	userID, _, err := consumeAccountToken(ctx, tx, model.AccountTokenResetPassword, tokenHash)
+	if errors.Is(err, ErrRecordNotFound) || errors.Is(err, ErrTokenExpired) {
+		return err
+	}
+	if err != nil {
+		return fail(err)
+	}
+	_, err = tx.ExecContext(
+		ctx,
+		"UPDATE users SET password_hash = ?, password_changed_at = NOW() WHERE id = ?",
+		passwordHash, userID[:],
+	)
+	if err != nil {
+		return fail(err)
+	}
+	_, err = tx.ExecContext(ctx, "DELETE FROM refresh_tokens WHERE user_id = ?", userID[:])
+	if err != nil {
+		return fail(err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fail(err)
+	}
+	return nil
+}
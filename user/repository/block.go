@@ -0,0 +1,153 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/google/uuid"
+)
+
+type Block struct {
+	db *sql.DB
+}
+
+func NewBlock(db *sql.DB) *Block {
+	return &Block{db: db}
+}
+
+// Follows, follow requests and close friend entries between the two users are deleted in the same transaction, in
+// both directions. Deleted follows are written to the outbox as UserUnfollowed events.
+func (b *Block) Create(ctx context.Context, blockerID, blockedID uuid.UUID) error {
+	fail := func(err error) error {
+		return fmt.Errorf("add block to db: %w", err)
+	}
+
+	tx, err := b.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fail(err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(
+		ctx,
+		"INSERT INTO blocks (blocker_id, blocked_id) VALUES (?, ?)",
+		blockerID[:], blockedID[:],
+	)
+	var mysqlError *mysql.MySQLError
+	if errors.As(err, &mysqlError) {
+		if mysqlError.Number == 1452 {
+			return ErrUserIDNotFound
+		}
+		if mysqlError.Number == 1062 {
+			return ErrRecordExists
+		}
+	}
+	if err != nil {
+		return fail(err)
+	}
+
+	if _, err = deleteFollow(ctx, tx, blockerID, blockedID); err != nil {
+		return fail(err)
+	}
+	if _, err = deleteFollow(ctx, tx, blockedID, blockerID); err != nil {
+		return fail(err)
+	}
+	_, err = tx.ExecContext(
+		ctx,
+		`DELETE FROM follow_requests
+		WHERE (requester_id = ? AND target_id = ?) OR (requester_id = ? AND target_id = ?)`,
+		blockerID[:], blockedID[:], blockedID[:], blockerID[:],
+	)
+	if err != nil {
+		return fail(err)
+	}
+	_, err = tx.ExecContext(
+		ctx,
+		`DELETE FROM close_friends
+		WHERE (user_id = ? AND friend_id = ?) OR (user_id = ? AND friend_id = ?)`,
+		blockerID[:], blockedID[:], blockedID[:], blockerID[:],
+	)
+	if err != nil {
+		return fail(err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fail(err)
+	}
+	return nil
+}
+
+func (b *Block) Delete(ctx context.Context, blockerID, blockedID uuid.UUID) error {
+	fail := func(err error) error {
+		return fmt.Errorf("delete block from db: %w", err)
+	}
+
+	result, err := b.db.ExecContext(
+		ctx,
+		"DELETE FROM blocks WHERE blocker_id = ? AND blocked_id = ?",
+		blockerID[:], blockedID[:],
+	)
+	if err != nil {
+		return fail(err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fail(err)
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// Whether either of the users has blocked the other.
+func (b *Block) Exists(ctx context.Context, userID, otherID uuid.UUID) (bool, error) {
+	var exists bool
+	err := b.db.QueryRowContext(
+		ctx,
+		`SELECT EXISTS(
+			SELECT 1 FROM blocks
+			WHERE (blocker_id = ? AND blocked_id = ?) OR (blocker_id = ? AND blocked_id = ?)
+		)`,
+		userID[:], otherID[:], otherID[:], userID[:],
+	).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("check if block exists in db: %w", err)
+	}
+	return exists, nil
+}
+
+// Returns the users blocked by the user and the users who have blocked the user.
+func (b *Block) GetBlockedIDs(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error) {
+	fail := func(err error) ([]uuid.UUID, error) {
+		return nil, fmt.Errorf("get blocked ids from db: %w", err)
+	}
+
+	rows, err := b.db.QueryContext(
+		ctx,
+		`SELECT blocked_id FROM blocks WHERE blocker_id = ?
+		UNION
+		SELECT blocker_id FROM blocks WHERE blocked_id = ?`,
+		userID[:], userID[:],
+	)
+	if err != nil {
+		return fail(err)
+	}
+	defer rows.Close()
+
+	blocked := make([]uuid.UUID, 0)
+	for rows.Next() {
+		var blockedID uuid.UUID
+		if err := rows.Scan(&blockedID); err != nil {
+			return fail(err)
+		}
+		blocked = append(blocked, blockedID)
+	}
+	if err := rows.Err(); err != nil {
+		return fail(err)
+	}
+	return blocked, nil
+}
@@ -0,0 +1,155 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"smapp/user/model"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/google/uuid"
+)
+
+type CloseFriend struct {
+	db *sql.DB
+}
+
+func NewCloseFriend(db *sql.DB) *CloseFriend {
+	return &CloseFriend{db: db}
+}
+
+func (cf *CloseFriend) Create(ctx context.Context, userID, friendID uuid.UUID) error {
+	_, err := cf.db.ExecContext(
+		ctx,
+		"INSERT INTO close_friends (user_id, friend_id) VALUES (?, ?)",
+		userID[:], friendID[:],
+	)
+	var mysqlError *mysql.MySQLError
+	if errors.As(err, &mysqlError) {
+		if mysqlError.Number == 1452 {
+			return ErrUserIDNotFound
+		}
+		if mysqlError.Number == 1062 {
+			return ErrRecordExists
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("add close friend to db: %w", err)
+	}
+	return nil
+}
+
+func (cf *CloseFriend) Delete(ctx context.Context, userID, friendID uuid.UUID) error {
+	fail := func(err error) error {
+		return fmt.Errorf("delete close friend from db: %w", err)
+	}
+
+	result, err := cf.db.ExecContext(
+		ctx,
+		"DELETE FROM close_friends WHERE user_id = ? AND friend_id = ?",
+		userID[:], friendID[:],
+	)
+	if err != nil {
+		return fail(err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fail(err)
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// Returns the candidates that have the user on their close friends list.
+func (cf *CloseFriend) FilterListedBy(ctx context.Context, userID uuid.UUID, candidateIDs []uuid.UUID) ([]uuid.UUID, error) {
+	fail := func(err error) ([]uuid.UUID, error) {
+		return nil, fmt.Errorf("filter close friend lists in db: %w", err)
+	}
+
+	placeholders := make([]string, len(candidateIDs))
+	args := make([]interface{}, 0, len(candidateIDs)+1)
+	args = append(args, userID[:])
+	for i, id := range candidateIDs {
+		placeholders[i] = "?"
+		args = append(args, id[:])
+	}
+	rows, err := cf.db.QueryContext(
+		ctx,
+		fmt.Sprintf(
+			"SELECT user_id FROM close_friends WHERE friend_id = ? AND user_id IN (%s)",
+			strings.Join(placeholders, ","),
+		),
+		args...,
+	)
+	if err != nil {
+		return fail(err)
+	}
+	defer rows.Close()
+
+	listedBy := make([]uuid.UUID, 0)
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return fail(err)
+		}
+		listedBy = append(listedBy, id)
+	}
+	if err := rows.Err(); err != nil {
+		return fail(err)
+	}
+	return listedBy, nil
+}
+
+func (cf *CloseFriend) GetPaginated(
+	ctx context.Context, userID uuid.UUID, cursor model.Cursor, limit int,
+) ([]model.CloseFriend, *model.Cursor, error) {
+	fail := func(err error) ([]model.CloseFriend, *model.Cursor, error) {
+		return nil, nil, fmt.Errorf("get close friends from db: %w", err)
+	}
+
+	rows, err := cf.db.QueryContext(
+		ctx,
+		`
+		SELECT u.id, u.name, u.handle, u.image_s3_bucket, u.image_s3_key, cf.created_at
+		FROM close_friends cf
+		JOIN users u ON u.id = cf.friend_id
+		WHERE cf.user_id = ? AND (cf.created_at < ? OR (cf.created_at = ? AND cf.friend_id > ?))
+		ORDER BY cf.created_at DESC, cf.friend_id
+		LIMIT ?
+		`,
+		userID[:], cursor.LastLoadedTimestamp, cursor.LastLoadedTimestamp, cursor.LastLoadedID[:], limit+1,
+	)
+	if err != nil {
+		return fail(err)
+	}
+	defer rows.Close()
+
+	friends := make([]model.CloseFriend, 0)
+	for i := 0; i < limit && rows.Next(); i++ {
+		var friend model.CloseFriend
+		var imageBucket, imageKey sql.NullString
+		err = rows.Scan(&friend.ID, &friend.Name, &friend.Handle, &imageBucket, &imageKey, &friend.AddedAt)
+		if err != nil {
+			return fail(err)
+		}
+		friend.Image = imageLocationFromNullable(imageBucket, imageKey)
+		friends = append(friends, friend)
+	}
+	if err = rows.Err(); err != nil {
+		return fail(err)
+	}
+
+	var nextCursor *model.Cursor
+	// Given that we have loaded limit+1 elements and iterated over at most limit elements, rows.Next() == false means its the last page.
+	if rows.Next() {
+		nextCursor = &model.Cursor{
+			LastLoadedTimestamp: friends[len(friends)-1].AddedAt,
+			LastLoadedID:        friends[len(friends)-1].ID,
+		}
+	}
+	return friends, nextCursor, nil
+}
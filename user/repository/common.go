@@ -1,9 +1,20 @@
 package repository
 
-import "errors"
+import (
+	"database/sql"
+	"errors"
+	"smapp/user/model"
+)
 
 var (
 	ErrUserIDNotFound = errors.New("id not found in users table")
 	ErrRecordNotFound = errors.New("record not found")
 	ErrRecordExists   = errors.New("record already exists")
 )
+
+func imageLocationFromNullable(bucket, key sql.NullString) *model.ImageLocation {
+	if !bucket.Valid || !key.Valid {
+		return nil
+	}
+	return &model.ImageLocation{Bucket: bucket.String, Key: key.String}
+}
@@ -5,6 +5,11 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"smapp/user/model"
+	"strings"
+
+	eventsPB "smapp/common/grpc/events"
+	"smapp/common/outbox"
 
 	"github.com/go-sql-driver/mysql"
 	"github.com/google/uuid"
@@ -23,7 +28,29 @@ func (f *Follow) Create(ctx context.Context, followerID, followedID uuid.UUID) e
 		return fmt.Errorf("add follow to db: %w", err)
 	}
 
-	_, err := f.db.ExecContext(
+	tx, err := f.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fail(err)
+	}
+	defer tx.Rollback()
+
+	err = insertFollow(ctx, tx, followerID, followedID)
+	if errors.Is(err, ErrUserIDNotFound) || errors.Is(err, ErrRecordExists) {
+		return err
+	}
+	if err != nil {
+		return fail(err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fail(err)
+	}
+	return nil
+}
+
+// Also adds the UserFollowed event to the outbox.
+func insertFollow(ctx context.Context, tx *sql.Tx, followerID, followedID uuid.UUID) error {
+	_, err := tx.ExecContext(
 		ctx,
 		"INSERT INTO follows (follower_id, followed_id) VALUES (?, ?)",
 		followerID[:], followedID[:],
@@ -37,10 +64,56 @@ func (f *Follow) Create(ctx context.Context, followerID, followedID uuid.UUID) e
 			return ErrRecordExists
 		}
 	}
+	if err != nil {
+		return err
+	}
+
+	return outbox.Add(ctx, tx, &eventsPB.Event{
+		Payload: &eventsPB.Event_UserFollowed{UserFollowed: &eventsPB.UserFollowed{
+			FollowerId: followerID[:],
+			FollowedId: followedID[:],
+		}},
+	})
+}
+
+// Returns up to limit follows in primary key order, starting after the given one, or from the beginning if it is nil.
+func (f *Follow) GetBatch(ctx context.Context, after *model.Follow, limit int) ([]model.Follow, error) {
+	fail := func(err error) ([]model.Follow, error) {
+		return nil, fmt.Errorf("get follows batch from db: %w", err)
+	}
+
+	condition := ""
+	args := make([]interface{}, 0, 4)
+	if after != nil {
+		condition = "WHERE (follower_id, followed_id) > (?, ?)"
+		args = append(args, after.FollowerID[:], after.FollowedID[:])
+	}
+	args = append(args, limit)
+	rows, err := f.db.QueryContext(
+		ctx,
+		fmt.Sprintf(
+			"SELECT follower_id, followed_id FROM follows %s ORDER BY follower_id, followed_id LIMIT ?",
+			condition,
+		),
+		args...,
+	)
 	if err != nil {
 		return fail(err)
 	}
-	return nil
+	defer rows.Close()
+
+	follows := make([]model.Follow, 0, limit)
+	for rows.Next() {
+		var follow model.Follow
+		if err = rows.Scan(&follow.FollowerID, &follow.FollowedID); err != nil {
+			return fail(err)
+		}
+		follows = append(follows, follow)
+	}
+	if err = rows.Err(); err != nil {
+		return fail(err)
+	}
+	return follows, nil
 }
 
 func (f *Follow) GetFollowed(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error) {
@@ -71,3 +144,204 @@ func (f *Follow) GetFollowed(ctx context.Context, userID uuid.UUID) ([]uuid.UUID
 	}
 	return followed, nil
 }
+
+// If the user has more than limit followers, no IDs are returned and limitExceeded is true.
+func (f *Follow) GetFollowerIDs(ctx context.Context, userID uuid.UUID, limit int) (followers []uuid.UUID, limitExceeded bool, err error) {
+	fail := func(err error) ([]uuid.UUID, bool, error) {
+		return nil, false, fmt.Errorf("get follower ids from db: %w", err)
+	}
+
+	rows, err := f.db.QueryContext(
+		ctx,
+		"SELECT follower_id FROM follows WHERE followed_id = ? LIMIT ?",
+		userID[:], limit+1,
+	)
+	if err != nil {
+		return fail(err)
+	}
+	defer rows.Close()
+
+	followers = make([]uuid.UUID, 0)
+	for rows.Next() {
+		var followerID uuid.UUID
+		if err := rows.Scan(&followerID); err != nil {
+			return fail(err)
+		}
+		followers = append(followers, followerID)
+	}
+	if err := rows.Err(); err != nil {
+		return fail(err)
+	}
+	if len(followers) > limit {
+		return nil, true, nil
+	}
+	return followers, false, nil
+}
+
+func (f *Follow) FilterFollowed(ctx context.Context, userID uuid.UUID, candidateIDs []uuid.UUID) ([]uuid.UUID, error) {
+	fail := func(err error) ([]uuid.UUID, error) {
+		return nil, fmt.Errorf("filter followed in db: %w", err)
+	}
+
+	placeholders := make([]string, len(candidateIDs))
+	args := make([]interface{}, 0, len(candidateIDs)+1)
+	args = append(args, userID[:])
+	for i, id := range candidateIDs {
+		placeholders[i] = "?"
+		args = append(args, id[:])
+	}
+	rows, err := f.db.QueryContext(
+		ctx,
+		fmt.Sprintf(
+			"SELECT followed_id FROM follows WHERE follower_id = ? AND followed_id IN (%s)",
+			strings.Join(placeholders, ","),
+		),
+		args...,
+	)
+	if err != nil {
+		return fail(err)
+	}
+	defer rows.Close()
+
+	followed := make([]uuid.UUID, 0)
+	for rows.Next() {
+		var followedID uuid.UUID
+		if err := rows.Scan(&followedID); err != nil {
+			return fail(err)
+		}
+		followed = append(followed, followedID)
+	}
+	if err := rows.Err(); err != nil {
+		return fail(err)
+	}
+	return followed, nil
+}
+
+func (f *Follow) Delete(ctx context.Context, followerID, followedID uuid.UUID) error {
+	fail := func(err error) error {
+		return fmt.Errorf("delete follow from db: %w", err)
+	}
+
+	tx, err := f.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fail(err)
+	}
+	defer tx.Rollback()
+
+	deleted, err := deleteFollow(ctx, tx, followerID, followedID)
+	if err != nil {
+		return fail(err)
+	}
+	if !deleted {
+		return ErrRecordNotFound
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fail(err)
+	}
+	return nil
+}
+
+// Also adds the UserUnfollowed event to the outbox if the follow existed.
+func deleteFollow(ctx context.Context, tx *sql.Tx, followerID, followedID uuid.UUID) (bool, error) {
+	result, err := tx.ExecContext(
+		ctx,
+		"DELETE FROM follows WHERE follower_id = ? AND followed_id = ?",
+		followerID[:], followedID[:],
+	)
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if rowsAffected == 0 {
+		return false, nil
+	}
+
+	err = outbox.Add(ctx, tx, &eventsPB.Event{
+		Payload: &eventsPB.Event_UserUnfollowed{UserUnfollowed: &eventsPB.UserUnfollowed{
+			FollowerId: followerID[:],
+			FollowedId: followedID[:],
+		}},
+	})
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (f *Follow) GetFollowersPaginated(
+	ctx context.Context, userID uuid.UUID, cursor model.Cursor, limit int,
+) ([]model.FollowEntry, *model.Cursor, error) {
+	query := `
+		SELECT u.id, u.name, u.handle, u.image_s3_bucket, u.image_s3_key, f.created_at 
+		FROM follows f 
+		JOIN users u ON u.id = f.follower_id 
+		WHERE f.followed_id = ? AND (f.created_at < ? OR (f.created_at = ? AND f.follower_id > ?)) 
+		ORDER BY f.created_at DESC, f.follower_id 
+		LIMIT ? 
+	`
+	entries, nextCursor, err := f.getPaginated(ctx, query, userID, cursor, limit)
+	if err != nil {
+		return nil, nil, fmt.Errorf("get followers from db: %w", err)
+	}
+	return entries, nextCursor, nil
+}
+
+func (f *Follow) GetFollowingPaginated(
+	ctx context.Context, userID uuid.UUID, cursor model.Cursor, limit int,
+) ([]model.FollowEntry, *model.Cursor, error) {
+	query := `
+		SELECT u.id, u.name, u.handle, u.image_s3_bucket, u.image_s3_key, f.created_at 
+		FROM follows f 
+		JOIN users u ON u.id = f.followed_id 
+		WHERE f.follower_id = ? AND (f.created_at < ? OR (f.created_at = ? AND f.followed_id > ?)) 
+		ORDER BY f.created_at DESC, f.followed_id 
+		LIMIT ? 
+	`
+	entries, nextCursor, err := f.getPaginated(ctx, query, userID, cursor, limit)
+	if err != nil {
+		return nil, nil, fmt.Errorf("get following from db: %w", err)
+	}
+	return entries, nextCursor, nil
+}
+
+func (f *Follow) getPaginated(
+	ctx context.Context, query string, userID uuid.UUID, cursor model.Cursor, limit int,
+) ([]model.FollowEntry, *model.Cursor, error) {
+	rows, err := f.db.QueryContext(
+		ctx,
+		query,
+		userID[:], cursor.LastLoadedTimestamp, cursor.LastLoadedTimestamp, cursor.LastLoadedID[:], limit+1,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	entries := make([]model.FollowEntry, 0)
+	for i := 0; i < limit && rows.Next(); i++ {
+		var entry model.FollowEntry
+		var imageBucket, imageKey sql.NullString
+		if err = rows.Scan(&entry.ID, &entry.Name, &entry.Handle, &imageBucket, &imageKey, &entry.FollowedAt); err != nil {
+			return nil, nil, err
+		}
+		entry.Image = imageLocationFromNullable(imageBucket, imageKey)
+		entries = append(entries, entry)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	var nextCursor *model.Cursor
+	// Given that we have loaded limit+1 elements and iterated over at most limit elements, rows.Next() == false means its the last page.
+	if rows.Next() {
+		nextCursor = &model.Cursor{
+			LastLoadedTimestamp: entries[len(entries)-1].FollowedAt,
+			LastLoadedID:        entries[len(entries)-1].ID,
+		}
+	}
+	return entries, nextCursor, nil
+}
@@ -0,0 +1,154 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"smapp/user/model"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/google/uuid"
+)
+
+type FollowRequest struct {
+	db *sql.DB
+}
+
+func NewFollowRequest(db *sql.DB) *FollowRequest {
+	return &FollowRequest{db: db}
+}
+
+func (fr *FollowRequest) Create(ctx context.Context, requesterID, targetID uuid.UUID) error {
+	_, err := fr.db.ExecContext(
+		ctx,
+		"INSERT INTO follow_requests (requester_id, target_id) VALUES (?, ?)",
+		requesterID[:], targetID[:],
+	)
+	var mysqlError *mysql.MySQLError
+	if errors.As(err, &mysqlError) {
+		if mysqlError.Number == 1452 {
+			return ErrUserIDNotFound
+		}
+		if mysqlError.Number == 1062 {
+			return ErrRecordExists
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("add follow request to db: %w", err)
+	}
+	return nil
+}
+
+func (fr *FollowRequest) Delete(ctx context.Context, requesterID, targetID uuid.UUID) error {
+	fail := func(err error) error {
+		return fmt.Errorf("delete follow request from db: %w", err)
+	}
+
+	result, err := fr.db.ExecContext(
+		ctx,
+		"DELETE FROM follow_requests WHERE requester_id = ? AND target_id = ?",
+		requesterID[:], targetID[:],
+	)
+	if err != nil {
+		return fail(err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fail(err)
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// Turns the request into a follow in one transaction. The requester can already be following, e.g. if they followed
+// while the target was public, in which case only the request is deleted.
+func (fr *FollowRequest) Approve(ctx context.Context, requesterID, targetID uuid.UUID) error {
+	fail := func(err error) error {
+		return fmt.Errorf("approve follow request in db: %w", err)
+	}
+
+	tx, err := fr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fail(err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(
+		ctx,
+		"DELETE FROM follow_requests WHERE requester_id = ? AND target_id = ?",
+		requesterID[:], targetID[:],
+	)
+	if err != nil {
+		return fail(err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fail(err)
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	err = insertFollow(ctx, tx, requesterID, targetID)
+	if err != nil && !errors.Is(err, ErrRecordExists) {
+		return fail(err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fail(err)
+	}
+	return nil
+}
+
+func (fr *FollowRequest) GetPaginated(
+	ctx context.Context, targetID uuid.UUID, cursor model.Cursor, limit int,
+) ([]model.FollowRequest, *model.Cursor, error) {
+	fail := func(err error) ([]model.FollowRequest, *model.Cursor, error) {
+		return nil, nil, fmt.Errorf("get follow requests from db: %w", err)
+	}
+
+	rows, err := fr.db.QueryContext(
+		ctx,
+		`
+		SELECT u.id, u.name, u.handle, u.image_s3_bucket, u.image_s3_key, fr.created_at
+		FROM follow_requests fr
+		JOIN users u ON u.id = fr.requester_id
+		WHERE fr.target_id = ? AND (fr.created_at < ? OR (fr.created_at = ? AND fr.requester_id > ?))
+		ORDER BY fr.created_at DESC, fr.requester_id
+		LIMIT ?
+		`,
+		targetID[:], cursor.LastLoadedTimestamp, cursor.LastLoadedTimestamp, cursor.LastLoadedID[:], limit+1,
+	)
+	if err != nil {
+		return fail(err)
+	}
+	defer rows.Close()
+
+	requests := make([]model.FollowRequest, 0)
+	for i := 0; i < limit && rows.Next(); i++ {
+		var request model.FollowRequest
+		var imageBucket, imageKey sql.NullString
+		err = rows.Scan(&request.ID, &request.Name, &request.Handle, &imageBucket, &imageKey, &request.RequestedAt)
+		if err != nil {
+			return fail(err)
+		}
+		request.Image = imageLocationFromNullable(imageBucket, imageKey)
+		requests = append(requests, request)
+	}
+	if err = rows.Err(); err != nil {
+		return fail(err)
+	}
+
+	var nextCursor *model.Cursor
+	// Given that we have loaded limit+1 elements and iterated over at most limit elements, rows.Next() == false means its the last page.
+	if rows.Next() {
+		nextCursor = &model.Cursor{
+			LastLoadedTimestamp: requests[len(requests)-1].RequestedAt,
+			LastLoadedID:        requests[len(requests)-1].ID,
+		}
+	}
+	return requests, nextCursor, nil
+}
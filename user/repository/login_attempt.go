@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type LoginAttempt struct {
+	db *sql.DB
+}
+
+func NewLoginAttempt(db *sql.DB) *LoginAttempt {
+	return &LoginAttempt{db: db}
+}
+
+// Returns how long the longest of the lockouts of the keys lasts, or 0 if none of them is locked.
+func (l *LoginAttempt) GetLockout(ctx context.Context, keys []string) (time.Duration, error) {
+	placeholders := make([]string, len(keys))
+	args := make([]interface{}, len(keys))
+	for i, key := range keys {
+		placeholders[i] = "?"
+		args[i] = key
+	}
+	var seconds sql.NullInt64
+	err := l.db.QueryRowContext(
+		ctx,
+		fmt.Sprintf(
+			`SELECT MAX(TIMESTAMPDIFF(SECOND, NOW(), locked_until))
+			FROM login_attempts
+			WHERE attempt_key IN (%s) AND locked_until > NOW()`,
+			strings.Join(placeholders, ","),
+		),
+		args...,
+	).Scan(&seconds)
+	if err != nil {
+		return 0, fmt.Errorf("get login lockout from db: %w", err)
+	}
+	if !seconds.Valid {
+		return 0, nil
+	}
+	// TIMESTAMPDIFF truncates, and the lockout is still active at 0 seconds left.
+	return time.Duration(seconds.Int64+1) * time.Second, nil
+}
+
+// Returns the number of failures of the key, which starts over if the previous failure was longer than resetAfter
+// ago. Counters that have been reset anyway are removed on the way.
+func (l *LoginAttempt) RecordFailure(ctx context.Context, key string, resetAfter time.Duration) (int, error) {
+	fail := func(err error) (int, error) {
+		return 0, fmt.Errorf("record failed login in db: %w", err)
+	}
+
+	_, err := l.db.ExecContext(
+		ctx,
+		`DELETE FROM login_attempts
+		WHERE last_failure_at < NOW() - INTERVAL ? SECOND AND (locked_until IS NULL OR locked_until < NOW())
+		LIMIT 1000`,
+		int(resetAfter.Seconds()),
+	)
+	if err != nil {
+		return fail(err)
+	}
+
+	tx, err := l.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fail(err)
+	}
+	defer tx.Rollback()
+
+	// Assignments are applied in order, so failures is computed from the previous last_failure_at.
+	_, err = tx.ExecContext(
+		ctx,
+		`INSERT INTO login_attempts (attempt_key, failures, last_failure_at) VALUES (?, 1, NOW())
+		ON DUPLICATE KEY UPDATE
+			failures = IF(last_failure_at < NOW() - INTERVAL ? SECOND, 1, failures + 1),
+			last_failure_at = NOW()`,
+		key, int(resetAfter.Seconds()),
+	)
+	if err != nil {
+		return fail(err)
+	}
+	var failures int
+	err = tx.QueryRowContext(ctx, "SELECT failures FROM login_attempts WHERE attempt_key = ?", key).Scan(&failures)
+	if err != nil {
+		return fail(err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fail(err)
+	}
+	return failures, nil
+}
+
+func (l *LoginAttempt) Lock(ctx context.Context, key string, duration time.Duration) error {
+	_, err := l.db.ExecContext(
+		ctx,
+		"UPDATE login_attempts SET locked_until = NOW() + INTERVAL ? SECOND WHERE attempt_key = ?",
+		int(duration.Seconds()), key,
+	)
+	if err != nil {
+		return fmt.Errorf("lock login in db: %w", err)
+	}
+	return nil
+}
+
+func (l *LoginAttempt) Reset(ctx context.Context, key string) error {
+	_, err := l.db.ExecContext(ctx, "DELETE FROM login_attempts WHERE attempt_key = ?", key)
+	if err != nil {
+		return fmt.Errorf("reset failed logins in db: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/google/uuid"
+)
+
+type Mute struct {
+	db *sql.DB
+}
+
+func NewMute(db *sql.DB) *Mute {
+	return &Mute{db: db}
+}
+
+func (m *Mute) Create(ctx context.Context, muterID, mutedID uuid.UUID) error {
+	_, err := m.db.ExecContext(
+		ctx,
+		"INSERT INTO mutes (muter_id, muted_id) VALUES (?, ?)",
+		muterID[:], mutedID[:],
+	)
+	var mysqlError *mysql.MySQLError
+	if errors.As(err, &mysqlError) {
+		if mysqlError.Number == 1452 {
+			return ErrUserIDNotFound
+		}
+		if mysqlError.Number == 1062 {
+			return ErrRecordExists
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("add mute to db: %w", err)
+	}
+	return nil
+}
+
+func (m *Mute) Delete(ctx context.Context, muterID, mutedID uuid.UUID) error {
+	fail := func(err error) error {
+		return fmt.Errorf("delete mute from db: %w", err)
+	}
+
+	result, err := m.db.ExecContext(
+		ctx,
+		"DELETE FROM mutes WHERE muter_id = ? AND muted_id = ?",
+		muterID[:], mutedID[:],
+	)
+	if err != nil {
+		return fail(err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fail(err)
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+func (m *Mute) GetMutedIDs(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error) {
+	fail := func(err error) ([]uuid.UUID, error) {
+		return nil, fmt.Errorf("get muted ids from db: %w", err)
+	}
+
+	rows, err := m.db.QueryContext(ctx, "SELECT muted_id FROM mutes WHERE muter_id = ?", userID[:])
+	if err != nil {
+		return fail(err)
+	}
+	defer rows.Close()
+
+	muted := make([]uuid.UUID, 0)
+	for rows.Next() {
+		var mutedID uuid.UUID
+		if err := rows.Scan(&mutedID); err != nil {
+			return fail(err)
+		}
+		muted = append(muted, mutedID)
+	}
+	if err := rows.Err(); err != nil {
+		return fail(err)
+	}
+	return muted, nil
+}
@@ -0,0 +1,147 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type SocialLogin struct {
+	db *sql.DB
+}
+
+func NewSocialLogin(db *sql.DB) *SocialLogin {
+	return &SocialLogin{db: db}
+}
+
+// Expired states are removed on the way.
+func (s *SocialLogin) CreateState(
+	ctx context.Context, stateHash []byte, provider, codeVerifier, nonce string, expiresAt time.Time,
+) error {
+	fail := func(err error) error {
+		return fmt.Errorf("add social login state to db: %w", err)
+	}
+
+	_, err := s.db.ExecContext(ctx, "DELETE FROM social_login_states WHERE expires_at < NOW() LIMIT 1000")
+	if err != nil {
+		return fail(err)
+	}
+	_, err = s.db.ExecContext(
+		ctx,
+		"INSERT INTO social_login_states (state_hash, provider, code_verifier, nonce, expires_at) VALUES (?, ?, ?, ?, ?)",
+		stateHash, provider, codeVerifier, nonce, expiresAt,
+	)
+	if err != nil {
+		return fail(err)
+	}
+	return nil
+}
+
+// Deletes the state, so that it can be used only once. Returns ErrRecordNotFound if the state does not exist, has
+// expired, or belongs to another provider.
+func (s *SocialLogin) ConsumeState(
+	ctx context.Context, stateHash []byte, provider string,
+) (codeVerifier, nonce string, err error) {
+	fail := func(err error) (string, string, error) {
+		return "", "", fmt.Errorf("consume social login state in db: %w", err)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fail(err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(
+		ctx,
+		`SELECT code_verifier, nonce
+		FROM social_login_states
+		WHERE state_hash = ? AND provider = ? AND expires_at > NOW()
+		FOR UPDATE`,
+		stateHash, provider,
+	).Scan(&codeVerifier, &nonce)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", "", ErrRecordNotFound
+	}
+	if err != nil {
+		return fail(err)
+	}
+	_, err = tx.ExecContext(ctx, "DELETE FROM social_login_states WHERE state_hash = ?", stateHash)
+	if err != nil {
+		return fail(err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fail(err)
+	}
+	return codeVerifier, nonce, nil
+}
+
+// Returns ErrRecordNotFound if the external account is not linked to a user.
+func (s *SocialLogin) GetUserID(ctx context.Context, provider, subject string) (uuid.UUID, error) {
+	var userID uuid.UUID
+	err := s.db.QueryRowContext(
+		ctx,
+		"SELECT user_id FROM user_identities WHERE provider = ? AND subject = ?",
+		provider, subject,
+	).Scan(&userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return uuid.Nil, ErrRecordNotFound
+	}
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("get user id by identity from db: %w", err)
+	}
+	return userID, nil
+}
+
+// Creates a user without a password, linked to the external account. Returns ErrEmailExists or ErrHandleExists on
+// conflicts with other users.
+func (s *SocialLogin) CreateUser(
+	ctx context.Context, name, email, handle string, emailVerified bool, provider, subject string,
+) (uuid.UUID, error) {
+	fail := func(err error) (uuid.UUID, error) {
+		return uuid.Nil, fmt.Errorf("insert user with identity in db: %w", err)
+	}
+
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return fail(err)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fail(err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(
+		ctx,
+		`INSERT INTO users (id, name, email, handle, password_hash, email_verified_at)
+		VALUES (?, ?, ?, ?, NULL, IF(?, NOW(), NULL))`,
+		id[:], name, email, handle, emailVerified,
+	)
+	err = mapUniqueViolation(err)
+	if errors.Is(err, ErrEmailExists) || errors.Is(err, ErrHandleExists) {
+		return uuid.Nil, err
+	}
+	if err != nil {
+		return fail(err)
+	}
+	_, err = tx.ExecContext(
+		ctx,
+		"INSERT INTO user_identities (provider, subject, user_id) VALUES (?, ?, ?)",
+		provider, subject, id[:],
+	)
+	if err != nil {
+		return fail(err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fail(err)
+	}
+	return id, nil
+}
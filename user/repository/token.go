@@ -0,0 +1,188 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Token struct {
+	db *sql.DB
+}
+
+func NewToken(db *sql.DB) *Token {
+	return &Token{db: db}
+}
+
+var (
+	ErrTokenExpired = errors.New("token expired")
+	ErrTokenReused  = errors.New("rotated token reused")
+)
+
+// Starts a new token family. Expired refresh tokens of the user are removed on the way.
+func (t *Token) CreateRefreshToken(ctx context.Context, userID uuid.UUID, tokenHash []byte, expiresAt time.Time) error {
+	fail := func(err error) error {
+		return fmt.Errorf("add refresh token to db: %w", err)
+	}
+
+	_, err := t.db.ExecContext(
+		ctx,
+		"DELETE FROM refresh_tokens WHERE user_id = ? AND expires_at < NOW()",
+		userID[:],
+	)
+	if err != nil {
+		return fail(err)
+	}
+
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return fail(err)
+	}
+	_, err = t.db.ExecContext(
+		ctx,
+		"INSERT INTO refresh_tokens (id, user_id, family_id, token_hash, expires_at) VALUES (?, ?, ?, ?, ?)",
+		id[:], userID[:], id[:], tokenHash, expiresAt,
+	)
+	if err != nil {
+		return fail(err)
+	}
+	return nil
+}
+
+// Replaces the token with a new one of the same family and returns the owner of the token. If the token has already
+// been rotated, the whole family is revoked and ErrTokenReused is returned.
+func (t *Token) RotateRefreshToken(
+	ctx context.Context, tokenHash, newTokenHash []byte, expiresAt time.Time,
+) (uuid.UUID, error) {
+	fail := func(err error) (uuid.UUID, error) {
+		return uuid.Nil, fmt.Errorf("rotate refresh token in db: %w", err)
+	}
+
+	tx, err := t.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fail(err)
+	}
+	defer tx.Rollback()
+
+	var id, userID, familyID uuid.UUID
+	var rotated, expired bool
+	err = tx.QueryRowContext(
+		ctx,
+		`SELECT id, user_id, family_id, rotated_at IS NOT NULL, expires_at < NOW()
+		FROM refresh_tokens
+		WHERE token_hash = ?
+		FOR UPDATE`,
+		tokenHash,
+	).Scan(&id, &userID, &familyID, &rotated, &expired)
+	if errors.Is(err, sql.ErrNoRows) {
+		return uuid.Nil, ErrRecordNotFound
+	}
+	if err != nil {
+		return fail(err)
+	}
+
+	if rotated {
+		_, err = tx.ExecContext(ctx, "DELETE FROM refresh_tokens WHERE family_id = ?", familyID[:])
+		if err != nil {
+			return fail(err)
+		}
+		if err = tx.Commit(); err != nil {
+			return fail(err)
+		}
+		return uuid.Nil, ErrTokenReused
+	}
+	if expired {
+		return uuid.Nil, ErrTokenExpired
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE refresh_tokens SET rotated_at = NOW() WHERE id = ?", id[:])
+	if err != nil {
+		return fail(err)
+	}
+	newID, err := uuid.NewRandom()
+	if err != nil {
+		return fail(err)
+	}
+	_, err = tx.ExecContext(
+		ctx,
+		"INSERT INTO refresh_tokens (id, user_id, family_id, token_hash, expires_at) VALUES (?, ?, ?, ?, ?)",
+		newID[:], userID[:], familyID[:], newTokenHash, expiresAt,
+	)
+	if err != nil {
+		return fail(err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fail(err)
+	}
+	return userID, nil
+}
+
+// Revokes the family of the token if the token belongs to the user.
+func (t *Token) DeleteRefreshTokenFamily(ctx context.Context, userID uuid.UUID, tokenHash []byte) error {
+	fail := func(err error) error {
+		return fmt.Errorf("delete refresh token family from db: %w", err)
+	}
+
+	// The derived table is needed because MySQL does not allow selecting from the table being deleted from.
+	result, err := t.db.ExecContext(
+		ctx,
+		`DELETE FROM refresh_tokens
+		WHERE family_id = (SELECT family_id FROM (
+			SELECT family_id FROM refresh_tokens WHERE token_hash = ? AND user_id = ?
+		) t)`,
+		tokenHash, userID[:],
+	)
+	if err != nil {
+		return fail(err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fail(err)
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// Revoked tokens that have expired anyway are removed on the way.
+func (t *Token) RevokeAccessToken(ctx context.Context, jti uuid.UUID, expiresAt time.Time) error {
+	fail := func(err error) error {
+		return fmt.Errorf("revoke access token in db: %w", err)
+	}
+
+	_, err := t.db.ExecContext(ctx, "DELETE FROM revoked_tokens WHERE expires_at < NOW() LIMIT 1000")
+	if err != nil {
+		return fail(err)
+	}
+	_, err = t.db.ExecContext(
+		ctx,
+		"INSERT IGNORE INTO revoked_tokens (jti, expires_at) VALUES (?, ?)",
+		jti[:], expiresAt,
+	)
+	if err != nil {
+		return fail(err)
+	}
+	return nil
+}
+
+// A token is revoked if its jti has been revoked, or if it was issued before the user last changed their password.
+// Issue times have a precision of seconds, so tokens issued in the same second as the change are still accepted.
+func (t *Token) IsAccessTokenRevoked(ctx context.Context, jti, userID uuid.UUID, issuedAt time.Time) (bool, error) {
+	var revoked bool
+	err := t.db.QueryRowContext(
+		ctx,
+		`SELECT EXISTS(SELECT 1 FROM revoked_tokens WHERE jti = ?)
+			OR EXISTS(SELECT 1 FROM users WHERE id = ? AND password_changed_at > ?)`,
+		jti[:], userID[:], issuedAt,
+	).Scan(&revoked)
+	if err != nil {
+		return false, fmt.Errorf("check if access token is revoked in db: %w", err)
+	}
+	return revoked, nil
+}
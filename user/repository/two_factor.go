@@ -0,0 +1,309 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type TwoFactor struct {
+	db *sql.DB
+}
+
+func NewTwoFactor(db *sql.DB) *TwoFactor {
+	return &TwoFactor{db: db}
+}
+
+var ErrCodeUsed = errors.New("code already used")
+
+// Replaces a pending secret. Returns ErrRecordExists if two-factor authentication is already enabled.
+func (t *TwoFactor) SetPendingTOTPSecret(ctx context.Context, userID uuid.UUID, secretEncrypted []byte) error {
+	fail := func(err error) error {
+		return fmt.Errorf("set pending totp secret in db: %w", err)
+	}
+
+	tx, err := t.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fail(err)
+	}
+	defer tx.Rollback()
+
+	var enabled bool
+	err = tx.QueryRowContext(
+		ctx,
+		"SELECT enabled_at IS NOT NULL FROM totp_secrets WHERE user_id = ? FOR UPDATE",
+		userID[:],
+	).Scan(&enabled)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fail(err)
+	}
+	if enabled {
+		return ErrRecordExists
+	}
+	_, err = tx.ExecContext(
+		ctx,
+		`INSERT INTO totp_secrets (user_id, secret_encrypted) VALUES (?, ?)
+		ON DUPLICATE KEY UPDATE secret_encrypted = VALUES(secret_encrypted), last_used_step = NULL`,
+		userID[:], secretEncrypted,
+	)
+	if err != nil {
+		return fail(err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fail(err)
+	}
+	return nil
+}
+
+// Returns ErrRecordNotFound if the user has not started the enrollment.
+func (t *TwoFactor) GetTOTPSecret(ctx context.Context, userID uuid.UUID) (secretEncrypted []byte, enabled bool, err error) {
+	err = t.db.QueryRowContext(
+		ctx,
+		"SELECT secret_encrypted, enabled_at IS NOT NULL FROM totp_secrets WHERE user_id = ?",
+		userID[:],
+	).Scan(&secretEncrypted, &enabled)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, ErrRecordNotFound
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("get totp secret from db: %w", err)
+	}
+	return secretEncrypted, enabled, nil
+}
+
+func (t *TwoFactor) IsTOTPEnabled(ctx context.Context, userID uuid.UUID) (bool, error) {
+	var enabled bool
+	err := t.db.QueryRowContext(
+		ctx,
+		"SELECT EXISTS(SELECT 1 FROM totp_secrets WHERE user_id = ? AND enabled_at IS NOT NULL)",
+		userID[:],
+	).Scan(&enabled)
+	if err != nil {
+		return false, fmt.Errorf("check if totp is enabled in db: %w", err)
+	}
+	return enabled, nil
+}
+
+// Enables the pending secret, whose code of the given step has been used for confirmation, and replaces the recovery
+// codes. Returns ErrRecordNotFound if there is no pending secret.
+func (t *TwoFactor) EnableTOTP(ctx context.Context, userID uuid.UUID, step int64, recoveryCodeHashes [][]byte) error {
+	fail := func(err error) error {
+		return fmt.Errorf("enable totp in db: %w", err)
+	}
+
+	tx, err := t.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fail(err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(
+		ctx,
+		"UPDATE totp_secrets SET enabled_at = NOW(), last_used_step = ? WHERE user_id = ? AND enabled_at IS NULL",
+		step, userID[:],
+	)
+	if err != nil {
+		return fail(err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fail(err)
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	_, err = tx.ExecContext(ctx, "DELETE FROM recovery_codes WHERE user_id = ?", userID[:])
+	if err != nil {
+		return fail(err)
+	}
+	for _, hash := range recoveryCodeHashes {
+		_, err = tx.ExecContext(
+			ctx,
+			"INSERT INTO recovery_codes (user_id, code_hash) VALUES (?, ?)",
+			userID[:], hash,
+		)
+		if err != nil {
+			return fail(err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fail(err)
+	}
+	return nil
+}
+
+// Returns ErrCodeUsed if a code of this or a later step has already been accepted.
+func (t *TwoFactor) UseTOTPStep(ctx context.Context, userID uuid.UUID, step int64) error {
+	fail := func(err error) error {
+		return fmt.Errorf("use totp step in db: %w", err)
+	}
+
+	result, err := t.db.ExecContext(
+		ctx,
+		`UPDATE totp_secrets SET last_used_step = ?
+		WHERE user_id = ? AND enabled_at IS NOT NULL AND (last_used_step IS NULL OR last_used_step < ?)`,
+		step, userID[:], step,
+	)
+	if err != nil {
+		return fail(err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fail(err)
+	}
+	if rowsAffected == 0 {
+		return ErrCodeUsed
+	}
+	return nil
+}
+
+// Returns ErrRecordNotFound if the code does not exist or has already been used.
+func (t *TwoFactor) UseRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash []byte) error {
+	fail := func(err error) error {
+		return fmt.Errorf("use recovery code in db: %w", err)
+	}
+
+	result, err := t.db.ExecContext(
+		ctx,
+		"DELETE FROM recovery_codes WHERE user_id = ? AND code_hash = ?",
+		userID[:], codeHash,
+	)
+	if err != nil {
+		return fail(err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fail(err)
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+func (t *TwoFactor) DisableTOTP(ctx context.Context, userID uuid.UUID) error {
+	fail := func(err error) error {
+		return fmt.Errorf("disable totp in db: %w", err)
+	}
+
+	tx, err := t.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fail(err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM totp_secrets WHERE user_id = ?", userID[:])
+	if err != nil {
+		return fail(err)
+	}
+	_, err = tx.ExecContext(ctx, "DELETE FROM recovery_codes WHERE user_id = ?", userID[:])
+	if err != nil {
+		return fail(err)
+	}
+	_, err = tx.ExecContext(ctx, "DELETE FROM login_challenges WHERE user_id = ?", userID[:])
+	if err != nil {
+		return fail(err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fail(err)
+	}
+	return nil
+}
+
+// The identifier is nil for social login. Expired challenges are removed on the way.
+func (t *TwoFactor) CreateLoginChallenge(
+	ctx context.Context, userID uuid.UUID, identifier *string, tokenHash []byte, expiresAt time.Time,
+) error {
+	fail := func(err error) error {
+		return fmt.Errorf("add login challenge to db: %w", err)
+	}
+
+	_, err := t.db.ExecContext(ctx, "DELETE FROM login_challenges WHERE expires_at < NOW() LIMIT 1000")
+	if err != nil {
+		return fail(err)
+	}
+	_, err = t.db.ExecContext(
+		ctx,
+		"INSERT INTO login_challenges (token_hash, user_id, identifier, expires_at) VALUES (?, ?, ?, ?)",
+		tokenHash, userID[:], identifier, expiresAt,
+	)
+	if err != nil {
+		return fail(err)
+	}
+	return nil
+}
+
+// Returns ErrRecordNotFound if the challenge does not exist or has expired. The identifier is nil if the challenge
+// was issued by social login.
+func (t *TwoFactor) GetLoginChallenge(
+	ctx context.Context, tokenHash []byte,
+) (userID uuid.UUID, identifier *string, err error) {
+	var identifierNullable sql.NullString
+	err = t.db.QueryRowContext(
+		ctx,
+		"SELECT user_id, identifier FROM login_challenges WHERE token_hash = ? AND expires_at > NOW()",
+		tokenHash,
+	).Scan(&userID, &identifierNullable)
+	if errors.Is(err, sql.ErrNoRows) {
+		return uuid.Nil, nil, ErrRecordNotFound
+	}
+	if err != nil {
+		return uuid.Nil, nil, fmt.Errorf("get login challenge from db: %w", err)
+	}
+	if identifierNullable.Valid {
+		identifier = &identifierNullable.String
+	}
+	return userID, identifier, nil
+}
+
+// The challenge is removed once it has failed maxFailures times.
+func (t *TwoFactor) RecordLoginChallengeFailure(ctx context.Context, tokenHash []byte, maxFailures int) error {
+	fail := func(err error) error {
+		return fmt.Errorf("record login challenge failure in db: %w", err)
+	}
+
+	_, err := t.db.ExecContext(
+		ctx,
+		"UPDATE login_challenges SET failures = failures + 1 WHERE token_hash = ?",
+		tokenHash,
+	)
+	if err != nil {
+		return fail(err)
+	}
+	_, err = t.db.ExecContext(
+		ctx,
+		"DELETE FROM login_challenges WHERE token_hash = ? AND failures >= ?",
+		tokenHash, maxFailures,
+	)
+	if err != nil {
+		return fail(err)
+	}
+	return nil
+}
+
+// Returns ErrRecordNotFound if the challenge has already been deleted, e.g. by a concurrent request.
+func (t *TwoFactor) DeleteLoginChallenge(ctx context.Context, tokenHash []byte) error {
+	fail := func(err error) error {
+		return fmt.Errorf("delete login challenge from db: %w", err)
+	}
+
+	result, err := t.db.ExecContext(ctx, "DELETE FROM login_challenges WHERE token_hash = ?", tokenHash)
+	if err != nil {
+		return fail(err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fail(err)
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
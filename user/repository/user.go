@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"smapp/user/model"
 	"strings"
 
 	"github.com/go-sql-driver/mysql"
@@ -38,21 +39,29 @@ func (u *User) Create(ctx context.Context, name, email, handle, passwordHash str
 		"INSERT INTO users (id, name, email, handle, password_hash) VALUES (?, ?, ?, ?, ?)",
 		id[:], name, email, handle, passwordHash,
 	)
+	err = mapUniqueViolation(err)
+	if errors.Is(err, ErrEmailExists) || errors.Is(err, ErrHandleExists) {
+		return uuid.Nil, err
+	}
+	if err != nil {
+		return fail(err)
+	}
+
+	return id, nil
+}
+
+func mapUniqueViolation(err error) error {
 	var mysqlError *mysql.MySQLError
 	if errors.As(err, &mysqlError) && mysqlError.Number == 1062 {
 		if strings.Contains(mysqlError.Message, "email_unique") {
-			return uuid.Nil, ErrEmailExists
+			return ErrEmailExists
 		} else if strings.Contains(mysqlError.Message, "handle_unique") {
-			return uuid.Nil, ErrHandleExists
+			return ErrHandleExists
 		} else {
-			return fail(fmt.Errorf("unexpected unique constraint: %w", err))
+			return fmt.Errorf("unexpected unique constraint: %w", err)
 		}
 	}
-	if err != nil {
-		return fail(err)
-	}
-
-	return id, nil
+	return err
 }
 
 func (u *User) GetAuthData(ctx context.Context, identifier string) (uuid.UUID, []byte, error) {
@@ -76,6 +85,432 @@ func (u *User) GetAuthData(ctx context.Context, identifier string) (uuid.UUID, [
 	return id, passwordHash, nil
 }
 
+func (u *User) GetEmail(ctx context.Context, id uuid.UUID) (email string, verified bool, err error) {
+	err = u.db.QueryRowContext(
+		ctx,
+		"SELECT email, email_verified_at IS NOT NULL FROM users WHERE id = ?",
+		id[:],
+	).Scan(&email, &verified)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", false, ErrRecordNotFound
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("get email from db: %w", err)
+	}
+	return email, verified, nil
+}
+
+func (u *User) GetIDByEmail(ctx context.Context, email string) (uuid.UUID, error) {
+	var id uuid.UUID
+	err := u.db.QueryRowContext(ctx, "SELECT id FROM users WHERE email = ?", email).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return uuid.Nil, ErrRecordNotFound
+	}
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("get user id by email from db: %w", err)
+	}
+	return id, nil
+}
+
+func (u *User) GetPasswordHash(ctx context.Context, id uuid.UUID) ([]byte, error) {
+	var passwordHash []byte
+	err := u.db.QueryRowContext(ctx, "SELECT password_hash FROM users WHERE id = ?", id[:]).Scan(&passwordHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrRecordNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get password hash from db: %w", err)
+	}
+	return passwordHash, nil
+}
+
+// Revokes all refresh tokens of the user along with the password change, so that other sessions have to log in again.
+func (u *User) UpdatePassword(ctx context.Context, id uuid.UUID, passwordHash string) error {
+	fail := func(err error) error {
+		return fmt.Errorf("update password in db: %w", err)
+	}
+
+	tx, err := u.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fail(err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(
+		ctx,
+		"UPDATE users SET password_hash = ?, password_changed_at = NOW() WHERE id = ?",
+		passwordHash, id[:],
+	)
+	if err != nil {
+		return fail(err)
+	}
+	_, err = tx.ExecContext(ctx, "DELETE FROM refresh_tokens WHERE user_id = ?", id[:])
+	if err != nil {
+		return fail(err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fail(err)
+	}
+	return nil
+}
+
+// The new email is unverified. Tokens sent to the old email stop working.
+func (u *User) UpdateEmail(ctx context.Context, id uuid.UUID, email string) error {
+	fail := func(err error) error {
+		return fmt.Errorf("update email in db: %w", err)
+	}
+
+	tx, err := u.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fail(err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(
+		ctx,
+		"UPDATE users SET email = ?, email_verified_at = NULL WHERE id = ?",
+		email, id[:],
+	)
+	err = mapUniqueViolation(err)
+	if errors.Is(err, ErrEmailExists) {
+		return err
+	}
+	if err != nil {
+		return fail(err)
+	}
+	_, err = tx.ExecContext(ctx, "DELETE FROM account_tokens WHERE user_id = ?", id[:])
+	if err != nil {
+		return fail(err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fail(err)
+	}
+	return nil
+}
+
+// Returns ErrRecordNotFound if the user does not exist.
+func (u *User) UpdateHandle(ctx context.Context, id uuid.UUID, handle string) error {
+	fail := func(err error) error {
+		return fmt.Errorf("update handle in db: %w", err)
+	}
+
+	result, err := u.db.ExecContext(ctx, "UPDATE users SET handle = ? WHERE id = ?", handle, id[:])
+	err = mapUniqueViolation(err)
+	if errors.Is(err, ErrHandleExists) {
+		return err
+	}
+	if err != nil {
+		return fail(err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fail(err)
+	}
+	// No rows are affected either if the handle has not changed.
+	if rowsAffected == 0 {
+		return u.CheckExists(ctx, id)
+	}
+	return nil
+}
+
+func (u *User) CheckExists(ctx context.Context, id uuid.UUID) error {
+	fail := func(err error) error {
+		return fmt.Errorf("check if user exists in db: %w", err)
+	}
+
+	var exists bool
+	err := u.db.QueryRowContext(
+		ctx,
+		"SELECT EXISTS(SELECT 1 FROM users WHERE id = ?)",
+		id[:],
+	).Scan(&exists)
+	if err != nil {
+		return fail(err)
+	}
+	if !exists {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+func (u *User) IsPrivate(ctx context.Context, id uuid.UUID) (bool, error) {
+	var isPrivate bool
+	err := u.db.QueryRowContext(ctx, "SELECT is_private FROM users WHERE id = ?", id[:]).Scan(&isPrivate)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, ErrRecordNotFound
+	}
+	if err != nil {
+		return false, fmt.Errorf("get is_private from db: %w", err)
+	}
+	return isPrivate, nil
+}
+
+// Returns the candidates that are private and not followed by the viewer. The viewer is never in the result, and
+// uuid.Nil stands for an anonymous viewer.
+func (u *User) FilterPrivateNotFollowed(ctx context.Context, viewerID uuid.UUID, candidateIDs []uuid.UUID) ([]uuid.UUID, error) {
+	fail := func(err error) ([]uuid.UUID, error) {
+		return nil, fmt.Errorf("filter private not followed in db: %w", err)
+	}
+
+	placeholders := make([]string, len(candidateIDs))
+	args := make([]interface{}, 0, len(candidateIDs)+2)
+	args = append(args, viewerID[:], viewerID[:])
+	for i, id := range candidateIDs {
+		placeholders[i] = "?"
+		args = append(args, id[:])
+	}
+	rows, err := u.db.QueryContext(
+		ctx,
+		fmt.Sprintf(
+			`SELECT id FROM users u 
+			WHERE u.is_private AND u.id != ? 
+			AND NOT EXISTS (SELECT 1 FROM follows f WHERE f.follower_id = ? AND f.followed_id = u.id) 
+			AND u.id IN (%s)`,
+			strings.Join(placeholders, ","),
+		),
+		args...,
+	)
+	if err != nil {
+		return fail(err)
+	}
+	defer rows.Close()
+
+	private := make([]uuid.UUID, 0)
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return fail(err)
+		}
+		private = append(private, id)
+	}
+	if err := rows.Err(); err != nil {
+		return fail(err)
+	}
+	return private, nil
+}
+
+func (u *User) Get(ctx context.Context, id uuid.UUID) (model.User, error) {
+	user, err := u.getBy(ctx, "id", id[:])
+	if err != nil && !errors.Is(err, ErrRecordNotFound) {
+		return model.User{}, fmt.Errorf("get user from db: %w", err)
+	}
+	return user, err
+}
+
+func (u *User) GetByHandle(ctx context.Context, handle string) (model.User, error) {
+	user, err := u.getBy(ctx, "handle", handle)
+	if err != nil && !errors.Is(err, ErrRecordNotFound) {
+		return model.User{}, fmt.Errorf("get user by handle from db: %w", err)
+	}
+	return user, err
+}
+
+func (u *User) getBy(ctx context.Context, column string, value interface{}) (model.User, error) {
+	var user model.User
+	var imageBucket, imageKey sql.NullString
+	err := u.db.QueryRowContext(
+		ctx,
+		fmt.Sprintf(
+			"SELECT id, name, handle, image_s3_bucket, image_s3_key, is_private, created_at FROM users WHERE %s = ?",
+			column,
+		),
+		value,
+	).Scan(&user.ID, &user.Name, &user.Handle, &imageBucket, &imageKey, &user.IsPrivate, &user.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.User{}, ErrRecordNotFound
+	}
+	if err != nil {
+		return model.User{}, err
+	}
+	user.Image = imageLocationFromNullable(imageBucket, imageKey)
+	return user, nil
+}
+
+// Users that do not exist are omitted from the result.
+func (u *User) GetSummaries(ctx context.Context, ids []uuid.UUID) ([]model.UserSummary, error) {
+	fail := func(err error) ([]model.UserSummary, error) {
+		return nil, fmt.Errorf("get user summaries from db: %w", err)
+	}
+
+	placeholders := make([]string, len(ids))
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		placeholders[i] = "?"
+		args[i] = id[:]
+	}
+	rows, err := u.db.QueryContext(
+		ctx,
+		fmt.Sprintf(
+			"SELECT id, name, handle, image_s3_bucket, image_s3_key FROM users WHERE id IN (%s)",
+			strings.Join(placeholders, ","),
+		),
+		args...,
+	)
+	if err != nil {
+		return fail(err)
+	}
+	defer rows.Close()
+
+	users := make([]model.UserSummary, 0, len(ids))
+	for rows.Next() {
+		var user model.UserSummary
+		var imageBucket, imageKey sql.NullString
+		if err = rows.Scan(&user.ID, &user.Name, &user.Handle, &imageBucket, &imageKey); err != nil {
+			return fail(err)
+		}
+		user.Image = imageLocationFromNullable(imageBucket, imageKey)
+		users = append(users, user)
+	}
+	if err = rows.Err(); err != nil {
+		return fail(err)
+	}
+	return users, nil
+}
+
+var likePatternEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// Matches users whose handle or name starts with the query. An exact handle match scores highest, then handle prefix
+// matches, then name prefix matches. A nil cursor means the first page.
+func (u *User) Search(
+	ctx context.Context, query string, cursor *model.SearchCursor, limit int,
+) ([]model.UserSummary, *model.SearchCursor, error) {
+	fail := func(err error) ([]model.UserSummary, *model.SearchCursor, error) {
+		return nil, nil, fmt.Errorf("search users in db: %w", err)
+	}
+
+	prefix := likePatternEscaper.Replace(query) + "%"
+	// Both prefix conditions are range conditions, so MySQL can use an index merge of the handle and name indexes.
+	matchQuery := `
+		SELECT id, name, handle, image_s3_bucket, image_s3_key, 
+			CASE WHEN handle = ? THEN 3 WHEN handle LIKE ? THEN 2 ELSE 1 END AS score 
+		FROM users 
+		WHERE handle LIKE ? OR name LIKE ? 
+	`
+	args := []interface{}{query, prefix, prefix, prefix}
+	cursorCondition := ""
+	if cursor != nil {
+		cursorCondition = "WHERE score < ? OR (score = ? AND id > ?)"
+		args = append(args, cursor.LastLoadedScore, cursor.LastLoadedScore, cursor.LastLoadedID[:])
+	}
+	args = append(args, limit+1)
+
+	rows, err := u.db.QueryContext(ctx, fmt.Sprintf(`
+		SELECT id, name, handle, image_s3_bucket, image_s3_key, score 
+		FROM (%s) m 
+		%s 
+		ORDER BY score DESC, id 
+		LIMIT ? 
+	`, matchQuery, cursorCondition), args...)
+	if err != nil {
+		return fail(err)
+	}
+	defer rows.Close()
+
+	users := make([]model.UserSummary, 0)
+	var lastScore int
+	for i := 0; i < limit && rows.Next(); i++ {
+		var user model.UserSummary
+		var imageBucket, imageKey sql.NullString
+		err = rows.Scan(&user.ID, &user.Name, &user.Handle, &imageBucket, &imageKey, &lastScore)
+		if err != nil {
+			return fail(err)
+		}
+		user.Image = imageLocationFromNullable(imageBucket, imageKey)
+		users = append(users, user)
+	}
+	if err = rows.Err(); err != nil {
+		return fail(err)
+	}
+
+	var nextCursor *model.SearchCursor
+	// Given that we have loaded limit+1 elements and iterated over at most limit elements, rows.Next() == false means its the last page.
+	if rows.Next() {
+		nextCursor = &model.SearchCursor{
+			LastLoadedScore: lastScore,
+			LastLoadedID:    users[len(users)-1].ID,
+		}
+	}
+	return users, nextCursor, nil
+}
+
+// Handles are matched case-insensitively. The result is keyed by the handles as stored, handles that do not exist
+// are omitted.
+func (u *User) GetIDsByHandles(ctx context.Context, handles []string) (map[string]uuid.UUID, error) {
+	fail := func(err error) (map[string]uuid.UUID, error) {
+		return nil, fmt.Errorf("get user ids by handles from db: %w", err)
+	}
+
+	placeholders := make([]string, len(handles))
+	args := make([]interface{}, len(handles))
+	for i, handle := range handles {
+		placeholders[i] = "?"
+		args[i] = handle
+	}
+	rows, err := u.db.QueryContext(
+		ctx,
+		fmt.Sprintf("SELECT handle, id FROM users WHERE handle IN (%s)", strings.Join(placeholders, ",")),
+		args...,
+	)
+	if err != nil {
+		return fail(err)
+	}
+	defer rows.Close()
+
+	ids := make(map[string]uuid.UUID, len(handles))
+	for rows.Next() {
+		var handle string
+		var id uuid.UUID
+		if err = rows.Scan(&handle, &id); err != nil {
+			return fail(err)
+		}
+		ids[handle] = id
+	}
+	if err = rows.Err(); err != nil {
+		return fail(err)
+	}
+	return ids, nil
+}
+
+// Only non-nil fields are updated.
+func (u *User) UpdateProfile(
+	ctx context.Context, id uuid.UUID, name *string, image *model.ImageLocation, isPrivate *bool,
+) error {
+	fail := func(err error) error {
+		return fmt.Errorf("update user profile in db: %w", err)
+	}
+
+	var assignments []string
+	var args []interface{}
+	if name != nil {
+		assignments = append(assignments, "name = ?")
+		args = append(args, *name)
+	}
+	if image != nil {
+		assignments = append(assignments, "image_s3_bucket = ?", "image_s3_key = ?")
+		args = append(args, image.Bucket, image.Key)
+	}
+	if isPrivate != nil {
+		assignments = append(assignments, "is_private = ?")
+		args = append(args, *isPrivate)
+	}
+	if len(assignments) == 0 {
+		return nil
+	}
+	args = append(args, id[:])
+
+	_, err := u.db.ExecContext(
+		ctx,
+		fmt.Sprintf("UPDATE users SET %s WHERE id = ?", strings.Join(assignments, ", ")),
+		args...,
+	)
+	if err != nil {
+		return fail(err)
+	}
+	return nil
+}
+
 func getIdentiferType(identifier string) string {
 	if strings.ContainsRune(identifier, '@') {
 		return "email"
@@ -0,0 +1,287 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"smapp/user/config"
+	"smapp/user/mailer"
+	"smapp/user/model"
+	"smapp/user/repository"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type Account struct {
+	userRepository         *repository.User
+	accountTokenRepository *repository.AccountToken
+	tokenService           *Token
+	mailer                 mailer.Mailer
+	// Base URL of the frontend, which links in emails point to.
+	appURL string
+}
+
+func NewAccount(
+	userRepository *repository.User,
+	accountTokenRepository *repository.AccountToken,
+	tokenService *Token,
+	mailer mailer.Mailer,
+	appURL string,
+) *Account {
+	return &Account{
+		userRepository:         userRepository,
+		accountTokenRepository: accountTokenRepository,
+		tokenService:           tokenService,
+		mailer:                 mailer,
+		appURL:                 appURL,
+	}
+}
+
+var (
+	ErrEmailAlreadyVerified = errors.New("email already verified")
+	ErrInvalidAccountToken  = errors.New("invalid account token")
+	ErrWrongPassword        = errors.New("wrong password")
+)
+
+func (svc *Account) link(path, token string) string {
+	return fmt.Sprintf("%s%s?%s", svc.appURL, path, url.Values{"token": {token}}.Encode())
+}
+
+func formatHours(d time.Duration) string {
+	if hours := int(d.Hours()); hours != 1 {
+		return fmt.Sprintf("%d hours", hours)
+	}
+	return "1 hour"
+}
+
+// Creates a token of the given purpose and returns it. Earlier tokens of the same purpose stop working.
+func (svc *Account) createToken(
+	ctx context.Context, userID uuid.UUID, purpose model.AccountTokenPurpose, email string, ttl time.Duration,
+) (string, error) {
+	token, hash, err := newOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	err = svc.accountTokenRepository.Create(ctx, userID, purpose, email, hash, time.Now().Add(ttl))
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// Runs send without waiting for it, so that the response does not depend on the mail server, and logs its error. The
+// send outlives the request, so it gets its own timeout.
+func sendInBackground(ctx context.Context, send func(ctx context.Context) error) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), config.EmailSendTimeout)
+	go func() {
+		defer cancel()
+		if err := send(ctx); err != nil {
+			log.Println(err)
+		}
+	}()
+}
+
+// Sends the verification email in the background. Failures are only logged, the user can request another email.
+func (svc *Account) SendVerificationEmailInBackground(ctx context.Context, userID uuid.UUID) {
+	sendInBackground(ctx, func(ctx context.Context) error {
+		return svc.SendVerificationEmail(ctx, userID)
+	})
+}
+
+func (svc *Account) SendVerificationEmail(ctx context.Context, userID uuid.UUID) error {
+	fail := func(err error) error {
+		return fmt.Errorf("send verification email: %w", err)
+	}
+
+	email, verified, err := svc.userRepository.GetEmail(ctx, userID)
+	if errors.Is(err, repository.ErrRecordNotFound) {
+		return fmt.Errorf("%w: %s", ErrUserNotFound, userID)
+	}
+	if err != nil {
+		return fail(err)
+	}
+	if verified {
+		return ErrEmailAlreadyVerified
+	}
+
+	token, err := svc.createToken(ctx, userID, model.AccountTokenVerifyEmail, email, config.EmailVerificationTokenTTL)
+	if err != nil {
+		return fail(err)
+	}
+	err = svc.mailer.Send(ctx, mailer.Message{
+		To:      email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf(
+			"To verify your email address, open the following link:\n\n%s\n\nThe link expires in %s.\n",
+			svc.link("/verify-email", token), formatHours(config.EmailVerificationTokenTTL),
+		),
+	})
+	if err != nil {
+		return fail(err)
+	}
+	return nil
+}
+
+func (svc *Account) VerifyEmail(ctx context.Context, token string) error {
+	err := svc.accountTokenRepository.VerifyEmail(ctx, hashOpaqueToken(token))
+	if errors.Is(err, repository.ErrRecordNotFound) || errors.Is(err, repository.ErrTokenExpired) {
+		return ErrInvalidAccountToken
+	}
+	if err != nil {
+		return fmt.Errorf("verify email: %w", err)
+	}
+	return nil
+}
+
+// Looks up the user and sends the email in the background, so that neither the response time nor the status reveals
+// which emails are registered.
+func (svc *Account) RequestPasswordReset(ctx context.Context, email string) {
+	sendInBackground(ctx, func(ctx context.Context) error {
+		return svc.ForgotPassword(ctx, email)
+	})
+}
+
+// Does nothing if there is no user with the email.
+func (svc *Account) ForgotPassword(ctx context.Context, email string) error {
+	fail := func(err error) error {
+		return fmt.Errorf("send password reset email: %w", err)
+	}
+
+	userID, err := svc.userRepository.GetIDByEmail(ctx, email)
+	if errors.Is(err, repository.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fail(err)
+	}
+
+	token, err := svc.createToken(ctx, userID, model.AccountTokenResetPassword, email, config.PasswordResetTokenTTL)
+	if err != nil {
+		return fail(err)
+	}
+	err = svc.mailer.Send(ctx, mailer.Message{
+		To:      email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf(
+			"To set a new password, open the following link:\n\n%s\n\n"+
+				"The link expires in %s. If you did not request a password reset, ignore this email.\n",
+			svc.link("/reset-password", token), formatHours(config.PasswordResetTokenTTL),
+		),
+	})
+	if err != nil {
+		return fail(err)
+	}
+	return nil
+}
+
+// All sessions of the user are logged out, and their access tokens are rejected.
+func (svc *Account) ResetPassword(ctx context.Context, token, password string) error {
+	fail := func(err error) error {
+		return fmt.Errorf("reset password: %w", err)
+	}
+
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return fail(err)
+	}
+	err = svc.accountTokenRepository.ResetPassword(ctx, hashOpaqueToken(token), string(passwordHash))
+	if errors.Is(err, repository.ErrRecordNotFound) || errors.Is(err, repository.ErrTokenExpired) {
+		return ErrInvalidAccountToken
+	}
+	if err != nil {
+		return fail(err)
+	}
+	return nil
+}
+
+func (svc *Account) checkPassword(ctx context.Context, userID uuid.UUID, password string) error {
+	passwordHash, err := svc.userRepository.GetPasswordHash(ctx, userID)
+	if errors.Is(err, repository.ErrRecordNotFound) {
+		return fmt.Errorf("%w: %s", ErrUserNotFound, userID)
+	}
+	if err != nil {
+		return err
+	}
+	// Users who signed up through social login can set a password with a password reset.
+	if passwordHash == nil {
+		return ErrWrongPassword
+	}
+	err = bcrypt.CompareHashAndPassword(passwordHash, []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return ErrWrongPassword
+	}
+	return err
+}
+
+// All sessions of the user are logged out, including the current one, which gets new tokens in exchange. Access tokens
+// issued before the change are rejected.
+func (svc *Account) ChangePassword(
+	ctx context.Context, userID uuid.UUID, currentPassword, newPassword string,
+) (model.TokenPair, error) {
+	fail := func(err error) (model.TokenPair, error) {
+		return model.TokenPair{}, fmt.Errorf("change password: %w", err)
+	}
+
+	err := svc.checkPassword(ctx, userID, currentPassword)
+	if errors.Is(err, ErrUserNotFound) || errors.Is(err, ErrWrongPassword) {
+		return model.TokenPair{}, err
+	}
+	if err != nil {
+		return fail(err)
+	}
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return fail(err)
+	}
+	if err = svc.userRepository.UpdatePassword(ctx, userID, string(passwordHash)); err != nil {
+		return fail(err)
+	}
+	tokens, err := svc.tokenService.Issue(ctx, userID)
+	if err != nil {
+		return fail(err)
+	}
+	return tokens, nil
+}
+
+// The password is required, so that a stolen session cannot take over the account through a password reset. The new
+// email has to be verified again.
+func (svc *Account) ChangeEmail(ctx context.Context, userID uuid.UUID, password, email string) error {
+	fail := func(err error) error {
+		return fmt.Errorf("change email: %w", err)
+	}
+
+	err := svc.checkPassword(ctx, userID, password)
+	if errors.Is(err, ErrUserNotFound) || errors.Is(err, ErrWrongPassword) {
+		return err
+	}
+	if err != nil {
+		return fail(err)
+	}
+	err = svc.userRepository.UpdateEmail(ctx, userID, email)
+	if errors.Is(err, repository.ErrEmailExists) {
+		return ErrEmailExists
+	}
+	if err != nil {
+		return fail(err)
+	}
+	svc.SendVerificationEmailInBackground(ctx, userID)
+	return nil
+}
+
+func (svc *Account) ChangeHandle(ctx context.Context, userID uuid.UUID, handle string) error {
+	err := svc.userRepository.UpdateHandle(ctx, userID, handle)
+	if errors.Is(err, repository.ErrHandleExists) {
+		return ErrHandleExists
+	}
+	if errors.Is(err, repository.ErrRecordNotFound) {
+		return fmt.Errorf("%w: %s", ErrUserNotFound, userID)
+	}
+	if err != nil {
+		return fmt.Errorf("change handle: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package service_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"regexp"
+	"smapp/user/mailer"
+	"smapp/user/repository"
+	"smapp/user/service"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/matryer/is"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestForgotPassword(t *testing.T) {
+	is := is.New(t)
+
+	db, mock, err := sqlmock.New()
+	is.NoErr(err)
+	defer db.Close()
+
+	var mails bytes.Buffer
+	accountService := service.NewAccount(
+		repository.NewUser(db),
+		repository.NewAccountToken(db),
+		nil,
+		mailer.NewLog(&mails, "no-reply@example.com"),
+		"https://example.com",
+	)
+
+	// Unknown emails are ignored without an error.
+	mock.ExpectQuery("SELECT id FROM users WHERE email").
+		WithArgs("unknown@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	is.NoErr(accountService.ForgotPassword(context.Background(), "unknown@example.com"))
+	is.Equal(mails.Len(), 0)
+
+	userID := uuid.New()
+	mock.ExpectQuery("SELECT id FROM users WHERE email").
+		WithArgs("user@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(userID[:]))
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM account_tokens").
+		WithArgs(userID[:], "reset_password").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO account_tokens").
+		WithArgs(sqlmock.AnyArg(), userID[:], "reset_password", "user@example.com", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	is.NoErr(accountService.ForgotPassword(context.Background(), "user@example.com"))
+	is.NoErr(mock.ExpectationsWereMet())
+
+	mail := mails.String()
+	is.True(strings.Contains(mail, "To: user@example.com\r\n"))
+	is.True(regexp.MustCompile(`https://example\.com/reset-password\?token=[\w-]{43}\r\n`).MatchString(mail))
+}
+
+func TestChangePassword(t *testing.T) {
+	is := is.New(t)
+
+	db, mock, err := sqlmock.New()
+	is.NoErr(err)
+	defer db.Close()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	is.NoErr(err)
+
+	accountService := service.NewAccount(
+		repository.NewUser(db),
+		nil,
+		service.NewToken(repository.NewToken(db), service.NewJWT([]*rsa.PrivateKey{key}, time.Minute), time.Hour),
+		nil,
+		"https://example.com",
+	)
+
+	userID := uuid.New()
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte("current"), bcrypt.MinCost)
+	is.NoErr(err)
+
+	// A wrong current password changes nothing.
+	mock.ExpectQuery("SELECT password_hash FROM users").
+		WithArgs(userID[:]).
+		WillReturnRows(sqlmock.NewRows([]string{"password_hash"}).AddRow(passwordHash))
+	_, err = accountService.ChangePassword(context.Background(), userID, "wrong", "new password")
+	is.True(errors.Is(err, service.ErrWrongPassword))
+
+	// Access tokens issued before the change are rejected through password_changed_at, and refresh tokens are deleted.
+	mock.ExpectQuery("SELECT password_hash FROM users").
+		WithArgs(userID[:]).
+		WillReturnRows(sqlmock.NewRows([]string{"password_hash"}).AddRow(passwordHash))
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE users SET password_hash = \\?, password_changed_at = NOW\\(\\)").
+		WithArgs(sqlmock.AnyArg(), userID[:]).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM refresh_tokens WHERE user_id").
+		WithArgs(userID[:]).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+	mock.ExpectExec("DELETE FROM refresh_tokens WHERE user_id = \\? AND expires_at").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO refresh_tokens").WillReturnResult(sqlmock.NewResult(0, 1))
+	tokens, err := accountService.ChangePassword(context.Background(), userID, "current", "new password")
+	is.NoErr(err)
+	is.True(tokens.AccessToken != "" && tokens.RefreshToken != "")
+	is.NoErr(mock.ExpectationsWereMet())
+}
+
+func TestResetPassword(t *testing.T) {
+	is := is.New(t)
+
+	db, mock, err := sqlmock.New()
+	is.NoErr(err)
+	defer db.Close()
+
+	accountService := service.NewAccount(nil, repository.NewAccountToken(db), nil, nil, "https://example.com")
+
+	// An expired token does not change the password.
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM account_tokens").
+		WithArgs(sqlmock.AnyArg(), "reset_password").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "email", "expired"}).AddRow(uuid.New().String(), "a@b.c", true))
+	mock.ExpectRollback()
+	err = accountService.ResetPassword(context.Background(), "token", "new password")
+	is.True(errors.Is(err, service.ErrInvalidAccountToken))
+
+	userID := uuid.New()
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM account_tokens").
+		WithArgs(sqlmock.AnyArg(), "reset_password").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "email", "expired"}).AddRow(userID[:], "a@b.c", false))
+	mock.ExpectExec("DELETE FROM account_tokens").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE users SET password_hash = \\?, password_changed_at = NOW\\(\\)").
+		WithArgs(sqlmock.AnyArg(), userID[:]).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM refresh_tokens WHERE user_id").
+		WithArgs(userID[:]).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	is.NoErr(accountService.ResetPassword(context.Background(), "token", "new password"))
+	is.NoErr(mock.ExpectationsWereMet())
+}
+
+func TestChangeHandle(t *testing.T) {
+	is := is.New(t)
+
+	db, mock, err := sqlmock.New()
+	is.NoErr(err)
+	defer db.Close()
+
+	accountService := service.NewAccount(repository.NewUser(db), nil, nil, nil, "https://example.com")
+	userID := uuid.New()
+
+	// No rows are affected if the handle is unchanged, which is not an error.
+	mock.ExpectExec("UPDATE users SET handle").
+		WithArgs("same", userID[:]).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT EXISTS").
+		WithArgs(userID[:]).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	is.NoErr(accountService.ChangeHandle(context.Background(), userID, "same"))
+
+	mock.ExpectExec("UPDATE users SET handle").
+		WithArgs("new", userID[:]).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT EXISTS").
+		WithArgs(userID[:]).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	err = accountService.ChangeHandle(context.Background(), userID, "new")
+	is.True(errors.Is(err, service.ErrUserNotFound))
+	is.NoErr(mock.ExpectationsWereMet())
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"smapp/user/repository"
+
+	"github.com/google/uuid"
+)
+
+type Block struct {
+	blockRepository *repository.Block
+}
+
+func NewBlock(blockRepository *repository.Block) *Block {
+	return &Block{
+		blockRepository: blockRepository,
+	}
+}
+
+var (
+	ErrSelfBlock     = errors.New("cannot block self")
+	ErrBlockExists   = errors.New("block already exists")
+	ErrBlockNotFound = errors.New("block not found")
+)
+
+// Follows between the users are removed in both directions. Their posts are removed from the timelines by the
+// Timeline service, which handles the UserUnfollowed events of the removed follows.
+func (svc *Block) Create(ctx context.Context, blockerID, blockedID uuid.UUID) error {
+	fail := func(err error) error {
+		return fmt.Errorf("create block: %w", err)
+	}
+	if blockerID == blockedID {
+		return ErrSelfBlock
+	}
+	err := svc.blockRepository.Create(ctx, blockerID, blockedID)
+	if errors.Is(err, repository.ErrUserIDNotFound) {
+		return fmt.Errorf("%w: %s", ErrUserNotFound, blockedID)
+	}
+	if errors.Is(err, repository.ErrRecordExists) {
+		return ErrBlockExists
+	}
+	if err != nil {
+		return fail(err)
+	}
+	return nil
+}
+
+func (svc *Block) Delete(ctx context.Context, blockerID, blockedID uuid.UUID) error {
+	err := svc.blockRepository.Delete(ctx, blockerID, blockedID)
+	if errors.Is(err, repository.ErrRecordNotFound) {
+		return ErrBlockNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("delete block: %w", err)
+	}
+	return nil
+}
+
+// Returns the users blocked by the user and the users who have blocked the user.
+func (svc *Block) GetBlockedIDs(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error) {
+	blocked, err := svc.blockRepository.GetBlockedIDs(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("get blocked ids: %w", err)
+	}
+	return blocked, nil
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"smapp/user/config"
+	"smapp/user/model"
+	"smapp/user/repository"
+
+	"github.com/google/uuid"
+)
+
+type CloseFriend struct {
+	closeFriendRepository *repository.CloseFriend
+}
+
+func NewCloseFriend(closeFriendRepository *repository.CloseFriend) *CloseFriend {
+	return &CloseFriend{closeFriendRepository: closeFriendRepository}
+}
+
+var (
+	ErrSelfCloseFriend     = errors.New("cannot add self to close friends")
+	ErrCloseFriendExists   = errors.New("close friend already exists")
+	ErrCloseFriendNotFound = errors.New("close friend not found")
+)
+
+// The list is private to the user. Close friends do not have to follow the user to see posts shared with them.
+func (svc *CloseFriend) Create(ctx context.Context, userID, friendID uuid.UUID) error {
+	if userID == friendID {
+		return ErrSelfCloseFriend
+	}
+	err := svc.closeFriendRepository.Create(ctx, userID, friendID)
+	if errors.Is(err, repository.ErrUserIDNotFound) {
+		return fmt.Errorf("%w: %s", ErrUserNotFound, friendID)
+	}
+	if errors.Is(err, repository.ErrRecordExists) {
+		return ErrCloseFriendExists
+	}
+	if err != nil {
+		return fmt.Errorf("create close friend: %w", err)
+	}
+	return nil
+}
+
+func (svc *CloseFriend) Delete(ctx context.Context, userID, friendID uuid.UUID) error {
+	err := svc.closeFriendRepository.Delete(ctx, userID, friendID)
+	if errors.Is(err, repository.ErrRecordNotFound) {
+		return ErrCloseFriendNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("delete close friend: %w", err)
+	}
+	return nil
+}
+
+func (svc *CloseFriend) Get(
+	ctx context.Context, userID uuid.UUID, cursor model.Cursor, limit int,
+) ([]model.CloseFriend, *model.Cursor, error) {
+	if limit < 1 || limit > config.FollowsPaginationLimit {
+		return nil, nil, fmt.Errorf(
+			"%w, should be in range: [1, %d]",
+			ErrFollowsPaginationLimitInvalid, config.FollowsPaginationLimit,
+		)
+	}
+
+	friends, nextCursor, err := svc.closeFriendRepository.GetPaginated(ctx, userID, cursor, limit)
+	if err != nil {
+		return nil, nil, fmt.Errorf("get close friends: %w", err)
+	}
+	return friends, nextCursor, nil
+}
+
+// Returns the candidates that have the user on their close friends list.
+func (svc *CloseFriend) FilterListedBy(ctx context.Context, userID uuid.UUID, candidateIDs []uuid.UUID) ([]uuid.UUID, error) {
+	if len(candidateIDs) == 0 {
+		return []uuid.UUID{}, nil
+	}
+	listedBy, err := svc.closeFriendRepository.FilterListedBy(ctx, userID, candidateIDs)
+	if err != nil {
+		return nil, fmt.Errorf("filter close friend lists: %w", err)
+	}
+	return listedBy, nil
+}
@@ -4,44 +4,104 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"smapp/user/config"
+	"smapp/user/model"
 	"smapp/user/repository"
 
 	"github.com/google/uuid"
 )
 
 type Follow struct {
-	followRepository *repository.Follow
+	followRepository        *repository.Follow
+	followRequestRepository *repository.FollowRequest
+	userRepository          *repository.User
+	blockRepository         *repository.Block
 }
 
-func NewFollow(followRepository *repository.Follow) *Follow {
+func NewFollow(
+	followRepository *repository.Follow,
+	followRequestRepository *repository.FollowRequest,
+	userRepository *repository.User,
+	blockRepository *repository.Block,
+) *Follow {
 	return &Follow{
-		followRepository: followRepository,
+		followRepository:        followRepository,
+		followRequestRepository: followRequestRepository,
+		userRepository:          userRepository,
+		blockRepository:         blockRepository,
 	}
 }
 
 var (
-	ErrSelfFollow   = errors.New("cannot follow self")
-	ErrFollowExists = errors.New("follow already exists")
+	ErrSelfFollow     = errors.New("cannot follow self")
+	ErrFollowExists   = errors.New("follow already exists")
+	ErrFollowNotFound = errors.New("follow not found")
+	ErrFollowBlocked  = errors.New("follow blocked")
 )
 
-func (svc *Follow) Create(ctx context.Context, followerID, followedID uuid.UUID) error {
-	fail := func(err error) error {
-		return fmt.Errorf("create follow: %w", err)
+// Following a private user creates a follow request instead, in which case requested is true.
+func (svc *Follow) Create(ctx context.Context, followerID, followedID uuid.UUID) (requested bool, err error) {
+	fail := func(err error) (bool, error) {
+		return false, fmt.Errorf("create follow: %w", err)
 	}
 	if followerID == followedID {
-		return ErrSelfFollow
+		return false, ErrSelfFollow
+	}
+	// Either user can have blocked the other.
+	blocked, err := svc.blockRepository.Exists(ctx, followerID, followedID)
+	if err != nil {
+		return fail(err)
 	}
-	err := svc.followRepository.Create(ctx, followerID, followedID)
+	if blocked {
+		return false, ErrFollowBlocked
+	}
+	isPrivate, err := svc.userRepository.IsPrivate(ctx, followedID)
+	if errors.Is(err, repository.ErrRecordNotFound) {
+		return false, fmt.Errorf("%w: %s", ErrUserNotFound, followedID)
+	}
+	if err != nil {
+		return fail(err)
+	}
+	if isPrivate {
+		return svc.createRequest(ctx, followerID, followedID)
+	}
+
+	err = svc.followRepository.Create(ctx, followerID, followedID)
 	if errors.Is(err, repository.ErrUserIDNotFound) {
-		return fmt.Errorf("%w: %s", ErrUserNotFound, followedID)
+		return false, fmt.Errorf("%w: %s", ErrUserNotFound, followedID)
 	}
 	if errors.Is(err, repository.ErrRecordExists) {
-		return ErrFollowExists
+		return false, ErrFollowExists
 	}
 	if err != nil {
 		return fail(err)
 	}
-	return nil
+	return false, nil
+}
+
+func (svc *Follow) createRequest(ctx context.Context, requesterID, targetID uuid.UUID) (bool, error) {
+	fail := func(err error) (bool, error) {
+		return false, fmt.Errorf("create follow request: %w", err)
+	}
+
+	followed, err := svc.followRepository.FilterFollowed(ctx, requesterID, []uuid.UUID{targetID})
+	if err != nil {
+		return fail(err)
+	}
+	if len(followed) > 0 {
+		return false, ErrFollowExists
+	}
+	err = svc.followRequestRepository.Create(ctx, requesterID, targetID)
+	if errors.Is(err, repository.ErrUserIDNotFound) {
+		return false, fmt.Errorf("%w: %s", ErrUserNotFound, targetID)
+	}
+	if errors.Is(err, repository.ErrRecordExists) {
+		return false, ErrFollowRequestExists
+	}
+	if err != nil {
+		return fail(err)
+	}
+	return true, nil
 }
 
 func (svc *Follow) GetFollowed(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error) {
@@ -54,3 +114,165 @@ func (svc *Follow) GetFollowed(ctx context.Context, userID uuid.UUID) ([]uuid.UU
 	}
 	return followed, nil
 }
+
+func (svc *Follow) Delete(ctx context.Context, followerID, followedID uuid.UUID) error {
+	fail := func(err error) error {
+		return fmt.Errorf("delete follow: %w", err)
+	}
+	err := svc.followRepository.Delete(ctx, followerID, followedID)
+	if errors.Is(err, repository.ErrRecordNotFound) {
+		// Unfollowing also cancels a pending follow request.
+		err = svc.followRequestRepository.Delete(ctx, followerID, followedID)
+		if errors.Is(err, repository.ErrRecordNotFound) {
+			return ErrFollowNotFound
+		}
+		if err != nil {
+			return fail(err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fail(err)
+	}
+	return nil
+}
+
+func (svc *Follow) GetFollowerIDs(ctx context.Context, userID uuid.UUID, limit int) ([]uuid.UUID, bool, error) {
+	followers, limitExceeded, err := svc.followRepository.GetFollowerIDs(ctx, userID, limit)
+	if err != nil {
+		return nil, false, fmt.Errorf("get follower ids: %w", err)
+	}
+	return followers, limitExceeded, nil
+}
+
+func (svc *Follow) FilterFollowed(ctx context.Context, userID uuid.UUID, candidateIDs []uuid.UUID) ([]uuid.UUID, error) {
+	fail := func(err error) ([]uuid.UUID, error) {
+		return nil, fmt.Errorf("filter followed: %w", err)
+	}
+	if len(candidateIDs) == 0 {
+		return []uuid.UUID{}, nil
+	}
+	followed, err := svc.followRepository.FilterFollowed(ctx, userID, candidateIDs)
+	if err != nil {
+		return fail(err)
+	}
+	return followed, nil
+}
+
+// Returns the candidates whose posts and comments the viewer cannot see: private users the viewer does not follow.
+// uuid.Nil stands for an anonymous viewer.
+func (svc *Follow) FilterPrivateNotFollowed(
+	ctx context.Context, viewerID uuid.UUID, candidateIDs []uuid.UUID,
+) ([]uuid.UUID, error) {
+	if len(candidateIDs) == 0 {
+		return []uuid.UUID{}, nil
+	}
+	private, err := svc.userRepository.FilterPrivateNotFollowed(ctx, viewerID, candidateIDs)
+	if err != nil {
+		return nil, fmt.Errorf("filter private not followed: %w", err)
+	}
+	return private, nil
+}
+
+var ErrFollowsPaginationLimitInvalid = errors.New("follows pagination limit invalid")
+
+func (svc *Follow) GetFollowers(
+	ctx context.Context, userID uuid.UUID, cursor model.Cursor, limit int,
+) ([]model.FollowEntry, *model.Cursor, error) {
+	fail := func(err error) ([]model.FollowEntry, *model.Cursor, error) {
+		return nil, nil, fmt.Errorf("get followers: %w", err)
+	}
+
+	if limit < 1 || limit > config.FollowsPaginationLimit {
+		return nil, nil, fmt.Errorf(
+			"%w, should be in range: [1, %d]",
+			ErrFollowsPaginationLimitInvalid, config.FollowsPaginationLimit,
+		)
+	}
+
+	if err := svc.userRepository.CheckExists(ctx, userID); err != nil {
+		if errors.Is(err, repository.ErrRecordNotFound) {
+			return nil, nil, fmt.Errorf("%w: %s", ErrUserNotFound, userID)
+		}
+		return fail(err)
+	}
+
+	followers, nextCursor, err := svc.followRepository.GetFollowersPaginated(ctx, userID, cursor, limit)
+	if err != nil {
+		return fail(err)
+	}
+	return followers, nextCursor, nil
+}
+
+func (svc *Follow) GetFollowing(
+	ctx context.Context, userID uuid.UUID, cursor model.Cursor, limit int,
+) ([]model.FollowEntry, *model.Cursor, error) {
+	fail := func(err error) ([]model.FollowEntry, *model.Cursor, error) {
+		return nil, nil, fmt.Errorf("get following: %w", err)
+	}
+
+	if limit < 1 || limit > config.FollowsPaginationLimit {
+		return nil, nil, fmt.Errorf(
+			"%w, should be in range: [1, %d]",
+			ErrFollowsPaginationLimitInvalid, config.FollowsPaginationLimit,
+		)
+	}
+
+	if err := svc.userRepository.CheckExists(ctx, userID); err != nil {
+		if errors.Is(err, repository.ErrRecordNotFound) {
+			return nil, nil, fmt.Errorf("%w: %s", ErrUserNotFound, userID)
+		}
+		return fail(err)
+	}
+
+	following, nextCursor, err := svc.followRepository.GetFollowingPaginated(ctx, userID, cursor, limit)
+	if err != nil {
+		return fail(err)
+	}
+	return following, nextCursor, nil
+}
+
+var (
+	ErrFollowRequestExists   = errors.New("follow request already exists")
+	ErrFollowRequestNotFound = errors.New("follow request not found")
+)
+
+// Returns the pending requests to follow the user, newest first.
+func (svc *Follow) GetRequests(
+	ctx context.Context, userID uuid.UUID, cursor model.Cursor, limit int,
+) ([]model.FollowRequest, *model.Cursor, error) {
+	if limit < 1 || limit > config.FollowsPaginationLimit {
+		return nil, nil, fmt.Errorf(
+			"%w, should be in range: [1, %d]",
+			ErrFollowsPaginationLimitInvalid, config.FollowsPaginationLimit,
+		)
+	}
+
+	requests, nextCursor, err := svc.followRequestRepository.GetPaginated(ctx, userID, cursor, limit)
+	if err != nil {
+		return nil, nil, fmt.Errorf("get follow requests: %w", err)
+	}
+	return requests, nextCursor, nil
+}
+
+func (svc *Follow) ApproveRequest(ctx context.Context, userID, requesterID uuid.UUID) error {
+	err := svc.followRequestRepository.Approve(ctx, requesterID, userID)
+	if errors.Is(err, repository.ErrRecordNotFound) {
+		return ErrFollowRequestNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("approve follow request: %w", err)
+	}
+	return nil
+}
+
+func (svc *Follow) RejectRequest(ctx context.Context, userID, requesterID uuid.UUID) error {
+	err := svc.followRequestRepository.Delete(ctx, requesterID, userID)
+	if errors.Is(err, repository.ErrRecordNotFound) {
+		return ErrFollowRequestNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("reject follow request: %w", err)
+	}
+	return nil
+}
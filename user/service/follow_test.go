@@ -0,0 +1,288 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"smapp/user/config"
+	"smapp/user/model"
+	"smapp/user/repository"
+	"smapp/user/service"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/matryer/is"
+)
+
+func TestFollowPrivateUserCreatesRequest(t *testing.T) {
+	is := is.New(t)
+
+	db, mock, err := sqlmock.New()
+	is.NoErr(err)
+	defer db.Close()
+
+	// No follow is created, so nothing is written to the outbox until the request is approved.
+	followService := service.NewFollow(
+		repository.NewFollow(db), repository.NewFollowRequest(db), repository.NewUser(db), repository.NewBlock(db),
+	)
+
+	followerID, followedID := uuid.New(), uuid.New()
+	mock.ExpectQuery("FROM blocks").WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectQuery("SELECT is_private FROM users").
+		WithArgs(followedID[:]).
+		WillReturnRows(sqlmock.NewRows([]string{"is_private"}).AddRow(true))
+	mock.ExpectQuery("FROM follows").
+		WithArgs(followerID[:], followedID[:]).
+		WillReturnRows(sqlmock.NewRows([]string{"followed_id"}))
+	mock.ExpectExec("INSERT INTO follow_requests").
+		WithArgs(followerID[:], followedID[:]).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	requested, err := followService.Create(context.Background(), followerID, followedID)
+	is.NoErr(err)
+	is.True(requested)
+	is.NoErr(mock.ExpectationsWereMet())
+}
+
+func TestFollowPrivateUserAlreadyFollowed(t *testing.T) {
+	is := is.New(t)
+
+	db, mock, err := sqlmock.New()
+	is.NoErr(err)
+	defer db.Close()
+
+	followService := service.NewFollow(
+		repository.NewFollow(db), repository.NewFollowRequest(db), repository.NewUser(db), repository.NewBlock(db),
+	)
+
+	// Followers from before the user became private do not need a request.
+	followerID, followedID := uuid.New(), uuid.New()
+	mock.ExpectQuery("FROM blocks").WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectQuery("SELECT is_private FROM users").
+		WillReturnRows(sqlmock.NewRows([]string{"is_private"}).AddRow(true))
+	mock.ExpectQuery("FROM follows").
+		WillReturnRows(sqlmock.NewRows([]string{"followed_id"}).AddRow(followedID[:]))
+
+	_, err = followService.Create(context.Background(), followerID, followedID)
+	is.Equal(err, service.ErrFollowExists)
+	is.NoErr(mock.ExpectationsWereMet())
+}
+
+func TestUnfollowWritesEvent(t *testing.T) {
+	is := is.New(t)
+
+	db, mock, err := sqlmock.New()
+	is.NoErr(err)
+	defer db.Close()
+
+	followService := service.NewFollow(
+		repository.NewFollow(db), repository.NewFollowRequest(db), repository.NewUser(db), repository.NewBlock(db),
+	)
+
+	// The event removes the posts of the followed user from the timeline, and is only written if the follow existed.
+	followerID, followedID := uuid.New(), uuid.New()
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM follows").
+		WithArgs(followerID[:], followedID[:]).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO outbox").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	is.NoErr(followService.Delete(context.Background(), followerID, followedID))
+	is.NoErr(mock.ExpectationsWereMet())
+}
+
+func TestUnfollowCancelsRequest(t *testing.T) {
+	is := is.New(t)
+
+	db, mock, err := sqlmock.New()
+	is.NoErr(err)
+	defer db.Close()
+
+	followService := service.NewFollow(
+		repository.NewFollow(db), repository.NewFollowRequest(db), repository.NewUser(db), repository.NewBlock(db),
+	)
+
+	followerID, followedID := uuid.New(), uuid.New()
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM follows").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+	mock.ExpectExec("DELETE FROM follow_requests").
+		WithArgs(followerID[:], followedID[:]).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	is.NoErr(followService.Delete(context.Background(), followerID, followedID))
+	is.NoErr(mock.ExpectationsWereMet())
+}
+
+func TestUnfollowNotFollowed(t *testing.T) {
+	is := is.New(t)
+
+	db, mock, err := sqlmock.New()
+	is.NoErr(err)
+	defer db.Close()
+
+	followService := service.NewFollow(
+		repository.NewFollow(db), repository.NewFollowRequest(db), repository.NewUser(db), repository.NewBlock(db),
+	)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM follows").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+	mock.ExpectExec("DELETE FROM follow_requests").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err = followService.Delete(context.Background(), uuid.New(), uuid.New())
+	is.Equal(err, service.ErrFollowNotFound)
+	is.NoErr(mock.ExpectationsWereMet())
+}
+
+var followEntryColumns = []string{"id", "name", "handle", "image_s3_bucket", "image_s3_key", "created_at"}
+
+func TestGetFollowersPagination(t *testing.T) {
+	userID := uuid.New()
+	cursor := model.Cursor{LastLoadedTimestamp: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), LastLoadedID: uuid.Nil}
+	followedAt := cursor.LastLoadedTimestamp.Add(-time.Hour)
+	followerIDs := []uuid.UUID{uuid.New(), uuid.New(), uuid.New()}
+
+	tests := []struct {
+		name               string
+		rows               int
+		expectedCount      int
+		expectedNextCursor *model.Cursor
+	}{
+		{
+			name:          "no followers",
+			rows:          0,
+			expectedCount: 0,
+		},
+		{
+			// Exactly limit rows are left, so there is no next page.
+			name:          "last page is full",
+			rows:          2,
+			expectedCount: 2,
+		},
+		{
+			// The followers have the same follow time, so the next page continues after the ID of the last one.
+			name:          "more pages",
+			rows:          3,
+			expectedCount: 2,
+			expectedNextCursor: &model.Cursor{
+				LastLoadedTimestamp: followedAt,
+				LastLoadedID:        followerIDs[1],
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			is := is.New(t)
+			db, mock, err := sqlmock.New()
+			is.NoErr(err)
+			defer db.Close()
+
+			followService := service.NewFollow(
+				repository.NewFollow(db), repository.NewFollowRequest(db), repository.NewUser(db), repository.NewBlock(db),
+			)
+
+			mock.ExpectQuery("SELECT EXISTS\\(SELECT 1 FROM users WHERE id = \\?\\)").
+				WithArgs(userID[:]).
+				WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+			rows := sqlmock.NewRows(followEntryColumns)
+			for _, followerID := range followerIDs[:tt.rows] {
+				rows.AddRow(followerID[:], "Follower", "follower", nil, nil, followedAt)
+			}
+			// One more row than the limit is loaded to find out whether there is a next page.
+			mock.ExpectQuery("FROM follows f JOIN users u ON u.id = f.follower_id WHERE f.followed_id = \\?").
+				WithArgs(userID[:], cursor.LastLoadedTimestamp, cursor.LastLoadedTimestamp, cursor.LastLoadedID[:], 3).
+				WillReturnRows(rows)
+
+			followers, nextCursor, err := followService.GetFollowers(context.Background(), userID, cursor, 2)
+			is.NoErr(err)
+			is.Equal(len(followers), tt.expectedCount)
+			is.Equal(nextCursor, tt.expectedNextCursor)
+			for i, follower := range followers {
+				is.Equal(follower.ID, followerIDs[i])
+				is.Equal(follower.Image, nil)
+			}
+			is.NoErr(mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestGetFollowingResumesFromCursor(t *testing.T) {
+	is := is.New(t)
+	db, mock, err := sqlmock.New()
+	is.NoErr(err)
+	defer db.Close()
+
+	followService := service.NewFollow(
+		repository.NewFollow(db), repository.NewFollowRequest(db), repository.NewUser(db), repository.NewBlock(db),
+	)
+
+	// The cursor of the previous page is passed as is: follows made at the same time come after its ID.
+	userID := uuid.New()
+	cursor := model.Cursor{LastLoadedTimestamp: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), LastLoadedID: uuid.New()}
+	followedID := uuid.New()
+	mock.ExpectQuery("SELECT EXISTS").WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery("FROM follows f JOIN users u ON u.id = f.followed_id WHERE f.follower_id = \\?").
+		WithArgs(userID[:], cursor.LastLoadedTimestamp, cursor.LastLoadedTimestamp, cursor.LastLoadedID[:], 11).
+		WillReturnRows(
+			sqlmock.NewRows(followEntryColumns).
+				AddRow(followedID[:], "Followed", "followed", "bucket", "images/profile/key", cursor.LastLoadedTimestamp),
+		)
+
+	following, nextCursor, err := followService.GetFollowing(context.Background(), userID, cursor, 10)
+	is.NoErr(err)
+	is.Equal(nextCursor, nil)
+	is.Equal(len(following), 1)
+	is.Equal(following[0].ID, followedID)
+	is.Equal(*following[0].Image, model.ImageLocation{Bucket: "bucket", Key: "images/profile/key"})
+	is.NoErr(mock.ExpectationsWereMet())
+}
+
+func TestGetFollowersInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		limit int
+		err   error
+	}{
+		{
+			name:  "limit zero",
+			limit: 0,
+			err:   service.ErrFollowsPaginationLimitInvalid,
+		},
+		{
+			name:  "limit too large",
+			limit: config.FollowsPaginationLimit + 1,
+			err:   service.ErrFollowsPaginationLimitInvalid,
+		},
+		{
+			name:  "user not found",
+			limit: config.FollowsPaginationLimit,
+			err:   service.ErrUserNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			is := is.New(t)
+			db, mock, err := sqlmock.New()
+			is.NoErr(err)
+			defer db.Close()
+
+			followService := service.NewFollow(
+				repository.NewFollow(db), repository.NewFollowRequest(db), repository.NewUser(db), repository.NewBlock(db),
+			)
+
+			// The limit is checked before any query.
+			if tt.err == service.ErrUserNotFound {
+				mock.ExpectQuery("SELECT EXISTS").WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+			}
+
+			_, _, err = followService.GetFollowers(context.Background(), uuid.New(), model.Cursor{}, tt.limit)
+			is.True(errors.Is(err, tt.err))
+			is.NoErr(mock.ExpectationsWereMet())
+		})
+	}
+}
@@ -2,29 +2,143 @@ package service
 
 import (
 	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"smapp/user/model"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
+type signingKey struct {
+	id  string
+	key *rsa.PrivateKey
+}
+
 type JWT struct {
-	privateKey *rsa.PrivateKey
-	ttl        time.Duration
+	// The first key signs new tokens. The others are only published and accepted for verification, which allows
+	// rotating keys without invalidating issued tokens.
+	keys []signingKey
+	ttl  time.Duration
 }
 
-func NewJWT(privateKey *rsa.PrivateKey, ttl time.Duration) *JWT {
+// The keys must not be empty. Key IDs are derived from the public keys, so they stay the same across restarts and
+// replicas without being configured.
+func NewJWT(privateKeys []*rsa.PrivateKey, ttl time.Duration) *JWT {
+	keys := make([]signingKey, len(privateKeys))
+	for i, key := range privateKeys {
+		keys[i] = signingKey{id: keyID(&key.PublicKey), key: key}
+	}
 	return &JWT{
-		privateKey: privateKey,
-		ttl:        ttl,
+		keys: keys,
+		ttl:  ttl,
 	}
 }
 
+func encodeBigInt(n *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(n.Bytes())
+}
+
+// JWK thumbprint as defined in RFC 7638.
+func keyID(key *rsa.PublicKey) string {
+	e := encodeBigInt(big.NewInt(int64(key.E)))
+	thumbprint := sha256.Sum256([]byte(fmt.Sprintf(`{"e":"%s","kty":"RSA","n":"%s"}`, e, encodeBigInt(key.N))))
+	return base64.RawURLEncoding.EncodeToString(thumbprint[:])
+}
+
+// Each token gets a unique ID (jti), so that it can be revoked before it expires.
 func (svc *JWT) GenerateJWT(userID uuid.UUID) (string, error) {
+	jti, err := uuid.NewRandom()
+	if err != nil {
+		return "", err
+	}
 	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
 		"sub": userID.String(),
+		"jti": jti.String(),
 		"iat": time.Now().Unix(),
 		"exp": time.Now().Add(svc.ttl).Unix(),
 	})
-	return token.SignedString(svc.privateKey)
+	token.Header["kid"] = svc.keys[0].id
+	return token.SignedString(svc.keys[0].key)
+}
+
+type AccessTokenClaims struct {
+	UserID    uuid.UUID
+	ID        uuid.UUID
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
+
+var ErrInvalidAccessToken = errors.New("invalid access token")
+
+// Tokens issued before kid headers were added are checked against all keys, so that they keep working until they
+// expire.
+func (svc *JWT) getPublicKey(token *jwt.Token) (interface{}, error) {
+	kid, ok := token.Header["kid"].(string)
+	if !ok {
+		keySet := jwt.VerificationKeySet{Keys: make([]jwt.VerificationKey, len(svc.keys))}
+		for i, key := range svc.keys {
+			keySet.Keys[i] = &key.key.PublicKey
+		}
+		return keySet, nil
+	}
+	for _, key := range svc.keys {
+		if key.id == kid {
+			return &key.key.PublicKey, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown key %s", kid)
+}
+
+// Verifies the signature and the expiration of a token issued by GenerateJWT.
+func (svc *JWT) ParseJWT(tokenString string) (AccessTokenClaims, error) {
+	fail := func(err error) (AccessTokenClaims, error) {
+		return AccessTokenClaims{}, fmt.Errorf("%w: %w", ErrInvalidAccessToken, err)
+	}
+
+	var claims jwt.RegisteredClaims
+	_, err := jwt.ParseWithClaims(
+		tokenString,
+		&claims,
+		svc.getPublicKey,
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg()}),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return fail(err)
+	}
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return fail(err)
+	}
+	jti, err := uuid.Parse(claims.ID)
+	if err != nil {
+		return fail(err)
+	}
+	if claims.IssuedAt == nil {
+		return fail(errors.New("missing iat claim"))
+	}
+	return AccessTokenClaims{
+		UserID: userID, ID: jti, IssuedAt: claims.IssuedAt.Time, ExpiresAt: claims.ExpiresAt.Time,
+	}, nil
+}
+
+// Returns the public parts of all keys, including the ones that do not sign yet or anymore.
+func (svc *JWT) GetPublicKeys() []model.JWK {
+	keys := make([]model.JWK, len(svc.keys))
+	for i, key := range svc.keys {
+		keys[i] = model.JWK{
+			Kty: "RSA",
+			Use: "sig",
+			Alg: jwt.SigningMethodRS256.Alg(),
+			Kid: key.id,
+			N:   encodeBigInt(key.key.PublicKey.N),
+			E:   encodeBigInt(big.NewInt(int64(key.key.PublicKey.E))),
+		}
+	}
+	return keys
 }
@@ -0,0 +1,63 @@
+package service_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"smapp/user/service"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/matryer/is"
+)
+
+func TestJWTKeyRotation(t *testing.T) {
+	is := is.New(t)
+
+	oldKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	is.NoErr(err)
+	newKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	is.NoErr(err)
+	userID := uuid.New()
+
+	token, err := service.NewJWT([]*rsa.PrivateKey{oldKey}, time.Minute).GenerateJWT(userID)
+	is.NoErr(err)
+
+	// The new key signs, and the old one is still published.
+	jwtService := service.NewJWT([]*rsa.PrivateKey{newKey, oldKey}, time.Minute)
+	is.Equal(len(jwtService.GetPublicKeys()), 2)
+	claims, err := jwtService.ParseJWT(token)
+	is.NoErr(err)
+	is.Equal(claims.UserID, userID)
+
+	// The old key was removed.
+	_, err = service.NewJWT([]*rsa.PrivateKey{newKey}, time.Minute).ParseJWT(token)
+	is.True(err != nil)
+}
+
+func TestJWTWithoutKeyID(t *testing.T) {
+	is := is.New(t)
+
+	oldKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	is.NoErr(err)
+	newKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	is.NoErr(err)
+	userID := uuid.New()
+
+	// Tokens issued before kid headers were added.
+	token, err := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"sub": userID.String(),
+		"jti": uuid.NewString(),
+		"iat": time.Now().Unix(),
+		"exp": time.Now().Add(time.Minute).Unix(),
+	}).SignedString(oldKey)
+	is.NoErr(err)
+
+	claims, err := service.NewJWT([]*rsa.PrivateKey{newKey, oldKey}, time.Minute).ParseJWT(token)
+	is.NoErr(err)
+	is.Equal(claims.UserID, userID)
+
+	_, err = service.NewJWT([]*rsa.PrivateKey{newKey}, time.Minute).ParseJWT(token)
+	is.True(err != nil)
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"smapp/user/repository"
+
+	"github.com/google/uuid"
+)
+
+type Mute struct {
+	muteRepository *repository.Mute
+}
+
+func NewMute(muteRepository *repository.Mute) *Mute {
+	return &Mute{muteRepository: muteRepository}
+}
+
+var (
+	ErrSelfMute     = errors.New("cannot mute self")
+	ErrMuteExists   = errors.New("mute already exists")
+	ErrMuteNotFound = errors.New("mute not found")
+)
+
+// Unlike a block, a mute is not noticeable by the muted user: follows stay, and only the muter stops seeing the posts
+// and comments of the muted user.
+func (svc *Mute) Create(ctx context.Context, muterID, mutedID uuid.UUID) error {
+	if muterID == mutedID {
+		return ErrSelfMute
+	}
+	err := svc.muteRepository.Create(ctx, muterID, mutedID)
+	if errors.Is(err, repository.ErrUserIDNotFound) {
+		return fmt.Errorf("%w: %s", ErrUserNotFound, mutedID)
+	}
+	if errors.Is(err, repository.ErrRecordExists) {
+		return ErrMuteExists
+	}
+	if err != nil {
+		return fmt.Errorf("create mute: %w", err)
+	}
+	return nil
+}
+
+func (svc *Mute) Delete(ctx context.Context, muterID, mutedID uuid.UUID) error {
+	err := svc.muteRepository.Delete(ctx, muterID, mutedID)
+	if errors.Is(err, repository.ErrRecordNotFound) {
+		return ErrMuteNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("delete mute: %w", err)
+	}
+	return nil
+}
+
+func (svc *Mute) GetMutedIDs(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error) {
+	muted, err := svc.muteRepository.GetMutedIDs(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("get muted ids: %w", err)
+	}
+	return muted, nil
+}
@@ -0,0 +1,35 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	eventsPB "smapp/common/grpc/events"
+	notificationPB "smapp/common/grpc/notification"
+)
+
+// Notifier turns domain events of the user service into notifications. It is run by the outbox relay, so an event can
+// be handled more than once. The notification service ignores actors that are already recorded.
+type Notifier struct {
+	notificationClient notificationPB.NotificationClient
+}
+
+func NewNotifier(notificationClient notificationPB.NotificationClient) *Notifier {
+	return &Notifier{notificationClient: notificationClient}
+}
+
+func (svc *Notifier) HandleEvent(ctx context.Context, event *eventsPB.Event) error {
+	payload, ok := event.Payload.(*eventsPB.Event_UserFollowed)
+	if !ok {
+		return nil
+	}
+	_, err := svc.notificationClient.RecordEvent(ctx, &notificationPB.Event{
+		Type:        notificationPB.EventType_USER_FOLLOWED,
+		RecipientId: payload.UserFollowed.FollowedId,
+		ActorId:     payload.UserFollowed.FollowerId,
+	})
+	if err != nil {
+		return fmt.Errorf("record follow notification: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"smapp/user/config"
+	"smapp/user/model"
+	"smapp/user/repository"
+	"strings"
+
+	imagePB "smapp/common/grpc/image"
+
+	"github.com/google/uuid"
+)
+
+type Profile struct {
+	userRepository *repository.User
+	imageClient    imagePB.ImageClient
+}
+
+func NewProfile(userRepository *repository.User, imageClient imagePB.ImageClient) *Profile {
+	return &Profile{
+		userRepository: userRepository,
+		imageClient:    imageClient,
+	}
+}
+
+func (svc *Profile) GetProfile(ctx context.Context, id uuid.UUID) (model.User, error) {
+	fail := func(err error) (model.User, error) {
+		return model.User{}, fmt.Errorf("get profile: %w", err)
+	}
+
+	user, err := svc.userRepository.Get(ctx, id)
+	if errors.Is(err, repository.ErrRecordNotFound) {
+		return model.User{}, fmt.Errorf("%w: %s", ErrUserNotFound, id)
+	}
+	if err != nil {
+		return fail(err)
+	}
+	return user, nil
+}
+
+func (svc *Profile) GetProfileByHandle(ctx context.Context, handle string) (model.User, error) {
+	fail := func(err error) (model.User, error) {
+		return model.User{}, fmt.Errorf("get profile by handle: %w", err)
+	}
+
+	user, err := svc.userRepository.GetByHandle(ctx, handle)
+	if errors.Is(err, repository.ErrRecordNotFound) {
+		return model.User{}, fmt.Errorf("%w: %s", ErrUserNotFound, handle)
+	}
+	if err != nil {
+		return fail(err)
+	}
+	return user, nil
+}
+
+var (
+	ErrSearchPaginationLimitInvalid = errors.New("search pagination limit invalid")
+	ErrSearchQueryInvalid           = errors.New("search query invalid")
+)
+
+const maxSearchQueryLength = 50
+
+func (svc *Profile) Search(
+	ctx context.Context, query string, cursor *model.SearchCursor, limit int,
+) ([]model.UserSummary, *model.SearchCursor, error) {
+	fail := func(err error) ([]model.UserSummary, *model.SearchCursor, error) {
+		return nil, nil, fmt.Errorf("search users: %w", err)
+	}
+
+	query = strings.TrimSpace(query)
+	if query == "" || len(query) > maxSearchQueryLength {
+		return nil, nil, fmt.Errorf(
+			"%w, should be non-empty and at most %d characters long",
+			ErrSearchQueryInvalid, maxSearchQueryLength,
+		)
+	}
+	if limit < 1 || limit > config.SearchPaginationLimit {
+		return nil, nil, fmt.Errorf(
+			"%w, should be in range: [1, %d]",
+			ErrSearchPaginationLimitInvalid, config.SearchPaginationLimit,
+		)
+	}
+
+	users, nextCursor, err := svc.userRepository.Search(ctx, query, cursor, limit)
+	if err != nil {
+		return fail(err)
+	}
+	return users, nextCursor, nil
+}
+
+var ErrInvalidImage = fmt.Errorf("image invalid or inaccessible")
+
+// Pending follow requests are kept when a private profile becomes public, and can still be approved.
+func (svc *Profile) UpdateProfile(
+	ctx context.Context, id uuid.UUID, name *string, image *model.ImageLocation, isPrivate *bool,
+) error {
+	fail := func(err error) error {
+		return fmt.Errorf("update profile: %w", err)
+	}
+
+	if image != nil {
+		// Make sure the image was uploaded by this user specifically for the profile, because different image types have different size limits.
+		if !strings.HasPrefix(image.Key, fmt.Sprintf("images/profile/%s/", id)) {
+			return fmt.Errorf("%w: %s", ErrInvalidImage, "profile image must be uploaded through the profile upload form")
+		}
+
+		_, err := svc.imageClient.CheckObjectExists(ctx, &imagePB.ObjectExistsRequest{
+			Bucket: image.Bucket,
+			Key:    image.Key,
+		})
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidImage, err)
+		}
+	}
+
+	if err := svc.userRepository.UpdateProfile(ctx, id, name, image, isPrivate); err != nil {
+		return fail(err)
+	}
+	return nil
+}
+
+func (svc *Profile) GetSummaries(ctx context.Context, ids []uuid.UUID) ([]model.UserSummary, error) {
+	fail := func(err error) ([]model.UserSummary, error) {
+		return nil, fmt.Errorf("get user summaries: %w", err)
+	}
+
+	if len(ids) == 0 {
+		return []model.UserSummary{}, nil
+	}
+	users, err := svc.userRepository.GetSummaries(ctx, ids)
+	if err != nil {
+		return fail(err)
+	}
+	return users, nil
+}
+
+func (svc *Profile) ResolveHandles(ctx context.Context, handles []string) (map[string]uuid.UUID, error) {
+	if len(handles) == 0 {
+		return map[string]uuid.UUID{}, nil
+	}
+	ids, err := svc.userRepository.GetIDsByHandles(ctx, handles)
+	if err != nil {
+		return nil, fmt.Errorf("resolve handles: %w", err)
+	}
+	return ids, nil
+}
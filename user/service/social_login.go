@@ -0,0 +1,201 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"smapp/user/config"
+	"smapp/user/model"
+	"smapp/user/oidc"
+	"smapp/user/repository"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type SocialLogin struct {
+	socialLoginRepository *repository.SocialLogin
+	tokenService          *Token
+	twoFactorService      *TwoFactor
+	// Keyed by the provider name used in URLs
+	providers map[string]*oidc.Provider
+}
+
+func NewSocialLogin(
+	socialLoginRepository *repository.SocialLogin,
+	tokenService *Token,
+	twoFactorService *TwoFactor,
+	providers map[string]*oidc.Provider,
+) *SocialLogin {
+	return &SocialLogin{
+		socialLoginRepository: socialLoginRepository,
+		tokenService:          tokenService,
+		twoFactorService:      twoFactorService,
+		providers:             providers,
+	}
+}
+
+var (
+	ErrUnknownProvider         = errors.New("unknown login provider")
+	ErrInvalidSocialLoginState = errors.New("invalid social login state")
+	ErrSocialLoginFailed       = errors.New("social login failed")
+	ErrSocialLoginEmailMissing = errors.New("provider did not return an email")
+)
+
+// The state, the PKCE code verifier and the nonce are stored until the provider redirects back to CompleteLogin.
+func (svc *SocialLogin) StartLogin(ctx context.Context, providerName string) (model.SocialLoginStart, error) {
+	fail := func(err error) (model.SocialLoginStart, error) {
+		return model.SocialLoginStart{}, fmt.Errorf("start social login: %w", err)
+	}
+
+	provider, ok := svc.providers[providerName]
+	if !ok {
+		return model.SocialLoginStart{}, fmt.Errorf("%w: %s", ErrUnknownProvider, providerName)
+	}
+	state, stateHash, err := newOpaqueToken()
+	if err != nil {
+		return fail(err)
+	}
+	codeVerifier, err := oidc.NewRandomString()
+	if err != nil {
+		return fail(err)
+	}
+	nonce, err := oidc.NewRandomString()
+	if err != nil {
+		return fail(err)
+	}
+	authorizationURL, err := provider.AuthCodeURL(ctx, state, nonce, codeVerifier)
+	if err != nil {
+		return fail(err)
+	}
+	err = svc.socialLoginRepository.CreateState(
+		ctx, stateHash, providerName, codeVerifier, nonce, time.Now().Add(config.SocialLoginStateTTL),
+	)
+	if err != nil {
+		return fail(err)
+	}
+	return model.SocialLoginStart{AuthorizationURL: authorizationURL, State: state}, nil
+}
+
+var handleDisallowedChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// Derived from the preferred username or the email. Handles are at most 20 characters long, which leaves room for a
+// random suffix if the handle is taken.
+func getHandleBase(claims oidc.Claims) string {
+	handle := claims.PreferredUsername
+	if handle == "" {
+		handle, _, _ = strings.Cut(claims.Email, "@")
+	}
+	handle = handleDisallowedChars.ReplaceAllString(handle, "")
+	if len(handle) > 15 {
+		handle = handle[:15]
+	}
+	if handle == "" {
+		handle = "user"
+	}
+	return handle
+}
+
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) > n {
+		return string(runes[:n])
+	}
+	return s
+}
+
+const handleAttempts = 5
+
+// The external account is linked to a new user on the first login. Users are not linked to existing accounts with the
+// same email, because the provider could have verified the email of someone else.
+func (svc *SocialLogin) createUser(ctx context.Context, providerName string, claims oidc.Claims) (uuid.UUID, error) {
+	if claims.Email == "" {
+		return uuid.Nil, ErrSocialLoginEmailMissing
+	}
+	handleBase := getHandleBase(claims)
+	name := truncateRunes(claims.Name, 50)
+	if name == "" {
+		name = handleBase
+	}
+
+	handle := handleBase
+	for i := 0; ; i++ {
+		id, err := svc.socialLoginRepository.CreateUser(
+			ctx, name, claims.Email, handle, claims.EmailVerified, providerName, claims.Subject,
+		)
+		if errors.Is(err, repository.ErrEmailExists) {
+			return uuid.Nil, ErrEmailExists
+		}
+		if errors.Is(err, repository.ErrHandleExists) && i < handleAttempts {
+			suffix, err := rand.Int(rand.Reader, big.NewInt(10000))
+			if err != nil {
+				return uuid.Nil, err
+			}
+			handle = fmt.Sprintf("%s_%04d", handleBase, suffix)
+			continue
+		}
+		if errors.Is(err, repository.ErrHandleExists) {
+			return uuid.Nil, ErrHandleExists
+		}
+		return id, err
+	}
+}
+
+// Called with the parameters the provider redirects back with. Creates a user on the first login. If the user has
+// two-factor authentication enabled, the result contains a challenge token instead of the tokens.
+func (svc *SocialLogin) CompleteLogin(
+	ctx context.Context, providerName, state, code string,
+) (model.LoginResult, error) {
+	fail := func(err error) (model.LoginResult, error) {
+		return model.LoginResult{}, fmt.Errorf("complete social login: %w", err)
+	}
+
+	provider, ok := svc.providers[providerName]
+	if !ok {
+		return model.LoginResult{}, fmt.Errorf("%w: %s", ErrUnknownProvider, providerName)
+	}
+	codeVerifier, nonce, err := svc.socialLoginRepository.ConsumeState(ctx, hashOpaqueToken(state), providerName)
+	if errors.Is(err, repository.ErrRecordNotFound) {
+		return model.LoginResult{}, ErrInvalidSocialLoginState
+	}
+	if err != nil {
+		return fail(err)
+	}
+
+	rawIDToken, err := provider.Exchange(ctx, code, codeVerifier)
+	if errors.Is(err, oidc.ErrExchangeFailed) {
+		return model.LoginResult{}, fmt.Errorf("%w: %w", ErrSocialLoginFailed, err)
+	}
+	if err != nil {
+		return fail(err)
+	}
+	claims, err := provider.VerifyIDToken(ctx, rawIDToken, nonce)
+	if errors.Is(err, oidc.ErrInvalidIDToken) {
+		return model.LoginResult{}, fmt.Errorf("%w: %w", ErrSocialLoginFailed, err)
+	}
+	if err != nil {
+		return fail(err)
+	}
+
+	userID, err := svc.socialLoginRepository.GetUserID(ctx, providerName, claims.Subject)
+	if errors.Is(err, repository.ErrRecordNotFound) {
+		userID, err = svc.createUser(ctx, providerName, claims)
+		if errors.Is(err, ErrEmailExists) || errors.Is(err, ErrHandleExists) ||
+			errors.Is(err, ErrSocialLoginEmailMissing) {
+			return model.LoginResult{}, err
+		}
+	}
+	if err != nil {
+		return fail(err)
+	}
+
+	result, err := issueLoginResult(ctx, svc.tokenService, svc.twoFactorService, userID, nil)
+	if err != nil {
+		return fail(err)
+	}
+	return result, nil
+}
@@ -0,0 +1,102 @@
+package service_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"database/sql/driver"
+	"net/http"
+	"smapp/user/oidc"
+	"smapp/user/oidc/oidctest"
+	"smapp/user/repository"
+	"smapp/user/service"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/matryer/is"
+)
+
+// Matches any string and keeps it, for values generated inside the service.
+type captureString struct {
+	value *string
+}
+
+func (c captureString) Match(v driver.Value) bool {
+	s, ok := v.(string)
+	*c.value = s
+	return ok
+}
+
+func TestSocialLoginCreatesUser(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	server, err := oidctest.NewServer("client", "secret")
+	is.NoErr(err)
+	defer server.Close()
+	db, mock, err := sqlmock.New()
+	is.NoErr(err)
+	defer db.Close()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	is.NoErr(err)
+
+	socialLoginService := service.NewSocialLogin(
+		repository.NewSocialLogin(db),
+		service.NewToken(repository.NewToken(db), service.NewJWT([]*rsa.PrivateKey{key}, time.Minute), time.Hour),
+		service.NewTwoFactor(repository.NewTwoFactor(db), nil, nil, nil, nil),
+		map[string]*oidc.Provider{
+			"test": oidc.NewProvider(oidc.Config{
+				Issuer:       server.URL,
+				ClientID:     "client",
+				ClientSecret: []byte("secret"),
+				RedirectURL:  "https://example.com/login/oidc/test/callback",
+			}, http.DefaultClient),
+		},
+	)
+
+	var codeVerifier, nonce string
+	mock.ExpectExec("DELETE FROM social_login_states").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO social_login_states").
+		WithArgs(sqlmock.AnyArg(), "test", captureString{&codeVerifier}, captureString{&nonce}, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	start, err := socialLoginService.StartLogin(ctx, "test")
+	is.NoErr(err)
+
+	code, state, err := server.Authorize(start.AuthorizationURL, oidctest.User{
+		Subject:           "123",
+		Email:             "jane@example.com",
+		EmailVerified:     true,
+		Name:              "Jane Doe",
+		PreferredUsername: "jane.doe",
+	})
+	is.NoErr(err)
+	is.Equal(state, start.State)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM social_login_states").
+		WithArgs(sqlmock.AnyArg(), "test").
+		WillReturnRows(sqlmock.NewRows([]string{"code_verifier", "nonce"}).AddRow(codeVerifier, nonce))
+	mock.ExpectExec("DELETE FROM social_login_states").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery("FROM user_identities").
+		WithArgs("test", "123").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}))
+	// The new user has no password, and the email is verified by the provider.
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO users .* NULL").
+		WithArgs(sqlmock.AnyArg(), "Jane Doe", "jane@example.com", "janedoe", true).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO user_identities").
+		WithArgs("test", "123", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery("FROM totp_secrets").WillReturnRows(sqlmock.NewRows([]string{"enabled"}).AddRow(false))
+	mock.ExpectExec("DELETE FROM refresh_tokens").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO refresh_tokens").WillReturnResult(sqlmock.NewResult(0, 1))
+	result, err := socialLoginService.CompleteLogin(ctx, "test", state, code)
+	is.NoErr(err)
+	is.True(result.TokenPair != nil)
+	is.True(!result.TwoFactorRequired)
+	is.NoErr(mock.ExpectationsWereMet())
+}
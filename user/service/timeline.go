@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"smapp/user/config"
+	"smapp/user/model"
+	"smapp/user/repository"
+
+	eventsPB "smapp/common/grpc/events"
+	postPB "smapp/common/grpc/post"
+)
+
+// Timeline keeps the timelines of the post service in sync with follows. It is run by the outbox relay, so a failed
+// call is retried and an event can be handled more than once. Adding and removing an author are both idempotent.
+type Timeline struct {
+	followRepository *repository.Follow
+	postClient       postPB.PostClient
+}
+
+func NewTimeline(followRepository *repository.Follow, postClient postPB.PostClient) *Timeline {
+	return &Timeline{
+		followRepository: followRepository,
+		postClient:       postClient,
+	}
+}
+
+// A follow adds the latest posts of the followed user to the follower's timeline, an unfollow removes all of them.
+func (svc *Timeline) HandleEvent(ctx context.Context, event *eventsPB.Event) error {
+	switch payload := event.Payload.(type) {
+	case *eventsPB.Event_UserFollowed:
+		_, err := svc.postClient.AddAuthorToTimeline(ctx, &postPB.TimelineAuthorRequest{
+			UserId:   payload.UserFollowed.FollowerId,
+			AuthorId: payload.UserFollowed.FollowedId,
+		})
+		if err != nil {
+			return fmt.Errorf("add author to timeline: %w", err)
+		}
+	case *eventsPB.Event_UserUnfollowed:
+		_, err := svc.postClient.RemoveAuthorFromTimeline(ctx, &postPB.TimelineAuthorRequest{
+			UserId:   payload.UserUnfollowed.FollowerId,
+			AuthorId: payload.UserUnfollowed.FollowedId,
+		})
+		if err != nil {
+			return fmt.Errorf("remove author from timeline: %w", err)
+		}
+	}
+	return nil
+}
+
+// Adds the latest posts of followed authors to the timelines of all followers, for follows made before timelines were
+// introduced. Posts that are already in a timeline are skipped, so it is safe to run again if interrupted. Returns the
+// number of follows processed.
+func (svc *Timeline) BackfillAll(ctx context.Context) (int, error) {
+	var after *model.Follow
+	processed := 0
+	for {
+		follows, err := svc.followRepository.GetBatch(ctx, after, config.TimelineBackfillBatchSize)
+		if err != nil {
+			return processed, fmt.Errorf("backfill all timelines: %w", err)
+		}
+		for _, follow := range follows {
+			_, err = svc.postClient.AddAuthorToTimeline(ctx, &postPB.TimelineAuthorRequest{
+				UserId:   follow.FollowerID[:],
+				AuthorId: follow.FollowedID[:],
+			})
+			if err != nil {
+				return processed, fmt.Errorf(
+					"backfill timeline of %s with posts of %s: %w", follow.FollowerID, follow.FollowedID, err,
+				)
+			}
+			processed++
+		}
+		if len(follows) < config.TimelineBackfillBatchSize {
+			return processed, nil
+		}
+		after = &follows[len(follows)-1]
+	}
+}
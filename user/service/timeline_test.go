@@ -0,0 +1,63 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"smapp/user/service"
+	"testing"
+
+	eventsPB "smapp/common/grpc/events"
+	postPB "smapp/common/grpc/post"
+	postmocks "smapp/common/grpc/post/mocks"
+
+	"github.com/google/uuid"
+	"github.com/matryer/is"
+	"go.uber.org/mock/gomock"
+)
+
+func TestTimelineHandleEvent(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+
+	followerID, followedID := uuid.New(), uuid.New()
+	request := &postPB.TimelineAuthorRequest{UserId: followerID[:], AuthorId: followedID[:]}
+
+	postClient := postmocks.NewMockPostClient(ctrl)
+	postClient.EXPECT().AddAuthorToTimeline(gomock.Any(), request).Return(&postPB.TimelineAuthorResponse{}, nil)
+	postClient.EXPECT().RemoveAuthorFromTimeline(gomock.Any(), request).Return(&postPB.TimelineAuthorResponse{}, nil)
+
+	timelineService := service.NewTimeline(nil, postClient)
+	err := timelineService.HandleEvent(context.Background(), &eventsPB.Event{
+		Payload: &eventsPB.Event_UserFollowed{UserFollowed: &eventsPB.UserFollowed{
+			FollowerId: followerID[:],
+			FollowedId: followedID[:],
+		}},
+	})
+	is.NoErr(err)
+	err = timelineService.HandleEvent(context.Background(), &eventsPB.Event{
+		Payload: &eventsPB.Event_UserUnfollowed{UserUnfollowed: &eventsPB.UserUnfollowed{
+			FollowerId: followerID[:],
+			FollowedId: followedID[:],
+		}},
+	})
+	is.NoErr(err)
+}
+
+func TestTimelineHandleEventFails(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+
+	// The error is returned, so that the relay publishes the event again.
+	unavailableErr := errors.New("post service unavailable")
+	postClient := postmocks.NewMockPostClient(ctrl)
+	postClient.EXPECT().RemoveAuthorFromTimeline(gomock.Any(), gomock.Any()).Return(nil, unavailableErr)
+
+	timelineService := service.NewTimeline(nil, postClient)
+	err := timelineService.HandleEvent(context.Background(), &eventsPB.Event{
+		Payload: &eventsPB.Event_UserUnfollowed{UserUnfollowed: &eventsPB.UserUnfollowed{
+			FollowerId: []byte("id"),
+			FollowedId: []byte("id"),
+		}},
+	})
+	is.True(errors.Is(err, unavailableErr))
+}
@@ -0,0 +1,152 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"smapp/user/model"
+	"smapp/user/repository"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Token struct {
+	tokenRepository *repository.Token
+	jwtService      *JWT
+	refreshTTL      time.Duration
+}
+
+func NewToken(tokenRepository *repository.Token, jwtService *JWT, refreshTTL time.Duration) *Token {
+	return &Token{
+		tokenRepository: tokenRepository,
+		jwtService:      jwtService,
+		refreshTTL:      refreshTTL,
+	}
+}
+
+const opaqueTokenBytes = 32
+
+// Generates a random token, used for refresh tokens and for tokens sent by email.
+func newOpaqueToken() (token string, hash []byte, err error) {
+	b := make([]byte, opaqueTokenBytes)
+	if _, err = rand.Read(b); err != nil {
+		return "", nil, err
+	}
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, hashOpaqueToken(token), nil
+}
+
+// The tokens are random, so a fast hash is enough to make a leaked table useless.
+func hashOpaqueToken(token string) []byte {
+	hash := sha256.Sum256([]byte(token))
+	return hash[:]
+}
+
+// Issues an access token and a refresh token of a new token family. Called on signup and login.
+func (svc *Token) Issue(ctx context.Context, userID uuid.UUID) (model.TokenPair, error) {
+	fail := func(err error) (model.TokenPair, error) {
+		return model.TokenPair{}, fmt.Errorf("issue tokens: %w", err)
+	}
+
+	accessToken, err := svc.jwtService.GenerateJWT(userID)
+	if err != nil {
+		return fail(err)
+	}
+	refreshToken, hash, err := newOpaqueToken()
+	if err != nil {
+		return fail(err)
+	}
+	if err = svc.tokenRepository.CreateRefreshToken(ctx, userID, hash, time.Now().Add(svc.refreshTTL)); err != nil {
+		return fail(err)
+	}
+	return model.TokenPair{AccessToken: accessToken, RefreshToken: refreshToken}, nil
+}
+
+var ErrInvalidRefreshToken = errors.New("invalid refresh token")
+
+// Exchanges the refresh token for a new access token and a new refresh token. The old refresh token stops working.
+func (svc *Token) Refresh(ctx context.Context, refreshToken string) (model.TokenPair, error) {
+	fail := func(err error) (model.TokenPair, error) {
+		return model.TokenPair{}, fmt.Errorf("refresh tokens: %w", err)
+	}
+
+	newRefreshToken, newHash, err := newOpaqueToken()
+	if err != nil {
+		return fail(err)
+	}
+	userID, err := svc.tokenRepository.RotateRefreshToken(
+		ctx, hashOpaqueToken(refreshToken), newHash, time.Now().Add(svc.refreshTTL),
+	)
+	if errors.Is(err, repository.ErrRecordNotFound) {
+		return model.TokenPair{}, ErrInvalidRefreshToken
+	}
+	if errors.Is(err, repository.ErrTokenExpired) || errors.Is(err, repository.ErrTokenReused) {
+		return model.TokenPair{}, fmt.Errorf("%w: %w", ErrInvalidRefreshToken, err)
+	}
+	if err != nil {
+		return fail(err)
+	}
+
+	accessToken, err := svc.jwtService.GenerateJWT(userID)
+	if err != nil {
+		return fail(err)
+	}
+	return model.TokenPair{AccessToken: accessToken, RefreshToken: newRefreshToken}, nil
+}
+
+// Revokes the access token, and the refresh token family if a refresh token is given. Tokens of other logins of the
+// user keep working.
+func (svc *Token) Logout(ctx context.Context, accessToken, refreshToken string) error {
+	fail := func(err error) error {
+		return fmt.Errorf("logout: %w", err)
+	}
+
+	claims, err := svc.jwtService.ParseJWT(accessToken)
+	if err != nil {
+		return err
+	}
+	if refreshToken != "" {
+		err = svc.tokenRepository.DeleteRefreshTokenFamily(ctx, claims.UserID, hashOpaqueToken(refreshToken))
+		if errors.Is(err, repository.ErrRecordNotFound) {
+			return ErrInvalidRefreshToken
+		}
+		if err != nil {
+			return fail(err)
+		}
+	}
+	if err = svc.tokenRepository.RevokeAccessToken(ctx, claims.ID, claims.ExpiresAt); err != nil {
+		return fail(err)
+	}
+	return nil
+}
+
+var ErrAccessTokenRevoked = errors.New("access token revoked")
+
+// Returns the user the access token was issued to, if the token is valid and has not been revoked.
+func (svc *Token) Check(ctx context.Context, accessToken string) (uuid.UUID, error) {
+	claims, err := svc.jwtService.ParseJWT(accessToken)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	revoked, err := svc.IsRevoked(ctx, claims.ID, claims.UserID, claims.IssuedAt)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("check access token: %w", err)
+	}
+	if revoked {
+		return uuid.Nil, ErrAccessTokenRevoked
+	}
+	return claims.UserID, nil
+}
+
+// Tokens issued before the user last changed their password count as revoked.
+func (svc *Token) IsRevoked(ctx context.Context, jti, userID uuid.UUID, issuedAt time.Time) (bool, error) {
+	revoked, err := svc.tokenRepository.IsAccessTokenRevoked(ctx, jti, userID, issuedAt)
+	if err != nil {
+		return false, fmt.Errorf("check if access token is revoked: %w", err)
+	}
+	return revoked, nil
+}
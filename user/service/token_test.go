@@ -0,0 +1,231 @@
+package service_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"smapp/user/repository"
+	"smapp/user/service"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/matryer/is"
+)
+
+func newTestJWTService(t *testing.T) *service.JWT {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return service.NewJWT([]*rsa.PrivateKey{key}, time.Minute)
+}
+
+func TestRefreshRotatesToken(t *testing.T) {
+	is := is.New(t)
+
+	db, mock, err := sqlmock.New()
+	is.NoErr(err)
+	defer db.Close()
+
+	jwtService := newTestJWTService(t)
+	tokenService := service.NewToken(repository.NewToken(db), jwtService, time.Hour)
+
+	// The old token is marked as rotated and the new one joins its family.
+	tokenID, userID, familyID := uuid.New(), uuid.New(), uuid.New()
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM refresh_tokens").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "family_id", "rotated", "expired"}).
+			AddRow(tokenID[:], userID[:], familyID[:], false, false))
+	mock.ExpectExec("UPDATE refresh_tokens SET rotated_at").
+		WithArgs(tokenID[:]).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO refresh_tokens").
+		WithArgs(sqlmock.AnyArg(), userID[:], familyID[:], sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	tokens, err := tokenService.Refresh(context.Background(), "refresh-token")
+	is.NoErr(err)
+	is.True(tokens.RefreshToken != "" && tokens.RefreshToken != "refresh-token")
+
+	claims, err := jwtService.ParseJWT(tokens.AccessToken)
+	is.NoErr(err)
+	is.Equal(claims.UserID, userID)
+	is.NoErr(mock.ExpectationsWereMet())
+}
+
+func TestRefreshReusedTokenRevokesFamily(t *testing.T) {
+	is := is.New(t)
+
+	db, mock, err := sqlmock.New()
+	is.NoErr(err)
+	defer db.Close()
+
+	tokenService := service.NewToken(repository.NewToken(db), newTestJWTService(t), time.Hour)
+
+	// The token has already been rotated, so whoever holds the latest token of the family is logged out too.
+	tokenID, userID, familyID := uuid.New(), uuid.New(), uuid.New()
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM refresh_tokens").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "family_id", "rotated", "expired"}).
+			AddRow(tokenID[:], userID[:], familyID[:], true, false))
+	mock.ExpectExec("DELETE FROM refresh_tokens WHERE family_id").
+		WithArgs(familyID[:]).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+	_, err = tokenService.Refresh(context.Background(), "refresh-token")
+
+	is.True(errors.Is(err, service.ErrInvalidRefreshToken))
+	is.True(errors.Is(err, repository.ErrTokenReused))
+	is.NoErr(mock.ExpectationsWereMet())
+}
+
+func TestRefreshExpiredToken(t *testing.T) {
+	is := is.New(t)
+
+	db, mock, err := sqlmock.New()
+	is.NoErr(err)
+	defer db.Close()
+
+	tokenService := service.NewToken(repository.NewToken(db), newTestJWTService(t), time.Hour)
+
+	// Nothing is written, the transaction is rolled back.
+	tokenID, userID, familyID := uuid.New(), uuid.New(), uuid.New()
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM refresh_tokens").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "family_id", "rotated", "expired"}).
+			AddRow(tokenID[:], userID[:], familyID[:], false, true))
+	mock.ExpectRollback()
+	_, err = tokenService.Refresh(context.Background(), "refresh-token")
+
+	is.True(errors.Is(err, service.ErrInvalidRefreshToken))
+	is.True(errors.Is(err, repository.ErrTokenExpired))
+	is.NoErr(mock.ExpectationsWereMet())
+}
+
+func TestRefreshUnknownToken(t *testing.T) {
+	is := is.New(t)
+
+	db, mock, err := sqlmock.New()
+	is.NoErr(err)
+	defer db.Close()
+
+	tokenService := service.NewToken(repository.NewToken(db), newTestJWTService(t), time.Hour)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM refresh_tokens").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "family_id", "rotated", "expired"}))
+	mock.ExpectRollback()
+	_, err = tokenService.Refresh(context.Background(), "refresh-token")
+
+	is.True(errors.Is(err, service.ErrInvalidRefreshToken))
+	is.NoErr(mock.ExpectationsWereMet())
+}
+
+func TestLogoutRevokesTokens(t *testing.T) {
+	is := is.New(t)
+
+	db, mock, err := sqlmock.New()
+	is.NoErr(err)
+	defer db.Close()
+
+	jwtService := newTestJWTService(t)
+	tokenService := service.NewToken(repository.NewToken(db), jwtService, time.Hour)
+
+	userID := uuid.New()
+	accessToken, err := jwtService.GenerateJWT(userID)
+	is.NoErr(err)
+	claims, err := jwtService.ParseJWT(accessToken)
+	is.NoErr(err)
+
+	mock.ExpectExec("DELETE FROM refresh_tokens").
+		WithArgs(sqlmock.AnyArg(), userID[:]).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM revoked_tokens").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT IGNORE INTO revoked_tokens").
+		WithArgs(claims.ID[:], sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	err = tokenService.Logout(context.Background(), accessToken, "refresh-token")
+
+	is.NoErr(err)
+	is.NoErr(mock.ExpectationsWereMet())
+}
+
+func TestLogoutWithRefreshTokenOfOtherUser(t *testing.T) {
+	is := is.New(t)
+
+	db, mock, err := sqlmock.New()
+	is.NoErr(err)
+	defer db.Close()
+
+	jwtService := newTestJWTService(t)
+	tokenService := service.NewToken(repository.NewToken(db), jwtService, time.Hour)
+
+	accessToken, err := jwtService.GenerateJWT(uuid.New())
+	is.NoErr(err)
+
+	// The family is only deleted if it belongs to the user of the access token, which is then not revoked either.
+	mock.ExpectExec("DELETE FROM refresh_tokens").WillReturnResult(sqlmock.NewResult(0, 0))
+	err = tokenService.Logout(context.Background(), accessToken, "refresh-token")
+
+	is.True(errors.Is(err, service.ErrInvalidRefreshToken))
+	is.NoErr(mock.ExpectationsWereMet())
+}
+
+func TestCheckRevokedToken(t *testing.T) {
+	is := is.New(t)
+
+	db, mock, err := sqlmock.New()
+	is.NoErr(err)
+	defer db.Close()
+
+	jwtService := newTestJWTService(t)
+	tokenService := service.NewToken(repository.NewToken(db), jwtService, time.Hour)
+
+	userID := uuid.New()
+	accessToken, err := jwtService.GenerateJWT(userID)
+	is.NoErr(err)
+	claims, err := jwtService.ParseJWT(accessToken)
+	is.NoErr(err)
+
+	mock.ExpectQuery("FROM revoked_tokens").
+		WithArgs(claims.ID[:], userID[:], sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"revoked"}).AddRow(false))
+	checkedID, err := tokenService.Check(context.Background(), accessToken)
+	is.NoErr(err)
+	is.Equal(checkedID, userID)
+
+	// The same token after logout.
+	mock.ExpectQuery("FROM revoked_tokens").
+		WithArgs(claims.ID[:], userID[:], sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"revoked"}).AddRow(true))
+	_, err = tokenService.Check(context.Background(), accessToken)
+	is.True(errors.Is(err, service.ErrAccessTokenRevoked))
+	is.NoErr(mock.ExpectationsWereMet())
+}
+
+func TestCheckTokenIssuedBeforePasswordChange(t *testing.T) {
+	is := is.New(t)
+
+	db, mock, err := sqlmock.New()
+	is.NoErr(err)
+	defer db.Close()
+
+	tokenService := service.NewToken(repository.NewToken(db), newTestJWTService(t), time.Hour)
+
+	userID := uuid.New()
+	mock.ExpectExec("DELETE FROM refresh_tokens").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO refresh_tokens").WillReturnResult(sqlmock.NewResult(0, 1))
+	tokens, err := tokenService.Issue(context.Background(), userID)
+	is.NoErr(err)
+
+	// The user has changed their password since the token was issued.
+	mock.ExpectQuery("password_changed_at > \\?").
+		WithArgs(sqlmock.AnyArg(), userID[:], sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"revoked"}).AddRow(true))
+	_, err = tokenService.Check(context.Background(), tokens.AccessToken)
+	is.True(errors.Is(err, service.ErrAccessTokenRevoked))
+	is.NoErr(mock.ExpectationsWereMet())
+}
@@ -0,0 +1,316 @@
+package service
+
+import (
+	"context"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"smapp/user/config"
+	"smapp/user/model"
+	"smapp/user/repository"
+	"smapp/user/totp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const totpIssuer = "Smapp"
+
+type TwoFactor struct {
+	twoFactorRepository    *repository.TwoFactor
+	userRepository         *repository.User
+	loginAttemptRepository *repository.LoginAttempt
+	tokenService           *Token
+	// Encrypts TOTP secrets at rest
+	aead cipher.AEAD
+}
+
+func NewTwoFactor(
+	twoFactorRepository *repository.TwoFactor,
+	userRepository *repository.User,
+	loginAttemptRepository *repository.LoginAttempt,
+	tokenService *Token,
+	aead cipher.AEAD,
+) *TwoFactor {
+	return &TwoFactor{
+		twoFactorRepository:    twoFactorRepository,
+		userRepository:         userRepository,
+		loginAttemptRepository: loginAttemptRepository,
+		tokenService:           tokenService,
+		aead:                   aead,
+	}
+}
+
+var (
+	ErrTwoFactorEnabled      = errors.New("two-factor authentication already enabled")
+	ErrTwoFactorNotEnabled   = errors.New("two-factor authentication not enabled")
+	ErrTwoFactorNotPending   = errors.New("two-factor enrollment not started")
+	ErrInvalidTwoFactorCode  = errors.New("invalid two-factor code")
+	ErrInvalidLoginChallenge = errors.New("invalid login challenge")
+)
+
+// The user ID is authenticated along with the secret, so that encrypted secrets cannot be swapped between users.
+func (svc *TwoFactor) encryptSecret(userID uuid.UUID, secret []byte) ([]byte, error) {
+	nonce := make([]byte, svc.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return svc.aead.Seal(nonce, nonce, secret, userID[:]), nil
+}
+
+func (svc *TwoFactor) decryptSecret(userID uuid.UUID, secretEncrypted []byte) ([]byte, error) {
+	nonceSize := svc.aead.NonceSize()
+	if len(secretEncrypted) < nonceSize {
+		return nil, errors.New("encrypted totp secret too short")
+	}
+	return svc.aead.Open(nil, secretEncrypted[:nonceSize], secretEncrypted[nonceSize:], userID[:])
+}
+
+// Stores a new secret, which is only used after ConfirmTOTPEnrollment. Calling it again replaces the pending secret.
+func (svc *TwoFactor) StartTOTPEnrollment(ctx context.Context, userID uuid.UUID) (model.TOTPEnrollment, error) {
+	fail := func(err error) (model.TOTPEnrollment, error) {
+		return model.TOTPEnrollment{}, fmt.Errorf("start totp enrollment: %w", err)
+	}
+
+	user, err := svc.userRepository.Get(ctx, userID)
+	if errors.Is(err, repository.ErrRecordNotFound) {
+		return model.TOTPEnrollment{}, fmt.Errorf("%w: %s", ErrUserNotFound, userID)
+	}
+	if err != nil {
+		return fail(err)
+	}
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return fail(err)
+	}
+	secretEncrypted, err := svc.encryptSecret(userID, secret)
+	if err != nil {
+		return fail(err)
+	}
+	err = svc.twoFactorRepository.SetPendingTOTPSecret(ctx, userID, secretEncrypted)
+	if errors.Is(err, repository.ErrRecordExists) {
+		return model.TOTPEnrollment{}, ErrTwoFactorEnabled
+	}
+	if err != nil {
+		return fail(err)
+	}
+	return model.TOTPEnrollment{
+		Secret: totp.EncodeSecret(secret),
+		URI:    totp.ProvisioningURI(totpIssuer, user.Handle, secret),
+	}, nil
+}
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// Formatted as two groups of 5 characters, e.g. "abcde-fghij". Input is normalized, so the case and the dash do
+// not matter.
+func newRecoveryCode() (string, error) {
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	code := strings.ToLower(recoveryCodeEncoding.EncodeToString(b))[:10]
+	return code[:5] + "-" + code[5:], nil
+}
+
+func normalizeCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
+
+// Enables two-factor authentication if the code matches the pending secret, and returns recovery codes, which are
+// not stored in plain text and cannot be shown again.
+func (svc *TwoFactor) ConfirmTOTPEnrollment(ctx context.Context, userID uuid.UUID, code string) ([]string, error) {
+	fail := func(err error) ([]string, error) {
+		return nil, fmt.Errorf("confirm totp enrollment: %w", err)
+	}
+
+	secretEncrypted, enabled, err := svc.twoFactorRepository.GetTOTPSecret(ctx, userID)
+	if errors.Is(err, repository.ErrRecordNotFound) {
+		return nil, ErrTwoFactorNotPending
+	}
+	if err != nil {
+		return fail(err)
+	}
+	if enabled {
+		return nil, ErrTwoFactorEnabled
+	}
+	secret, err := svc.decryptSecret(userID, secretEncrypted)
+	if err != nil {
+		return fail(err)
+	}
+	step, ok := totp.Match(secret, normalizeCode(code), time.Now())
+	if !ok {
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	codes := make([]string, config.RecoveryCodeCount)
+	hashes := make([][]byte, config.RecoveryCodeCount)
+	for i := range codes {
+		if codes[i], err = newRecoveryCode(); err != nil {
+			return fail(err)
+		}
+		hashes[i] = hashOpaqueToken(normalizeCode(codes[i]))
+	}
+	err = svc.twoFactorRepository.EnableTOTP(ctx, userID, step, hashes)
+	if errors.Is(err, repository.ErrRecordNotFound) {
+		return nil, ErrTwoFactorNotPending
+	}
+	if err != nil {
+		return fail(err)
+	}
+	return codes, nil
+}
+
+// Accepts either a TOTP code or a recovery code. Each code can be used only once.
+func (svc *TwoFactor) verifyCode(ctx context.Context, userID uuid.UUID, code string) error {
+	code = normalizeCode(code)
+	if len(code) != 6 {
+		err := svc.twoFactorRepository.UseRecoveryCode(ctx, userID, hashOpaqueToken(code))
+		if errors.Is(err, repository.ErrRecordNotFound) {
+			return ErrInvalidTwoFactorCode
+		}
+		return err
+	}
+
+	secretEncrypted, enabled, err := svc.twoFactorRepository.GetTOTPSecret(ctx, userID)
+	if errors.Is(err, repository.ErrRecordNotFound) || (err == nil && !enabled) {
+		return ErrTwoFactorNotEnabled
+	}
+	if err != nil {
+		return err
+	}
+	secret, err := svc.decryptSecret(userID, secretEncrypted)
+	if err != nil {
+		return err
+	}
+	step, ok := totp.Match(secret, code, time.Now())
+	if !ok {
+		return ErrInvalidTwoFactorCode
+	}
+	err = svc.twoFactorRepository.UseTOTPStep(ctx, userID, step)
+	if errors.Is(err, repository.ErrCodeUsed) {
+		return ErrInvalidTwoFactorCode
+	}
+	return err
+}
+
+// Requires a valid code, so that a stolen session alone cannot turn off the second factor.
+func (svc *TwoFactor) DisableTOTP(ctx context.Context, userID uuid.UUID, code string) error {
+	fail := func(err error) error {
+		return fmt.Errorf("disable totp: %w", err)
+	}
+
+	enabled, err := svc.twoFactorRepository.IsTOTPEnabled(ctx, userID)
+	if err != nil {
+		return fail(err)
+	}
+	if !enabled {
+		return ErrTwoFactorNotEnabled
+	}
+	err = svc.verifyCode(ctx, userID, code)
+	if errors.Is(err, ErrInvalidTwoFactorCode) {
+		return err
+	}
+	if err != nil {
+		return fail(err)
+	}
+	if err = svc.twoFactorRepository.DisableTOTP(ctx, userID); err != nil {
+		return fail(err)
+	}
+	return nil
+}
+
+func (svc *TwoFactor) IsEnabled(ctx context.Context, userID uuid.UUID) (bool, error) {
+	return svc.twoFactorRepository.IsTOTPEnabled(ctx, userID)
+}
+
+// Called after the first factor has been checked. The returned token identifies the login in the second step.
+func (svc *TwoFactor) CreateLoginChallenge(ctx context.Context, userID uuid.UUID, identifier *string) (string, error) {
+	token, hash, err := newOpaqueToken()
+	if err != nil {
+		return "", fmt.Errorf("create login challenge: %w", err)
+	}
+	err = svc.twoFactorRepository.CreateLoginChallenge(ctx, userID, identifier, hash, time.Now().Add(config.LoginChallengeTTL))
+	if err != nil {
+		return "", fmt.Errorf("create login challenge: %w", err)
+	}
+	return token, nil
+}
+
+// Wrong codes count as failed logins of the identifier of the first step and of the client IP, so that new
+// challenges cannot be used to guess codes without limit. Challenges issued by social login only count against the IP.
+func getChallengeAttemptKeys(identifier *string, clientIP string) []loginAttemptKey {
+	if identifier == nil {
+		return getLoginAttemptKeys("", clientIP)[1:]
+	}
+	return getLoginAttemptKeys(*identifier, clientIP)
+}
+
+// The second login step. The challenge is single-use, and stops working after too many wrong codes. Returns
+// LoginLockedError without checking the code if the identifier or the client IP has failed too often.
+func (svc *TwoFactor) CompleteLogin(
+	ctx context.Context, challengeToken, code, clientIP string,
+) (model.TokenPair, error) {
+	fail := func(err error) (model.TokenPair, error) {
+		return model.TokenPair{}, fmt.Errorf("complete login: %w", err)
+	}
+
+	hash := hashOpaqueToken(challengeToken)
+	userID, identifier, err := svc.twoFactorRepository.GetLoginChallenge(ctx, hash)
+	if errors.Is(err, repository.ErrRecordNotFound) {
+		return model.TokenPair{}, ErrInvalidLoginChallenge
+	}
+	if err != nil {
+		return fail(err)
+	}
+	keys := getChallengeAttemptKeys(identifier, clientIP)
+	err = checkLoginLockout(ctx, svc.loginAttemptRepository, keys)
+	var lockedErr *LoginLockedError
+	if errors.As(err, &lockedErr) {
+		return model.TokenPair{}, err
+	}
+	if err != nil {
+		return fail(err)
+	}
+
+	err = svc.verifyCode(ctx, userID, code)
+	if errors.Is(err, ErrInvalidTwoFactorCode) {
+		if recordErr := svc.twoFactorRepository.RecordLoginChallengeFailure(
+			ctx, hash, config.LoginChallengeMaxFailures,
+		); recordErr != nil {
+			return fail(recordErr)
+		}
+		if recordErr := recordLoginFailure(ctx, svc.loginAttemptRepository, keys); recordErr != nil {
+			return fail(recordErr)
+		}
+		return model.TokenPair{}, err
+	}
+	// Two-factor authentication was disabled after the first step.
+	if errors.Is(err, ErrTwoFactorNotEnabled) {
+		return model.TokenPair{}, ErrInvalidLoginChallenge
+	}
+	if err != nil {
+		return fail(err)
+	}
+
+	err = svc.twoFactorRepository.DeleteLoginChallenge(ctx, hash)
+	if errors.Is(err, repository.ErrRecordNotFound) {
+		return model.TokenPair{}, ErrInvalidLoginChallenge
+	}
+	if err != nil {
+		return fail(err)
+	}
+	if identifier != nil {
+		if err = svc.loginAttemptRepository.Reset(ctx, keys[0].key); err != nil {
+			return fail(err)
+		}
+	}
+	tokens, err := svc.tokenService.Issue(ctx, userID)
+	if err != nil {
+		return fail(err)
+	}
+	return tokens, nil
+}
@@ -4,20 +4,38 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"smapp/user/config"
+	"smapp/user/model"
 	"smapp/user/repository"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type User struct {
-	userRepository *repository.User
-	jwtService     *JWT
+	userRepository         *repository.User
+	loginAttemptRepository *repository.LoginAttempt
+	tokenService           *Token
+	accountService         *Account
+	twoFactorService       *TwoFactor
 }
 
-func NewUser(userRepository *repository.User, jwtService *JWT) *User {
+func NewUser(
+	userRepository *repository.User,
+	loginAttemptRepository *repository.LoginAttempt,
+	tokenService *Token,
+	accountService *Account,
+	twoFactorService *TwoFactor,
+) *User {
 	return &User{
-		userRepository: userRepository,
-		jwtService:     jwtService,
+		userRepository:         userRepository,
+		loginAttemptRepository: loginAttemptRepository,
+		tokenService:           tokenService,
+		accountService:         accountService,
+		twoFactorService:       twoFactorService,
 	}
 }
 
@@ -26,9 +44,9 @@ var (
 	ErrHandleExists = errors.New("handle already exists")
 )
 
-func (svc *User) Signup(ctx context.Context, name, email, handle, password string) (string, error) {
-	fail := func(err error) (string, error) {
-		return "", fmt.Errorf("signup: %w", err)
+func (svc *User) Signup(ctx context.Context, name, email, handle, password string) (model.TokenPair, error) {
+	fail := func(err error) (model.TokenPair, error) {
+		return model.TokenPair{}, fmt.Errorf("signup: %w", err)
 	}
 
 	passwordHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
@@ -37,40 +55,176 @@ func (svc *User) Signup(ctx context.Context, name, email, handle, password strin
 	}
 	id, err := svc.userRepository.Create(ctx, name, email, handle, string(passwordHash))
 	if errors.Is(err, repository.ErrEmailExists) {
-		return "", ErrEmailExists
+		return model.TokenPair{}, ErrEmailExists
 	}
 	if errors.Is(err, repository.ErrHandleExists) {
-		return "", ErrHandleExists
+		return model.TokenPair{}, ErrHandleExists
 	}
 	if err != nil {
 		return fail(err)
 	}
-	token, err := svc.jwtService.GenerateJWT(id)
+	svc.accountService.SendVerificationEmailInBackground(ctx, id)
+	tokens, err := svc.tokenService.Issue(ctx, id)
 	if err != nil {
 		return fail(err)
 	}
-	return token, nil
+	return tokens, nil
 }
 
-func (svc *User) Login(ctx context.Context, identifier string, password []byte) (string, error) {
-	fail := func(err error) (string, error) {
-		return "", fmt.Errorf("login: %w", err)
+type LoginLockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginLockedError) Error() string {
+	return fmt.Sprintf("login locked for %s", e.RetryAfter)
+}
+
+type loginAttemptKey struct {
+	key          string
+	freeAttempts int
+}
+
+// Failures are counted per identifier, whether the user exists or not, so that the lockout does not reveal it.
+func getLoginAttemptKeys(identifier, clientIP string) []loginAttemptKey {
+	return []loginAttemptKey{
+		{key: "identifier:" + strings.ToLower(identifier), freeAttempts: config.LoginIdentifierFreeAttempts},
+		{key: "ip:" + clientIP, freeAttempts: config.LoginIPFreeAttempts},
+	}
+}
+
+// Doubles with every failure after the free ones.
+func getLoginLockout(failures, freeAttempts int) time.Duration {
+	if failures <= freeAttempts {
+		return 0
+	}
+	lockout := config.LoginLockoutBase
+	for i := freeAttempts + 1; i < failures && lockout < config.LoginLockoutMax; i++ {
+		lockout *= 2
+	}
+	return min(lockout, config.LoginLockoutMax)
+}
+
+// Compared against when the user does not exist, so that the response time does not reveal it.
+var dummyPasswordHash = sync.OnceValue(func() []byte {
+	hash, err := bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+	if err != nil {
+		panic(err)
+	}
+	return hash
+})
+
+func recordLoginFailure(
+	ctx context.Context, loginAttemptRepository *repository.LoginAttempt, keys []loginAttemptKey,
+) error {
+	for _, key := range keys {
+		failures, err := loginAttemptRepository.RecordFailure(ctx, key.key, config.LoginFailureWindow)
+		if err != nil {
+			return err
+		}
+		if lockout := getLoginLockout(failures, key.freeAttempts); lockout > 0 {
+			if err = loginAttemptRepository.Lock(ctx, key.key, lockout); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Returns LoginLockedError if any of the keys is locked.
+func checkLoginLockout(
+	ctx context.Context, loginAttemptRepository *repository.LoginAttempt, keys []loginAttemptKey,
+) error {
+	lockoutKeys := make([]string, len(keys))
+	for i, key := range keys {
+		lockoutKeys[i] = key.key
+	}
+	retryAfter, err := loginAttemptRepository.GetLockout(ctx, lockoutKeys)
+	if err != nil {
+		return err
+	}
+	if retryAfter > 0 {
+		return &LoginLockedError{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+// Returns LoginLockedError without checking the password if the identifier or the client IP has failed too often. If
+// the user has two-factor authentication enabled, the result contains a challenge token instead of the tokens, and
+// the failures of the identifier are only reset once the second step succeeds.
+func (svc *User) Login(ctx context.Context, identifier string, password []byte, clientIP string) (model.LoginResult, error) {
+	fail := func(err error) (model.LoginResult, error) {
+		return model.LoginResult{}, fmt.Errorf("login: %w", err)
+	}
+
+	keys := getLoginAttemptKeys(identifier, clientIP)
+	err := checkLoginLockout(ctx, svc.loginAttemptRepository, keys)
+	var lockedErr *LoginLockedError
+	if errors.As(err, &lockedErr) {
+		return model.LoginResult{}, err
+	}
+	if err != nil {
+		return fail(err)
 	}
 
 	id, passwordHash, err := svc.userRepository.GetAuthData(ctx, identifier)
 	if errors.Is(err, repository.ErrRecordNotFound) {
-		return "", ErrUserNotFound
+		bcrypt.CompareHashAndPassword(dummyPasswordHash(), password)
+		if err = recordLoginFailure(ctx, svc.loginAttemptRepository, keys); err != nil {
+			return fail(err)
+		}
+		return model.LoginResult{}, ErrUserNotFound
 	}
 	if err != nil {
 		return fail(err)
 	}
-	err = bcrypt.CompareHashAndPassword(passwordHash, password)
+	// Accounts created through social login have no password, which is treated like a wrong one.
+	if passwordHash == nil {
+		bcrypt.CompareHashAndPassword(dummyPasswordHash(), password)
+		err = bcrypt.ErrMismatchedHashAndPassword
+	} else {
+		err = bcrypt.CompareHashAndPassword(passwordHash, password)
+	}
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		if recordErr := recordLoginFailure(ctx, svc.loginAttemptRepository, keys); recordErr != nil {
+			return fail(recordErr)
+		}
+	}
 	if err != nil {
 		return fail(err)
 	}
-	token, err := svc.jwtService.GenerateJWT(id)
+
+	result, err := issueLoginResult(ctx, svc.tokenService, svc.twoFactorService, id, &identifier)
 	if err != nil {
 		return fail(err)
 	}
-	return token, nil
+	// The IP counter is not reset, otherwise an attacker could reset it by logging into their own account.
+	if !result.TwoFactorRequired {
+		if err = svc.loginAttemptRepository.Reset(ctx, keys[0].key); err != nil {
+			return fail(err)
+		}
+	}
+	return result, nil
+}
+
+// Called once the user has been authenticated with the first factor, be it the password or an external provider. The
+// identifier the password login was made with is stored in the challenge, and is nil for social login.
+func issueLoginResult(
+	ctx context.Context, tokenService *Token, twoFactorService *TwoFactor, userID uuid.UUID, identifier *string,
+) (model.LoginResult, error) {
+	twoFactorEnabled, err := twoFactorService.IsEnabled(ctx, userID)
+	if err != nil {
+		return model.LoginResult{}, err
+	}
+	if twoFactorEnabled {
+		challengeToken, err := twoFactorService.CreateLoginChallenge(ctx, userID, identifier)
+		if err != nil {
+			return model.LoginResult{}, err
+		}
+		return model.LoginResult{TwoFactorRequired: true, ChallengeToken: challengeToken}, nil
+	}
+	tokens, err := tokenService.Issue(ctx, userID)
+	if err != nil {
+		return model.LoginResult{}, err
+	}
+	return model.LoginResult{TokenPair: &tokens}, nil
 }
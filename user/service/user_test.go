@@ -0,0 +1,154 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"smapp/user/repository"
+	"smapp/user/service"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/matryer/is"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestLoginLocked(t *testing.T) {
+	is := is.New(t)
+
+	db, mock, err := sqlmock.New()
+	is.NoErr(err)
+	defer db.Close()
+
+	userService := service.NewUser(repository.NewUser(db), repository.NewLoginAttempt(db), nil, nil, nil)
+
+	// The password is not checked while locked, so no user is loaded.
+	mock.ExpectQuery("FROM login_attempts").
+		WithArgs("identifier:someone@example.com", "ip:203.0.113.1").
+		WillReturnRows(sqlmock.NewRows([]string{"seconds"}).AddRow(29))
+	_, err = userService.Login(context.Background(), "Someone@example.com", []byte("password"), "203.0.113.1")
+
+	var lockedErr *service.LoginLockedError
+	is.True(errors.As(err, &lockedErr))
+	is.Equal(lockedErr.RetryAfter, 30*time.Second)
+	is.NoErr(mock.ExpectationsWereMet())
+}
+
+func TestLoginWithoutPassword(t *testing.T) {
+	is := is.New(t)
+
+	db, mock, err := sqlmock.New()
+	is.NoErr(err)
+	defer db.Close()
+
+	userService := service.NewUser(repository.NewUser(db), repository.NewLoginAttempt(db), nil, nil, nil)
+
+	// Users created through social login have no password hash, which counts as a failed login.
+	userID := uuid.New()
+	mock.ExpectQuery("FROM login_attempts").WillReturnRows(sqlmock.NewRows([]string{"seconds"}).AddRow(nil))
+	mock.ExpectQuery("SELECT id, password_hash FROM users").
+		WithArgs("someone").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "password_hash"}).AddRow(userID[:], nil))
+	for _, key := range []string{"identifier:someone", "ip:203.0.113.1"} {
+		mock.ExpectExec("DELETE FROM login_attempts").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT INTO login_attempts").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("SELECT failures").WithArgs(key).WillReturnRows(sqlmock.NewRows([]string{"failures"}).AddRow(1))
+		mock.ExpectCommit()
+	}
+	_, err = userService.Login(context.Background(), "someone", []byte("password"), "203.0.113.1")
+
+	is.True(errors.Is(err, bcrypt.ErrMismatchedHashAndPassword))
+	is.NoErr(mock.ExpectationsWereMet())
+}
+
+func TestLoginWithTwoFactorKeepsFailures(t *testing.T) {
+	is := is.New(t)
+
+	db, mock, err := sqlmock.New()
+	is.NoErr(err)
+	defer db.Close()
+
+	twoFactorService := service.NewTwoFactor(repository.NewTwoFactor(db), nil, nil, nil, nil)
+	userService := service.NewUser(
+		repository.NewUser(db), repository.NewLoginAttempt(db), nil, nil, twoFactorService,
+	)
+
+	// The failures of the identifier are not reset until the second step succeeds, so no DELETE of the counter.
+	userID := uuid.New()
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	is.NoErr(err)
+	mock.ExpectQuery("FROM login_attempts").WillReturnRows(sqlmock.NewRows([]string{"seconds"}).AddRow(nil))
+	mock.ExpectQuery("SELECT id, password_hash FROM users").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "password_hash"}).AddRow(userID[:], passwordHash))
+	mock.ExpectQuery("FROM totp_secrets").WillReturnRows(sqlmock.NewRows([]string{"enabled"}).AddRow(true))
+	mock.ExpectExec("DELETE FROM login_challenges").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO login_challenges").
+		WithArgs(sqlmock.AnyArg(), userID[:], "someone", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	result, err := userService.Login(context.Background(), "someone", []byte("password"), "203.0.113.1")
+
+	is.NoErr(err)
+	is.True(result.TwoFactorRequired)
+	is.NoErr(mock.ExpectationsWereMet())
+}
+
+func TestCompleteLoginWrongCodeCountsAsFailedLogin(t *testing.T) {
+	is := is.New(t)
+
+	db, mock, err := sqlmock.New()
+	is.NoErr(err)
+	defer db.Close()
+
+	twoFactorService := service.NewTwoFactor(
+		repository.NewTwoFactor(db), nil, repository.NewLoginAttempt(db), nil, nil,
+	)
+
+	userID := uuid.New()
+	mock.ExpectQuery("FROM login_challenges").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "identifier"}).AddRow(userID[:], "someone"))
+	mock.ExpectQuery("FROM login_attempts").
+		WithArgs("identifier:someone", "ip:203.0.113.1").
+		WillReturnRows(sqlmock.NewRows([]string{"seconds"}).AddRow(nil))
+	mock.ExpectExec("DELETE FROM recovery_codes").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("UPDATE login_challenges SET failures").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM login_challenges").WillReturnResult(sqlmock.NewResult(0, 0))
+	for _, key := range []string{"identifier:someone", "ip:203.0.113.1"} {
+		mock.ExpectExec("DELETE FROM login_attempts").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT INTO login_attempts").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("SELECT failures").WithArgs(key).WillReturnRows(sqlmock.NewRows([]string{"failures"}).AddRow(1))
+		mock.ExpectCommit()
+	}
+	_, err = twoFactorService.CompleteLogin(context.Background(), "challenge", "abcde-fghij", "203.0.113.1")
+
+	is.True(errors.Is(err, service.ErrInvalidTwoFactorCode))
+	is.NoErr(mock.ExpectationsWereMet())
+}
+
+func TestCompleteLoginLocked(t *testing.T) {
+	is := is.New(t)
+
+	db, mock, err := sqlmock.New()
+	is.NoErr(err)
+	defer db.Close()
+
+	twoFactorService := service.NewTwoFactor(
+		repository.NewTwoFactor(db), nil, repository.NewLoginAttempt(db), nil, nil,
+	)
+
+	// The code is not checked while locked.
+	userID := uuid.New()
+	mock.ExpectQuery("FROM login_challenges").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "identifier"}).AddRow(userID[:], "someone"))
+	mock.ExpectQuery("FROM login_attempts").
+		WithArgs("identifier:someone", "ip:203.0.113.1").
+		WillReturnRows(sqlmock.NewRows([]string{"seconds"}).AddRow(59))
+	_, err = twoFactorService.CompleteLogin(context.Background(), "challenge", "123456", "203.0.113.1")
+
+	var lockedErr *service.LoginLockedError
+	is.True(errors.As(err, &lockedErr))
+	is.Equal(lockedErr.RetryAfter, time.Minute)
+	is.NoErr(mock.ExpectationsWereMet())
+}
@@ -0,0 +1,77 @@
+// Package totp implements time-based one-time passwords (RFC 6238) with the parameters authenticator apps default to:
+// HMAC-SHA1, 6 digits and 30 second steps.
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+const (
+	period      = 30
+	digits      = 6
+	secretBytes = 20
+	// Codes of this many steps before and after the current one are accepted, to allow for clock drift.
+	skew = 1
+)
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+func GenerateSecret() ([]byte, error) {
+	secret := make([]byte, secretBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// The secret in the form users type into authenticator apps.
+func EncodeSecret(secret []byte) string {
+	return encoding.EncodeToString(secret)
+}
+
+// Authenticator apps scan this URI from a QR code.
+func ProvisioningURI(issuer, account string, secret []byte) string {
+	query := url.Values{
+		"secret": {EncodeSecret(secret)},
+		"issuer": {issuer},
+	}
+	return fmt.Sprintf(
+		"otpauth://totp/%s:%s?%s",
+		url.PathEscape(issuer), url.PathEscape(account), query.Encode(),
+	)
+}
+
+func Step(t time.Time) int64 {
+	return t.Unix() / period
+}
+
+func CodeAt(secret []byte, step int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", digits, value%1_000_000)
+}
+
+// Returns the step the code belongs to. Callers should reject steps that have already been used, so that a code
+// cannot be replayed.
+func Match(secret []byte, code string, t time.Time) (int64, bool) {
+	current := Step(t)
+	for step := current - skew; step <= current+skew; step++ {
+		if subtle.ConstantTimeCompare([]byte(CodeAt(secret, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,42 @@
+package totp_test
+
+import (
+	"smapp/user/totp"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+// Test vectors from RFC 6238, truncated to 6 digits.
+func TestCodeAt(t *testing.T) {
+	is := is.New(t)
+
+	secret := []byte("12345678901234567890")
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1234567890, "005924"},
+		{20000000000, "353130"},
+	}
+	for _, test := range tests {
+		is.Equal(totp.CodeAt(secret, totp.Step(time.Unix(test.unix, 0))), test.code)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	is := is.New(t)
+
+	secret := []byte("12345678901234567890")
+	now := time.Unix(1111111109, 0)
+	step := totp.Step(now)
+
+	matched, ok := totp.Match(secret, totp.CodeAt(secret, step-1), now)
+	is.True(ok)
+	is.Equal(matched, step-1)
+	_, ok = totp.Match(secret, totp.CodeAt(secret, step+2), now)
+	is.True(!ok)
+}